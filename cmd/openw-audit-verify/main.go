@@ -0,0 +1,83 @@
+// openw-audit-verify 校验TransmitAuditLog写出的JSON行审计日志。
+//
+// 逐条校验HMAC哈希链，密钥从环境变量OPENW_AUDIT_KEY读取，与写入日志时的key一致。
+// 删除末尾的记录无法从日志本身发现，-head传入另外保存的TransmitAuditLog.Head，
+// 校验日志中对应序号的记录，并确认之后的记录都能接上。
+//
+//	OPENW_AUDIT_KEY=... openw-audit-verify -log audit.jsonl -head 120:9f86d08...
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+)
+
+func main() {
+	var (
+		logFile = flag.String("log", "", "audit log file in JSON lines")
+		head    = flag.String("head", "", "saved head checkpoint, seq:hash")
+	)
+	flag.Parse()
+
+	if len(*logFile) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*logFile, *head); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(logFile, head string) error {
+	key := os.Getenv("OPENW_AUDIT_KEY")
+	if len(key) == 0 {
+		return fmt.Errorf("OPENW_AUDIT_KEY is not set")
+	}
+	f, err := os.Open(logFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := openwsdk.ReadTransmitAuditJSONL(f)
+	if err != nil {
+		return err
+	}
+	if err := openwsdk.VerifyTransmitAudit(entries, []byte(key)); err != nil {
+		return err
+	}
+	if len(head) > 0 {
+		if err := checkHead(entries, head); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("OK: %d entries\n", len(entries))
+	if n := len(entries); n > 0 {
+		fmt.Printf("Head: %d:%s\n", entries[n-1].Seq, entries[n-1].Hash)
+	}
+	return nil
+}
+
+// checkHead 日志必须包含保存的head，哈希链已校验，之后的记录都接在head后面
+func checkHead(entries []*openwsdk.TransmitAuditEntry, head string) error {
+	parts := strings.SplitN(head, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid head %q, expected seq:hash", head)
+	}
+	seq, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || seq == 0 {
+		return fmt.Errorf("invalid head seq %q", parts[0])
+	}
+	if seq > uint64(len(entries)) {
+		return fmt.Errorf("log truncated: head is entry %d, log has %d entries", seq, len(entries))
+	}
+	if entries[seq-1].Hash != parts[1] {
+		return fmt.Errorf("entry %d does not match head", seq)
+	}
+	return nil
+}
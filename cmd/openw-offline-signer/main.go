@@ -0,0 +1,121 @@
+// openw-offline-signer 在离线机器上签名离线签名文件。
+//
+// 联网端通过openwsdk.NewRawTransactionEnvelope导出未签名文件，
+// 离线端核对摘要后签名并写出已签名文件，联网端读取后调用SubmitTrade广播。
+// 展示的摘要由离线端从交易单重新生成，与文件中的摘要不一致时拒绝签名。
+// 密钥密码从环境变量OPENW_KEY_PASSWORD读取，为空时从标准输入提示输入，终端输入时不回显。
+//
+//	openw-offline-signer -key wallet.key -in unsigned.json -out signed.json
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"golang.org/x/term"
+)
+
+func main() {
+	var (
+		keyFile = flag.String("key", "", "hdkeystore key file, password is read from OPENW_KEY_PASSWORD or stdin")
+		in      = flag.String("in", "", "unsigned envelope file")
+		out     = flag.String("out", "", "signed envelope file")
+		yes     = flag.Bool("y", false, "sign without confirmation")
+	)
+	flag.Parse()
+
+	if len(*keyFile) == 0 || len(*in) == 0 || len(*out) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*keyFile, *in, *out, *yes); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(keyFile, in, out string, yes bool) error {
+	envelope, err := openwsdk.ReadEnvelopeFile(in)
+	if err != nil {
+		return err
+	}
+	if envelope.Status == openwsdk.EnvelopeStatusSigned {
+		return fmt.Errorf("%s is already signed", in)
+	}
+
+	fmt.Printf("Type: %s\n", envelope.Type)
+	fmt.Printf("Checksum: %s\n", envelope.Checksum)
+	//ReadEnvelopeFile已确认文件中的摘要与交易单一致，这里展示离线端生成的摘要
+	summary, err := envelope.PayloadSummary()
+	if err != nil {
+		return err
+	}
+	fmt.Print(summary)
+
+	stdin := bufio.NewReader(os.Stdin)
+	if !yes {
+		fmt.Print("Sign this transaction? [y/N]: ")
+		answer, _ := stdin.ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+			return fmt.Errorf("signing cancelled")
+		}
+	}
+
+	password, err := readPassword(stdin)
+	if err != nil {
+		return err
+	}
+	keyjson, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		zero(password)
+		return err
+	}
+	key, err := hdkeystore.DecryptHDKey(keyjson, string(password))
+	zero(password)
+	if err != nil {
+		return err
+	}
+
+	signed, err := openwsdk.SignEnvelope(envelope, key)
+	if err != nil {
+		return err
+	}
+	if err := openwsdk.WriteEnvelopeFile(out, signed); err != nil {
+		return err
+	}
+	fmt.Printf("Signed envelope written to %s\n", out)
+	return nil
+}
+
+// readPassword 读取密钥密码，标准输入是终端时不回显，用完后调用zero清除
+func readPassword(stdin *bufio.Reader) ([]byte, error) {
+	if password := os.Getenv("OPENW_KEY_PASSWORD"); len(password) > 0 {
+		return []byte(password), nil
+	}
+	fmt.Print("Key password: ")
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Println()
+		return password, err
+	}
+	line, err := stdin.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, fmt.Errorf("read password failed: %v", err)
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// zero 清除内存中的密码
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
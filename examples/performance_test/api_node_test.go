@@ -48,6 +48,12 @@ func testNewAPINode() *openwsdk.APINode {
 	}
 
 	api := openwsdk.NewAPINode(config)
+	//并发压测时限制发往openw-server的请求，超出时阻塞等待
+	api.SetRateLimitConfig(&openwsdk.RateLimitConfig{
+		Global:      openwsdk.RateLimit{Rate: 200, Burst: 50},
+		MaxInFlight: 100,
+		Block:       true,
+	})
 	api.BindAppDevice()
 
 	return api
@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"github.com/astaxie/beego/config"
 	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/owtp"
@@ -14,11 +14,8 @@ func init() {
 	owtp.Debug = false
 }
 
-type Subscriber struct {
-}
-
-//OpenwNewTransactionNotify openw新交易单通知
-func (s *Subscriber) OpenwNewTransactionNotify(transaction *openwsdk.Transaction, subscribeToken string) (bool, error) {
+//onTransaction openw新交易单通知
+func onTransaction(ctx context.Context, transaction *openwsdk.Transaction, subscribeToken string) error {
 	log.Infof("OpenwNewTransactionNotify")
 	log.Infof("---------------------------------")
 	log.Infof("subscribeToken: %s", subscribeToken)
@@ -31,11 +28,11 @@ func (s *Subscriber) OpenwNewTransactionNotify(transaction *openwsdk.Transaction
 	log.Infof("accountID: %+v", transaction.AccountID)
 	log.Infof("fees: %+v", transaction.Fees)
 	log.Infof("---------------------------------")
-	return true, nil
+	return nil
 }
 
-//OpenwNewBlockNotify openw新区块头通知
-func (s *Subscriber) OpenwNewBlockNotify(blockHeader *openwsdk.BlockHeader, subscribeToken string) (bool, error) {
+//onBlock openw新区块头通知
+func onBlock(ctx context.Context, blockHeader *openwsdk.BlockHeader, subscribeToken string) error {
 	log.Infof("OpenwNewBlockNotify")
 	log.Infof("---------------------------------")
 	log.Infof("subscribeToken: %s", subscribeToken)
@@ -43,11 +40,11 @@ func (s *Subscriber) OpenwNewBlockNotify(blockHeader *openwsdk.BlockHeader, subs
 	log.Infof("blockHash: %+v", blockHeader.Hash)
 	log.Infof("blockHeight: %+v", blockHeader.Height)
 	log.Infof("---------------------------------")
-	return true, nil
+	return nil
 }
 
-//OpenwBalanceUpdateNotify openw余额更新
-func (s *Subscriber) OpenwBalanceUpdateNotify(balance *openwsdk.Balance, tokenBalance *openwsdk.TokenBalance, subscribeToken string) (bool, error) {
+//onBalanceUpdate openw余额更新
+func onBalanceUpdate(ctx context.Context, balance *openwsdk.Balance, tokenBalance *openwsdk.TokenBalance, subscribeToken string) error {
 	log.Infof("OpenwBalanceUpdateNotify")
 	log.Infof("---------------------------------")
 	log.Infof("subscribeToken: %s", subscribeToken)
@@ -57,11 +54,11 @@ func (s *Subscriber) OpenwBalanceUpdateNotify(balance *openwsdk.Balance, tokenBa
 	log.Infof("Token: %+v", tokenBalance.Token)
 	log.Infof("Balance: %+v", tokenBalance.Balance)
 	log.Infof("---------------------------------")
-	return true, nil
+	return nil
 }
 
-//OpenwNewSmartContractReceiptNotify 智能合约交易回执通知
-func (s *Subscriber) OpenwNewSmartContractReceiptNotify(receipt *openwsdk.SmartContractReceipt, subscribeToken string) (bool, error) {
+//onSmartContractReceipt 智能合约交易回执通知
+func onSmartContractReceipt(ctx context.Context, receipt *openwsdk.SmartContractReceipt, subscribeToken string) error {
 	log.Infof("OpenwNewSmartContractReceiptNotify")
 	log.Infof("---------------------------------")
 	log.Infof("subscribeToken: %s", subscribeToken)
@@ -77,12 +74,7 @@ func (s *Subscriber) OpenwNewSmartContractReceiptNotify(receipt *openwsdk.SmartC
 		log.Std.Notice("events[%d]: %+v", i, event)
 	}
 	log.Infof("---------------------------------")
-	return true, nil
-}
-
-//OpenwNFTTransferNotify NFT合约交易数据通知
-func (s *Subscriber) OpenwNFTTransferNotify(transfer *openwsdk.NFTTransfer, subscribeToken string) (bool, error) {
-	return true, nil
+	return nil
 }
 
 func main() {
@@ -106,33 +98,20 @@ func main() {
 	network := strings.Split(*address, ":")
 	port := network[1]
 
-	c, err := config.NewConfig("ini", *confFile)
+	config, err := openwsdk.LoadAPINodeConfig(*confFile)
 	if err != nil {
-		log.Error("NewConfig error:", err)
+		log.Error("LoadAPINodeConfig error:", err)
 		return
 	}
 
-	AppID := c.String("AppID")
-	AppKey := c.String("AppKey")
-	Host := c.String("Host")
-	PrivateKey := c.String("PrivateKey")
-	EnableKeyAgreement, _ := c.Bool("EnableKeyAgreement")
-	EnableSignature, _ := c.Bool("EnableSignature")
-	EnableSSL, _ := c.Bool("EnableSSL")
-	ConnectType := c.String("ConnectType")
-	//owtp.RandomPrivateKey()
-	cert, _ := owtp.NewCertificate(PrivateKey)
-
-	config := &openwsdk.APINodeConfig{
-		AppID:              AppID,
-		AppKey:             AppKey,
-		Host:               Host,
-		Cert:               cert,
-		ConnectType:        owtp.HTTP,
-		EnableKeyAgreement: EnableKeyAgreement,
-		EnableSSL:          EnableSSL,
-		TimeoutSEC:         60,
-	}
+	//配置的ConnectType用于回调节点，API请求使用HTTP
+	ConnectType := config.ConnectType
+	EnableKeyAgreement := config.EnableKeyAgreement
+	EnableSignature := config.EnableSignature
+	config.ConnectType = owtp.HTTP
+	config.EnableSignature = false
+
+	log.Infof("config:\n%s", config.Summary())
 
 	api, err := openwsdk.NewAPINodeWithError(config)
 	if err != nil {
@@ -171,7 +150,11 @@ func main() {
 		return
 	}
 
-	api.AddObserver(&Subscriber{})
+	api.Use(openwsdk.RecoveryMiddleware(), openwsdk.LoggingMiddleware())
+	api.OnTransaction(onTransaction)
+	api.OnBlock(onBlock)
+	api.OnBalanceUpdate(onBalanceUpdate)
+	api.OnSmartContractReceipt(onSmartContractReceipt)
 
 	<-endRunning
 
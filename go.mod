@@ -1,14 +1,18 @@
 module github.com/blocktree/go-openw-sdk/v2
 
-go 1.12
+go 1.13
 
 require (
+	github.com/asdine/storm v2.1.2+incompatible
 	github.com/astaxie/beego v1.12.0
-	github.com/blocktree/go-owcdrivers v1.2.22 // indirect
+	github.com/blocktree/go-owcdrivers v1.2.22
 	github.com/blocktree/go-owcrypt v1.1.7
 	github.com/blocktree/openwallet/v2 v2.4.3
 	github.com/google/uuid v1.2.0
+	github.com/shopspring/decimal v0.0.0-20200105231215-408a2507e114
 	github.com/tidwall/gjson v1.9.3
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 //replace github.com/blocktree/go-owcdrivers => ../go-owcdrivers
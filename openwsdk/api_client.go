@@ -0,0 +1,775 @@
+package openwsdk
+
+import (
+	"context"
+	"fmt"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// APIClient 基于context的API客户端
+// 包装APINode的回调接口，每个方法接收context.Context，返回类型化结果和error。
+// context取消或超时后，放弃等待owtp请求，迟到的响应会被丢弃。
+type APIClient struct {
+	api *APINode
+}
+
+// NewAPIClient 创建API客户端
+func NewAPIClient(api *APINode) *APIClient {
+	return &APIClient{
+		api: api,
+	}
+}
+
+// APINode 底层的API节点
+func (c *APIClient) APINode() *APINode {
+	if c == nil {
+		return nil
+	}
+	return c.api
+}
+
+// call 在独立协程中以异步方式发起请求，等待回调完成或ctx结束。
+// HTTP连接的invoke会阻塞到收到响应，同样在ctx结束时放弃等待；invoke使用绑定ctx的api，限流排队时ctx结束则不再发出。
+// invoke中的回调必须先写入结果，再调用done；params仅用于生成错误信息
+func (c *APIClient) call(ctx context.Context, method string, params map[string]interface{}, invoke func(api *APINode, done func(status uint64, msg string)) error) error {
+	if c == nil || c.api == nil {
+		return fmt.Errorf("APIClient is not inited")
+	}
+	if ctx.Err() != nil {
+		return contextError(ctx, method, params)
+	}
+	//回调和invoke的错误各写入一次，不会阻塞
+	result := make(chan error, 2)
+	go func() {
+		err := invoke(c.api.withContext(ctx), func(status uint64, msg string) {
+			if status != owtp.StatusSuccess {
+				result <- NewError(method, status, msg, params)
+				return
+			}
+			result <- nil
+		})
+		if err != nil {
+			result <- err
+		}
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return contextError(ctx, method, params)
+	}
+}
+
+// callSync 在独立协程中执行同步方法，ctx结束时放弃等待
+func (c *APIClient) callSync(ctx context.Context, method string, fn func() error) error {
+	if c == nil || c.api == nil {
+		return fmt.Errorf("APIClient is not inited")
+	}
+	if ctx.Err() != nil {
+		return contextError(ctx, method, nil)
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- fn()
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return contextError(ctx, method, nil)
+	}
+}
+
+// contextError ctx超时转换为ErrTimeout类的Error，同时保留context.DeadlineExceeded
+func contextError(ctx context.Context, method string, params map[string]interface{}) error {
+	err := ctx.Err()
+	if err != context.DeadlineExceeded {
+		return err
+	}
+	e := NewError(method, owtp.ErrRequestTimeout, err.Error(), params)
+	e.cause = err
+	return e
+}
+
+// copyParams 复制查询参数，APINode会改写传入的map
+func copyParams(params map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		cp[k] = v
+	}
+	return cp
+}
+
+// BindAppDevice 绑定通信节点
+func (c *APIClient) BindAppDevice(ctx context.Context) error {
+	return c.callSync(ctx, "bindAppDevice", func() error {
+		return c.api.BindAppDevice()
+	})
+}
+
+// GetNotifierNodeInfo 获取通知者节点信息
+func (c *APIClient) GetNotifierNodeInfo(ctx context.Context) (string, string, error) {
+	var pubKey, nodeID string
+	err := c.callSync(ctx, "getNodeInfo", func() error {
+		p, n, err := c.api.GetNotifierNodeInfo()
+		pubKey, nodeID = p, n
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return pubKey, nodeID, nil
+}
+
+// Subscribe 订阅
+func (c *APIClient) Subscribe(ctx context.Context, subscribeMethod []string, listenAddr string, callbackMode int, callbackNode CallbackNode, subscribeToken string) error {
+	return c.callSync(ctx, "subscribe", func() error {
+		return c.api.Subscribe(subscribeMethod, listenAddr, callbackMode, callbackNode, subscribeToken)
+	})
+}
+
+// GetSymbolList 获取主链列表
+func (c *APIClient) GetSymbolList(ctx context.Context, symbol string, offset, limit, hasRole int) ([]*Symbol, error) {
+	var result []*Symbol
+	err := c.call(ctx, "getSymbolBlockList", map[string]interface{}{"symbol": symbol, "offset": offset, "limit": limit, "hasRole": hasRole}, func(api *APINode, done func(uint64, string)) error {
+		return api.GetSymbolList(symbol, offset, limit, hasRole, false, func(status uint64, msg string, total int, symbols []*Symbol) {
+			result = symbols
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateWallet 创建钱包
+func (c *APIClient) CreateWallet(ctx context.Context, wallet *Wallet) (*Wallet, error) {
+	var result *Wallet
+	err := c.call(ctx, "createWallet", map[string]interface{}{"wallet": wallet}, func(api *APINode, done func(uint64, string)) error {
+		return api.CreateWallet(wallet, false, func(status uint64, msg string, w *Wallet) {
+			result = w
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindWalletByWalletID 通过钱包ID获取钱包信息
+func (c *APIClient) FindWalletByWalletID(ctx context.Context, walletID string) (*Wallet, error) {
+	var result *Wallet
+	err := c.call(ctx, "findWalletByWalletID", map[string]interface{}{"walletID": walletID}, func(api *APINode, done func(uint64, string)) error {
+		return api.FindWalletByWalletID(walletID, false, func(status uint64, msg string, w *Wallet) {
+			result = w
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateNormalAccount 创建资产账户
+func (c *APIClient) CreateNormalAccount(ctx context.Context, accountParam *Account) (*Account, []*Address, error) {
+	var (
+		account   *Account
+		addresses []*Address
+	)
+	err := c.call(ctx, "createAccount", map[string]interface{}{"account": accountParam}, func(api *APINode, done func(uint64, string)) error {
+		return api.CreateNormalAccount(accountParam, false, func(status uint64, msg string, a *Account, addrs []*Address) {
+			account, addresses = a, addrs
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return account, addresses, nil
+}
+
+// CreateImportAccount 创建导入资产账户
+func (c *APIClient) CreateImportAccount(ctx context.Context, accountParam *Account) (*Account, []*Address, error) {
+	var (
+		account   *Account
+		addresses []*Address
+	)
+	err := c.call(ctx, "createAccount", map[string]interface{}{"account": accountParam}, func(api *APINode, done func(uint64, string)) error {
+		return api.CreateImportAccount(accountParam, false, func(status uint64, msg string, a *Account, addrs []*Address) {
+			account, addresses = a, addrs
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return account, addresses, nil
+}
+
+// FindAccountByAccountID 通过资产账户ID获取资产账户信息
+func (c *APIClient) FindAccountByAccountID(ctx context.Context, symbol, accountID string, refresh int) (*Account, error) {
+	var result *Account
+	err := c.call(ctx, "findAccountByAccountID", map[string]interface{}{"symbol": symbol, "accountID": accountID, "refresh": refresh}, func(api *APINode, done func(uint64, string)) error {
+		return api.FindAccountByAccountID(symbol, accountID, refresh, false, func(status uint64, msg string, a *Account) {
+			result = a
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindAccountByWalletID 通过钱包ID获取资产账户列表信息
+func (c *APIClient) FindAccountByWalletID(ctx context.Context, symbol, walletID string, lastID, limit int64) ([]*Account, error) {
+	var result []*Account
+	err := c.call(ctx, "findAccountByWalletID", map[string]interface{}{"symbol": symbol, "walletID": walletID, "lastID": lastID, "limit": limit}, func(api *APINode, done func(uint64, string)) error {
+		return api.FindAccountByWalletID(symbol, walletID, lastID, limit, false, func(status uint64, msg string, accounts []*Account) {
+			result = accounts
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateAddress 创建资产账户的地址
+func (c *APIClient) CreateAddress(ctx context.Context, symbol, walletID, accountID string, count uint64) ([]*Address, error) {
+	var result []*Address
+	err := c.call(ctx, "createAddress", map[string]interface{}{"symbol": symbol, "walletID": walletID, "accountID": accountID, "count": count}, func(api *APINode, done func(uint64, string)) error {
+		return api.CreateAddress(symbol, walletID, accountID, count, false, func(status uint64, msg string, addresses []*Address) {
+			result = addresses
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ImportAddress 导入地址
+func (c *APIClient) ImportAddress(ctx context.Context, symbol, walletID, accountID string, address []string) ([]*Address, error) {
+	var result []*Address
+	err := c.call(ctx, "importAddress", map[string]interface{}{"symbol": symbol, "walletID": walletID, "accountID": accountID, "address": address}, func(api *APINode, done func(uint64, string)) error {
+		return api.ImportAddress(symbol, walletID, accountID, address, false, func(status uint64, msg string, addresses []*Address) {
+			result = addresses
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateBatchAddress 批量创建资产账户的地址
+func (c *APIClient) CreateBatchAddress(ctx context.Context, walletID, accountID string, count uint64) ([]string, error) {
+	var result []string
+	err := c.call(ctx, "createBatchAddress", map[string]interface{}{"walletID": walletID, "accountID": accountID, "count": count}, func(api *APINode, done func(uint64, string)) error {
+		return api.CreateBatchAddress(walletID, accountID, count, false, func(status uint64, msg string, addresses []string) {
+			result = addresses
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindAddressByAddress 通获取具体交易地址信息
+func (c *APIClient) FindAddressByAddress(ctx context.Context, symbol, address string) (*Address, error) {
+	var result *Address
+	err := c.call(ctx, "findAddressByAddress", map[string]interface{}{"symbol": symbol, "address": address}, func(api *APINode, done func(uint64, string)) error {
+		return api.FindAddressByAddress(symbol, address, false, func(status uint64, msg string, a *Address) {
+			result = a
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindAddressByAccountID 通过资产账户ID获取交易地址列表
+func (c *APIClient) FindAddressByAccountID(ctx context.Context, symbol, accountID string, lastID, limit int64) ([]*Address, error) {
+	var result []*Address
+	err := c.call(ctx, "findAddressByAccountID", map[string]interface{}{"symbol": symbol, "accountID": accountID, "lastID": lastID, "limit": limit}, func(api *APINode, done func(uint64, string)) error {
+		return api.FindAddressByAccountID(symbol, accountID, lastID, limit, false, func(status uint64, msg string, addresses []*Address) {
+			result = addresses
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateTrade 创建转账交易订单
+func (c *APIClient) CreateTrade(ctx context.Context, accountID, sid string, coin Coin, to map[string]string, feeRate, memo, extParam string) (*RawTransaction, error) {
+	var result *RawTransaction
+	err := c.call(ctx, "createTrade", map[string]interface{}{"accountID": accountID, "sid": sid, "coin": coin, "to": to, "feeRate": feeRate, "memo": memo, "extParam": extParam}, func(api *APINode, done func(uint64, string)) error {
+		return api.CreateTrade(accountID, sid, coin, to, feeRate, memo, extParam, false, func(status uint64, msg string, rawTx *RawTransaction) {
+			result = rawTx
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateBatchTrade 创建批量转账交易订单
+func (c *APIClient) CreateBatchTrade(ctx context.Context, accountID, sid string, coin Coin, to map[string]string, feeRate, memo, extParam string) (*RawTransaction, error) {
+	var result *RawTransaction
+	err := c.call(ctx, "createBatchTrade", map[string]interface{}{"accountID": accountID, "sid": sid, "coin": coin, "address": to, "feeRate": feeRate, "memo": memo, "extParam": extParam}, func(api *APINode, done func(uint64, string)) error {
+		return api.CreateBatchTrade(accountID, sid, coin, to, feeRate, memo, extParam, false, func(status uint64, msg string, rawTx *RawTransaction) {
+			result = rawTx
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SubmitTrade 广播转账交易订单
+func (c *APIClient) SubmitTrade(ctx context.Context, rawTx []*RawTransaction) ([]*Transaction, []*FailedRawTransaction, error) {
+	var (
+		successTx    []*Transaction
+		failedRawTxs []*FailedRawTransaction
+	)
+	err := c.call(ctx, "submitTrade", map[string]interface{}{"rawTx": rawTx}, func(api *APINode, done func(uint64, string)) error {
+		return api.SubmitTrade(rawTx, false, func(status uint64, msg string, txs []*Transaction, failed []*FailedRawTransaction) {
+			successTx, failedRawTxs = txs, failed
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return successTx, failedRawTxs, nil
+}
+
+// FindTradeLogByParams 根据条件获取转账交易订单日志
+func (c *APIClient) FindTradeLogByParams(ctx context.Context, params map[string]interface{}) ([]*Transaction, error) {
+	var result []*Transaction
+	err := c.call(ctx, "findTradeLog", params, func(api *APINode, done func(uint64, string)) error {
+		return api.FindTradeLogByParams(copyParams(params), false, func(status uint64, msg string, txs []*Transaction) {
+			result = txs
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindTradeLog 获取转账交易订单日志
+func (c *APIClient) FindTradeLog(
+	ctx context.Context,
+	walletID string,
+	accountID string,
+	symbol string,
+	txid string,
+	address string,
+	isTmp int,
+	orderType int,
+	startHeight int64,
+	endHeight int64,
+	height int64,
+	isDesc bool,
+	offset int,
+	limit int,
+) ([]*Transaction, error) {
+	var result []*Transaction
+	err := c.call(ctx, "findTradeLog", map[string]interface{}{"walletID": walletID, "accountID": accountID, "symbol": symbol, "txid": txid, "address": address, "offset": offset, "limit": limit}, func(api *APINode, done func(uint64, string)) error {
+		return api.FindTradeLog(walletID, accountID, symbol, txid, address, isTmp, orderType,
+			startHeight, endHeight, height, isDesc, offset, limit, false,
+			func(status uint64, msg string, txs []*Transaction) {
+				result = txs
+				done(status, msg)
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetContracts 获取智能合约
+func (c *APIClient) GetContracts(ctx context.Context, symbol, contractID string, lastID, limit int) ([]*TokenContract, error) {
+	var result []*TokenContract
+	err := c.call(ctx, "getContracts", map[string]interface{}{"symbol": symbol, "contractID": contractID, "lastID": lastID, "limit": limit}, func(api *APINode, done func(uint64, string)) error {
+		return api.GetContracts(symbol, contractID, lastID, limit, false, func(status uint64, msg string, tokens []*TokenContract) {
+			result = tokens
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetBalanceByAccount 获取accountID主币/合约余额
+func (c *APIClient) GetBalanceByAccount(ctx context.Context, symbol, accountID, contractID string) (*BalanceResult, error) {
+	var result *BalanceResult
+	err := c.call(ctx, "getBalanceByAccount", map[string]interface{}{"symbol": symbol, "accountID": accountID, "contractID": contractID}, func(api *APINode, done func(uint64, string)) error {
+		return api.GetBalanceByAccount(symbol, accountID, contractID, false, func(status uint64, msg string, balance *BalanceResult) {
+			result = balance
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetBalanceByAddress 获取address主币/合约余额
+func (c *APIClient) GetBalanceByAddress(ctx context.Context, symbol, address, contractID string) (*BalanceResult, error) {
+	var result *BalanceResult
+	err := c.call(ctx, "getBalanceByAddress", map[string]interface{}{"symbol": symbol, "address": address, "contractID": contractID}, func(api *APINode, done func(uint64, string)) error {
+		return api.GetBalanceByAddress(symbol, address, contractID, false, func(status uint64, msg string, balance *BalanceResult) {
+			result = balance
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAllTokenBalanceByAccount 获取账户所有token余额接口
+func (c *APIClient) GetAllTokenBalanceByAccount(ctx context.Context, walletID, accountID, symbol string) ([]*BalanceResult, error) {
+	var result []*BalanceResult
+	err := c.call(ctx, "getAccountBalanceList", map[string]interface{}{"walletID": walletID, "accountID": accountID, "symbol": symbol}, func(api *APINode, done func(uint64, string)) error {
+		return api.GetAllTokenBalanceByAccount(walletID, accountID, symbol, false, func(status uint64, msg string, balance []*BalanceResult) {
+			result = balance
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAllTokenBalanceByAddress 获取地址的token余额接口
+func (c *APIClient) GetAllTokenBalanceByAddress(ctx context.Context, walletID, accountID, address, symbol string) ([]*BalanceResult, error) {
+	var result []*BalanceResult
+	err := c.call(ctx, "getAddressBalanceList", map[string]interface{}{"walletID": walletID, "accountID": accountID, "address": address, "symbol": symbol}, func(api *APINode, done func(uint64, string)) error {
+		return api.GetAllTokenBalanceByAddress(walletID, accountID, address, symbol, false, func(status uint64, msg string, balance []*BalanceResult) {
+			result = balance
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetFeeRate 获取推荐手续费率接口
+func (c *APIClient) GetFeeRate(ctx context.Context, symbol string) (*SupportFeeRate, error) {
+	var result *SupportFeeRate
+	err := c.call(ctx, "getFeeRate", map[string]interface{}{"symbol": symbol}, func(api *APINode, done func(uint64, string)) error {
+		return api.GetFeeRate(symbol, false, func(status uint64, msg string, symbol, feeRate, unit string) {
+			result = &SupportFeeRate{
+				FeeRate: feeRate,
+				Symbol:  symbol,
+				Unit:    unit,
+			}
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetFeeRateList 获取所有主链的推荐手续费率
+func (c *APIClient) GetFeeRateList(ctx context.Context) ([]SupportFeeRate, error) {
+	var result []SupportFeeRate
+	err := c.call(ctx, "getFeeRateList", nil, func(api *APINode, done func(uint64, string)) error {
+		return api.GetFeeRateList(false, func(status uint64, msg string, feeRates []SupportFeeRate) {
+			result = feeRates
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateSummaryTx 创建汇总交易单
+func (c *APIClient) CreateSummaryTx(
+	ctx context.Context,
+	accountID string,
+	sumAddress string,
+	coin Coin,
+	feeRate string,
+	minTransfer string,
+	retainedBalance string,
+	addressStartIndex int,
+	addressLimit int,
+	confirms uint64,
+	sid string,
+	feesSupportAccount *FeesSupportAccount,
+	memo string,
+) ([]*RawTransaction, error) {
+	var result []*RawTransaction
+	err := c.call(ctx, "createSummaryTx", map[string]interface{}{"accountID": accountID, "address": sumAddress, "coin": coin, "sid": sid}, func(api *APINode, done func(uint64, string)) error {
+		return api.CreateSummaryTx(accountID, sumAddress, coin, feeRate, minTransfer, retainedBalance,
+			addressStartIndex, addressLimit, confirms, sid, feesSupportAccount, memo, false,
+			func(status uint64, msg string, rawTxs []*RawTransaction) {
+				result = rawTxs
+				done(status, msg)
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetSymbolBlockList 获取币种最大高度
+func (c *APIClient) GetSymbolBlockList(ctx context.Context, symbol string) ([]*BlockHeader, error) {
+	var result []*BlockHeader
+	err := c.call(ctx, "getSymbolBlockList", map[string]interface{}{"symbol": symbol}, func(api *APINode, done func(uint64, string)) error {
+		return api.GetSymbolBlockList(symbol, false, func(status uint64, msg string, blockHeaders []*BlockHeader) {
+			result = blockHeaders
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ImportAccount 导入第三方资产账户
+func (c *APIClient) ImportAccount(ctx context.Context, accountParam *Account) (*Account, []*Address, error) {
+	var (
+		account   *Account
+		addresses []*Address
+	)
+	err := c.call(ctx, "importAccount", map[string]interface{}{"account": accountParam}, func(api *APINode, done func(uint64, string)) error {
+		return api.ImportAccount(accountParam, false, func(status uint64, msg string, a *Account, addrs []*Address) {
+			account, addresses = a, addrs
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return account, addresses, nil
+}
+
+// ImportBatchAddress 批量导入地址
+func (c *APIClient) ImportBatchAddress(ctx context.Context, walletID, accountID, memo string, addressAndPubs map[string]string, updateBalance bool) ([]string, error) {
+	var result []string
+	err := c.call(ctx, "importBatchAddress", map[string]interface{}{"walletID": walletID, "accountID": accountID, "memo": memo}, func(api *APINode, done func(uint64, string)) error {
+		return api.ImportBatchAddress(walletID, accountID, memo, addressAndPubs, updateBalance, false, func(status uint64, msg string, importAddresses []string) {
+			result = importAddresses
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindWalletByParams 查询钱包列表
+func (c *APIClient) FindWalletByParams(ctx context.Context, params map[string]interface{}, offset, limit int) ([]*Wallet, error) {
+	var result []*Wallet
+	err := c.call(ctx, "findWalletByParams", params, func(api *APINode, done func(uint64, string)) error {
+		return api.FindWalletByParams(copyParams(params), offset, limit, false, func(status uint64, msg string, wallets []*Wallet) {
+			result = wallets
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindAccountByParams 根据条件查询账户列表
+func (c *APIClient) FindAccountByParams(ctx context.Context, params map[string]interface{}, offset, limit int) ([]*Account, error) {
+	var result []*Account
+	err := c.call(ctx, "findAccountByParams", params, func(api *APINode, done func(uint64, string)) error {
+		return api.FindAccountByParams(copyParams(params), offset, limit, false, func(status uint64, msg string, accounts []*Account) {
+			result = accounts
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindAddressByParams 通过条件查询地址列表
+func (c *APIClient) FindAddressByParams(ctx context.Context, params map[string]interface{}, offset, limit int) ([]*Address, error) {
+	var result []*Address
+	err := c.call(ctx, "findAddressByParams", params, func(api *APINode, done func(uint64, string)) error {
+		return api.FindAddressByParams(copyParams(params), offset, limit, false, func(status uint64, msg string, addresses []*Address) {
+			result = addresses
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// VerifyAddress 地址校验
+func (c *APIClient) VerifyAddress(ctx context.Context, symbol, address string) (bool, error) {
+	var result bool
+	err := c.call(ctx, "verifyAddress", map[string]interface{}{"symbol": symbol, "address": address}, func(api *APINode, done func(uint64, string)) error {
+		return api.VerifyAddress(symbol, address, false, func(status uint64, msg string, flag bool) {
+			result = flag
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return result, nil
+}
+
+// CallSmartContractABI 调用智能合约ABI方法
+func (c *APIClient) CallSmartContractABI(ctx context.Context, accountID string, coin Coin, abiParam []string, raw string, rawType uint64) (*SmartContractCallResult, error) {
+	var result *SmartContractCallResult
+	err := c.call(ctx, "callSmartContractABI", map[string]interface{}{"accountID": accountID, "coin": coin, "abiParam": abiParam}, func(api *APINode, done func(uint64, string)) error {
+		return api.CallSmartContractABI(accountID, coin, abiParam, raw, rawType, false, func(status uint64, msg string, callResult *SmartContractCallResult) {
+			result = callResult
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateSmartContractTrade 创建智能合约交易单
+func (c *APIClient) CreateSmartContractTrade(
+	ctx context.Context,
+	sid string,
+	accountID string,
+	coin Coin,
+	abiParam []string,
+	raw string,
+	rawType uint64,
+	feeRate string,
+	value string,
+) (*SmartContractRawTransaction, error) {
+	var result *SmartContractRawTransaction
+	err := c.call(ctx, "createSmartContractTrade", map[string]interface{}{"sid": sid, "accountID": accountID, "coin": coin, "abiParam": abiParam}, func(api *APINode, done func(uint64, string)) error {
+		return api.CreateSmartContractTrade(sid, accountID, coin, abiParam, raw, rawType, feeRate, value, false,
+			func(status uint64, msg string, rawTx *SmartContractRawTransaction) {
+				result = rawTx
+				done(status, msg)
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SubmitSmartContractTrade 广播智能合约交易单
+func (c *APIClient) SubmitSmartContractTrade(ctx context.Context, rawTx []*SmartContractRawTransaction) ([]*SmartContractReceipt, []*FailureSmartContractLog, error) {
+	var (
+		successTx    []*SmartContractReceipt
+		failedRawTxs []*FailureSmartContractLog
+	)
+	err := c.call(ctx, "submitSmartContractTrade", map[string]interface{}{"rawTx": rawTx}, func(api *APINode, done func(uint64, string)) error {
+		return api.SubmitSmartContractTrade(rawTx, false, func(status uint64, msg string, txs []*SmartContractReceipt, failed []*FailureSmartContractLog) {
+			successTx, failedRawTxs = txs, failed
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return successTx, failedRawTxs, nil
+}
+
+// FindSmartContractReceiptByParams 获取智能合约交易回执
+func (c *APIClient) FindSmartContractReceiptByParams(ctx context.Context, params map[string]interface{}) ([]*SmartContractReceipt, error) {
+	var result []*SmartContractReceipt
+	err := c.call(ctx, "findSmartContractReceipt", params, func(api *APINode, done func(uint64, string)) error {
+		return api.FindSmartContractReceiptByParams(copyParams(params), false, func(status uint64, msg string, receipts []*SmartContractReceipt) {
+			result = receipts
+			done(status, msg)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FollowSmartContractReceipt 订阅要关注智能合约回执通知
+func (c *APIClient) FollowSmartContractReceipt(ctx context.Context, followContracts []string) error {
+	return c.call(ctx, "followSmartContractReceipt", map[string]interface{}{"followContracts": followContracts}, func(api *APINode, done func(uint64, string)) error {
+		return api.FollowSmartContractReceipt(followContracts, false, func(status uint64, msg string) {
+			done(status, msg)
+		})
+	})
+}
+
+// GetAccountBalanceList 获取账户余额列表
+// opType 0: 所有，1：主币，2：代币
+func (c *APIClient) GetAccountBalanceList(ctx context.Context, walletID, accountID, symbol, contractID string, opType int, lastID, limit int) ([]*BalanceResult, error) {
+	var result []*BalanceResult
+	err := c.call(ctx, "getAccountBalanceList", map[string]interface{}{"walletID": walletID, "accountID": accountID, "symbol": symbol, "contractID": contractID, "type": opType, "lastID": lastID, "limit": limit}, func(api *APINode, done func(uint64, string)) error {
+		return api.GetAccountBalanceList(walletID, accountID, symbol, contractID, opType, lastID, limit, false,
+			func(status uint64, msg string, balances []*BalanceResult) {
+				result = balances
+				done(status, msg)
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAddressBalanceList 获取地址余额列表
+// opType 0: 所有，1：主币，2：代币
+func (c *APIClient) GetAddressBalanceList(ctx context.Context, walletID, accountID, address, symbol, contractID string, opType int, lastID, limit int) ([]*BalanceResult, error) {
+	var result []*BalanceResult
+	err := c.call(ctx, "getAddressBalanceList", map[string]interface{}{"walletID": walletID, "accountID": accountID, "address": address, "symbol": symbol, "contractID": contractID, "type": opType, "lastID": lastID, "limit": limit}, func(api *APINode, done func(uint64, string)) error {
+		return api.GetAddressBalanceList(walletID, accountID, address, symbol, contractID, opType, lastID, limit, false,
+			func(status uint64, msg string, balances []*BalanceResult) {
+				result = balances
+				done(status, msg)
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,119 @@
+package openwsdk
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// testNewLocalServer 启动本地owtp节点模拟openw-server
+func testNewLocalServer(t *testing.T, connectType string) (*owtp.OWTPNode, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen unexpected error: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	node := owtp.NewNode(owtp.NodeConfig{
+		Cert:       owtp.NewRandomCertificate(),
+		TimeoutSEC: 10,
+	})
+	err = node.Listen(owtp.ConnectConfig{
+		Address:     addr,
+		ConnectType: connectType,
+	})
+	if err != nil {
+		t.Fatalf("Listen unexpected error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	return node, addr
+}
+
+func testNewLocalClient(t *testing.T, addr, connectType string) *APIClient {
+	api, err := NewAPINodeWithError(&APINodeConfig{
+		Host:        addr,
+		AppID:       "testapp",
+		AppKey:      "testkey",
+		ConnectType: connectType,
+		Cert:        owtp.NewRandomCertificate(),
+		TimeoutSEC:  10,
+	})
+	if err != nil {
+		t.Fatalf("NewAPINodeWithError unexpected error: %v", err)
+	}
+	return NewAPIClient(api)
+}
+
+func TestAPIClient_FindWalletByWalletID(t *testing.T) {
+	server, addr := testNewLocalServer(t, owtp.Websocket)
+	defer server.Close()
+	server.HandleFunc("findWalletByWalletID", func(ctx *owtp.Context) {
+		ctx.Response(map[string]interface{}{
+			"walletID": ctx.Params().Get("walletID").String(),
+			"alias":    "hello",
+		}, owtp.StatusSuccess, "success")
+	})
+
+	client := testNewLocalClient(t, addr, owtp.Websocket)
+	wallet, err := client.FindWalletByWalletID(context.Background(), "W123")
+	if err != nil {
+		t.Fatalf("FindWalletByWalletID unexpected error: %v", err)
+	}
+	if wallet.WalletID != "W123" || wallet.Alias != "hello" {
+		t.Errorf("unexpected wallet: %+v", wallet)
+	}
+}
+
+func TestAPIClient_StatusError(t *testing.T) {
+	server, addr := testNewLocalServer(t, owtp.HTTP)
+	defer server.Close()
+	server.HandleFunc("getBalanceByAccount", func(ctx *owtp.Context) {
+		ctx.Response(nil, 3001, "account not found")
+	})
+
+	client := testNewLocalClient(t, addr, owtp.HTTP)
+	balance, err := client.GetBalanceByAccount(context.Background(), "BTC", "A1", "")
+	if err == nil {
+		t.Fatalf("GetBalanceByAccount expected error, got balance: %+v", balance)
+	}
+	if balance != nil {
+		t.Errorf("unexpected balance: %+v", balance)
+	}
+	if !errors.Is(err, ErrUnknownAccount) {
+		t.Errorf("GetBalanceByAccount expected ErrUnknownAccount, got: %v", err)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Method != "getBalanceByAccount" || e.Params["accountID"] != "A1" {
+		t.Errorf("unexpected error detail: %+v", e)
+	}
+}
+
+func TestAPIClient_ContextCancel(t *testing.T) {
+	//HTTP连接的请求阻塞到收到响应，两种连接都要在ctx结束时返回
+	for _, connectType := range []string{owtp.Websocket, owtp.HTTP} {
+		server, addr := testNewLocalServer(t, connectType)
+		server.HandleFunc("getFeeRate", func(ctx *owtp.Context) {
+			time.Sleep(2 * time.Second)
+			ctx.Response(nil, owtp.StatusSuccess, "success")
+		})
+
+		client := testNewLocalClient(t, addr, connectType)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+
+		start := time.Now()
+		_, err := client.GetFeeRate(ctx, "BTC")
+		if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrTimeout) {
+			t.Fatalf("%s: GetFeeRate expected timeout error, got: %v", connectType, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: GetFeeRate did not return at deadline, elapsed: %v", connectType, elapsed)
+		}
+		cancel()
+		server.Close()
+	}
+}
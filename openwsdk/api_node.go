@@ -1,6 +1,7 @@
 package openwsdk
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -28,30 +29,59 @@ const (
 )
 
 type APINodeConfig struct {
-	Host               string           `json:"host"`
-	AppID              string           `json:"appid"`
-	AppKey             string           `json:"appkey"`
-	ConnectType        string           `json:"connectType"`
-	EnableKeyAgreement bool             `json:"enableKeyAgreement"`
-	EnableSSL          bool             `json:"enableSSL"`
-	EnableSignature    bool             `json:"enableSignature"`
-	Cert               owtp.Certificate `json:"cert"`
-	TimeoutSEC         int              `json:"timeoutSEC"`
+	Host               string             `json:"host"`
+	AppID              string             `json:"appid"`
+	AppKey             string             `json:"appkey"`
+	ConnectType        string             `json:"connectType"`
+	EnableKeyAgreement bool               `json:"enableKeyAgreement"`
+	EnableSSL          bool               `json:"enableSSL"`
+	EnableSignature    bool               `json:"enableSignature"`
+	Cert               owtp.Certificate   `json:"cert"`
+	TimeoutSEC         int                `json:"timeoutSEC"`
+	KeyGraceSEC        int                `json:"keyGraceSEC"` //密钥轮换后旧密钥的宽限期，默认600秒
+	Credentials        CredentialProvider `json:"-"`           //凭证提供者，为空时使用AppID和AppKey
 }
 
 // APINode APINode通信节点
 type APINode struct {
-	mu            sync.RWMutex //读写锁
-	node          *owtp.OWTPNode
-	config        *APINodeConfig
-	observers     map[OpenwNotificationObject]bool //观察者
-	transmitNode  *TransmitNode                    //钱包转发节点
-	proxyNode     *ProxyNode                       //代理服务节点，用于转发请求到openw-server接口
-	subscribeInfo *CallbackNode                    `json:"subscribeInfo"`
+	mu             sync.RWMutex //读写锁
+	node           *owtp.OWTPNode
+	config         *APINodeConfig
+	observers      map[OpenwNotificationObject]*HandlerRegistration //观察者
+	handlers       []*registeredHandler                             //通知处理函数，按注册顺序调用
+	handlerSeq     uint64                                           //处理函数注册序号
+	middlewares    []NotificationMiddleware                         //通知处理中间件
+	dispatchConfig *DispatchConfig                                  //通知分发配置
+	dispatchSem    chan struct{}                                    //限制同时执行的处理函数数量
+	transmitNode   *TransmitNode                                    //钱包转发节点
+	proxyNode      *ProxyNode                                       //代理服务节点，用于转发请求到openw-server接口
+	subscribeInfo  *CallbackNode                                    `json:"subscribeInfo"`
+	subscribeArgs  *subscribeArgs                                   //最近一次订阅的参数，重连后重放
+	reconnector    *reconnector                                     //断线重连监管者
+	inbox          *NotificationInbox                               //通知收件箱
+	inboxStop      chan struct{}                                    //关闭收件箱重试投递
+	creds          *credentialStore                                 //各应用的凭证和请求签名
+	appID          string                                           //WithAppID指定的应用，为空使用默认应用
+	parent         *APINode                                         //WithAppID的原节点
+	limiter        *rateLimiter                                     //客户端限流，WithAppID的节点使用原节点的限流
+	ctx            context.Context                                  //withContext绑定的context，限流排队时结束则放弃
 }
 
-// NewAPINodeWithError 创建API节点
+// NewAPINodeWithError 创建API节点，未设置的ConnectType和TimeoutSEC使用默认值，host等无法连接的配置返回错误。
+// 节点使用配置的副本，创建后修改config不会生效。
 func NewAPINodeWithError(config *APINodeConfig) (*APINode, error) {
+	if config == nil {
+		return nil, fmt.Errorf("APINodeConfig is nil")
+	}
+	enableDebugLogRedaction()
+	config = config.withDefaults()
+	problems, warnings := config.validate()
+	if err := configProblems(problems); err != nil {
+		return nil, err
+	}
+	for _, w := range warnings {
+		log.Warningf("APINodeConfig: %s", w)
+	}
 	connectCfg := owtp.ConnectConfig{}
 	connectCfg.Address = config.Host
 	connectCfg.ConnectType = config.ConnectType
@@ -62,18 +92,26 @@ func NewAPINodeWithError(config *APINodeConfig) (*APINode, error) {
 		Cert:       config.Cert,
 		TimeoutSEC: config.TimeoutSEC,
 	})
-	_, err := node.Connect(HostNodeID, connectCfg)
+	creds, err := newCredentialStore(config)
+	if err != nil {
+		return nil, err
+	}
+	_, err = node.Connect(HostNodeID, connectCfg)
 	if err != nil {
 		return nil, err
 	}
 	api := APINode{
 		node:   node,
 		config: config,
+		creds:  creds,
 	}
-	api.observers = make(map[OpenwNotificationObject]bool)
+	api.observers = make(map[OpenwNotificationObject]*HandlerRegistration)
+	api.reconnector = newReconnector(&api)
 
 	// 设置重新加载配置回调
 	node.SetReloadPeerInfoHandler(api.getConnectCfg)
+	// 断开连接后按需自动重连
+	node.SetCloseHandler(api.reconnector.onClose)
 
 	api.node.HandleFunc("checkNodeIsOnline", api.checkNodeIsOnline)
 	api.node.HandleFunc("subscribeToAccount", api.subscribeToAccount)
@@ -89,6 +127,7 @@ func NewAPINodeWithError(config *APINodeConfig) (*APINode, error) {
 func NewAPINode(config *APINodeConfig) *APINode {
 	api, err := NewAPINodeWithError(config)
 	if err != nil {
+		log.Errorf("NewAPINode failed: %v", err)
 		return nil
 	}
 	return api
@@ -102,6 +141,17 @@ func (api *APINode) OWTPNode() *owtp.OWTPNode {
 	return api.node
 }
 
+// Close 关闭自动重连，断开所有连接
+func (api *APINode) Close() {
+	if api == nil || api.parent != nil {
+		return
+	}
+	api.WatchCredentials(0)
+	api.DisableAutoReconnect()
+	api.SetNotificationInbox(nil)
+	api.node.Close()
+}
+
 // NodeID
 func (api *APINode) NodeID() string {
 	if api == nil {
@@ -117,6 +167,26 @@ func (api *APINode) Subscribe(subscribeMethod []string, listenAddr string, callb
 		return fmt.Errorf("APINode is not inited")
 	}
 
+	return api.subscribe(&subscribeArgs{
+		subscribeMethod: subscribeMethod,
+		listenAddr:      listenAddr,
+		callbackMode:    callbackMode,
+		callbackNode:    callbackNode,
+		subscribeToken:  subscribeToken,
+	}, false)
+}
+
+// subscribe 订阅，replay为true表示重连后重放订阅，已开启的回调监听不再报错
+func (api *APINode) subscribe(args *subscribeArgs, replay bool) error {
+
+	var (
+		subscribeMethod = args.subscribeMethod
+		listenAddr      = args.listenAddr
+		callbackMode    = args.callbackMode
+		callbackNode    = args.callbackNode
+		subscribeToken  = args.subscribeToken
+	)
+
 	//获取通知节点的NodeID
 	_, notifierNodeID, err := api.GetNotifierNodeInfo()
 	if err != nil {
@@ -128,12 +198,14 @@ func (api *APINode) Subscribe(subscribeMethod []string, listenAddr string, callb
 		if callbackNode.ConnectType != owtp.Websocket {
 			return fmt.Errorf("%s can not use [SubscribeModeCurrentConnection]", callbackNode.ConnectType)
 		}
-	} else {
+	} else if api.node.Listening(callbackNode.ConnectType) {
 
-		if api.node.Listening(callbackNode.ConnectType) {
+		if !replay {
 			return fmt.Errorf("subscribe connenct type [%s] is listening", callbackNode.ConnectType)
 		}
 
+	} else {
+
 		//开启监听
 		log.Infof("%s start to listen [%s] connection...", listenAddr, callbackNode.ConnectType)
 		api.node.Listen(owtp.ConnectConfig{
@@ -146,14 +218,14 @@ func (api *APINode) Subscribe(subscribeMethod []string, listenAddr string, callb
 
 	params := map[string]interface{}{
 		//"subscriptions": subscriptions,
-		"appID":           api.config.AppID,
+		"appID":           api.AppID(),
 		"subscribeMethod": subscribeMethod,
 		"callbackMode":    callbackMode,
 		"callbackNode":    callbackNode,
 		"subscribeToken":  subscribeToken,
 	}
 
-	response, err := api.node.CallSync(HostNodeID, "subscribe", params)
+	response, err := api.callSync("subscribe", params)
 	if err != nil {
 		return err
 	}
@@ -163,12 +235,18 @@ func (api *APINode) Subscribe(subscribeMethod []string, listenAddr string, callb
 		api.subscribeInfo = &callbackNode
 		api.subscribeInfo.notifierNodeID = notifierNodeID
 
+		api.mu.Lock()
+		api.subscribeArgs = args
+		api.mu.Unlock()
+
 		return nil
 	} else {
 		//关闭临时开启的端口
-		log.Infof("%s close listener [%s] connection...", listenAddr, callbackNode.ConnectType)
-		api.node.CloseListener(callbackNode.ConnectType)
-		return fmt.Errorf("[%d]%s", response.Status, response.Msg)
+		if !replay {
+			log.Infof("%s close listener [%s] connection...", listenAddr, callbackNode.ConnectType)
+			api.node.CloseListener(callbackNode.ConnectType)
+		}
+		return NewError("subscribe", response.Status, response.Msg, params)
 	}
 
 	return nil
@@ -197,13 +275,30 @@ func HmacSHA256(data, key []byte) string {
 	return hex.EncodeToString(hmac.Sum([]byte(nil)))
 }
 
+// RandNonce 随机nonce，不保证唯一。
+//
+// Deprecated: 使用SecureNonce获取错误，签名请求应使用RequestSigner。
+// 系统随机数不可用时记录错误并退回到基于时间的伪随机数。
 func RandNonce() string {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	n := r.Int63n(99999999)
-	if n < 10000000 {
-		n += 10000000
+	nonce, err := SecureNonce()
+	if err != nil {
+		log.Errorf("RandNonce: %v, fall back to math/rand", err)
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		return strconv.FormatInt(100000000000000000+r.Int63n(900000000000000000), 10)
 	}
-	return strconv.FormatInt(n, 10)
+	return nonce
+}
+
+// RequestSigner 当前应用密钥的请求签名者，可设置时钟和校正服务端时间
+func (api *APINode) RequestSigner() *RequestSigner {
+	if api == nil {
+		return nil
+	}
+	signers, err := api.creds.signers(api.AppID())
+	if err != nil {
+		return nil
+	}
+	return signers[0]
 }
 
 // signAppDevice 生成登记节点的签名
@@ -223,27 +318,24 @@ func (api *APINode) BindAppDevice() error {
 	}
 
 	nodeID := api.config.Cert.ID()
-	accessTime := time.Now().UnixNano()
-	nonce := RandNonce()
-	sig := api.signAppDevice(api.config.AppID, nodeID, nonce, api.config.AppKey, accessTime)
-
-	params := map[string]interface{}{
-		"appID":      api.config.AppID,
-		"deviceID":   nodeID,
-		"nonce":      nonce,
-		"accessTime": accessTime,
-		"sign":       sig,
-	}
-
-	response, err := api.node.CallSync(HostNodeID, "bindAppDevice", params)
+	response, params, err := api.callSigned("bindAppDevice", func(req *SignedRequest) map[string]interface{} {
+		return map[string]interface{}{
+			"appID":      req.AppID,
+			"deviceID":   nodeID,
+			"nonce":      req.Nonce,
+			"accessTime": req.Time,
+			"sign":       req.Sign,
+		}
+	}, nodeID)
 	if err != nil {
 		return err
 	}
 
 	if response.Status == owtp.StatusSuccess {
+		api.creds.setBound(api.AppID(), true)
 		return nil
 	} else {
-		return fmt.Errorf("[%d]%s", response.Status, response.Msg)
+		return NewError("bindAppDevice", response.Status, response.Msg, params)
 	}
 
 	return nil
@@ -257,14 +349,14 @@ func (api *APINode) GetSymbolList(symbol string, offset, limit, hasRole int, syn
 	}
 
 	params := map[string]interface{}{
-		"appID":   api.config.AppID,
+		"appID":   api.AppID(),
 		"symbol":  symbol,
 		"offset":  offset,
 		"limit":   limit,
 		"hasRole": hasRole,
 	}
 
-	return api.node.Call(HostNodeID, "getSymbolBlockList", params, sync, func(resp owtp.Response) {
+	return api.call("getSymbolBlockList", params, sync, func(resp owtp.Response) {
 		var result []*Symbol
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), &result); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -281,7 +373,7 @@ func (api *APINode) CreateWallet(wallet *Wallet, sync bool, reqFunc func(status
 	}
 
 	params := map[string]interface{}{
-		"appID":    api.config.AppID,
+		"appID":    api.AppID(),
 		"alias":    wallet.Alias,
 		"walletID": wallet.WalletID,
 		"rootPath": hdkeystore.OpenwCoinTypePath,
@@ -295,7 +387,7 @@ func (api *APINode) CreateWallet(wallet *Wallet, sync bool, reqFunc func(status
 		params["authKey"] = wallet.AuthKey
 	}
 
-	return api.node.Call(HostNodeID, "createWallet", params, sync, func(resp owtp.Response) {
+	return api.call("createWallet", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var wallet Wallet
 		json.Unmarshal([]byte(data.Raw), &wallet)
@@ -309,10 +401,10 @@ func (api *APINode) FindWalletByWalletID(walletID string, sync bool, reqFunc fun
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":    api.config.AppID,
+		"appID":    api.AppID(),
 		"walletID": walletID,
 	}
-	return api.node.Call(HostNodeID, "findWalletByWalletID", params, sync, func(resp owtp.Response) {
+	return api.call("findWalletByWalletID", params, sync, func(resp owtp.Response) {
 		var wallet Wallet
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), &wallet); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -330,7 +422,7 @@ func (api *APINode) CreateNormalAccount(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":        api.config.AppID,
+		"appID":        api.AppID(),
 		"alias":        accountParam.Alias,
 		"walletID":     accountParam.WalletID,
 		"accountID":    accountParam.AccountID,
@@ -342,7 +434,7 @@ func (api *APINode) CreateNormalAccount(
 		"isTrust":      0,
 		"remark":       accountParam.Symbol,
 	}
-	return api.node.Call(HostNodeID, "createAccount", params, sync, func(resp owtp.Response) {
+	return api.call("createAccount", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var account Account
 		if err := json.Unmarshal([]byte(data.Get("account").Raw), &account); err != nil {
@@ -365,7 +457,7 @@ func (api *APINode) CreateImportAccount(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":        api.config.AppID,
+		"appID":        api.AppID(),
 		"alias":        accountParam.Alias,
 		"walletID":     accountParam.WalletID,
 		"accountID":    accountParam.AccountID,
@@ -378,7 +470,7 @@ func (api *APINode) CreateImportAccount(
 		"remark":       accountParam.Symbol,
 		"onlyAccount":  1,
 	}
-	return api.node.Call(HostNodeID, "createAccount", params, sync, func(resp owtp.Response) {
+	return api.call("createAccount", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var account Account
 		if err := json.Unmarshal([]byte(data.Get("account").Raw), &account); err != nil {
@@ -398,12 +490,12 @@ func (api *APINode) FindAccountByAccountID(symbol, accountID string, refresh int
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":     api.config.AppID,
+		"appID":     api.AppID(),
 		"symbol":    symbol,
 		"accountID": accountID,
 		"refresh":   refresh,
 	}
-	return api.node.Call(HostNodeID, "findAccountByAccountID", params, sync, func(resp owtp.Response) {
+	return api.call("findAccountByAccountID", params, sync, func(resp owtp.Response) {
 		var account Account
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), &account); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -418,13 +510,13 @@ func (api *APINode) FindAccountByWalletID(symbol, walletID string, lastID, limit
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":    api.config.AppID,
+		"appID":    api.AppID(),
 		"walletID": walletID,
 		"symbol":   symbol,
 		"lastID":   lastID,
 		"limit":    limit,
 	}
-	return api.node.Call(HostNodeID, "findAccountByWalletID", params, sync, func(resp owtp.Response) {
+	return api.call("findAccountByWalletID", params, sync, func(resp owtp.Response) {
 		var accounts []*Account
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), &accounts); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -445,13 +537,13 @@ func (api *APINode) CreateAddress(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":     api.config.AppID,
+		"appID":     api.AppID(),
 		"symbol":    symbol,
 		"walletID":  walletID,
 		"accountID": accountID,
 		"count":     count,
 	}
-	return api.node.Call(HostNodeID, "createAddress", params, sync, func(resp owtp.Response) {
+	return api.call("createAddress", params, sync, func(resp owtp.Response) {
 		var addresses []*Address
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), &addresses); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -472,13 +564,13 @@ func (api *APINode) ImportAddress(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":     api.config.AppID,
+		"appID":     api.AppID(),
 		"symbol":    symbol,
 		"walletID":  walletID,
 		"accountID": accountID,
 		"address":   address,
 	}
-	return api.node.Call(HostNodeID, "importAddress", params, sync, func(resp owtp.Response) {
+	return api.call("importAddress", params, sync, func(resp owtp.Response) {
 		var addresses []*Address
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), &addresses); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -498,12 +590,12 @@ func (api *APINode) CreateBatchAddress(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":     api.config.AppID,
+		"appID":     api.AppID(),
 		"walletID":  walletID,
 		"accountID": accountID,
 		"count":     count,
 	}
-	return api.node.Call(HostNodeID, "createBatchAddress", params, sync, func(resp owtp.Response) {
+	return api.call("createBatchAddress", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var addresses []string
 		addressArray := data
@@ -522,11 +614,11 @@ func (api *APINode) FindAddressByAddress(symbol, address string, sync bool, reqF
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":   api.config.AppID,
+		"appID":   api.AppID(),
 		"symbol":  symbol,
 		"address": address,
 	}
-	return api.node.Call(HostNodeID, "findAddressByAddress", params, sync, func(resp owtp.Response) {
+	return api.call("findAddressByAddress", params, sync, func(resp owtp.Response) {
 		var address Address
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), &address); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -541,13 +633,13 @@ func (api *APINode) FindAddressByAccountID(symbol, accountID string, lastID, lim
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":     api.config.AppID,
+		"appID":     api.AppID(),
 		"symbol":    symbol,
 		"accountID": accountID,
 		"lastID":    lastID,
 		"limit":     limit,
 	}
-	return api.node.Call(HostNodeID, "findAddressByAccountID", params, sync, func(resp owtp.Response) {
+	return api.call("findAddressByAccountID", params, sync, func(resp owtp.Response) {
 		var addresses []*Address
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), &addresses); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -572,7 +664,7 @@ func (api *APINode) CreateTrade(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":     api.config.AppID,
+		"appID":     api.AppID(),
 		"accountID": accountID,
 		"sid":       sid,
 		"coin":      coin,
@@ -581,7 +673,7 @@ func (api *APINode) CreateTrade(
 		"memo":      memo,
 		"extParam":  extParam,
 	}
-	return api.node.Call(HostNodeID, "createTrade", params, sync, func(resp owtp.Response) {
+	return api.call("createTrade", params, sync, func(resp owtp.Response) {
 		if resp.Status != owtp.StatusSuccess {
 			reqFunc(resp.Status, resp.Msg, nil)
 			return
@@ -614,7 +706,7 @@ func (api *APINode) CreateBatchTrade(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":     api.config.AppID,
+		"appID":     api.AppID(),
 		"accountID": accountID,
 		"sid":       sid,
 		"coin":      coin,
@@ -624,7 +716,7 @@ func (api *APINode) CreateBatchTrade(
 		"extParam":  extParam,
 	}
 
-	return api.node.Call(HostNodeID, "createBatchTrade", params, sync, func(resp owtp.Response) {
+	return api.call("createBatchTrade", params, sync, func(resp owtp.Response) {
 
 		if resp.Status != owtp.StatusSuccess {
 			reqFunc(resp.Status, resp.Msg, nil)
@@ -655,10 +747,10 @@ func (api *APINode) SubmitTrade(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID": api.config.AppID,
+		"appID": api.AppID(),
 		"rawTx": rawTx,
 	}
-	return api.node.Call(HostNodeID, "submitTrade", params, sync, func(resp owtp.Response) {
+	return api.call("submitTrade", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var failedRawTxs []*FailedRawTransaction
 		if err := json.Unmarshal([]byte(data.Get("failure").Raw), &failedRawTxs); err != nil {
@@ -678,8 +770,8 @@ func (api *APINode) FindTradeLogByParams(
 	sync bool,
 	reqFunc func(status uint64, msg string, tx []*Transaction),
 ) error {
-	params["appID"] = api.config.AppID
-	return api.node.Call(HostNodeID, "findTradeLog", params, sync, func(resp owtp.Response) {
+	params["appID"] = api.AppID()
+	return api.call("findTradeLog", params, sync, func(resp owtp.Response) {
 		var txs []*Transaction
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), &txs); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -715,7 +807,7 @@ func (api *APINode) FindTradeLog(
 		sortby = 1
 	}
 	params := map[string]interface{}{
-		"appID":        api.config.AppID,
+		"appID":        api.AppID(),
 		"walletID":     walletID,
 		"accountID":    accountID,
 		"symbol":       symbol,
@@ -730,7 +822,7 @@ func (api *APINode) FindTradeLog(
 		"limit":        limit,
 	}
 
-	return api.node.Call(HostNodeID, "findTradeLog", params, sync, func(resp owtp.Response) {
+	return api.call("findTradeLog", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 
 		var txs []*Transaction
@@ -759,13 +851,13 @@ func (api *APINode) GetContracts(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":      api.config.AppID,
+		"appID":      api.AppID(),
 		"symbol":     symbol,
 		"contractID": contractID,
 		"lastID":     lastID,
 		"limit":      limit,
 	}
-	return api.node.Call(HostNodeID, "getContracts", params, sync, func(resp owtp.Response) {
+	return api.call("getContracts", params, sync, func(resp owtp.Response) {
 		var tokens []*TokenContract
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), &tokens); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -785,12 +877,12 @@ func (api *APINode) GetBalanceByAccount(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":      api.config.AppID,
+		"appID":      api.AppID(),
 		"symbol":     symbol,
 		"accountID":  accountID,
 		"contractID": contractID, // 查询合约余额不能为空,如主币余额为空
 	}
-	return api.node.Call(HostNodeID, "getBalanceByAccount", params, sync, func(resp owtp.Response) {
+	return api.call("getBalanceByAccount", params, sync, func(resp owtp.Response) {
 		balance := &BalanceResult{}
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), balance); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -810,12 +902,12 @@ func (api *APINode) GetBalanceByAddress(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":      api.config.AppID,
+		"appID":      api.AppID(),
 		"symbol":     symbol,
 		"address":    address,
 		"contractID": contractID, // 查询合约余额不能为空,如主币余额为空
 	}
-	return api.node.Call(HostNodeID, "getBalanceByAddress", params, sync, func(resp owtp.Response) {
+	return api.call("getBalanceByAddress", params, sync, func(resp owtp.Response) {
 		balance := &BalanceResult{}
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), balance); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -835,14 +927,14 @@ func (api *APINode) GetAllTokenBalanceByAccount(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":     api.config.AppID,
+		"appID":     api.AppID(),
 		"walletID":  walletID,
 		"accountID": accountID,
 		"symbol":    symbol,
 		"type":      2, //0: all, 1: native coin, 2: token coin
 	}
 
-	return api.node.Call(HostNodeID, "getAccountBalanceList", params, sync, func(resp owtp.Response) {
+	return api.call("getAccountBalanceList", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var balance []*BalanceResult
 		json.Unmarshal([]byte(data.Raw), &balance)
@@ -863,7 +955,7 @@ func (api *APINode) GetAllTokenBalanceByAddress(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":     api.config.AppID,
+		"appID":     api.AppID(),
 		"walletID":  walletID,
 		"accountID": accountID,
 		"address":   address,
@@ -871,7 +963,7 @@ func (api *APINode) GetAllTokenBalanceByAddress(
 		"type":      2, //0: all, 1: native coin, 2: token coin
 	}
 
-	return api.node.Call(HostNodeID, "getAddressBalanceList", params, sync, func(resp owtp.Response) {
+	return api.call("getAddressBalanceList", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var balance []*BalanceResult
 		json.Unmarshal([]byte(data.Raw), &balance)
@@ -889,11 +981,11 @@ func (api *APINode) GetFeeRate(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":  api.config.AppID,
+		"appID":  api.AppID(),
 		"symbol": symbol,
 	}
 
-	return api.node.Call(HostNodeID, "getFeeRate", params, sync, func(resp owtp.Response) {
+	return api.call("getFeeRate", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		symbol := data.Get("symbol").String()
 		feeRate := data.Get("feeRate").String()
@@ -910,10 +1002,10 @@ func (api *APINode) GetFeeRateList(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":  api.config.AppID,
+		"appID":  api.AppID(),
 		"symbol": "",
 	}
-	return api.node.Call(HostNodeID, "getFeeRateList", params, sync, func(resp owtp.Response) {
+	return api.call("getFeeRateList", params, sync, func(resp owtp.Response) {
 		out := make([]SupportFeeRate, 0)
 		data := resp.JsonData()
 		if data.IsArray() {
@@ -949,7 +1041,7 @@ func (api *APINode) CreateSummaryTx(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":              api.config.AppID,
+		"appID":              api.AppID(),
 		"accountID":          accountID,
 		"address":            sumAddress,
 		"coin":               coin,
@@ -963,7 +1055,7 @@ func (api *APINode) CreateSummaryTx(
 		"feesSupportAccount": feesSupportAccount,
 		"memo":               memo,
 	}
-	return api.node.Call(HostNodeID, "createSummaryTx", params, sync, func(resp owtp.Response) {
+	return api.call("createSummaryTx", params, sync, func(resp owtp.Response) {
 		rawTxs := make([]*RawTransaction, 0)
 		if err := json.Unmarshal([]byte(resp.JsonData().Raw), &rawTxs); err != nil {
 			log.Error("json unmarshal failed: ", err)
@@ -979,11 +1071,16 @@ func (api *APINode) ServeTransmitNode(address string) error {
 		return fmt.Errorf("transmit node is inited")
 	}
 
+	creds, err := api.Credentials()
+	if err != nil {
+		return err
+	}
+
 	transmitNode, err := NewTransmitNode(&APINodeConfig{
 		Host:               address,
 		ConnectType:        owtp.Websocket,
-		AppID:              api.config.AppID,
-		AppKey:             api.config.AppKey,
+		AppID:              creds.AppID,
+		AppKey:             creds.AppKey,
 		Cert:               api.config.Cert,
 		EnableSignature:    api.config.EnableSignature,
 		EnableKeyAgreement: api.config.EnableKeyAgreement,
@@ -1028,11 +1125,11 @@ func (api *APINode) GetSymbolBlockList(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":  api.config.AppID,
+		"appID":  api.AppID(),
 		"symbol": symbol,
 	}
 
-	return api.node.Call(HostNodeID, "getSymbolBlockList", params, sync, func(resp owtp.Response) {
+	return api.call("getSymbolBlockList", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		headers := make([]*BlockHeader, 0)
 		if data.IsArray() {
@@ -1054,7 +1151,7 @@ func (api *APINode) ImportAccount(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":        api.config.AppID,
+		"appID":        api.AppID(),
 		"alias":        accountParam.Alias,
 		"walletID":     accountParam.WalletID,
 		"accountID":    accountParam.AccountID,
@@ -1064,7 +1161,7 @@ func (api *APINode) ImportAccount(
 		"hdPath":       accountParam.HdPath,
 	}
 
-	return api.node.Call(HostNodeID, "importAccount", params, sync, func(resp owtp.Response) {
+	return api.call("importAccount", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var account Account
 		json.Unmarshal([]byte(data.Get("account").Raw), &account)
@@ -1103,7 +1200,7 @@ func (api *APINode) ImportBatchAddress(
 		publickeys = append(publickeys, pub)
 	}
 
-	appID := api.config.AppID
+	appID := api.AppID()
 	params := map[string]interface{}{
 		"appID":         appID,
 		"walletID":      walletID,
@@ -1113,7 +1210,7 @@ func (api *APINode) ImportBatchAddress(
 		"publicKeys":    publickeys,
 		"updateBalance": common.NewString(updateBalance).Int64(),
 	}
-	return api.node.Call(HostNodeID, "importBatchAddress", params, sync, func(resp owtp.Response) {
+	return api.call("importBatchAddress", params, sync, func(resp owtp.Response) {
 
 		data := resp.JsonData()
 
@@ -1135,44 +1232,31 @@ func (api *APINode) GetNotifierNodeInfo() (string, string, error) {
 		return "", "", fmt.Errorf("APINode is not inited")
 	}
 
-	var (
-		pubKey     string
-		nodeId     string
-		requestErr *openwallet.Error
-	)
-	appID := api.config.AppID
-	time := time.Now().UnixNano()
-	nonce := RandNonce()
-	plainText := fmt.Sprintf("%s%s%d", appID, nonce, time)
-	sign := HmacSHA256([]byte(plainText), []byte(api.config.AppKey))
-
-	params := map[string]interface{}{
-		"appID": appID,
-		"nonce": nonce,
-		"time":  time,
-		"sign":  sign,
-	}
-
-	err := api.node.Call(HostNodeID, "getNodeInfo", params, true, func(resp owtp.Response) {
-
-		if resp.Status != owtp.StatusSuccess {
-			requestErr = openwallet.Errorf(resp.Status, resp.Msg)
-			return
+	resp, params, err := api.callSigned("getNodeInfo", func(req *SignedRequest) map[string]interface{} {
+		return map[string]interface{}{
+			"appID": req.AppID,
+			"nonce": req.Nonce,
+			"time":  req.Time,
+			"sign":  req.Sign,
 		}
-
-		data := resp.JsonData()
-		pubKey = data.Get("pubKey").String()
-		nodeId = data.Get("nodeID").String()
 	})
 	if err != nil {
 		return "", "", err
 	}
 
-	if requestErr != nil {
-		return "", "", requestErr
+	if resp.Status != owtp.StatusSuccess {
+		return "", "", NewError("getNodeInfo", resp.Status, resp.Msg, params)
+	}
+
+	data := resp.JsonData()
+	//服务端返回时间时，校正之后的请求时间戳
+	if serverTime := data.Get("time"); serverTime.Exists() {
+		if err := api.observeServerTime(time.Unix(0, serverTime.Int())); err != nil {
+			log.Warningf("getNodeInfo: %v", err)
+		}
 	}
 
-	return pubKey, nodeId, nil
+	return data.Get("pubKey").String(), data.Get("nodeID").String(), nil
 }
 
 // FindWalletByParams 查询钱包列表
@@ -1187,10 +1271,10 @@ func (api *APINode) FindWalletByParams(
 		params = make(map[string]interface{})
 	}
 
-	params["appID"] = api.config.AppID
+	params["appID"] = api.AppID()
 	params["offset"] = offset
 	params["limit"] = limit
-	return api.node.Call(HostNodeID, "findWalletByParams", params, sync, func(resp owtp.Response) {
+	return api.call("findWalletByParams", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var wallets []*Wallet
 		array := data
@@ -1220,10 +1304,10 @@ func (api *APINode) FindAccountByParams(
 		params = make(map[string]interface{})
 	}
 
-	params["appID"] = api.config.AppID
+	params["appID"] = api.AppID()
 	params["offset"] = offset
 	params["limit"] = limit
-	return api.node.Call(HostNodeID, "findAccountByParams", params, sync, func(resp owtp.Response) {
+	return api.call("findAccountByParams", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var accounts []*Account
 		array := data
@@ -1252,10 +1336,10 @@ func (api *APINode) FindAddressByParams(
 		params = make(map[string]interface{})
 	}
 
-	params["appID"] = api.config.AppID
+	params["appID"] = api.AppID()
 	params["offset"] = offset
 	params["limit"] = limit
-	return api.node.Call(HostNodeID, "findAddressByParams", params, sync, func(resp owtp.Response) {
+	return api.call("findAddressByParams", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var addresses []*Address
 		array := data
@@ -1278,10 +1362,10 @@ func (api *APINode) VerifyAddress(symbol, address string, sync bool,
 ) error {
 	params := make(map[string]interface{})
 
-	params["appID"] = api.config.AppID
+	params["appID"] = api.AppID()
 	params["symbol"] = symbol
 	params["address"] = address
-	return api.node.Call(HostNodeID, "verifyAddress", params, sync, func(resp owtp.Response) {
+	return api.call("verifyAddress", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		reqFunc(resp.Status, resp.Msg, data.Bool())
 	})
@@ -1298,13 +1382,13 @@ func (api *APINode) CallSmartContractABI(
 ) error {
 	params := make(map[string]interface{})
 
-	params["appID"] = api.config.AppID
+	params["appID"] = api.AppID()
 	params["accountID"] = accountID
 	params["coin"] = coin
 	params["abiParam"] = abiParam
 	params["raw"] = raw
 	params["rawType"] = rawType
-	return api.node.Call(HostNodeID, "callSmartContractABI", params, sync, func(resp owtp.Response) {
+	return api.call("callSmartContractABI", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var callResult SmartContractCallResult
 		err := json.Unmarshal([]byte(data.Raw), &callResult)
@@ -1340,7 +1424,7 @@ func (api *APINode) CreateSmartContractTrade(
 ) error {
 	params := make(map[string]interface{})
 
-	params["appID"] = api.config.AppID
+	params["appID"] = api.AppID()
 	params["sid"] = sid
 	params["accountID"] = accountID
 	params["coin"] = coin
@@ -1349,7 +1433,7 @@ func (api *APINode) CreateSmartContractTrade(
 	params["rawType"] = rawType
 	params["feeRate"] = feeRate
 	params["value"] = value
-	return api.node.Call(HostNodeID, "createSmartContractTrade", params, sync, func(resp owtp.Response) {
+	return api.call("createSmartContractTrade", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var rawTx SmartContractRawTransaction
 		err := json.Unmarshal([]byte(data.Raw), &rawTx)
@@ -1371,11 +1455,11 @@ func (api *APINode) SubmitSmartContractTrade(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID": api.config.AppID,
+		"appID": api.AppID(),
 		"rawTx": rawTx,
 	}
 
-	return api.node.Call(HostNodeID, "submitSmartContractTrade", params, sync, func(resp owtp.Response) {
+	return api.call("submitSmartContractTrade", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		failedRawTxs := make([]*FailureSmartContractLog, 0)
 		failedArray := data.Get("failure")
@@ -1420,8 +1504,8 @@ func (api *APINode) FindSmartContractReceiptByParams(
 	if api == nil {
 		return fmt.Errorf("APINode is not inited")
 	}
-	params["appID"] = api.config.AppID
-	return api.node.Call(HostNodeID, "findSmartContractReceipt", params, sync, func(resp owtp.Response) {
+	params["appID"] = api.AppID()
+	return api.call("findSmartContractReceipt", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var receipts []*SmartContractReceipt
 		array := data
@@ -1449,10 +1533,10 @@ func (api *APINode) FollowSmartContractReceipt(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":           api.config.AppID,
+		"appID":           api.AppID(),
 		"followContracts": followContracts,
 	}
-	return api.node.Call(HostNodeID, "followSmartContractReceipt", params, sync, func(resp owtp.Response) {
+	return api.call("followSmartContractReceipt", params, sync, func(resp owtp.Response) {
 		reqFunc(resp.Status, resp.Msg)
 	})
 
@@ -1471,7 +1555,7 @@ func (api *APINode) GetAccountBalanceList(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":      api.config.AppID,
+		"appID":      api.AppID(),
 		"walletID":   walletID,
 		"accountID":  accountID,
 		"symbol":     symbol,
@@ -1480,7 +1564,7 @@ func (api *APINode) GetAccountBalanceList(
 		"lastID":     lastID,
 		"limit":      limit,
 	}
-	return api.node.Call(HostNodeID, "getAccountBalanceList", params, sync,
+	return api.call("getAccountBalanceList", params, sync,
 		func(resp owtp.Response) {
 			data := resp.JsonData()
 			var balances []*BalanceResult
@@ -1512,7 +1596,7 @@ func (api *APINode) GetAddressBalanceList(
 		return fmt.Errorf("APINode is not inited")
 	}
 	params := map[string]interface{}{
-		"appID":      api.config.AppID,
+		"appID":      api.AppID(),
 		"walletID":   walletID,
 		"accountID":  accountID,
 		"address":    address,
@@ -1522,7 +1606,7 @@ func (api *APINode) GetAddressBalanceList(
 		"lastID":     lastID,
 		"limit":      limit,
 	}
-	return api.node.Call(HostNodeID, "getAddressBalanceList", params, sync,
+	return api.call("getAddressBalanceList", params, sync,
 		func(resp owtp.Response) {
 			data := resp.JsonData()
 			var balances []*BalanceResult
@@ -1,8 +1,12 @@
+//go:build live
+// +build live
+
+// 需要conf/test.ini配置的openw-server和托管节点，使用 go test -tags live 运行
+
 package openwsdk
 
 import (
 	"fmt"
-	"github.com/astaxie/beego/config"
 	"github.com/blocktree/go-owcrypt"
 	"github.com/blocktree/openwallet/v2/common/file"
 	"github.com/blocktree/openwallet/v2/hdkeystore"
@@ -24,31 +28,12 @@ func testNewAPINode() *APINode {
 
 	//confFile := filepath.Join("conf", "node.ini")
 	confFile := filepath.Join("conf", "test.ini")
-	c, err := config.NewConfig("ini", confFile)
+	config, err := LoadAPINodeConfig(confFile)
 	if err != nil {
-		log.Error("NewConfig error:", err)
+		log.Error("LoadAPINodeConfig error:", err)
 		return nil
 	}
-
-	PrivateKey := c.String("PrivateKey")
-	AppID := c.String("AppID")
-	AppKey := c.String("AppKey")
-	Host := c.String("Host")
-	EnableKeyAgreement, _ := c.Bool("EnableKeyAgreement")
-	EnableSSL, _ := c.Bool("EnableSSL")
-
-	cert, _ := owtp.NewCertificate(PrivateKey)
-
-	config := &APINodeConfig{
-		AppID:              AppID,
-		AppKey:             AppKey,
-		Host:               Host,
-		Cert:               cert,
-		ConnectType:        owtp.HTTP,
-		EnableKeyAgreement: EnableKeyAgreement,
-		EnableSSL:          EnableSSL,
-		TimeoutSEC:         120,
-	}
+	config.TimeoutSEC = 120
 
 	api, err := NewAPINodeWithError(config)
 	if err != nil {
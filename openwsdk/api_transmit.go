@@ -20,6 +20,9 @@ type TransmitNode struct {
 	disconnectHandler func(transmitNode *TransmitNode, nodeID string)           //托管节点断开连接后的通知
 	connectHandler    func(transmitNode *TransmitNode, nodeInfo *TrustNodeInfo) //托管节点连接成功的通知
 	parent            *APINode
+	registry          *TrustNodeRegistry //托管节点注册表
+	secrets           SecretProvider     //钱包密码的提供者
+	audit             *TransmitAuditLog  //命令审计日志
 }
 
 func NewTransmitNode(config *APINodeConfig) (*TransmitNode, error) {
@@ -27,6 +30,7 @@ func NewTransmitNode(config *APINodeConfig) (*TransmitNode, error) {
 	if config.ConnectType != owtp.Websocket {
 		return nil, fmt.Errorf("Transmit node only support websocket ")
 	}
+	enableDebugLogRedaction()
 
 	connectCfg := owtp.ConnectConfig{}
 	connectCfg.Address = config.Host
@@ -42,10 +46,12 @@ func NewTransmitNode(config *APINodeConfig) (*TransmitNode, error) {
 		node:   node,
 		config: config,
 	}
+	t.registry = newTrustNodeRegistry(t)
 
 	node.HandleFunc("newNodeJoin", t.newNodeJoin)
 
 	node.SetCloseHandler(func(n *owtp.OWTPNode, peer owtp.PeerInfo) {
+		t.registry.leave(peer.ID)
 		if t.disconnectHandler != nil {
 			t.disconnectHandler(t, peer.ID)
 		}
@@ -84,6 +90,7 @@ func (transmit *TransmitNode) Listen() {
 
 // Close 关闭监听
 func (transmit *TransmitNode) Close() {
+	transmit.SetHealthCheckConfig(nil)
 	transmit.node.Close()
 }
 
@@ -98,13 +105,20 @@ func (transmit *TransmitNode) SetDisconnectHandler(h func(transmitNode *Transmit
 }
 
 func (transmit *TransmitNode) newNodeJoin(ctx *owtp.Context) {
-	if transmit.connectHandler != nil {
-		var nodeInfo TrustNodeInfo
-		err := json.Unmarshal([]byte(ctx.Params().Get("nodeInfo").Raw), &nodeInfo)
-		if err != nil {
-			ctx.Response(nil, openwallet.ErrUnknownException, err.Error())
-			return
+	var nodeInfo TrustNodeInfo
+	err := json.Unmarshal([]byte(ctx.Params().Get("nodeInfo").Raw), &nodeInfo)
+	if err != nil {
+		ctx.Response(nil, openwallet.ErrUnknownException, err.Error())
+		return
+	}
+	transmit.registry.join(ctx.PID, &nodeInfo)
+	//节点等待newNodeJoin响应，在响应后同步钱包列表
+	go func(nodeID string) {
+		if err := transmit.registry.RefreshWallets(nodeID); err != nil {
+			log.Warningf("trust node %s: refresh wallets failed: %v", nodeID, err)
 		}
+	}(ctx.PID)
+	if transmit.connectHandler != nil {
 		transmit.connectHandler(transmit, &nodeInfo)
 	}
 
@@ -114,25 +128,35 @@ func (transmit *TransmitNode) newNodeJoin(ctx *owtp.Context) {
 // GetTrustNodeInfo 获取授信的托管节点信息
 func (transmit *TransmitNode) GetTrustNodeInfo(nodeID string,
 	sync bool, reqFunc func(status uint64, msg string, nodeInfo *TrustNodeInfo)) error {
+	return transmit.getTrustNodeInfo(nodeID, sync, true, reqFunc)
+}
+
+// getTrustNodeInfo 获取托管节点信息，audit为false时不写审计日志，用于健康检查
+func (transmit *TransmitNode) getTrustNodeInfo(nodeID string, sync, audit bool,
+	reqFunc func(status uint64, msg string, nodeInfo *TrustNodeInfo)) error {
 
 	if transmit == nil {
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
 		"appID": transmit.config.AppID,
 	}
 
-	return transmit.node.Call(nodeID, "getTrustNodeInfo", params, sync, func(resp owtp.Response) {
+	h := func(resp owtp.Response) {
 		data := resp.JsonData()
 		var nodeInfo TrustNodeInfo
 		json.Unmarshal([]byte(data.Raw), &nodeInfo)
 		reqFunc(resp.Status, resp.Msg, &nodeInfo)
-	})
+	}
+	if !audit {
+		return owtpCall(transmit.node, nodeID, "getTrustNodeInfo", params, sync, h)
+	}
+	return transmit.call(nodeID, "getTrustNodeInfo", params, sync, h)
 }
 
 // CreateWalletViaTrustNode 指定节点，创建种子托管钱包
@@ -143,8 +167,8 @@ func (transmit *TransmitNode) CreateWalletViaTrustNode(nodeID, alias, password s
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
@@ -153,10 +177,13 @@ func (transmit *TransmitNode) CreateWalletViaTrustNode(nodeID, alias, password s
 		"password": password,
 	}
 
-	return transmit.node.Call(nodeID, "createWalletViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "createWalletViaTrustNode", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var wallet Wallet
 		json.Unmarshal([]byte(data.Raw), &wallet)
+		if resp.Status == owtp.StatusSuccess {
+			transmit.registry.addWallet(nodeID, wallet.WalletID)
+		}
 		reqFunc(resp.Status, resp.Msg, &wallet)
 	})
 }
@@ -169,19 +196,25 @@ func (transmit *TransmitNode) CreateAccountViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
+	}
+
+	pw, release, err := transmit.passwordParam(walletID, password)
+	if err != nil {
+		return err
 	}
+	defer release()
 
 	params := map[string]interface{}{
 		"appID":    transmit.config.AppID,
 		"alias":    alias,
 		"walletID": walletID,
-		"password": password,
+		"password": pw,
 		"symbol":   symbol,
 	}
 
-	return transmit.node.Call(nodeID, "createAccountViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "createAccountViaTrustNode", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var account Account
 		json.Unmarshal([]byte(data.Get("account").Raw), &account)
@@ -203,6 +236,7 @@ func (transmit *TransmitNode) CreateAccountViaTrustNode(
 }
 
 // SendTransactionViaTrustNode 创建转账交易订单
+// 位置参数容易传错，建议使用SendTransactionViaTrustNodeWithRequest
 func (transmit *TransmitNode) SendTransactionViaTrustNode(
 	nodeID string,
 	accountID string,
@@ -217,19 +251,46 @@ func (transmit *TransmitNode) SendTransactionViaTrustNode(
 	extParam string,
 	sync bool,
 	reqFunc func(status uint64, msg string, successTx []*Transaction, failedRawTxs []*FailedRawTransaction),
+) error {
+	return transmit.sendTransactionViaTrustNode(nodeID, "", accountID, password, sid, symbol, contractAddress,
+		amount, address, feeRate, memo, extParam, sync, reqFunc)
+}
+
+// sendTransactionViaTrustNode 创建转账交易订单，password为空时通过SecretProvider获取walletID的密码
+func (transmit *TransmitNode) sendTransactionViaTrustNode(
+	nodeID string,
+	walletID string,
+	accountID string,
+	password string,
+	sid string,
+	symbol string,
+	contractAddress string,
+	amount string,
+	address string,
+	feeRate string,
+	memo string,
+	extParam string,
+	sync bool,
+	reqFunc func(status uint64, msg string, successTx []*Transaction, failedRawTxs []*FailedRawTransaction),
 ) error {
 	if transmit == nil {
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
+	}
+
+	pw, release, err := transmit.passwordParam(walletID, password)
+	if err != nil {
+		return err
 	}
+	defer release()
 
 	params := map[string]interface{}{
 		"appID":           transmit.config.AppID,
 		"accountID":       accountID,
-		"password":        password,
+		"password":        pw,
 		"sid":             sid,
 		"contractAddress": contractAddress,
 		"amount":          amount,
@@ -240,7 +301,7 @@ func (transmit *TransmitNode) SendTransactionViaTrustNode(
 		"symbol":          symbol,
 	}
 
-	return transmit.node.Call(nodeID, "sendTransactionViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "sendTransactionViaTrustNode", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		failedRawTxs := make([]*FailedRawTransaction, 0)
 		failedArray := data.Get("failure")
@@ -279,8 +340,8 @@ func (transmit *TransmitNode) SetSummaryInfoViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
@@ -288,7 +349,7 @@ func (transmit *TransmitNode) SetSummaryInfoViaTrustNode(
 		"summarySetting": summarySetting,
 	}
 
-	return transmit.node.Call(nodeID, "setSummaryInfoViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "setSummaryInfoViaTrustNode", params, sync, func(resp owtp.Response) {
 		reqFunc(resp.Status, resp.Msg)
 	})
 }
@@ -302,8 +363,8 @@ func (transmit *TransmitNode) FindSummaryInfoByWalletIDViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
@@ -311,7 +372,7 @@ func (transmit *TransmitNode) FindSummaryInfoByWalletIDViaTrustNode(
 		"walletID": walletID,
 	}
 
-	return transmit.node.Call(nodeID, "findSummaryInfoByWalletIDViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "findSummaryInfoByWalletIDViaTrustNode", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var summaryInfoList []*SummarySetting
 		if data.IsArray() {
@@ -343,20 +404,31 @@ func (transmit *TransmitNode) StartSummaryTaskViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
+	}
+
+	task, release, err := transmit.summaryTaskParam(summaryTask)
+	if err != nil {
+		return err
 	}
+	defer release()
 
 	params := map[string]interface{}{
 		"appID":       transmit.config.AppID,
 		"cycleSec":    cycleSec,
-		"summaryTask": summaryTask,
+		"summaryTask": task,
 		"operateType": operateType,
 	}
 
-	return transmit.node.Call(nodeID, "startSummaryTaskViaTrustNode", params, sync, func(resp owtp.Response) {
+	err = transmit.call(nodeID, "startSummaryTaskViaTrustNode", params, sync, func(resp owtp.Response) {
 		reqFunc(resp.Status, resp.Msg)
 	})
+	if err == nil {
+		//任务已交给托管节点，不再保留密码
+		summaryTask.scrubPasswords()
+	}
+	return err
 }
 
 // StopSummaryTaskViaTrustNode 指定节点，停止汇总任务
@@ -367,15 +439,15 @@ func (transmit *TransmitNode) StopSummaryTaskViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
 		"appID": transmit.config.AppID,
 	}
 
-	return transmit.node.Call(nodeID, "stopSummaryTaskViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "stopSummaryTaskViaTrustNode", params, sync, func(resp owtp.Response) {
 		reqFunc(resp.Status, resp.Msg)
 	})
 }
@@ -388,15 +460,15 @@ func (transmit *TransmitNode) UpdateInfoViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
 		"appID": transmit.config.AppID,
 	}
 
-	return transmit.node.Call(nodeID, "updateInfoViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "updateInfoViaTrustNode", params, sync, func(resp owtp.Response) {
 		reqFunc(resp.Status, resp.Msg)
 	})
 }
@@ -410,18 +482,29 @@ func (transmit *TransmitNode) AppendSummaryTaskViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
+	task, release, err := transmit.summaryTaskParam(summaryTask)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	params := map[string]interface{}{
 		"appID":       transmit.config.AppID,
-		"summaryTask": summaryTask,
+		"summaryTask": task,
 	}
 
-	return transmit.node.Call(nodeID, "appendSummaryTaskViaTrustNode", params, sync, func(resp owtp.Response) {
+	err = transmit.call(nodeID, "appendSummaryTaskViaTrustNode", params, sync, func(resp owtp.Response) {
 		reqFunc(resp.Status, resp.Msg)
 	})
+	if err == nil {
+		//任务已交给托管节点，不再保留密码
+		summaryTask.scrubPasswords()
+	}
+	return err
 }
 
 // RemoveSummaryTaskViaTrustNode 指定节点，移除汇总任务
@@ -434,8 +517,8 @@ func (transmit *TransmitNode) RemoveSummaryTaskViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
@@ -444,7 +527,7 @@ func (transmit *TransmitNode) RemoveSummaryTaskViaTrustNode(
 		"accountID": accountID,
 	}
 
-	return transmit.node.Call(nodeID, "removeSummaryTaskViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "removeSummaryTaskViaTrustNode", params, sync, func(resp owtp.Response) {
 		reqFunc(resp.Status, resp.Msg)
 	})
 }
@@ -457,18 +540,19 @@ func (transmit *TransmitNode) GetCurrentSummaryTaskViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
 		"appID": transmit.config.AppID,
 	}
 
-	return transmit.node.Call(nodeID, "getCurrentSummaryTaskViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "getCurrentSummaryTaskViaTrustNode", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var summaryTask SummaryTask
 		json.Unmarshal([]byte(data.Raw), &summaryTask)
+		summaryTask.scrubPasswords()
 		reqFunc(resp.Status, resp.Msg, &summaryTask)
 	})
 }
@@ -483,8 +567,8 @@ func (transmit *TransmitNode) GetSummaryTaskLogViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
@@ -493,7 +577,7 @@ func (transmit *TransmitNode) GetSummaryTaskLogViaTrustNode(
 		"limit":  limit,
 	}
 
-	return transmit.node.Call(nodeID, "getSummaryTaskLogViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "getSummaryTaskLogViaTrustNode", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var taskLog []*SummaryTaskLog
 		json.Unmarshal([]byte(data.Raw), &taskLog)
@@ -509,15 +593,15 @@ func (transmit *TransmitNode) GetLocalWalletListViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
 		"appID": transmit.config.AppID,
 	}
 
-	return transmit.node.Call(nodeID, "getLocalWalletListViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "getLocalWalletListViaTrustNode", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var list []*Wallet
 		json.Unmarshal([]byte(data.Raw), &list)
@@ -534,8 +618,8 @@ func (transmit *TransmitNode) GetTrustAddressListViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
 	params := map[string]interface{}{
@@ -543,7 +627,7 @@ func (transmit *TransmitNode) GetTrustAddressListViaTrustNode(
 		"symbol": symbol,
 	}
 
-	return transmit.node.Call(nodeID, "getTrustAddressListViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "getTrustAddressListViaTrustNode", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var (
 			list               []*TrustAddress
@@ -569,18 +653,24 @@ func (transmit *TransmitNode) SignTransactionViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
+	}
+
+	pw, release, err := transmit.passwordParam(walletID, password)
+	if err != nil {
+		return err
 	}
+	defer release()
 
 	params := map[string]interface{}{
 		"appID":    transmit.config.AppID,
 		"walletID": walletID,
-		"password": password,
+		"password": pw,
 		"rawTx":    rawTx,
 	}
 
-	return transmit.node.Call(nodeID, "signTransactionViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "signTransactionViaTrustNode", params, sync, func(resp owtp.Response) {
 
 		if resp.Status == owtp.StatusSuccess {
 			data := resp.JsonData()
@@ -602,6 +692,7 @@ func (transmit *TransmitNode) SignTransactionViaTrustNode(
 }
 
 // TriggerABIViaTrustNode 触发ABI上链调用
+// 位置参数容易传错，建议使用TriggerABIViaTrustNodeWithRequest
 // @param nodeID 必填 节点ID
 // @param accountID 必填 账户ID
 // @param password 可选 钱包解锁密码
@@ -630,19 +721,48 @@ func (transmit *TransmitNode) TriggerABIViaTrustNode(
 	awaitResult bool,
 	sync bool,
 	reqFunc func(status uint64, msg string, receipt *SmartContractReceipt),
+) error {
+	return transmit.triggerABIViaTrustNode(nodeID, "", accountID, password, sid, symbol, contractAddress,
+		contractABI, amount, feeRate, abiParam, raw, rawType, awaitResult, sync, reqFunc)
+}
+
+// triggerABIViaTrustNode 触发ABI上链调用，password为空时通过SecretProvider获取walletID的密码
+func (transmit *TransmitNode) triggerABIViaTrustNode(
+	nodeID string,
+	walletID string,
+	accountID string,
+	password string,
+	sid string,
+	symbol string,
+	contractAddress string,
+	contractABI string,
+	amount string,
+	feeRate string,
+	abiParam []string,
+	raw string,
+	rawType uint64,
+	awaitResult bool,
+	sync bool,
+	reqFunc func(status uint64, msg string, receipt *SmartContractReceipt),
 ) error {
 	if transmit == nil {
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
 	}
 
+	pw, release, err := transmit.passwordParam(walletID, password)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	params := map[string]interface{}{
 		"appID":           transmit.config.AppID,
 		"accountID":       accountID,
-		"password":        password,
+		"password":        pw,
 		"sid":             sid,
 		"contractAddress": contractAddress,
 		"contractABI":     contractABI,
@@ -655,7 +775,7 @@ func (transmit *TransmitNode) TriggerABIViaTrustNode(
 		"symbol":          symbol,
 	}
 
-	return transmit.node.Call(nodeID, "triggerABIViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "triggerABIViaTrustNode", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		var receipt SmartContractReceipt
 		err := json.Unmarshal([]byte(data.Raw), &receipt)
@@ -696,9 +816,15 @@ func (transmit *TransmitNode) SignHashViaTrustNode(
 		return fmt.Errorf("TransmitNode is not inited")
 	}
 
-	if p := transmit.node.GetOnlinePeer(nodeID); p == nil {
-		return fmt.Errorf("Node ID: %s is not connected ", nodeID)
+	if err := transmit.checkOnline(nodeID); err != nil {
+		return err
+	}
+
+	pw, release, err := transmit.passwordParam(walletID, password)
+	if err != nil {
+		return err
 	}
+	defer release()
 
 	params := map[string]interface{}{
 		"appID":     transmit.config.AppID,
@@ -706,13 +832,13 @@ func (transmit *TransmitNode) SignHashViaTrustNode(
 		"accountID": accountID,
 		"address":   address,
 		"message":   message,
-		"password":  password,
+		"password":  pw,
 		"symbol":    symbol,
 		"hdPath":    hdPath,
 		"rsv":       rsv,
 	}
 
-	return transmit.node.Call(nodeID, "signHashViaTrustNode", params, sync, func(resp owtp.Response) {
+	return transmit.call(nodeID, "signHashViaTrustNode", params, sync, func(resp owtp.Response) {
 		data := resp.JsonData()
 		signature := data.Get("signature").String()
 		reqFunc(resp.Status, resp.Msg, signature)
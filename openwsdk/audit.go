@@ -0,0 +1,442 @@
+package openwsdk
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// MaxTransmitAuditEntries 审计日志在内存中保留的记录条数，完整的记录写入NewTransmitAuditLog的w
+const MaxTransmitAuditEntries = 10000
+
+const (
+	/* 审计记录类型 */
+	AuditEntryIntent = "intent" //发送命令之前写入
+	AuditEntryResult = "result" //收到响应或发送失败后写入，IntentSeq指向对应的intent
+)
+
+// ErrAuditTampered 审计日志被篡改
+var ErrAuditTampered = errors.New("audit log tampered")
+
+// TransmitAuditEntry 通过TransmitNode发送的命令的审计记录。
+// 每条命令先写入intent记录再发送，收到响应后写入result记录；只有intent没有result说明结果未知。
+// Hash是记录内容（包括PrevHash）的HMAC-SHA256，每条记录引用上一条的Hash形成哈希链，没有密钥无法重新计算。
+type TransmitAuditEntry struct {
+	Seq       uint64                 `json:"seq"`                 //序号，从1开始
+	Kind      string                 `json:"kind"`                //AuditEntryIntent或AuditEntryResult
+	IntentSeq uint64                 `json:"intentSeq,omitempty"` //result对应的intent序号
+	Time      time.Time              `json:"time"`                //intent为发送时间，result为收到响应的时间，UTC
+	Method    string                 `json:"method"`              //命令
+	NodeID    string                 `json:"nodeID"`              //托管节点ID
+	Params    map[string]interface{} `json:"params,omitempty"`    //已脱敏的参数，只在intent中记录
+	Status    uint64                 `json:"status,omitempty"`    //响应状态，发送失败时为0
+	Msg       string                 `json:"msg,omitempty"`       //响应信息
+	Error     string                 `json:"error,omitempty"`     //发送失败的错误
+	Duration  time.Duration          `json:"duration,omitempty"`  //从发送到收到响应的耗时，纳秒
+	PrevHash  string                 `json:"prevHash"`            //上一条记录的Hash，第一条为空
+	Hash      string                 `json:"hash"`
+}
+
+// computeHash 用key计算记录的HMAC，Hash字段不参与计算
+func (e *TransmitAuditEntry) computeHash(key []byte) (string, error) {
+	c := *e
+	c.Hash = ""
+	raw, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(raw)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// AuditTamperError 审计日志校验失败的位置和原因
+type AuditTamperError struct {
+	Seq    uint64
+	Reason string
+}
+
+func (e *AuditTamperError) Error() string {
+	return fmt.Sprintf("audit entry %d: %s", e.Seq, e.Reason)
+}
+
+// Is 匹配ErrAuditTampered
+func (e *AuditTamperError) Is(target error) bool {
+	return target == ErrAuditTampered
+}
+
+// errAuditKeyRequired 审计日志必须配置密钥
+var errAuditKeyRequired = errors.New("audit key is required")
+
+// VerifyTransmitAudit 用key校验完整的审计日志：序号从1开始连续，每条记录的HMAC正确并引用上一条记录，
+// result记录指向之前的intent。删除末尾的记录无法从日志本身发现，需要对照另外保存的TransmitAuditLog.Head。
+func VerifyTransmitAudit(entries []*TransmitAuditEntry, key []byte) error {
+	if len(key) == 0 {
+		return errAuditKeyRequired
+	}
+	return verifyTransmitAudit(entries, key, true)
+}
+
+// verifyTransmitAudit genesis为false时只校验entries内部的链接，用于内存中保留的部分记录
+func verifyTransmitAudit(entries []*TransmitAuditEntry, key []byte, genesis bool) error {
+	intents := make(map[uint64]*TransmitAuditEntry)
+	for i, e := range entries {
+		if e == nil {
+			return &AuditTamperError{Reason: fmt.Sprintf("entry at index %d is empty", i)}
+		}
+		if i == 0 {
+			if genesis && (e.Seq != 1 || len(e.PrevHash) > 0) {
+				return &AuditTamperError{Seq: e.Seq, Reason: "chain does not start at entry 1"}
+			}
+		} else {
+			prev := entries[i-1]
+			if e.Seq != prev.Seq+1 {
+				return &AuditTamperError{Seq: e.Seq, Reason: fmt.Sprintf("expected seq %d", prev.Seq+1)}
+			}
+			if e.PrevHash != prev.Hash {
+				return &AuditTamperError{Seq: e.Seq, Reason: "prevHash does not match previous entry"}
+			}
+		}
+		hash, err := e.computeHash(key)
+		if err != nil {
+			return &AuditTamperError{Seq: e.Seq, Reason: err.Error()}
+		}
+		if !hmac.Equal([]byte(hash), []byte(e.Hash)) {
+			return &AuditTamperError{Seq: e.Seq, Reason: "hash does not match content"}
+		}
+		switch e.Kind {
+		case AuditEntryIntent:
+			intents[e.Seq] = e
+		case AuditEntryResult:
+			intent, ok := intents[e.IntentSeq]
+			if !ok && (genesis || e.IntentSeq >= entries[0].Seq) {
+				return &AuditTamperError{Seq: e.Seq, Reason: fmt.Sprintf("intent %d not found", e.IntentSeq)}
+			}
+			if ok && (intent.Method != e.Method || intent.NodeID != e.NodeID) {
+				return &AuditTamperError{Seq: e.Seq, Reason: fmt.Sprintf("result does not match intent %d", e.IntentSeq)}
+			}
+		default:
+			return &AuditTamperError{Seq: e.Seq, Reason: fmt.Sprintf("unknown kind %q", e.Kind)}
+		}
+	}
+	return nil
+}
+
+// ReadTransmitAuditJSONL 读取JSON行格式的审计日志
+func ReadTransmitAuditJSONL(r io.Reader) ([]*TransmitAuditEntry, error) {
+	var entries []*TransmitAuditEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e TransmitAuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("audit log line %d: %v", line, err)
+		}
+		entries = append(entries, &e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// VerifyTransmitAuditJSONL 读取并用key校验JSON行格式的审计日志，返回记录条数
+func VerifyTransmitAuditJSONL(r io.Reader, key []byte) (int, error) {
+	entries, err := ReadTransmitAuditJSONL(r)
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), VerifyTransmitAudit(entries, key)
+}
+
+// ExportTransmitAuditJSONL 把审计记录按JSON行写入w
+func ExportTransmitAuditJSONL(w io.Writer, entries []*TransmitAuditEntry) error {
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransmitAuditLog 只追加的托管节点命令审计日志。
+// 每条记录写入w（如以O_APPEND打开的文件），内存中保留最近MaxTransmitAuditEntries条。
+// key用于计算HMAC，应与日志分开保存，校验时需要同一个key。
+type TransmitAuditLog struct {
+	mu       sync.Mutex
+	key      []byte
+	enc      *json.Encoder //为空时只保存在内存中
+	entries  []*TransmitAuditEntry
+	seq      uint64
+	lastHash string
+	now      func() time.Time
+}
+
+// NewTransmitAuditLog 创建审计日志，w为空时只保存在内存中，key不能为空
+func NewTransmitAuditLog(w io.Writer, key []byte) (*TransmitAuditLog, error) {
+	if len(key) == 0 {
+		return nil, errAuditKeyRequired
+	}
+	l := &TransmitAuditLog{key: append([]byte(nil), key...), now: time.Now}
+	if w != nil {
+		l.enc = json.NewEncoder(w)
+	}
+	return l, nil
+}
+
+// OpenTransmitAuditLog 用key校验r中已有的审计日志，新记录接着已有的哈希链写入w。
+// r和w通常是同一个文件，进程重启后继续追加。
+func OpenTransmitAuditLog(r io.Reader, w io.Writer, key []byte) (*TransmitAuditLog, error) {
+	entries, err := ReadTransmitAuditJSONL(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyTransmitAudit(entries, key); err != nil {
+		return nil, err
+	}
+	l, err := NewTransmitAuditLog(w, key)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(entries); n > 0 {
+		l.seq = entries[n-1].Seq
+		l.lastHash = entries[n-1].Hash
+		if n > MaxTransmitAuditEntries {
+			entries = entries[n-MaxTransmitAuditEntries:]
+		}
+		l.entries = entries
+	}
+	return l, nil
+}
+
+// Head 最后一条记录的序号和Hash，另外保存后可以发现末尾记录被删除
+func (l *TransmitAuditLog) Head() (seq uint64, hash string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq, l.lastHash
+}
+
+// Entries 内存中保留的记录
+func (l *TransmitAuditLog) Entries() []*TransmitAuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]*TransmitAuditEntry, 0, len(l.entries))
+	for _, e := range l.entries {
+		c := *e
+		entries = append(entries, &c)
+	}
+	return entries
+}
+
+// Verify 校验内存中保留的记录
+func (l *TransmitAuditLog) Verify() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := verifyTransmitAudit(l.entries, l.key, false); err != nil {
+		return err
+	}
+	if n := len(l.entries); n > 0 && (l.entries[n-1].Seq != l.seq || l.entries[n-1].Hash != l.lastHash) {
+		return &AuditTamperError{Seq: l.entries[n-1].Seq, Reason: "last entry does not match head"}
+	}
+	return nil
+}
+
+// ExportJSONL 把内存中保留的记录按JSON行写入w
+func (l *TransmitAuditLog) ExportJSONL(w io.Writer) error {
+	return ExportTransmitAuditJSONL(w, l.Entries())
+}
+
+// append 追加记录，计算哈希链并写入w，写入失败时不改变哈希链
+func (l *TransmitAuditLog) append(e *TransmitAuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Seq = l.seq + 1
+	e.PrevHash = l.lastHash
+	hash, err := e.computeHash(l.key)
+	if err != nil {
+		return fmt.Errorf("audit entry %d: %v", e.Seq, err)
+	}
+	e.Hash = hash
+	if l.enc != nil {
+		if err := l.enc.Encode(e); err != nil {
+			return fmt.Errorf("audit entry %d: write failed: %v", e.Seq, err)
+		}
+	}
+	l.seq = e.Seq
+	l.lastHash = hash
+	l.entries = append(l.entries, e)
+	if n := len(l.entries); n > MaxTransmitAuditEntries {
+		l.entries = append([]*TransmitAuditEntry(nil), l.entries[n-MaxTransmitAuditEntries:]...)
+	}
+	return nil
+}
+
+// SetAuditLog 设置审计日志，在发送命令前设置，记录之后通过TransmitNode发送的所有命令，nil关闭
+func (transmit *TransmitNode) SetAuditLog(l *TransmitAuditLog) {
+	transmit.audit = l
+}
+
+// AuditLog 当前的审计日志
+func (transmit *TransmitNode) AuditLog() *TransmitAuditLog {
+	return transmit.audit
+}
+
+// call 向托管节点发送命令并写入审计日志，intent记录写入失败时不发送
+func (transmit *TransmitNode) call(nodeID, method string, params map[string]interface{}, sync bool, reqFunc owtp.RequestFunc) error {
+	audit := transmit.audit
+	if audit == nil {
+		return owtpCall(transmit.node, nodeID, method, params, sync, reqFunc)
+	}
+	record, err := audit.begin(nodeID, method, params)
+	if err != nil {
+		return fmt.Errorf("%s refused: %w", method, err)
+	}
+	err = owtpCall(transmit.node, nodeID, method, params, sync, func(resp owtp.Response) {
+		record(resp.Status, resp.Msg, nil)
+		reqFunc(resp)
+	})
+	if err != nil {
+		record(0, "", err)
+	}
+	return err
+}
+
+// owtpPreSendErrors owtp.Call在发送数据之前返回的错误
+var owtpPreSendErrors = []string{
+	"keyAgreement is enabled", //密码协商未完成
+	"OWTP: authorization failed",
+	"peer has been closed",
+	"OWTP: nonce exist",
+	"API url is not setup",
+}
+
+// owtpCall 发送owtp请求，区分请求是否确定未送达，见callError
+func owtpCall(node *owtp.OWTPNode, nodeID, method string, params interface{}, sync bool, reqFunc owtp.RequestFunc) error {
+	peer, err := onlinePeer(node, nodeID)
+	if err != nil {
+		return err
+	}
+	return callError(peer, nodeID, method, node.Call(nodeID, method, params, sync, reqFunc))
+}
+
+// owtpCallSync 发送同步owtp请求，区分请求是否确定未送达，见callError
+func owtpCallSync(node *owtp.OWTPNode, nodeID, method string, params interface{}) (*owtp.Response, error) {
+	peer, err := onlinePeer(node, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := node.CallSync(nodeID, method, params)
+	if err != nil {
+		return nil, callError(peer, nodeID, method, err)
+	}
+	return resp, nil
+}
+
+// onlinePeer 返回在线的节点，不在线时与owtp.Call一样按保存的连接信息重新连接，连接失败时请求未送达
+func onlinePeer(node *owtp.OWTPNode, nodeID string) (owtp.Peer, error) {
+	if peer := node.GetOnlinePeer(nodeID); peer != nil {
+		return peer, nil
+	}
+	peer, err := node.Connect(nodeID, node.Peerstore().PeerInfo(nodeID).Config)
+	if err != nil {
+		return nil, &undeliveredError{NodeID: nodeID, Err: err}
+	}
+	return peer, nil
+}
+
+// callError 区分owtp.Call返回的错误。
+// websocket的send只在数据发出前失败，错误都标记为未送达；
+// HTTP和MQ的send完成整个请求，非200状态码、空响应和读取失败时对方可能已经执行，
+// 除了已知的发送前错误和建立连接失败，都返回*UnknownOutcomeError。
+func callError(peer owtp.Peer, nodeID, method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch peer.ConnectConfig().ConnectType {
+	case owtp.HTTP, owtp.MQ:
+		if !isPreSendError(err) {
+			return &UnknownOutcomeError{Method: method, NodeID: nodeID, Err: err}
+		}
+	}
+	return &undeliveredError{NodeID: nodeID, Err: err}
+}
+
+// isPreSendError 错误是否确定发生在请求发出之前
+func isPreSendError(err error) bool {
+	msg := err.Error()
+	for _, prefix := range owtpPreSendErrors {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// begin 写入intent记录，返回的record在收到响应或发送失败时写入result记录，只生效一次。
+// 命令已经发出，result写入失败只能记录日志。
+func (l *TransmitAuditLog) begin(nodeID, method string, params map[string]interface{}) (record func(status uint64, msg string, err error), err error) {
+	start := l.now()
+	intent := &TransmitAuditEntry{
+		Kind:   AuditEntryIntent,
+		Time:   start.UTC().Round(0),
+		Method: method,
+		NodeID: nodeID,
+		Params: auditParams(params),
+	}
+	if err := l.append(intent); err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func(status uint64, msg string, err error) {
+		once.Do(func() {
+			end := l.now()
+			result := &TransmitAuditEntry{
+				Kind:      AuditEntryResult,
+				IntentSeq: intent.Seq,
+				Time:      end.UTC().Round(0),
+				Method:    method,
+				NodeID:    nodeID,
+				Status:    status,
+				Msg:       msg,
+				Duration:  end.Sub(start),
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			if err := l.append(result); err != nil {
+				log.Errorf("%s via trust node %s: %v", method, nodeID, err)
+			}
+		})
+	}, nil
+}
+
+// auditParams 脱敏的参数，secretParam直接替换，避免密码被转换为字符串
+func auditParams(params map[string]interface{}) map[string]interface{} {
+	shallow := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if _, ok := v.(secretParam); ok {
+			v = redactedValue
+		}
+		shallow[k] = v
+	}
+	return redactParams(shallow)
+}
@@ -0,0 +1,163 @@
+package openwsdk_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func TestTransmitAuditLog(t *testing.T) {
+	transmit, addr := testTransmitNode(t)
+	defer transmit.Close()
+	key := testHDKey(t)
+	trustNode := openwsdktest.NewTrustNode("trust")
+	defer trustNode.Close()
+	trustNode.ImportKey(key, "12345678")
+	testConnectTrustNode(t, trustNode, addr)
+	testWaitFor(t, "wallet list", func() bool {
+		return len(transmit.Registry().NodesForWallet(key.KeyID)) == 1
+	})
+
+	var buf bytes.Buffer
+	auditKey := []byte("audit-key")
+	audit, err := openwsdk.NewTransmitAuditLog(&buf, auditKey)
+	if err != nil {
+		t.Fatalf("NewTransmitAuditLog unexpected error: %v", err)
+	}
+	transmit.SetAuditLog(audit)
+	transmit.SetSecretProvider(openwsdk.SecretProviderFunc(func(walletID string) (*openwsdk.Secret, error) {
+		return openwsdk.NewSecret([]byte("12345678")), nil
+	}))
+
+	send := func(password string) {
+		err := transmit.SendTransactionViaTrustNode(trustNode.NodeID(), "A1", password, "sid-1", "BTC", "", "0.1", "1xyz", "", "", "", true,
+			func(uint64, string, []*openwsdk.Transaction, []*openwsdk.FailedRawTransaction) {})
+		if err != nil {
+			t.Fatalf("SendTransactionViaTrustNode unexpected error: %v", err)
+		}
+	}
+	send("12345678")
+	send("wrong")
+	signer := &openwsdk.TrustNodeSigner{Transmit: transmit, NodeID: trustNode.NodeID()}
+	if err := signer.Sign(context.Background(), testKeySignature(key)); err != nil {
+		t.Fatalf("Sign unexpected error: %v", err)
+	}
+
+	entries := audit.Entries()
+	if len(entries) != 6 {
+		t.Fatalf("expected 6 audit entries, got: %d", len(entries))
+	}
+	if e := entries[0]; e.Seq != 1 || e.Kind != openwsdk.AuditEntryIntent || e.Method != "sendTransactionViaTrustNode" ||
+		e.NodeID != trustNode.NodeID() || e.Params["amount"] != "0.1" || len(e.PrevHash) > 0 {
+		t.Errorf("unexpected first entry: %+v", e)
+	}
+	if e := entries[1]; e.Kind != openwsdk.AuditEntryResult || e.IntentSeq != 1 || e.Status != owtp.StatusSuccess ||
+		e.Duration <= 0 || e.Params != nil || e.PrevHash != entries[0].Hash {
+		t.Errorf("unexpected second entry: %+v", e)
+	}
+	if e := entries[3]; e.IntentSeq != 3 || e.Status == owtp.StatusSuccess || e.Msg != "wallet password is incorrect" {
+		t.Errorf("unexpected fourth entry: %+v", e)
+	}
+	if e := entries[5]; e.Method != "signHashViaTrustNode" || e.IntentSeq != 5 || e.Status != owtp.StatusSuccess {
+		t.Errorf("unexpected sixth entry: %+v", e)
+	}
+	if strings.Contains(buf.String(), "12345678") || strings.Contains(buf.String(), "wrong") {
+		t.Errorf("audit log leaked password: %s", buf.String())
+	}
+	if err := audit.Verify(); err != nil {
+		t.Errorf("Verify unexpected error: %v", err)
+	}
+	seq, hash := audit.Head()
+	if seq != 6 || hash != entries[5].Hash {
+		t.Errorf("unexpected head: %d, %s", seq, hash)
+	}
+
+	var exported bytes.Buffer
+	if err := audit.ExportJSONL(&exported); err != nil || exported.String() != buf.String() {
+		t.Errorf("ExportJSONL expected same lines as written log, err: %v", err)
+	}
+	if n, err := openwsdk.VerifyTransmitAuditJSONL(strings.NewReader(buf.String()), auditKey); n != 6 || err != nil {
+		t.Errorf("VerifyTransmitAuditJSONL unexpected result: %d, %v", n, err)
+	}
+	//没有密钥无法重新计算哈希链
+	if _, err := openwsdk.VerifyTransmitAuditJSONL(strings.NewReader(buf.String()), []byte("other-key")); !errors.Is(err, openwsdk.ErrAuditTampered) {
+		t.Errorf("VerifyTransmitAuditJSONL with wrong key expected ErrAuditTampered, got: %v", err)
+	}
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	tampered := map[string]string{
+		"modified":  strings.Replace(buf.String(), `"amount":"0.1"`, `"amount":"10"`, 1),
+		"removed":   lines[0] + lines[2] + lines[3],
+		"reordered": lines[1] + lines[0] + lines[2],
+		"truncated": lines[2] + lines[3],
+	}
+	for name, log := range tampered {
+		if _, err := openwsdk.VerifyTransmitAuditJSONL(strings.NewReader(log), auditKey); !errors.Is(err, openwsdk.ErrAuditTampered) {
+			t.Errorf("%s: expected ErrAuditTampered, got: %v", name, err)
+		}
+	}
+
+	//重启后接着已有的哈希链追加
+	var appended bytes.Buffer
+	resumed, err := openwsdk.OpenTransmitAuditLog(strings.NewReader(buf.String()), &appended, auditKey)
+	if err != nil {
+		t.Fatalf("OpenTransmitAuditLog unexpected error: %v", err)
+	}
+	transmit.SetAuditLog(resumed)
+	send("12345678")
+	if n, err := openwsdk.VerifyTransmitAuditJSONL(strings.NewReader(buf.String()+appended.String()), auditKey); n != 8 || err != nil {
+		t.Errorf("resumed log unexpected result: %d, %v", n, err)
+	}
+	if _, err := openwsdk.OpenTransmitAuditLog(strings.NewReader(tampered["modified"]), nil, auditKey); !errors.Is(err, openwsdk.ErrAuditTampered) {
+		t.Errorf("OpenTransmitAuditLog expected ErrAuditTampered, got: %v", err)
+	}
+
+	//intent写入失败时不发送命令
+	failed, _ := openwsdk.NewTransmitAuditLog(testFailWriter{}, auditKey)
+	transmit.SetAuditLog(failed)
+	sends := trustNode.Calls("sendTransactionViaTrustNode")
+	err = transmit.SendTransactionViaTrustNode(trustNode.NodeID(), "A1", "12345678", "sid-2", "BTC", "", "0.1", "1xyz", "", "", "", true,
+		func(uint64, string, []*openwsdk.Transaction, []*openwsdk.FailedRawTransaction) {})
+	if err == nil {
+		t.Errorf("SendTransactionViaTrustNode expected audit write error")
+	}
+	if n := trustNode.Calls("sendTransactionViaTrustNode"); n != sends {
+		t.Errorf("command sent without audit entry")
+	}
+	if seq, _ := failed.Head(); seq != 0 {
+		t.Errorf("failed write expected not to advance head, got: %d", seq)
+	}
+}
+
+func TestTransmitAuditLog_HealthCheck(t *testing.T) {
+	transmit, addr := testTransmitNode(t)
+	defer transmit.Close()
+	trustNode := openwsdktest.NewTrustNode("trust")
+	defer trustNode.Close()
+	testConnectTrustNode(t, trustNode, addr)
+	testWaitFor(t, "trust node join", func() bool {
+		_, ok := transmit.Registry().Node(trustNode.NodeID())
+		return ok
+	})
+
+	audit, _ := openwsdk.NewTransmitAuditLog(nil, []byte("audit-key"))
+	transmit.SetAuditLog(audit)
+	if err := transmit.Registry().CheckHealth(trustNode.NodeID()); err != nil {
+		t.Fatalf("CheckHealth unexpected error: %v", err)
+	}
+	if n := len(audit.Entries()); n != 0 {
+		t.Errorf("health probe expected not to be audited, got %d entries", n)
+	}
+}
+
+type testFailWriter struct{}
+
+func (testFailWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
@@ -0,0 +1,59 @@
+package openwsdk
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff 指数退避策略
+type Backoff struct {
+	InitialInterval time.Duration //首次重试等待时间
+	MaxInterval     time.Duration //最大等待时间
+	Multiplier      float64       //每次等待时间的倍数
+	Jitter          float64       //随机抖动比例，0.2表示在±20%范围内浮动
+	MaxAttempts     int           //最大尝试次数，0表示不限制
+}
+
+// DefaultBackoff 默认退避策略：500ms起，每次翻倍，最长30s
+var DefaultBackoff = Backoff{
+	InitialInterval: 500 * time.Millisecond,
+	MaxInterval:     30 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.2,
+}
+
+// Duration 第attempt次尝试前的等待时间，attempt从1开始
+func (b Backoff) Duration(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	interval := float64(b.InitialInterval)
+	if interval <= 0 {
+		interval = float64(DefaultBackoff.InitialInterval)
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	max := float64(b.MaxInterval)
+	for i := 1; i < attempt; i++ {
+		interval *= multiplier
+		if max > 0 && interval >= max {
+			interval = max
+			break
+		}
+	}
+	if b.Jitter > 0 {
+		delta := interval * b.Jitter
+		interval = interval - delta + rand.Float64()*2*delta
+	}
+	if max > 0 && interval > max {
+		interval = max
+	}
+	return time.Duration(interval)
+}
+
+// Exhausted 第attempt次尝试后是否已达最大次数
+func (b Backoff) Exhausted(attempt int) bool {
+	return b.MaxAttempts > 0 && attempt >= b.MaxAttempts
+}
@@ -0,0 +1,41 @@
+package openwsdk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff_Duration(t *testing.T) {
+	b := Backoff{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		Multiplier:      2,
+		MaxAttempts:     3,
+	}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+	for _, test := range tests {
+		if got := b.Duration(test.attempt); got != test.want {
+			t.Errorf("Duration(%d) = %v, want %v", test.attempt, got, test.want)
+		}
+	}
+	if b.Exhausted(2) || !b.Exhausted(3) {
+		t.Errorf("unexpected Exhausted result")
+	}
+
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := b.Duration(2); d < 100*time.Millisecond || d > 300*time.Millisecond {
+			t.Fatalf("Duration with jitter out of range: %v", d)
+		}
+	}
+}
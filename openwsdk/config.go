@@ -0,0 +1,315 @@
+package openwsdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/astaxie/beego/config"
+	"github.com/blocktree/go-owcdrivers/addressEncoder"
+	"github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/owtp"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// DefaultTimeoutSEC 配置未设置时的请求超时秒数
+	DefaultTimeoutSEC = 60
+	// DefaultCertKeyPath 从加密keystore派生通信证书私钥的路径
+	DefaultCertKeyPath = "m/44'/88'/0'"
+)
+
+// apiNodeConfigFile 配置文件和环境变量的字段，INI的键名不区分大小写，
+// 环境变量为DefaultCredentialEnvPrefix加上大写的字段名，如OPENW_HOST
+type apiNodeConfigFile struct {
+	Host               string `json:"host" yaml:"host"`
+	AppID              string `json:"appid" yaml:"appid"`
+	AppKey             string `json:"appkey" yaml:"appkey"`
+	ConnectType        string `json:"connectType" yaml:"connectType"`
+	EnableKeyAgreement bool   `json:"enableKeyAgreement" yaml:"enableKeyAgreement"`
+	EnableSSL          bool   `json:"enableSSL" yaml:"enableSSL"`
+	EnableSignature    bool   `json:"enableSignature" yaml:"enableSignature"`
+	TimeoutSEC         int    `json:"timeoutSEC" yaml:"timeoutSEC"`
+	KeyGraceSEC        int    `json:"keyGraceSEC" yaml:"keyGraceSEC"`
+	PrivateKey         string `json:"privateKey" yaml:"privateKey"`     //base58编码的通信证书私钥
+	CertFile           string `json:"certFile" yaml:"certFile"`         //保存base58私钥的文件
+	CertKeystore       string `json:"certKeystore" yaml:"certKeystore"` //hdkeystore加密的钥匙文件
+	CertPassword       string `json:"certPassword" yaml:"certPassword"` //keystore解密密码
+	CertKeyPath        string `json:"certKeyPath" yaml:"certKeyPath"`   //keystore派生私钥的路径，默认DefaultCertKeyPath
+}
+
+// fields 按字段名访问，用于读取INI和环境变量
+func (f *apiNodeConfigFile) fields() []struct {
+	name  string
+	value interface{}
+} {
+	return []struct {
+		name  string
+		value interface{}
+	}{
+		{"Host", &f.Host},
+		{"AppID", &f.AppID},
+		{"AppKey", &f.AppKey},
+		{"ConnectType", &f.ConnectType},
+		{"EnableKeyAgreement", &f.EnableKeyAgreement},
+		{"EnableSSL", &f.EnableSSL},
+		{"EnableSignature", &f.EnableSignature},
+		{"TimeoutSEC", &f.TimeoutSEC},
+		{"KeyGraceSEC", &f.KeyGraceSEC},
+		{"PrivateKey", &f.PrivateKey},
+		{"CertFile", &f.CertFile},
+		{"CertKeystore", &f.CertKeystore},
+		{"CertPassword", &f.CertPassword},
+		{"CertKeyPath", &f.CertKeyPath},
+	}
+}
+
+// set 读取到的字符串按字段类型赋值，空字符串不覆盖
+func (f *apiNodeConfigFile) set(lookup func(name string) string) error {
+	for _, field := range f.fields() {
+		v := strings.TrimSpace(lookup(field.name))
+		if len(v) == 0 {
+			continue
+		}
+		switch p := field.value.(type) {
+		case *string:
+			*p = v
+		case *bool:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid bool %q", field.name, v)
+			}
+			*p = b
+		case *int:
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid integer %q", field.name, v)
+			}
+			*p = n
+		}
+	}
+	return nil
+}
+
+// LoadAPINodeConfig 加载APINode配置。
+// .ini/.conf按INI解析，.yaml/.yml按YAML解析，其余按JSON解析，path为空时只读取环境变量。
+// 环境变量OPENW_<字段名>覆盖文件中的值，之后加载通信证书并校验配置。
+func LoadAPINodeConfig(path string) (*APINodeConfig, error) {
+	var file apiNodeConfigFile
+	if len(path) > 0 {
+		if err := readAPINodeConfigFile(path, &file); err != nil {
+			return nil, err
+		}
+	}
+	if err := file.set(func(name string) string {
+		return os.Getenv(DefaultCredentialEnvPrefix + strings.ToUpper(name))
+	}); err != nil {
+		return nil, fmt.Errorf("environment: %v", err)
+	}
+
+	cert, err := loadCertificate(&file)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &APINodeConfig{
+		Host:               file.Host,
+		AppID:              file.AppID,
+		AppKey:             file.AppKey,
+		ConnectType:        file.ConnectType,
+		EnableKeyAgreement: file.EnableKeyAgreement,
+		EnableSSL:          file.EnableSSL,
+		EnableSignature:    file.EnableSignature,
+		Cert:               cert,
+		TimeoutSEC:         file.TimeoutSEC,
+		KeyGraceSEC:        file.KeyGraceSEC,
+	}
+	cfg = cfg.withDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func readAPINodeConfigFile(path string, file *apiNodeConfigFile) error {
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ini", ".conf":
+		var c config.Configer
+		c, err = config.NewConfig("ini", path)
+		if err == nil {
+			err = file.set(c.String)
+		}
+	case ".yaml", ".yml":
+		var data []byte
+		if data, err = ioutil.ReadFile(path); err == nil {
+			err = yaml.Unmarshal(data, file)
+		}
+	default:
+		var data []byte
+		if data, err = ioutil.ReadFile(path); err == nil {
+			err = json.Unmarshal(data, file)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("load config %s failed: %v", path, err)
+	}
+	return nil
+}
+
+// loadCertificate 依次从PrivateKey、CertFile、CertKeystore加载通信证书，都未设置时生成随机证书
+func loadCertificate(file *apiNodeConfigFile) (owtp.Certificate, error) {
+	sources := 0
+	for _, s := range []string{file.PrivateKey, file.CertFile, file.CertKeystore} {
+		if len(s) > 0 {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return owtp.Certificate{}, fmt.Errorf("only one of privateKey, certFile and certKeystore can be set")
+	}
+
+	switch {
+	case len(file.PrivateKey) > 0:
+		return owtp.NewCertificate(file.PrivateKey)
+	case len(file.CertFile) > 0:
+		data, err := ioutil.ReadFile(file.CertFile)
+		if err != nil {
+			return owtp.Certificate{}, err
+		}
+		return owtp.NewCertificate(strings.TrimSpace(string(data)))
+	case len(file.CertKeystore) > 0:
+		keyjson, err := ioutil.ReadFile(file.CertKeystore)
+		if err != nil {
+			return owtp.Certificate{}, err
+		}
+		key, err := hdkeystore.DecryptHDKey(keyjson, file.CertPassword)
+		if err != nil {
+			return owtp.Certificate{}, fmt.Errorf("decrypt cert keystore failed: %v", err)
+		}
+		keyPath := file.CertKeyPath
+		if len(keyPath) == 0 {
+			keyPath = DefaultCertKeyPath
+		}
+		childKey, err := key.DerivedKeyWithPath(keyPath, owcrypt.ECC_CURVE_SM2_STANDARD)
+		if err != nil {
+			return owtp.Certificate{}, err
+		}
+		prikey, err := childKey.GetPrivateKeyBytes()
+		if err != nil {
+			return owtp.Certificate{}, err
+		}
+		alphabet := addressEncoder.NewBase58Alphabet(addressEncoder.BTCAlphabet)
+		return owtp.NewCertificate(addressEncoder.Base58Encode(prikey, alphabet))
+	}
+	return owtp.NewRandomCertificate(), nil
+}
+
+// withDefaults 返回配置的副本，未设置的ConnectType和TimeoutSEC使用默认值，不修改调用方的配置
+func (config *APINodeConfig) withDefaults() *APINodeConfig {
+	c := *config
+	if len(c.ConnectType) == 0 {
+		c.ConnectType = owtp.HTTP
+	}
+	if c.TimeoutSEC == 0 {
+		c.TimeoutSEC = DefaultTimeoutSEC
+	}
+	return &c
+}
+
+// Validate 校验配置，返回所有不合法的项
+func (config *APINodeConfig) Validate() error {
+	problems, warnings := config.validate()
+	return configProblems(append(problems, warnings...))
+}
+
+// validate problems是无法建立连接的配置；warnings是不推荐的组合，
+// LoadAPINodeConfig视为错误，NewAPINode只记录日志，兼容手工构造的配置
+func (config *APINodeConfig) validate() (problems, warnings []string) {
+	if err := validateHost(config.Host); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if config.ConnectType != owtp.HTTP && config.ConnectType != owtp.Websocket {
+		problems = append(problems, fmt.Sprintf("connectType %q must be %s or %s", config.ConnectType, owtp.HTTP, owtp.Websocket))
+	}
+	if config.TimeoutSEC <= 0 {
+		problems = append(problems, fmt.Sprintf("timeoutSEC %d must be positive", config.TimeoutSEC))
+	}
+	if config.KeyGraceSEC < 0 {
+		problems = append(problems, fmt.Sprintf("keyGraceSEC %d must not be negative", config.KeyGraceSEC))
+	}
+	if config.EnableSSL && config.EnableKeyAgreement {
+		warnings = append(warnings, "enableSSL and enableKeyAgreement both encrypt the connection, enable only one")
+	}
+	if (config.EnableKeyAgreement || config.EnableSignature) && len(config.Cert.ID()) == 0 {
+		warnings = append(warnings, "enableKeyAgreement and enableSignature require a certificate")
+	}
+	if config.Credentials == nil && (len(config.AppID) == 0 || len(config.AppKey) == 0) {
+		warnings = append(warnings, "appid and appkey are required")
+	}
+	return problems, warnings
+}
+
+func configProblems(problems []string) error {
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid APINodeConfig: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateHost host为不带协议的host[:port]，协议由ConnectType和EnableSSL决定
+func validateHost(host string) error {
+	if len(host) == 0 {
+		return fmt.Errorf("host is empty")
+	}
+	if strings.Contains(host, "://") {
+		return fmt.Errorf("host %q must not contain a scheme", host)
+	}
+	u, err := url.Parse("http://" + host)
+	if err != nil || len(u.Hostname()) == 0 {
+		return fmt.Errorf("host %q is invalid", host)
+	}
+	if port := u.Port(); len(port) > 0 {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return fmt.Errorf("host %q has invalid port", host)
+		}
+	} else if strings.HasSuffix(u.Host, ":") {
+		return fmt.Errorf("host %q has invalid port", host)
+	}
+	return nil
+}
+
+// Summary 配置摘要，AppKey等密钥不显示明文，证书只显示节点ID
+func (config *APINodeConfig) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Host: %s\n", config.Host)
+	fmt.Fprintf(&b, "ConnectType: %s\n", config.ConnectType)
+	fmt.Fprintf(&b, "AppID: %s\n", config.AppID)
+	fmt.Fprintf(&b, "AppKey: %s\n", redact(config.AppKey))
+	if config.Credentials != nil {
+		fmt.Fprintf(&b, "Credentials: %T\n", config.Credentials)
+	}
+	fmt.Fprintf(&b, "EnableSSL: %t\n", config.EnableSSL)
+	fmt.Fprintf(&b, "EnableSignature: %t\n", config.EnableSignature)
+	fmt.Fprintf(&b, "EnableKeyAgreement: %t\n", config.EnableKeyAgreement)
+	fmt.Fprintf(&b, "TimeoutSEC: %d\n", config.TimeoutSEC)
+	fmt.Fprintf(&b, "KeyGraceSEC: %d\n", config.KeyGraceSEC)
+	fmt.Fprintf(&b, "NodeID: %s", config.Cert.ID())
+	return b.String()
+}
+
+// redact 只显示长度足够的密钥的首尾各2位
+func redact(secret string) string {
+	switch {
+	case len(secret) == 0:
+		return ""
+	case len(secret) < 12:
+		return "******"
+	}
+	return secret[:2] + "******" + secret[len(secret)-2:]
+}
@@ -0,0 +1,147 @@
+package openwsdk_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func TestLoadAPINodeConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("TempDir unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert := owtp.NewRandomCertificate()
+	privateKey, _ := cert.KeyPair()
+	certFile := filepath.Join(dir, "cert.key")
+	ioutil.WriteFile(certFile, []byte(privateKey+"\n"), 0600)
+
+	files := map[string]string{
+		"node.ini": "Host = 127.0.0.1:8422\nAppID = app1\nAppKey = appkey-0123456789\nConnectType = ws\n" +
+			"EnableSignature = true\nTimeoutSEC = 30\nPrivateKey = " + privateKey + "\n",
+		"node.json": `{"host":"127.0.0.1:8422","appid":"app1","appkey":"appkey-0123456789","connectType":"ws",` +
+			`"enableSignature":true,"timeoutSEC":30,"certFile":"` + certFile + `"}`,
+		"node.yaml": "host: 127.0.0.1:8422\nappid: app1\nappkey: appkey-0123456789\nconnectType: ws\n" +
+			"enableSignature: true\ntimeoutSEC: 30\nprivateKey: " + privateKey + "\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		ioutil.WriteFile(path, []byte(content), 0600)
+		config, err := openwsdk.LoadAPINodeConfig(path)
+		if err != nil {
+			t.Errorf("%s: LoadAPINodeConfig unexpected error: %v", name, err)
+			continue
+		}
+		if config.Host != "127.0.0.1:8422" || config.AppID != "app1" || config.ConnectType != owtp.Websocket ||
+			!config.EnableSignature || config.TimeoutSEC != 30 || config.Cert.ID() != cert.ID() {
+			t.Errorf("%s: unexpected config: %+v", name, config)
+		}
+	}
+
+	//环境变量覆盖文件配置
+	os.Setenv("OPENW_HOST", "10.0.0.1:443")
+	os.Setenv("OPENW_ENABLESSL", "true")
+	os.Setenv("OPENW_TIMEOUTSEC", "90")
+	config, err := openwsdk.LoadAPINodeConfig(filepath.Join(dir, "node.yaml"))
+	os.Unsetenv("OPENW_HOST")
+	os.Unsetenv("OPENW_ENABLESSL")
+	os.Unsetenv("OPENW_TIMEOUTSEC")
+	if err != nil {
+		t.Fatalf("LoadAPINodeConfig unexpected error: %v", err)
+	}
+	if config.Host != "10.0.0.1:443" || !config.EnableSSL || config.TimeoutSEC != 90 || config.AppID != "app1" {
+		t.Errorf("unexpected overridden config: %+v", config)
+	}
+
+	summary := config.Summary()
+	if strings.Contains(summary, "appkey-0123456789") || strings.Contains(summary, privateKey) || !strings.Contains(summary, cert.ID()) {
+		t.Errorf("summary is not redacted: %s", summary)
+	}
+
+	//加密keystore派生的证书在每次加载时相同
+	_, keyFile, err := hdkeystore.StoreHDKey(dir, "cert", "password", hdkeystore.LightScryptN, hdkeystore.LightScryptP)
+	if err != nil {
+		t.Fatalf("StoreHDKey unexpected error: %v", err)
+	}
+	keystoreConfig := filepath.Join(dir, "keystore.json")
+	ioutil.WriteFile(keystoreConfig, []byte(`{"host":"127.0.0.1:8422","appid":"app1","appkey":"k","certKeystore":"`+keyFile+`"}`), 0600)
+	if _, err := openwsdk.LoadAPINodeConfig(keystoreConfig); err == nil {
+		t.Errorf("LoadAPINodeConfig expected error with wrong keystore password")
+	}
+	os.Setenv("OPENW_CERTPASSWORD", "password")
+	c1, err1 := openwsdk.LoadAPINodeConfig(keystoreConfig)
+	c2, err2 := openwsdk.LoadAPINodeConfig(keystoreConfig)
+	os.Unsetenv("OPENW_CERTPASSWORD")
+	if err1 != nil || err2 != nil {
+		t.Fatalf("LoadAPINodeConfig unexpected error: %v, %v", err1, err2)
+	}
+	if len(c1.Cert.ID()) == 0 || c1.Cert.ID() != c2.Cert.ID() {
+		t.Errorf("unexpected keystore cert: %s, %s", c1.Cert.ID(), c2.Cert.ID())
+	}
+	if c1.ConnectType != owtp.HTTP || c1.TimeoutSEC != openwsdk.DefaultTimeoutSEC {
+		t.Errorf("unexpected defaults: %+v", c1)
+	}
+}
+
+func TestAPINodeConfig_Validate(t *testing.T) {
+	valid := func() *openwsdk.APINodeConfig {
+		return &openwsdk.APINodeConfig{
+			Host:        "127.0.0.1:8422",
+			AppID:       "app1",
+			AppKey:      "key",
+			ConnectType: owtp.HTTP,
+			Cert:        owtp.NewRandomCertificate(),
+			TimeoutSEC:  60,
+		}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Validate unexpected error: %v", err)
+	}
+
+	cases := map[string]func(c *openwsdk.APINodeConfig){
+		"empty host":       func(c *openwsdk.APINodeConfig) { c.Host = "" },
+		"host with scheme": func(c *openwsdk.APINodeConfig) { c.Host = "http://127.0.0.1:8422" },
+		"invalid port":     func(c *openwsdk.APINodeConfig) { c.Host = "127.0.0.1:99999" },
+		"connect type":     func(c *openwsdk.APINodeConfig) { c.ConnectType = "mq" },
+		"ssl and key agreement": func(c *openwsdk.APINodeConfig) {
+			c.EnableSSL, c.EnableKeyAgreement = true, true
+		},
+		"signature without cert": func(c *openwsdk.APINodeConfig) {
+			c.EnableSignature, c.Cert = true, owtp.Certificate{}
+		},
+		"timeout":     func(c *openwsdk.APINodeConfig) { c.TimeoutSEC = 0 },
+		"credentials": func(c *openwsdk.APINodeConfig) { c.AppKey = "" },
+	}
+	for name, modify := range cases {
+		c := valid()
+		modify(c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: Validate expected error", name)
+		}
+	}
+
+	c := valid()
+	c.Host = "bad://host"
+	if _, err := openwsdk.NewAPINodeWithError(c); err == nil || !strings.Contains(err.Error(), "host") {
+		t.Errorf("NewAPINodeWithError expected host error, got: %v", err)
+	}
+
+	//手工构造的配置只对不推荐的组合记录警告，也不修改调用方的配置
+	c = valid()
+	c.ConnectType, c.TimeoutSEC = "", 0
+	c.EnableSignature, c.Cert = true, owtp.Certificate{}
+	if api := openwsdk.NewAPINode(c); api == nil {
+		t.Errorf("NewAPINode expected node with warnings")
+	}
+	if len(c.ConnectType) > 0 || c.TimeoutSEC != 0 {
+		t.Errorf("NewAPINode modified caller config: %+v", c)
+	}
+}
@@ -0,0 +1,558 @@
+package openwsdk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/v2/log"
+)
+
+// ConfirmationState 被跟踪交易单的状态
+type ConfirmationState int
+
+const (
+	ConfirmationPending    ConfirmationState = iota + 1 //已提交，未上链
+	ConfirmationConfirming                              //已上链，未达到币种确认数
+	ConfirmationFinal                                   //已达到币种确认数
+	ConfirmationFailed                                  //链上执行失败
+	ConfirmationDropped                                 //超时未上链，或已从交易记录中消失
+)
+
+func (s ConfirmationState) String() string {
+	switch s {
+	case ConfirmationPending:
+		return "pending"
+	case ConfirmationConfirming:
+		return "confirming"
+	case ConfirmationFinal:
+		return "final"
+	case ConfirmationFailed:
+		return "failed"
+	case ConfirmationDropped:
+		return "dropped"
+	}
+	return fmt.Sprintf("ConfirmationState(%d)", int(s))
+}
+
+// Done 是否已是最终状态，不再跟踪
+func (s ConfirmationState) Done() bool {
+	return s == ConfirmationFinal || s == ConfirmationFailed || s == ConfirmationDropped
+}
+
+// TrackedTransaction 被跟踪的交易单
+type TrackedTransaction struct {
+	TxID          string            `json:"txid" storm:"id"`
+	Symbol        string            `json:"symbol" storm:"index"`
+	AccountID     string            `json:"accountID"`
+	WxID          string            `json:"wxid"`
+	State         ConfirmationState `json:"state" storm:"index"`
+	BlockHeight   uint64            `json:"blockHeight"`
+	BlockHash     string            `json:"blockHash"`
+	Confirmations uint64            `json:"confirmations"` //当前确认数
+	Required      uint64            `json:"required"`      //币种确认数，Symbol.Confirm
+	Milestone     uint64            `json:"milestone"`     //已回调的最高里程碑
+	Reason        string            `json:"reason"`        //失败或丢弃的原因
+	NotifyPending bool              `json:"notifyPending"` //最终状态已保存，OnFinal/OnFailed/OnDropped回调还未完成
+	TrackedAt     time.Time         `json:"trackedAt"`
+	UpdatedAt     time.Time         `json:"updatedAt"`
+}
+
+// ConfirmationConfig 确认跟踪配置
+type ConfirmationConfig struct {
+	Milestones   []uint64      //需要回调的确认数，例如1、3
+	PollInterval time.Duration //FindTradeLog轮询间隔，0表示30s
+	DropAfter    time.Duration //提交后超过该时间仍未上链视为丢弃，0表示1小时
+}
+
+// confirmationEvent 状态持久化后需要回调的事件
+type confirmationEvent struct {
+	tx        TrackedTransaction
+	milestone uint64
+}
+
+// ConfirmationTracker 跟踪提交或收到的交易单，直到达到币种确认数
+// 由subscribeToBlock的区块高度和FindTradeLog轮询驱动，状态保存在本地文件数据库，重启后继续跟踪
+type ConfirmationTracker struct {
+	mu                sync.Mutex
+	client            *APIClient
+	db                *storm.DB
+	config            ConfirmationConfig
+	tips              map[string]uint64 //symbol -> 最新高度
+	required          map[string]uint64 //symbol -> Symbol.Confirm
+	milestoneHandlers []func(ctx context.Context, tx *TrackedTransaction, milestone uint64)
+	finalHandlers     []func(ctx context.Context, tx *TrackedTransaction)
+	failedHandlers    []func(ctx context.Context, tx *TrackedTransaction)
+	droppedHandlers   []func(ctx context.Context, tx *TrackedTransaction)
+	emitting          map[string]bool //正在回调最终状态的交易单，Redeliver跳过
+	stop              chan struct{}
+}
+
+// OpenConfirmationTracker 打开确认跟踪器，path为状态文件路径
+func OpenConfirmationTracker(path string, client *APIClient, config *ConfirmationConfig) (*ConfirmationTracker, error) {
+	if client == nil {
+		return nil, fmt.Errorf("APIClient is not inited")
+	}
+	db, err := storm.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	ct := &ConfirmationTracker{
+		client:   client,
+		db:       db,
+		tips:     make(map[string]uint64),
+		required: make(map[string]uint64),
+		emitting: make(map[string]bool),
+	}
+	if config != nil {
+		ct.config = *config
+	}
+	if ct.config.PollInterval <= 0 {
+		ct.config.PollInterval = 30 * time.Second
+	}
+	if ct.config.DropAfter <= 0 {
+		ct.config.DropAfter = time.Hour
+	}
+	sort.Slice(ct.config.Milestones, func(i, j int) bool {
+		return ct.config.Milestones[i] < ct.config.Milestones[j]
+	})
+	return ct, nil
+}
+
+// Close 停止轮询，关闭状态文件
+func (ct *ConfirmationTracker) Close() error {
+	ct.mu.Lock()
+	if ct.stop != nil {
+		close(ct.stop)
+		ct.stop = nil
+	}
+	ct.mu.Unlock()
+	return ct.db.Close()
+}
+
+// OnMilestone 交易单确认数达到ConfirmationConfig.Milestones中的值时回调
+func (ct *ConfirmationTracker) OnMilestone(handler func(ctx context.Context, tx *TrackedTransaction, milestone uint64)) {
+	ct.mu.Lock()
+	ct.milestoneHandlers = append(ct.milestoneHandlers, handler)
+	ct.mu.Unlock()
+}
+
+// OnFinal 交易单达到币种确认数时回调
+func (ct *ConfirmationTracker) OnFinal(handler func(ctx context.Context, tx *TrackedTransaction)) {
+	ct.mu.Lock()
+	ct.finalHandlers = append(ct.finalHandlers, handler)
+	ct.mu.Unlock()
+}
+
+// OnFailed 交易单链上执行失败时回调，Transaction.Success为"0"
+func (ct *ConfirmationTracker) OnFailed(handler func(ctx context.Context, tx *TrackedTransaction)) {
+	ct.mu.Lock()
+	ct.failedHandlers = append(ct.failedHandlers, handler)
+	ct.mu.Unlock()
+}
+
+// OnDropped 交易单被丢弃时回调
+func (ct *ConfirmationTracker) OnDropped(handler func(ctx context.Context, tx *TrackedTransaction)) {
+	ct.mu.Lock()
+	ct.droppedHandlers = append(ct.droppedHandlers, handler)
+	ct.mu.Unlock()
+}
+
+// Attach 处理APINode的区块头和交易单通知
+func (ct *ConfirmationTracker) Attach(api *APINode) *HandlerRegistration {
+	return api.Handle("", func(ctx context.Context, n *Notification) error {
+		switch n.Method {
+		case SubscribeToBlock:
+			return ct.HandleBlock(ctx, n.BlockHeader)
+		case SubscribeToTrade:
+			return ct.HandleTransaction(ctx, n.Transaction)
+		}
+		return nil
+	})
+}
+
+// Track 开始跟踪交易单，已跟踪的交易单不重复添加
+func (ct *ConfirmationTracker) Track(ctx context.Context, symbol, txid, accountID string) error {
+	required, err := ct.symbolConfirm(ctx, symbol)
+	if err != nil {
+		return err
+	}
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	var exist TrackedTransaction
+	err = ct.db.One("TxID", txid, &exist)
+	if err == nil {
+		return nil
+	}
+	if err != storm.ErrNotFound {
+		return err
+	}
+	now := time.Now()
+	return ct.db.Save(&TrackedTransaction{
+		TxID:      txid,
+		Symbol:    symbol,
+		AccountID: accountID,
+		State:     ConfirmationPending,
+		Required:  required,
+		TrackedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+// TrackTransaction 跟踪SubmitTrade返回或通知收到的交易单
+func (ct *ConfirmationTracker) TrackTransaction(ctx context.Context, tx *Transaction) error {
+	if err := ct.Track(ctx, tx.Symbol, tx.TxID, tx.AccountID); err != nil {
+		return err
+	}
+	return ct.HandleTransaction(ctx, tx)
+}
+
+// Untrack 停止跟踪交易单
+func (ct *ConfirmationTracker) Untrack(txid string) error {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	err := ct.db.DeleteStruct(&TrackedTransaction{TxID: txid})
+	if err == storm.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// Get 查询被跟踪的交易单
+func (ct *ConfirmationTracker) Get(txid string) (*TrackedTransaction, error) {
+	var tx TrackedTransaction
+	if err := ct.db.One("TxID", txid, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// Tracking 未到最终状态的交易单
+func (ct *ConfirmationTracker) Tracking() ([]*TrackedTransaction, error) {
+	txs := make([]*TrackedTransaction, 0)
+	err := ct.db.Select(q.In("State", []ConfirmationState{ConfirmationPending, ConfirmationConfirming})).
+		OrderBy("TrackedAt").Find(&txs)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// HandleBlock 根据新区块高度更新已上链交易单的确认数
+func (ct *ConfirmationTracker) HandleBlock(ctx context.Context, header *BlockHeader) error {
+	if header == nil {
+		return nil
+	}
+	ct.mu.Lock()
+	if header.Height > ct.tips[header.Symbol] || header.Fork {
+		ct.tips[header.Symbol] = header.Height
+	}
+	txs := make([]*TrackedTransaction, 0)
+	err := ct.db.Select(q.Eq("Symbol", header.Symbol), q.Eq("State", ConfirmationConfirming)).Find(&txs)
+	if err != nil && err != storm.ErrNotFound {
+		ct.mu.Unlock()
+		return err
+	}
+	events := make([]*confirmationEvent, 0)
+	for _, tx := range txs {
+		evs, err := ct.update(tx, nil)
+		if err != nil {
+			ct.mu.Unlock()
+			return err
+		}
+		events = append(events, evs...)
+	}
+	ct.mu.Unlock()
+
+	ct.emit(ctx, events)
+	return nil
+}
+
+// HandleTransaction 根据交易单通知或查询结果更新状态，未跟踪的交易单忽略
+func (ct *ConfirmationTracker) HandleTransaction(ctx context.Context, tx *Transaction) error {
+	if tx == nil {
+		return nil
+	}
+	ct.mu.Lock()
+	var tracked TrackedTransaction
+	err := ct.db.One("TxID", tx.TxID, &tracked)
+	if err == storm.ErrNotFound {
+		ct.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		ct.mu.Unlock()
+		return err
+	}
+	events, err := ct.update(&tracked, tx)
+	ct.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	ct.emit(ctx, events)
+	return nil
+}
+
+// Poll 通过FindTradeLog查询所有未到最终状态的交易单
+func (ct *ConfirmationTracker) Poll(ctx context.Context) error {
+	txs, err := ct.Tracking()
+	if err != nil {
+		return err
+	}
+	for _, tracked := range txs {
+		logs, err := ct.client.FindTradeLogByParams(ctx, map[string]interface{}{
+			"symbol": tracked.Symbol,
+			"txid":   tracked.TxID,
+		})
+		if err != nil {
+			return err
+		}
+		if len(logs) > 0 {
+			if err := ct.HandleTransaction(ctx, logs[0]); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ct.checkDropped(ctx, tracked.TxID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redeliver 重新回调已保存最终状态但回调未完成的交易单，例如回调过程中进程退出。
+// Start时自动调用，需要在注册回调之后执行。
+func (ct *ConfirmationTracker) Redeliver(ctx context.Context) error {
+	ct.mu.Lock()
+	txs := make([]*TrackedTransaction, 0)
+	err := ct.db.Select(q.Eq("NotifyPending", true)).OrderBy("UpdatedAt").Find(&txs)
+	if err != nil && err != storm.ErrNotFound {
+		ct.mu.Unlock()
+		return err
+	}
+	events := make([]*confirmationEvent, 0, len(txs))
+	for _, tx := range txs {
+		if ct.emitting[tx.TxID] {
+			continue
+		}
+		ct.emitting[tx.TxID] = true
+		events = append(events, &confirmationEvent{tx: *tx})
+	}
+	ct.mu.Unlock()
+
+	ct.emit(ctx, events)
+	return nil
+}
+
+// Start 先重新回调未完成的最终状态，再开始按PollInterval轮询，Close时停止
+func (ct *ConfirmationTracker) Start() {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if ct.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	ct.stop = stop
+	go func() {
+		if err := ct.Redeliver(context.Background()); err != nil {
+			log.Warningf("confirmation tracker redeliver failed, unexpected error: %v", err)
+		}
+		ticker := time.NewTicker(ct.config.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := ct.Poll(context.Background()); err != nil {
+					log.Warningf("confirmation tracker poll failed, unexpected error: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// checkDropped 查询不到的交易单，上链前超过DropAfter，或上链后消失，视为丢弃
+func (ct *ConfirmationTracker) checkDropped(ctx context.Context, txid string) error {
+	ct.mu.Lock()
+	var tracked TrackedTransaction
+	if err := ct.db.One("TxID", txid, &tracked); err != nil {
+		ct.mu.Unlock()
+		return err
+	}
+	if tracked.State.Done() ||
+		(tracked.State == ConfirmationPending && time.Since(tracked.TrackedAt) < ct.config.DropAfter) {
+		ct.mu.Unlock()
+		return nil
+	}
+	if tracked.State == ConfirmationPending {
+		tracked.Reason = "transaction not found on chain"
+	} else {
+		tracked.Reason = "transaction disappeared from trade log"
+	}
+	tracked.State = ConfirmationDropped
+	tracked.NotifyPending = true
+	tracked.UpdatedAt = time.Now()
+	err := ct.db.Save(&tracked)
+	if err == nil {
+		ct.emitting[tracked.TxID] = true
+	}
+	ct.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	ct.emit(ctx, []*confirmationEvent{{tx: tracked}})
+	return nil
+}
+
+// update 更新确认数和状态并保存，返回需要回调的事件，调用者需持有ct.mu
+func (ct *ConfirmationTracker) update(tracked *TrackedTransaction, tx *Transaction) ([]*confirmationEvent, error) {
+	if tracked.State.Done() {
+		return nil, nil
+	}
+	events := make([]*confirmationEvent, 0)
+	before := *tracked
+
+	if tx != nil {
+		if len(tx.WxID) > 0 {
+			tracked.WxID = tx.WxID
+		}
+		if tx.BlockHeight > 0 {
+			if tracked.BlockHash != tx.BlockHash {
+				//重新打包到其他区块，按新区块计算确认数
+				tracked.Confirmations = 0
+			}
+			tracked.BlockHeight = uint64(tx.BlockHeight)
+			tracked.BlockHash = tx.BlockHash
+			tracked.State = ConfirmationConfirming
+			if tx.Confirm > 0 && uint64(tx.Confirm) > tracked.Confirmations {
+				tracked.Confirmations = uint64(tx.Confirm)
+			}
+		}
+		if tx.Success == "0" {
+			tracked.State = ConfirmationFailed
+			tracked.Reason = "transaction failed on chain"
+		}
+	}
+
+	if tracked.State == ConfirmationConfirming {
+		if tip := ct.tips[tracked.Symbol]; tip >= tracked.BlockHeight {
+			if confirmations := tip - tracked.BlockHeight + 1; confirmations > tracked.Confirmations {
+				tracked.Confirmations = confirmations
+			}
+		}
+		for _, milestone := range ct.config.Milestones {
+			if milestone > tracked.Milestone && milestone <= tracked.Confirmations && milestone < tracked.Required {
+				tracked.Milestone = milestone
+				events = append(events, &confirmationEvent{tx: *tracked, milestone: milestone})
+			}
+		}
+		if tracked.Confirmations >= tracked.Required {
+			tracked.State = ConfirmationFinal
+		}
+	}
+
+	if *tracked == before {
+		return nil, nil
+	}
+	tracked.UpdatedAt = time.Now()
+	if tracked.State.Done() {
+		//与最终状态一起保存，回调完成后清除，重启后由Redeliver补发
+		tracked.NotifyPending = true
+	}
+	if err := ct.db.Save(tracked); err != nil {
+		return nil, err
+	}
+	if tracked.State.Done() {
+		ct.emitting[tracked.TxID] = true
+		events = append(events, &confirmationEvent{tx: *tracked})
+	}
+	return events, nil
+}
+
+// emit 状态已保存后回调，回调中可以调用ConfirmationTracker的方法。
+// 最终状态的回调全部返回后才清除NotifyPending。
+func (ct *ConfirmationTracker) emit(ctx context.Context, events []*confirmationEvent) {
+	if len(events) == 0 {
+		return
+	}
+	ct.mu.Lock()
+	milestoneHandlers := ct.milestoneHandlers
+	finalHandlers := ct.finalHandlers
+	failedHandlers := ct.failedHandlers
+	droppedHandlers := ct.droppedHandlers
+	ct.mu.Unlock()
+
+	for _, event := range events {
+		tx := event.tx
+		if event.milestone > 0 {
+			for _, h := range milestoneHandlers {
+				h(ctx, &tx, event.milestone)
+			}
+			continue
+		}
+		var handlers []func(ctx context.Context, tx *TrackedTransaction)
+		switch tx.State {
+		case ConfirmationFinal:
+			handlers = finalHandlers
+		case ConfirmationFailed:
+			handlers = failedHandlers
+		case ConfirmationDropped:
+			handlers = droppedHandlers
+		}
+		for _, h := range handlers {
+			h(ctx, &tx)
+		}
+		if err := ct.notified(tx.TxID); err != nil {
+			log.Warningf("confirmation tracker %s: save notified state failed, unexpected error: %v", tx.TxID, err)
+		}
+	}
+}
+
+// notified 最终状态的回调已完成
+func (ct *ConfirmationTracker) notified(txid string) error {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	delete(ct.emitting, txid)
+	var tracked TrackedTransaction
+	err := ct.db.One("TxID", txid, &tracked)
+	if err == storm.ErrNotFound {
+		//回调中已Untrack
+		return nil
+	}
+	if err != nil || !tracked.NotifyPending {
+		return err
+	}
+	tracked.NotifyPending = false
+	return ct.db.Save(&tracked)
+}
+
+// symbolConfirm 币种确认数，从openw-server查询后缓存，未设置时为1
+func (ct *ConfirmationTracker) symbolConfirm(ctx context.Context, symbol string) (uint64, error) {
+	ct.mu.Lock()
+	required, ok := ct.required[symbol]
+	ct.mu.Unlock()
+	if ok {
+		return required, nil
+	}
+	symbols, err := ct.client.GetSymbolList(ctx, symbol, 0, 1, 0)
+	if err != nil {
+		return 0, err
+	}
+	required = 1
+	for _, s := range symbols {
+		if s.Symbol == symbol && s.Confirm > 0 {
+			required = uint64(s.Confirm)
+		}
+	}
+	ct.mu.Lock()
+	ct.required[symbol] = required
+	ct.mu.Unlock()
+	return required, nil
+}
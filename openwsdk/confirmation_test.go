@@ -0,0 +1,165 @@
+package openwsdk_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+)
+
+func TestConfirmationTracker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confirmation")
+	if err != nil {
+		t.Fatalf("TempDir unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "confirmation.db")
+
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+	s.AddSymbol(&openwsdk.Symbol{Symbol: "BTC", Confirm: 3, MaxHeight: 10})
+
+	api := testSubscribeAPINode(t, s, openwsdk.SubscribeToBlock)
+	defer api.Close()
+	client := openwsdk.NewAPIClient(api)
+
+	var events []string
+	config := &openwsdk.ConfirmationConfig{Milestones: []uint64{2, 1}, DropAfter: time.Millisecond}
+	open := func() (*openwsdk.ConfirmationTracker, *openwsdk.HandlerRegistration) {
+		tracker, err := openwsdk.OpenConfirmationTracker(path, client, config)
+		if err != nil {
+			t.Fatalf("OpenConfirmationTracker unexpected error: %v", err)
+		}
+		tracker.OnMilestone(func(ctx context.Context, tx *openwsdk.TrackedTransaction, milestone uint64) {
+			events = append(events, fmt.Sprintf("%s:milestone:%d", tx.TxID, milestone))
+		})
+		tracker.OnFinal(func(ctx context.Context, tx *openwsdk.TrackedTransaction) {
+			events = append(events, fmt.Sprintf("%s:final:%d", tx.TxID, tx.Confirmations))
+		})
+		tracker.OnFailed(func(ctx context.Context, tx *openwsdk.TrackedTransaction) {
+			events = append(events, tx.TxID+":failed")
+		})
+		tracker.OnDropped(func(ctx context.Context, tx *openwsdk.TrackedTransaction) {
+			events = append(events, tx.TxID+":dropped")
+		})
+		return tracker, tracker.Attach(api)
+	}
+
+	ctx := context.Background()
+	tracker, registration := open()
+	s.AddTransaction(&openwsdk.Transaction{TxID: "tx-1", Symbol: "BTC"})
+	if err := tracker.Track(ctx, "BTC", "tx-1", "A1"); err != nil {
+		t.Fatalf("Track unexpected error: %v", err)
+	}
+	s.PushBlock(s.MineBlock("BTC"))
+	if err := tracker.Poll(ctx); err != nil {
+		t.Fatalf("Poll unexpected error: %v", err)
+	}
+	s.PushBlock(s.MineBlock("BTC"))
+
+	tx, err := tracker.Get("tx-1")
+	if err != nil || tx.State != openwsdk.ConfirmationConfirming || tx.Confirmations != 2 || tx.Required != 3 {
+		t.Fatalf("Get unexpected result: %+v, %v", tx, err)
+	}
+
+	//重启后继续跟踪
+	registration.Unsubscribe()
+	tracker.Close()
+	tracker, _ = open()
+	defer tracker.Close()
+	s.PushBlock(s.MineBlock("BTC"))
+
+	tx, err = tracker.Get("tx-1")
+	if err != nil || tx.State != openwsdk.ConfirmationFinal || tx.BlockHeight != 11 {
+		t.Fatalf("Get unexpected result: %+v, %v", tx, err)
+	}
+
+	//链上失败和丢弃
+	failed := &openwsdk.Transaction{TxID: "tx-2", Symbol: "BTC", BlockHeight: 13, BlockHash: "BTC-13-0", Success: "0"}
+	if err := tracker.TrackTransaction(ctx, failed); err != nil {
+		t.Fatalf("TrackTransaction unexpected error: %v", err)
+	}
+	if err := tracker.Track(ctx, "BTC", "tx-3", "A1"); err != nil {
+		t.Fatalf("Track unexpected error: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := tracker.Poll(ctx); err != nil {
+		t.Fatalf("Poll unexpected error: %v", err)
+	}
+	tracking, err := tracker.Tracking()
+	if err != nil || len(tracking) != 0 {
+		t.Errorf("Tracking unexpected result: %v, %v", tracking, err)
+	}
+
+	want := []string{"tx-1:milestone:1", "tx-1:milestone:2", "tx-1:final:3", "tx-2:failed", "tx-3:dropped"}
+	if fmt.Sprint(events) != fmt.Sprint(want) {
+		t.Errorf("unexpected events: %v, want: %v", events, want)
+	}
+}
+
+func TestConfirmationTracker_Redeliver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confirmation")
+	if err != nil {
+		t.Fatalf("TempDir unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "confirmation.db")
+
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+	s.AddSymbol(&openwsdk.Symbol{Symbol: "BTC", Confirm: 1, MaxHeight: 10})
+	api := testSubscribeAPINode(t, s, openwsdk.SubscribeToBlock)
+	defer api.Close()
+	client := openwsdk.NewAPIClient(api)
+	ctx := context.Background()
+
+	//最终状态已保存，回调返回前进程退出
+	tracker, err := openwsdk.OpenConfirmationTracker(path, client, nil)
+	if err != nil {
+		t.Fatalf("OpenConfirmationTracker unexpected error: %v", err)
+	}
+	tracker.OnFinal(func(ctx context.Context, tx *openwsdk.TrackedTransaction) {
+		panic("crash")
+	})
+	func() {
+		defer func() { recover() }()
+		tracker.TrackTransaction(ctx, &openwsdk.Transaction{TxID: "tx-1", Symbol: "BTC", BlockHeight: 5, BlockHash: "h5", Confirm: 1})
+	}()
+	tracker.Close()
+
+	tracker, err = openwsdk.OpenConfirmationTracker(path, client, nil)
+	if err != nil {
+		t.Fatalf("OpenConfirmationTracker unexpected error: %v", err)
+	}
+	defer tracker.Close()
+	if tx, err := tracker.Get("tx-1"); err != nil || tx.State != openwsdk.ConfirmationFinal || !tx.NotifyPending {
+		t.Fatalf("Get unexpected result after restart: %+v, %v", tx, err)
+	}
+	finals := 0
+	tracker.OnFinal(func(ctx context.Context, tx *openwsdk.TrackedTransaction) {
+		finals++
+	})
+	for i := 0; i < 2; i++ {
+		if err := tracker.Redeliver(ctx); err != nil {
+			t.Fatalf("Redeliver unexpected error: %v", err)
+		}
+	}
+	if finals != 1 {
+		t.Errorf("OnFinal expected 1 call after restart, got: %d", finals)
+	}
+	if tx, err := tracker.Get("tx-1"); err != nil || tx.NotifyPending {
+		t.Errorf("Get expected notified transaction: %+v, %v", tx, err)
+	}
+}
@@ -0,0 +1,229 @@
+package openwsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SignatureConflict 同一签名位置收到了不同的签名
+type SignatureConflict struct {
+	AccountID  string
+	InputIndex uint32
+	Existing   string
+	Incoming   string
+}
+
+// ConflictError 合并时发现签名冲突，冲突位置保留字典序较小的签名，其余签名照常合并
+type ConflictError struct {
+	Conflicts []*SignatureConflict
+}
+
+// Error 实现error接口
+func (e *ConflictError) Error() string {
+	parts := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		parts = append(parts, fmt.Sprintf("%s input %d", c.AccountID, c.InputIndex))
+	}
+	return "conflicting signatures: " + strings.Join(parts, ", ")
+}
+
+// CoSigningStatus 多签收集进度
+type CoSigningStatus struct {
+	Required uint64   //必要签名数
+	Signed   []string //已完成签名的拥有者accountID
+	Pending  []string //未完成签名的拥有者accountID
+	Complete bool     //已满足必要签名数，可以SubmitTrade
+}
+
+// CoSigningSession 多签账户的签名收集，合并各签名方返回的部分签名交易单。
+// 同一签名位置收到不同的签名时保留字典序较小的一个并返回*ConflictError，合并结果与顺序无关。
+// 未设置校验时伪造的签名也可能被保留，应通过SetVerifier校验。
+type CoSigningSession struct {
+	mu       sync.Mutex
+	rawTx    *RawTransaction
+	unsigned []byte //去除签名后的交易单，用于确认是同一笔交易
+	resolver PubKeyResolver
+}
+
+// NewCoSigningSession 从createTrade返回的未签名交易单开始收集签名
+func NewCoSigningSession(rawTx *RawTransaction) (*CoSigningSession, error) {
+	if rawTx == nil || len(rawTx.Signatures) == 0 {
+		return nil, fmt.Errorf("raw transaction has no signatures to collect")
+	}
+	cp, err := copyRawTransaction(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	unsigned, err := unsignedRawTransaction(cp)
+	if err != nil {
+		return nil, err
+	}
+	return &CoSigningSession{rawTx: cp, unsigned: unsigned}, nil
+}
+
+// SetVerifier 合并前用VerifyKeySignature校验收到的签名
+func (s *CoSigningSession) SetVerifier(resolver PubKeyResolver) {
+	s.mu.Lock()
+	s.resolver = resolver
+	s.mu.Unlock()
+}
+
+// Merge 合并部分签名交易单，交易内容不一致或校验失败时不做任何修改，
+// 签名冲突时返回合并后的进度和*ConflictError
+func (s *CoSigningSession) Merge(partial *RawTransaction) (*CoSigningStatus, error) {
+	if partial == nil {
+		return nil, fmt.Errorf("raw transaction is nil")
+	}
+	unsigned, err := unsignedRawTransaction(partial)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !bytes.Equal(unsigned, s.unsigned) {
+		return nil, fmt.Errorf("partially signed transaction does not match the session transaction")
+	}
+
+	type update struct {
+		target    *KeySignature
+		signature string
+	}
+	var (
+		updates   []update
+		conflicts []*SignatureConflict
+	)
+	for _, accountID := range sortedOwners(partial.Signatures) {
+		for i, incoming := range partial.Signatures[accountID] {
+			if incoming == nil || len(incoming.Signature) == 0 {
+				continue
+			}
+			existing := s.rawTx.Signatures[accountID][i]
+			if existing.Signature == incoming.Signature {
+				continue
+			}
+			if s.resolver != nil {
+				account, err := s.resolver(accountID)
+				if err != nil {
+					return nil, err
+				}
+				if err := VerifyKeySignature(account, incoming); err != nil {
+					return nil, err
+				}
+			}
+			if len(existing.Signature) > 0 {
+				conflicts = append(conflicts, &SignatureConflict{
+					AccountID:  accountID,
+					InputIndex: existing.InputIndex,
+					Existing:   existing.Signature,
+					Incoming:   incoming.Signature,
+				})
+				//保留较小的签名，合并结果与顺序无关
+				if incoming.Signature > existing.Signature {
+					continue
+				}
+			}
+			updates = append(updates, update{target: existing, signature: incoming.Signature})
+		}
+	}
+	for _, u := range updates {
+		u.target.Signature = u.signature
+	}
+	if len(conflicts) > 0 {
+		return s.status(), &ConflictError{Conflicts: conflicts}
+	}
+	return s.status(), nil
+}
+
+// MergeEnvelope 合并离线签名文件中的部分签名交易单
+func (s *CoSigningSession) MergeEnvelope(e *OfflineEnvelope) (*CoSigningStatus, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	rawTx, err := e.RawTransaction()
+	if err != nil {
+		return nil, err
+	}
+	return s.Merge(rawTx)
+}
+
+// Status 当前进度
+func (s *CoSigningSession) Status() *CoSigningStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status()
+}
+
+// RawTransaction 当前合并结果的副本，Complete后用于SubmitTrade
+func (s *CoSigningSession) RawTransaction() *RawTransaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, _ := copyRawTransaction(s.rawTx)
+	return cp
+}
+
+func (s *CoSigningSession) status() *CoSigningStatus {
+	status := &CoSigningStatus{Required: s.rawTx.Required}
+	for _, accountID := range sortedOwners(s.rawTx.Signatures) {
+		signed := true
+		for _, ks := range s.rawTx.Signatures[accountID] {
+			if ks == nil || len(ks.Signature) == 0 {
+				signed = false
+				break
+			}
+		}
+		if signed {
+			status.Signed = append(status.Signed, accountID)
+		} else {
+			status.Pending = append(status.Pending, accountID)
+		}
+	}
+	//未指定必要签名数时需要所有拥有者签名
+	if status.Required == 0 {
+		status.Required = uint64(len(s.rawTx.Signatures))
+	}
+	status.Complete = uint64(len(status.Signed)) >= status.Required
+	return status
+}
+
+func sortedOwners(signatures map[string][]*KeySignature) []string {
+	owners := make([]string, 0, len(signatures))
+	for accountID := range signatures {
+		owners = append(owners, accountID)
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+func copyRawTransaction(rawTx *RawTransaction) (*RawTransaction, error) {
+	data, err := json.Marshal(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	var cp RawTransaction
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// unsignedRawTransaction 清空签名后序列化，签名位置的结构保留
+func unsignedRawTransaction(rawTx *RawTransaction) ([]byte, error) {
+	cp, err := copyRawTransaction(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	for _, keySignatures := range cp.Signatures {
+		for _, ks := range keySignatures {
+			if ks != nil {
+				ks.Signature = ""
+			}
+		}
+	}
+	return json.Marshal(cp)
+}
@@ -0,0 +1,138 @@
+package openwsdk_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+)
+
+func TestCoSigningSession(t *testing.T) {
+	owners := []string{"A1", "A2", "A3"}
+	keys := make(map[string]*hdkeystore.HDKey)
+	var accounts []*openwsdk.Account
+	unsigned := &openwsdk.RawTransaction{
+		Coin:       openwsdk.Coin{Symbol: "BTC"},
+		AccountID:  "M1",
+		To:         map[string]string{"addr-1": "1"},
+		Required:   2,
+		Signatures: make(map[string][]*openwsdk.KeySignature),
+	}
+	for _, owner := range owners {
+		key := testHDKey(t)
+		keys[owner] = key
+		accountKey, err := key.DerivedKeyWithPath("m/44'/88'/0'", owcrypt.ECC_CURVE_SECP256K1)
+		if err != nil {
+			t.Fatalf("DerivedKeyWithPath unexpected error: %v", err)
+		}
+		accounts = append(accounts, &openwsdk.Account{AccountID: owner, PublicKey: accountKey.GetPublicKey().OWEncode(), HdPath: "m/44'/88'/0'"})
+		for i := uint32(0); i < 2; i++ {
+			ks := testKeySignature(key)
+			ks.InputIndex = i
+			unsigned.Signatures[owner] = append(unsigned.Signatures[owner], ks)
+		}
+	}
+
+	//每个签名方只签自己的部分
+	partial := func(owner string) *openwsdk.RawTransaction {
+		data, _ := json.Marshal(unsigned)
+		var rawTx openwsdk.RawTransaction
+		json.Unmarshal(data, &rawTx)
+		signatures := map[string][]*openwsdk.KeySignature{owner: rawTx.Signatures[owner]}
+		if _, err := openwsdk.SignTxHash(signatures, keys[owner]); err != nil {
+			t.Fatalf("SignTxHash unexpected error: %v", err)
+		}
+		return &rawTx
+	}
+	p1, p2, p3 := partial("A1"), partial("A2"), partial("A3")
+
+	merge := func(s *openwsdk.CoSigningSession, parts ...*openwsdk.RawTransaction) *openwsdk.CoSigningStatus {
+		var status *openwsdk.CoSigningStatus
+		for _, p := range parts {
+			var err error
+			if status, err = s.Merge(p); err != nil {
+				t.Fatalf("Merge unexpected error: %v", err)
+			}
+		}
+		return status
+	}
+
+	s1, err := openwsdk.NewCoSigningSession(unsigned)
+	if err != nil {
+		t.Fatalf("NewCoSigningSession unexpected error: %v", err)
+	}
+	s1.SetVerifier(openwsdk.AccountsResolver(accounts...))
+	status := merge(s1, p1)
+	if status.Complete || !reflect.DeepEqual(status.Signed, []string{"A1"}) || !reflect.DeepEqual(status.Pending, []string{"A2", "A3"}) {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	status = merge(s1, p3, p1)
+	if !status.Complete || status.Required != 2 || !reflect.DeepEqual(status.Pending, []string{"A2"}) {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	//没有冲突时合并顺序不影响结果
+	s2, _ := openwsdk.NewCoSigningSession(unsigned)
+	merge(s2, p3, p1)
+	if !reflect.DeepEqual(s1.RawTransaction(), s2.RawTransaction()) {
+		t.Errorf("merge result depends on order")
+	}
+	if unsigned.Signatures["A1"][0].Signature != "" {
+		t.Errorf("session modified the input transaction")
+	}
+
+	//同一位置的不同签名，两种合并顺序的结果相同
+	forged := partial("A2")
+	forged.Signatures["A1"][1].Signature = forged.Signatures["A2"][1].Signature
+	conflicted := func(first, second *openwsdk.RawTransaction) *openwsdk.RawTransaction {
+		s, _ := openwsdk.NewCoSigningSession(unsigned)
+		merge(s, first)
+		status, err := s.Merge(second)
+		conflict, ok := err.(*openwsdk.ConflictError)
+		if !ok || len(conflict.Conflicts) != 1 || conflict.Conflicts[0].AccountID != "A1" || conflict.Conflicts[0].InputIndex != 1 {
+			t.Fatalf("Merge expected conflict error, got: %v", err)
+		}
+		if status == nil || !reflect.DeepEqual(status.Signed, []string{"A1", "A2"}) {
+			t.Errorf("unexpected status after conflict: %+v", status)
+		}
+		return s.RawTransaction()
+	}
+	rawTx1, rawTx2 := conflicted(p1, forged), conflicted(forged, p1)
+	if !reflect.DeepEqual(rawTx1, rawTx2) {
+		t.Errorf("conflicting merge result depends on order")
+	}
+
+	//设置校验后伪造的签名不会被合并
+	if _, err := s1.Merge(forged); err == nil {
+		t.Errorf("Merge expected verify error for forged signature")
+	}
+	if status := s1.Status(); !reflect.DeepEqual(status.Pending, []string{"A2"}) {
+		t.Errorf("failed merge should not change the session: %+v", status)
+	}
+
+	//签名校验失败
+	bad := partial("A2")
+	bad.Signatures["A2"][0].Signature = p1.Signatures["A1"][0].Signature
+	if _, err := s1.Merge(bad); err == nil {
+		t.Errorf("Merge expected verify error")
+	}
+
+	//不同的交易单
+	other := partial("A2")
+	other.To["addr-1"] = "2"
+	if _, err := s1.Merge(other); err == nil {
+		t.Errorf("Merge expected mismatch error")
+	}
+
+	envelope, _ := openwsdk.NewRawTransactionEnvelope(p2)
+	status, err = s1.MergeEnvelope(envelope)
+	if err != nil || len(status.Signed) != 3 {
+		t.Errorf("MergeEnvelope unexpected result: %+v, %v", status, err)
+	}
+	if err := openwsdk.VerifyRawTransactionSignatures(s1.RawTransaction(), openwsdk.AccountsResolver(accounts...)); err != nil {
+		t.Errorf("VerifyRawTransactionSignatures unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,561 @@
+package openwsdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/owtp"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// DefaultKeyGracePeriod 密钥轮换后旧密钥的默认宽限期
+	DefaultKeyGracePeriod = 10 * time.Minute
+	// DefaultCredentialEnvPrefix EnvCredentials默认的环境变量前缀
+	DefaultCredentialEnvPrefix = "OPENW_"
+)
+
+// Credentials 应用授权凭证
+type Credentials struct {
+	AppID  string `json:"appID" yaml:"appID"`
+	AppKey string `json:"appKey" yaml:"appKey"`
+}
+
+// CredentialProvider 凭证提供者，appID为空时返回默认应用的凭证
+type CredentialProvider interface {
+	Credentials(appID string) (*Credentials, error)
+}
+
+// CredentialFunc 回调形式的凭证提供者
+type CredentialFunc func(appID string) (*Credentials, error)
+
+// Credentials 实现CredentialProvider接口
+func (f CredentialFunc) Credentials(appID string) (*Credentials, error) {
+	return f(appID)
+}
+
+// staticCredentials 固定的凭证
+type staticCredentials struct {
+	defaultApp string
+	apps       map[string]string
+}
+
+// StaticCredentials 固定的凭证，第一个为默认应用
+func StaticCredentials(creds ...*Credentials) CredentialProvider {
+	p := &staticCredentials{apps: make(map[string]string)}
+	for _, c := range creds {
+		if len(p.defaultApp) == 0 {
+			p.defaultApp = c.AppID
+		}
+		p.apps[c.AppID] = c.AppKey
+	}
+	return p
+}
+
+// Credentials 实现CredentialProvider接口
+func (p *staticCredentials) Credentials(appID string) (*Credentials, error) {
+	return lookupCredentials(p.defaultApp, p.apps, appID)
+}
+
+// envCredentials 从环境变量读取凭证
+type envCredentials struct {
+	prefix string
+}
+
+// EnvCredentials 每次从环境变量读取凭证，prefix为空时使用DefaultCredentialEnvPrefix。
+// 默认应用读取<prefix>APPID和<prefix>APPKEY，其他应用读取<prefix>APPKEY_<APPID>，
+// appID转为大写，字母数字以外的字符替换为下划线
+func EnvCredentials(prefix string) CredentialProvider {
+	if len(prefix) == 0 {
+		prefix = DefaultCredentialEnvPrefix
+	}
+	return &envCredentials{prefix: prefix}
+}
+
+// Credentials 实现CredentialProvider接口
+func (p *envCredentials) Credentials(appID string) (*Credentials, error) {
+	defaultApp := os.Getenv(p.prefix + "APPID")
+	if len(appID) == 0 || appID == defaultApp {
+		appKey := os.Getenv(p.prefix + "APPKEY")
+		if len(defaultApp) == 0 || len(appKey) == 0 {
+			return nil, fmt.Errorf("environment variables %sAPPID and %sAPPKEY are not set", p.prefix, p.prefix)
+		}
+		return &Credentials{AppID: defaultApp, AppKey: appKey}, nil
+	}
+	name := p.prefix + "APPKEY_" + envName(appID)
+	appKey := os.Getenv(name)
+	if len(appKey) == 0 {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return &Credentials{AppID: appID, AppKey: appKey}, nil
+}
+
+func envName(appID string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, appID)
+}
+
+// credentialFile 凭证文件格式
+type credentialFile struct {
+	Default string            `json:"default" yaml:"default"` //默认应用，为空时只能有一个应用
+	Apps    map[string]string `json:"apps" yaml:"apps"`       //appID -> appKey
+}
+
+// FileCredentials 从文件读取的凭证，文件修改后自动重新加载
+type FileCredentials struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	file    *credentialFile
+}
+
+// NewFileCredentials 从文件读取凭证，.yaml/.yml按YAML解析，其余按JSON解析
+func NewFileCredentials(path string) (*FileCredentials, error) {
+	p := &FileCredentials{path: path}
+	if _, err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Credentials 实现CredentialProvider接口，文件修改时间或大小变化时重新加载
+func (p *FileCredentials) Credentials(appID string) (*Credentials, error) {
+	file, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	return lookupCredentials(file.Default, file.Apps, appID)
+}
+
+func (p *FileCredentials) load() (*credentialFile, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return nil, err
+	}
+	if p.file != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return p.file, nil
+	}
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+	var file credentialFile
+	switch strings.ToLower(filepath.Ext(p.path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse credentials %s failed: %v", p.path, err)
+	}
+	if len(file.Default) == 0 && len(file.Apps) == 1 {
+		for appID := range file.Apps {
+			file.Default = appID
+		}
+	}
+	p.file, p.modTime, p.size = &file, info.ModTime(), info.Size()
+	return p.file, nil
+}
+
+func lookupCredentials(defaultApp string, apps map[string]string, appID string) (*Credentials, error) {
+	if len(appID) == 0 {
+		appID = defaultApp
+	}
+	appKey, ok := apps[appID]
+	if !ok || len(appID) == 0 {
+		return nil, fmt.Errorf("credentials of app %q not found", appID)
+	}
+	return &Credentials{AppID: appID, AppKey: appKey}, nil
+}
+
+// appCredential 应用当前使用的密钥，以及轮换前的旧密钥
+type appCredential struct {
+	current   *RequestSigner
+	key       string
+	previous  *RequestSigner
+	rotatedAt time.Time
+	bound     bool //设备已绑定，重连和轮换后需要重新绑定
+}
+
+// credentialStore APINode使用的各应用凭证
+type credentialStore struct {
+	mu         sync.RWMutex
+	provider   CredentialProvider
+	defaultApp string
+	grace      time.Duration
+	clock      Clock
+	apps       map[string]*appCredential
+	watchStop  chan struct{}
+}
+
+func newCredentialStore(config *APINodeConfig) (*credentialStore, error) {
+	provider := config.Credentials
+	if provider == nil {
+		provider = StaticCredentials(&Credentials{AppID: config.AppID, AppKey: config.AppKey})
+	}
+	s := &credentialStore{
+		provider: provider,
+		grace:    DefaultKeyGracePeriod,
+		clock:    SystemClock,
+		apps:     make(map[string]*appCredential),
+	}
+	if config.KeyGraceSEC > 0 {
+		s.grace = time.Duration(config.KeyGraceSEC) * time.Second
+	}
+	c, err := s.get(config.AppID)
+	if err != nil {
+		return nil, err
+	}
+	s.defaultApp = c.current.appID
+	return s, nil
+}
+
+// get 应用的凭证，首次使用时从provider获取
+func (s *credentialStore) get(appID string) (*appCredential, error) {
+	s.mu.RLock()
+	if len(appID) == 0 {
+		appID = s.defaultApp
+	}
+	c, ok := s.apps[appID]
+	s.mu.RUnlock()
+	if ok {
+		return c, nil
+	}
+
+	creds, err := s.provider.Credentials(appID)
+	if err != nil {
+		return nil, err
+	}
+	if len(creds.AppID) == 0 {
+		creds.AppID = appID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.apps[creds.AppID]; ok {
+		return c, nil
+	}
+	signer := NewRequestSigner(creds.AppID, creds.AppKey)
+	signer.SetClock(s.clock)
+	c = &appCredential{current: signer, key: creds.AppKey}
+	s.apps[creds.AppID] = c
+	return c, nil
+}
+
+// signers 当前密钥的签名者，宽限期内再加上旧密钥的签名者
+func (s *credentialStore) signers(appID string) ([]*RequestSigner, error) {
+	c, err := s.get(appID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	signers := []*RequestSigner{c.current}
+	if c.previous != nil && s.clock.Now().Sub(c.rotatedAt) < s.grace {
+		signers = append(signers, c.previous)
+	}
+	return signers, nil
+}
+
+// rotate 更换应用密钥，密钥未变化时返回false
+func (s *credentialStore) rotate(appID, appKey string) (bool, error) {
+	c, err := s.get(appID)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.key == appKey {
+		return false, nil
+	}
+	c.previous = c.current
+	c.current = c.current.withAppKey(appKey)
+	c.key = appKey
+	c.rotatedAt = s.clock.Now()
+	return true, nil
+}
+
+func (s *credentialStore) setBound(appID string, bound bool) {
+	c, err := s.get(appID)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	c.bound = bound
+	s.mu.Unlock()
+}
+
+func (s *credentialStore) isBound(appID string) bool {
+	c, err := s.get(appID)
+	if err != nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return c.bound
+}
+
+// appIDs 已使用的应用，默认应用在前
+func (s *credentialStore) appIDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	appIDs := []string{s.defaultApp}
+	for appID := range s.apps {
+		if appID != s.defaultApp {
+			appIDs = append(appIDs, appID)
+		}
+	}
+	return appIDs
+}
+
+func (s *credentialStore) setClock(clock Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = clock
+	for _, c := range s.apps {
+		c.current.SetClock(clock)
+		if c.previous != nil {
+			c.previous.SetClock(clock)
+		}
+	}
+}
+
+// root 创建WithAppID节点的原节点
+func (api *APINode) root() *APINode {
+	if api.parent != nil {
+		return api.parent
+	}
+	return api
+}
+
+// AppID 发起请求使用的应用
+func (api *APINode) AppID() string {
+	if api == nil {
+		return ""
+	}
+	if len(api.appID) > 0 {
+		return api.appID
+	}
+	api.creds.mu.RLock()
+	defer api.creds.mu.RUnlock()
+	return api.creds.defaultApp
+}
+
+// Credentials 当前使用的应用凭证
+func (api *APINode) Credentials() (*Credentials, error) {
+	if api == nil {
+		return nil, fmt.Errorf("APINode is not inited")
+	}
+	c, err := api.creds.get(api.AppID())
+	if err != nil {
+		return nil, err
+	}
+	api.creds.mu.RLock()
+	defer api.creds.mu.RUnlock()
+	return &Credentials{AppID: c.current.appID, AppKey: c.key}, nil
+}
+
+// WithAppID 使用指定应用发起请求的APINode，与原节点共用连接和重连。
+// 返回的节点只用于发起请求，调用业务方法前需要先BindAppDevice；
+// 通知处理和订阅重放仍由原节点管理，Close对返回的节点无效。
+func (api *APINode) WithAppID(appID string) (*APINode, error) {
+	if api == nil {
+		return nil, fmt.Errorf("APINode is not inited")
+	}
+	if len(appID) == 0 {
+		return nil, fmt.Errorf("appID is empty")
+	}
+	if _, err := api.creds.get(appID); err != nil {
+		return nil, err
+	}
+	root := api.root()
+	return &APINode{
+		node:        root.node,
+		config:      root.config,
+		observers:   make(map[OpenwNotificationObject]*HandlerRegistration),
+		reconnector: root.reconnector,
+		creds:       root.creds,
+		appID:       appID,
+		parent:      root,
+	}, nil
+}
+
+// withContext 绑定ctx发起请求的APINode，与WithAppID一样共用原节点的连接和凭证，只用于发起请求
+func (api *APINode) withContext(ctx context.Context) *APINode {
+	root := api.root()
+	return &APINode{
+		node:        root.node,
+		config:      root.config,
+		observers:   make(map[OpenwNotificationObject]*HandlerRegistration),
+		reconnector: root.reconnector,
+		creds:       root.creds,
+		appID:       api.appID,
+		parent:      root,
+		ctx:         ctx,
+	}
+}
+
+// SetClock 设置请求时间戳和密钥宽限期使用的时钟
+func (api *APINode) SetClock(clock Clock) {
+	if api == nil {
+		return
+	}
+	api.creds.setClock(clock)
+}
+
+// RotateAppKey 轮换当前应用的密钥，不断开连接。
+// 设备已绑定时用新密钥重新绑定，宽限期内新密钥未通过授权会改用旧密钥。
+func (api *APINode) RotateAppKey(appKey string) error {
+	if api == nil {
+		return fmt.Errorf("APINode is not inited")
+	}
+	if len(appKey) == 0 {
+		return fmt.Errorf("appKey is empty")
+	}
+	appID := api.AppID()
+	changed, err := api.creds.rotate(appID, appKey)
+	if err != nil || !changed || !api.creds.isBound(appID) {
+		return err
+	}
+	return api.BindAppDevice()
+}
+
+// ReloadCredentials 从凭证提供者重新获取已使用应用的密钥，有变化的执行RotateAppKey
+func (api *APINode) ReloadCredentials() error {
+	if api == nil {
+		return fmt.Errorf("APINode is not inited")
+	}
+	var failed []string
+	for _, appID := range api.creds.appIDs() {
+		creds, err := api.creds.provider.Credentials(appID)
+		if err == nil {
+			err = api.appNode(appID).RotateAppKey(creds.AppKey)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", appID, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("reload credentials failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// WatchCredentials 按interval定时ReloadCredentials，Close或再次调用时停止之前的定时
+func (api *APINode) WatchCredentials(interval time.Duration) {
+	if api == nil {
+		return
+	}
+	s := api.creds
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watchStop != nil {
+		close(s.watchStop)
+		s.watchStop = nil
+	}
+	if interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	s.watchStop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := api.ReloadCredentials(); err != nil {
+					log.Warningf("%v", err)
+				}
+			}
+		}
+	}()
+}
+
+// appNode appID对应的请求节点，默认应用返回原节点
+func (api *APINode) appNode(appID string) *APINode {
+	root := api.root()
+	if appID == root.AppID() {
+		return root
+	}
+	node, err := root.WithAppID(appID)
+	if err != nil {
+		return root
+	}
+	return node
+}
+
+// bindAppDevices 重新绑定所有已绑定过的应用，默认应用总是绑定
+func (api *APINode) bindAppDevices() error {
+	for i, appID := range api.creds.appIDs() {
+		if i > 0 && !api.creds.isBound(appID) {
+			continue
+		}
+		if err := api.appNode(appID).BindAppDevice(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callSigned 发起HmacSHA256签名的请求，fields为appID之后参与签名的字段值。
+// 密钥轮换后的宽限期内，新密钥未通过授权时使用旧密钥重试。
+func (api *APINode) callSigned(method string, buildParams func(req *SignedRequest) map[string]interface{}, fields ...string) (*owtp.Response, map[string]interface{}, error) {
+	signers, err := api.creds.signers(api.AppID())
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		response *owtp.Response
+		params   map[string]interface{}
+	)
+	for _, signer := range signers {
+		req, err := signer.Sign(fields...)
+		if err != nil {
+			return nil, nil, err
+		}
+		params = buildParams(req)
+		response, err = api.callSync(method, params)
+		if err != nil {
+			return nil, params, err
+		}
+		if response.Status != owtp.ErrUnauthorized {
+			break
+		}
+	}
+	return response, params, nil
+}
+
+// observeServerTime 服务端时间用于校正应用所有签名者的时间戳
+func (api *APINode) observeServerTime(serverTime time.Time) error {
+	signers, err := api.creds.signers(api.AppID())
+	if err != nil {
+		return err
+	}
+	for _, signer := range signers {
+		err = signer.ObserveServerTime(serverTime)
+	}
+	return err
+}
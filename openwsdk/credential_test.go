@@ -0,0 +1,198 @@
+package openwsdk_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func TestCredentialProviders(t *testing.T) {
+	static := openwsdk.StaticCredentials(
+		&openwsdk.Credentials{AppID: "app1", AppKey: "key1"},
+		&openwsdk.Credentials{AppID: "app2", AppKey: "key2"},
+	)
+	if c, err := static.Credentials(""); err != nil || c.AppID != "app1" || c.AppKey != "key1" {
+		t.Errorf("unexpected default credentials: %+v, %v", c, err)
+	}
+	if c, err := static.Credentials("app2"); err != nil || c.AppKey != "key2" {
+		t.Errorf("unexpected app2 credentials: %+v, %v", c, err)
+	}
+	if _, err := static.Credentials("app3"); err == nil {
+		t.Errorf("Credentials expected not found error")
+	}
+
+	os.Setenv("OPENWTEST_APPID", "app1")
+	os.Setenv("OPENWTEST_APPKEY", "env-key1")
+	os.Setenv("OPENWTEST_APPKEY_APP_2", "env-key2")
+	defer func() {
+		os.Unsetenv("OPENWTEST_APPID")
+		os.Unsetenv("OPENWTEST_APPKEY")
+		os.Unsetenv("OPENWTEST_APPKEY_APP_2")
+	}()
+	env := openwsdk.EnvCredentials("OPENWTEST_")
+	if c, err := env.Credentials(""); err != nil || c.AppID != "app1" || c.AppKey != "env-key1" {
+		t.Errorf("unexpected env default credentials: %+v, %v", c, err)
+	}
+	if c, err := env.Credentials("app-2"); err != nil || c.AppKey != "env-key2" {
+		t.Errorf("unexpected env app-2 credentials: %+v, %v", c, err)
+	}
+
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatalf("TempDir unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials.yaml")
+	ioutil.WriteFile(path, []byte("apps:\n  app1: file-key1\n"), 0600)
+	file, err := openwsdk.NewFileCredentials(path)
+	if err != nil {
+		t.Fatalf("NewFileCredentials unexpected error: %v", err)
+	}
+	if c, err := file.Credentials(""); err != nil || c.AppID != "app1" || c.AppKey != "file-key1" {
+		t.Errorf("unexpected file credentials: %+v, %v", c, err)
+	}
+	//文件修改后重新加载
+	ioutil.WriteFile(path, []byte("default: app1\napps:\n  app1: file-key1-rotated\n  app2: file-key2\n"), 0600)
+	if c, err := file.Credentials("app1"); err != nil || c.AppKey != "file-key1-rotated" {
+		t.Errorf("unexpected reloaded credentials: %+v, %v", c, err)
+	}
+
+	callback := openwsdk.CredentialFunc(func(appID string) (*openwsdk.Credentials, error) {
+		return &openwsdk.Credentials{AppID: appID, AppKey: "cb-" + appID}, nil
+	})
+	if c, _ := callback.Credentials("app9"); c.AppKey != "cb-app9" {
+		t.Errorf("unexpected callback credentials: %+v", c)
+	}
+}
+
+func TestAPINode_RotateAppKey(t *testing.T) {
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	config := s.APINodeConfig(owtp.Websocket)
+	config.KeyGraceSEC = 60
+	api, err := openwsdk.NewAPINodeWithError(config)
+	if err != nil {
+		t.Fatalf("NewAPINodeWithError unexpected error: %v", err)
+	}
+	defer api.Close()
+
+	var (
+		mu    sync.Mutex
+		shift time.Duration
+	)
+	api.SetClock(openwsdk.ClockFunc(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return time.Now().Add(shift)
+	}))
+	if err := api.BindAppDevice(); err != nil {
+		t.Fatalf("BindAppDevice unexpected error: %v", err)
+	}
+
+	//服务端还未更新密钥，宽限期内使用旧密钥绑定
+	if err := api.RotateAppKey("rotated-appkey"); err != nil {
+		t.Fatalf("RotateAppKey unexpected error: %v", err)
+	}
+	if c, _ := api.Credentials(); c.AppKey != "rotated-appkey" {
+		t.Errorf("unexpected credentials after rotation: %+v", c)
+	}
+	if _, _, err := api.GetNotifierNodeInfo(); err != nil {
+		t.Errorf("GetNotifierNodeInfo unexpected error in grace window: %v", err)
+	}
+
+	//宽限期后旧密钥失效
+	mu.Lock()
+	shift = 2 * time.Minute
+	mu.Unlock()
+	if err := api.BindAppDevice(); err == nil {
+		t.Errorf("BindAppDevice expected error after grace window")
+	}
+
+	s.AddApp(openwsdktest.DefaultAppID, "rotated-appkey")
+	if err := api.BindAppDevice(); err != nil {
+		t.Errorf("BindAppDevice unexpected error with rotated key: %v", err)
+	}
+}
+
+func TestAPINode_WithAppID(t *testing.T) {
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+	s.AddApp("app2", "app2-key")
+
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatalf("TempDir unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials.json")
+	ioutil.WriteFile(path, []byte(`{"default":"openwsdktest","apps":{"openwsdktest":"openwsdktest-appkey","app2":"app2-key"}}`), 0600)
+	provider, err := openwsdk.NewFileCredentials(path)
+	if err != nil {
+		t.Fatalf("NewFileCredentials unexpected error: %v", err)
+	}
+
+	config := s.APINodeConfig(owtp.Websocket)
+	config.AppID, config.AppKey = "", ""
+	config.Credentials = provider
+	api, err := openwsdk.NewAPINodeWithError(config)
+	if err != nil {
+		t.Fatalf("NewAPINodeWithError unexpected error: %v", err)
+	}
+	defer api.Close()
+	if err := api.BindAppDevice(); err != nil {
+		t.Fatalf("BindAppDevice unexpected error: %v", err)
+	}
+
+	app2, err := api.WithAppID("app2")
+	if err != nil {
+		t.Fatalf("WithAppID unexpected error: %v", err)
+	}
+	if _, err := api.WithAppID("app3"); err == nil {
+		t.Errorf("WithAppID expected error for unknown app")
+	}
+
+	client := openwsdk.NewAPIClient(app2)
+	if _, err := client.CreateWallet(context.Background(), &openwsdk.Wallet{WalletID: "W1"}); err == nil {
+		t.Errorf("CreateWallet expected error before app2 is bound")
+	}
+	if err := app2.BindAppDevice(); err != nil {
+		t.Fatalf("app2 BindAppDevice unexpected error: %v", err)
+	}
+	wallet, err := client.CreateWallet(context.Background(), &openwsdk.Wallet{WalletID: "W1"})
+	if err != nil || wallet.AppID != "app2" {
+		t.Fatalf("unexpected app2 wallet: %+v, %v", wallet, err)
+	}
+	wallet, err = openwsdk.NewAPIClient(api).CreateWallet(context.Background(), &openwsdk.Wallet{WalletID: "W2"})
+	if err != nil || wallet.AppID != openwsdktest.DefaultAppID {
+		t.Fatalf("unexpected default wallet: %+v, %v", wallet, err)
+	}
+
+	//凭证文件更新后重新绑定
+	s.AddApp("app2", "app2-key-rotated")
+	time.Sleep(10 * time.Millisecond)
+	ioutil.WriteFile(path, []byte(`{"default":"openwsdktest","apps":{"openwsdktest":"openwsdktest-appkey","app2":"app2-key-rotated"}}`), 0600)
+	if err := api.ReloadCredentials(); err != nil {
+		t.Fatalf("ReloadCredentials unexpected error: %v", err)
+	}
+	if c, _ := app2.Credentials(); c.AppKey != "app2-key-rotated" {
+		t.Errorf("unexpected app2 credentials: %+v", c)
+	}
+	if c, _ := api.Credentials(); c.AppID != openwsdktest.DefaultAppID || c.AppKey != openwsdktest.DefaultAppKey {
+		t.Errorf("unexpected default credentials: %+v", c)
+	}
+}
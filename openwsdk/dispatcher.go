@@ -0,0 +1,191 @@
+package openwsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/log"
+)
+
+// AcceptPolicy 根据各处理函数的结果决定是否回复openw-server已接收
+type AcceptPolicy int
+
+const (
+	AcceptAll     AcceptPolicy = iota //所有处理函数都接收
+	AcceptAny                         //至少一个处理函数接收
+	AcceptPrimary                     //由指定的主处理函数决定，主处理函数不处理该通知时按AcceptAll
+)
+
+func (p AcceptPolicy) String() string {
+	switch p {
+	case AcceptAll:
+		return "all"
+	case AcceptAny:
+		return "any"
+	case AcceptPrimary:
+		return "primary"
+	}
+	return fmt.Sprintf("AcceptPolicy(%d)", int(p))
+}
+
+// DispatchConfig 通知分发配置
+type DispatchConfig struct {
+	Concurrency int                          //同时执行的处理函数上限，所有通知共享，小于等于1表示按注册顺序逐个执行
+	Policy      AcceptPolicy                 //接收策略
+	Primary     *HandlerRegistration         //AcceptPrimary策略的主处理函数
+	Timeout     time.Duration                //单次分发的超时时间，通过ctx传给处理函数，0表示不限制
+	Report      func(result *DispatchResult) //每次分发完成后回调，用于记录各处理函数的结果
+}
+
+// HandlerOutcome 单个处理函数的处理结果
+type HandlerOutcome struct {
+	HandlerID uint64        //处理函数注册序号，见HandlerRegistration.ID
+	Accepted  bool          //是否接收
+	Err       error         //处理失败的原因，拒绝接收时为ErrNotificationRejected
+	Panicked  bool          //处理函数是否panic
+	Elapsed   time.Duration //处理耗时
+}
+
+// DispatchResult 一次通知分发的结果
+type DispatchResult struct {
+	Method   string
+	Accepted bool
+	Outcomes []*HandlerOutcome //按处理函数注册顺序排列
+}
+
+// Err 第一个处理失败的原因，拒绝接收不算作失败
+func (r *DispatchResult) Err() error {
+	for _, o := range r.Outcomes {
+		if o.Err != nil && !errors.Is(o.Err, ErrNotificationRejected) {
+			return o.Err
+		}
+	}
+	return nil
+}
+
+// SetDispatchConfig 设置通知分发配置
+// 所有处理函数都会被执行，是否接收由Policy决定，与注册顺序和执行顺序无关
+func (api *APINode) SetDispatchConfig(config *DispatchConfig) error {
+	if config == nil {
+		config = &DispatchConfig{}
+	}
+	if config.Policy == AcceptPrimary && config.Primary == nil {
+		return fmt.Errorf("primary handler is required by accept policy: %s", config.Policy)
+	}
+	c := *config
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.dispatchConfig = &c
+	if c.Concurrency > 1 {
+		api.dispatchSem = make(chan struct{}, c.Concurrency)
+	} else {
+		api.dispatchSem = nil
+	}
+	return nil
+}
+
+// ObserverRegistration AddObserver添加的观测者对应的注册句柄，用于指定主处理函数
+func (api *APINode) ObserverRegistration(obj OpenwNotificationObject) *HandlerRegistration {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.observers[obj]
+}
+
+// dispatch 把通知交给当前已注册的处理函数，并按接收策略汇总结果
+func (api *APINode) dispatch(n *Notification) *DispatchResult {
+	api.mu.RLock()
+	config := api.dispatchConfig
+	sem := api.dispatchSem
+	api.mu.RUnlock()
+	if config == nil {
+		config = &DispatchConfig{}
+	}
+
+	handlers := api.notificationHandlers(n.Method)
+	result := &DispatchResult{
+		Method:   n.Method,
+		Outcomes: make([]*HandlerOutcome, len(handlers)),
+	}
+
+	ctx := context.Background()
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	if sem == nil {
+		for i, h := range handlers {
+			result.Outcomes[i] = runHandler(ctx, h, n)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i, h := range handlers {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(i int, h *registeredHandler) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				result.Outcomes[i] = runHandler(ctx, h, n)
+			}(i, h)
+		}
+		wg.Wait()
+	}
+
+	result.Accepted = acceptOutcomes(config, result.Outcomes)
+	if config.Report != nil {
+		config.Report(result)
+	}
+	return result
+}
+
+// runHandler 执行处理函数，panic转为错误
+func runHandler(ctx context.Context, h *registeredHandler, n *Notification) (outcome *HandlerOutcome) {
+	outcome = &HandlerOutcome{HandlerID: h.id}
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("handle %s notify panic: %v\n%s", n.Method, r, debug.Stack())
+			outcome.Panicked = true
+			outcome.Err = fmt.Errorf("handle %s notify panic: %v", n.Method, r)
+		}
+		outcome.Accepted = outcome.Err == nil
+		outcome.Elapsed = time.Since(start)
+	}()
+	outcome.Err = h.handler(ctx, n)
+	return outcome
+}
+
+// acceptOutcomes 按接收策略决定是否接收，没有处理函数时不接收
+func acceptOutcomes(config *DispatchConfig, outcomes []*HandlerOutcome) bool {
+	if len(outcomes) == 0 {
+		return false
+	}
+	switch config.Policy {
+	case AcceptAny:
+		for _, o := range outcomes {
+			if o.Accepted {
+				return true
+			}
+		}
+		return false
+	case AcceptPrimary:
+		for _, o := range outcomes {
+			if o.HandlerID == config.Primary.ID() {
+				return o.Accepted
+			}
+		}
+	}
+	for _, o := range outcomes {
+		if !o.Accepted {
+			return false
+		}
+	}
+	return true
+}
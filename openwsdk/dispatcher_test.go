@@ -0,0 +1,130 @@
+package openwsdk_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+)
+
+func TestAPINode_DispatchPolicy(t *testing.T) {
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+	s.AddSymbol(&openwsdk.Symbol{Symbol: "BTC", MaxHeight: 10})
+
+	api := testSubscribeAPINode(t, s, openwsdk.SubscribeToBlock)
+	defer api.Close()
+
+	accept := api.OnBlock(func(ctx context.Context, header *openwsdk.BlockHeader, subscribeToken string) error {
+		return nil
+	})
+	reject := api.OnBlock(func(ctx context.Context, header *openwsdk.BlockHeader, subscribeToken string) error {
+		return openwsdk.ErrNotificationRejected
+	})
+	api.OnBlock(func(ctx context.Context, header *openwsdk.BlockHeader, subscribeToken string) error {
+		panic("boom")
+	})
+
+	tests := []struct {
+		name   string
+		config *openwsdk.DispatchConfig
+		want   bool
+	}{
+		{"all", &openwsdk.DispatchConfig{Policy: openwsdk.AcceptAll}, false},
+		{"any", &openwsdk.DispatchConfig{Policy: openwsdk.AcceptAny, Concurrency: 3}, true},
+		{"primary accept", &openwsdk.DispatchConfig{Policy: openwsdk.AcceptPrimary, Primary: accept}, true},
+		{"primary reject", &openwsdk.DispatchConfig{Policy: openwsdk.AcceptPrimary, Primary: reject, Concurrency: 2}, false},
+	}
+	for _, test := range tests {
+		var result *openwsdk.DispatchResult
+		test.config.Report = func(r *openwsdk.DispatchResult) {
+			result = r
+		}
+		if err := api.SetDispatchConfig(test.config); err != nil {
+			t.Fatalf("%s: SetDispatchConfig unexpected error: %v", test.name, err)
+		}
+		accepted, err := s.PushBlock(s.MineBlock("BTC"))
+		if err != nil || accepted != test.want {
+			t.Errorf("%s: PushBlock expected %v, got: %v, %v", test.name, test.want, accepted, err)
+			continue
+		}
+		if result == nil || len(result.Outcomes) != 3 {
+			t.Errorf("%s: unexpected dispatch result: %+v", test.name, result)
+			continue
+		}
+		if result.Outcomes[0].HandlerID != accept.ID() || !result.Outcomes[0].Accepted ||
+			result.Outcomes[1].Err != openwsdk.ErrNotificationRejected ||
+			!result.Outcomes[2].Panicked || result.Outcomes[2].Accepted {
+			t.Errorf("%s: unexpected outcomes: %+v, %+v, %+v", test.name,
+				result.Outcomes[0], result.Outcomes[1], result.Outcomes[2])
+		}
+	}
+
+	if err := api.SetDispatchConfig(&openwsdk.DispatchConfig{Policy: openwsdk.AcceptPrimary}); err == nil {
+		t.Errorf("SetDispatchConfig without primary expected error")
+	}
+}
+
+func TestAPINode_DispatchConcurrency(t *testing.T) {
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+	s.AddSymbol(&openwsdk.Symbol{Symbol: "BTC", MaxHeight: 10})
+
+	api := testSubscribeAPINode(t, s, openwsdk.SubscribeToBlock)
+	defer api.Close()
+	api.SetDispatchConfig(&openwsdk.DispatchConfig{Concurrency: 2})
+
+	var running, max int32
+	for i := 0; i < 6; i++ {
+		api.OnBlock(func(ctx context.Context, header *openwsdk.BlockHeader, subscribeToken string) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+	}
+
+	//分发过程中增删观察者
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			observer := &blockObserver{blocks: make(chan *openwsdk.BlockHeader, 8)}
+			api.AddObserver(observer)
+			api.RemoveObserver(observer)
+		}
+	}()
+
+	accepted, err := s.PushBlock(s.MineBlock("BTC"))
+	close(stop)
+	wg.Wait()
+	if err != nil || !accepted {
+		t.Fatalf("PushBlock unexpected result: %v, %v", accepted, err)
+	}
+	if max != 2 {
+		t.Errorf("expected 2 handlers running concurrently, got %d", max)
+	}
+}
@@ -0,0 +1,384 @@
+package openwsdk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+)
+
+const (
+	// OfflineEnvelopeVersion 离线签名文件格式版本
+	OfflineEnvelopeVersion = 1
+
+	/* 离线签名文件的交易单类型 */
+	EnvelopeTypeRawTransaction              = "rawTransaction"
+	EnvelopeTypeSmartContractRawTransaction = "smartContractRawTransaction"
+
+	/* 离线签名文件的签名状态 */
+	EnvelopeStatusUnsigned = "unsigned"
+	EnvelopeStatusSigned   = "signed"
+)
+
+// OfflineEnvelope 离线签名文件，在联网机器和离线签名机器之间传递交易单
+type OfflineEnvelope struct {
+	Version   int             `json:"version"`
+	Type      string          `json:"type"`
+	Status    string          `json:"status"`
+	Coin      Coin            `json:"coin"`
+	AccountID string          `json:"accountID"`
+	Summary   string          `json:"summary"` //交易单摘要，由联网端写入，Validate时与Payload重新生成的摘要比对
+	CreatedAt int64           `json:"createdAt"`
+	Payload   json.RawMessage `json:"payload"` //RawTransaction或SmartContractRawTransaction，包含Signatures
+	Checksum  string          `json:"checksum"`
+}
+
+// NewRawTransactionEnvelope 打包交易单
+func NewRawTransactionEnvelope(rawTx *RawTransaction) (*OfflineEnvelope, error) {
+	if rawTx == nil {
+		return nil, fmt.Errorf("raw transaction is nil")
+	}
+	return newOfflineEnvelope(EnvelopeTypeRawTransaction, rawTx.Coin, rawTx.AccountID,
+		SummarizeRawTransaction(rawTx), rawTx.Signatures, rawTx)
+}
+
+// NewSmartContractEnvelope 打包智能合约交易单
+func NewSmartContractEnvelope(rawTx *SmartContractRawTransaction) (*OfflineEnvelope, error) {
+	if rawTx == nil {
+		return nil, fmt.Errorf("raw transaction is nil")
+	}
+	return newOfflineEnvelope(EnvelopeTypeSmartContractRawTransaction, rawTx.Coin, rawTx.AccountID,
+		SummarizeSmartContractRawTransaction(rawTx), rawTx.Signatures, rawTx)
+}
+
+func newOfflineEnvelope(typ string, coin Coin, accountID, summary string, signatures map[string][]*KeySignature, payload interface{}) (*OfflineEnvelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	e := &OfflineEnvelope{
+		Version:   OfflineEnvelopeVersion,
+		Type:      typ,
+		Status:    envelopeStatus(signatures),
+		Coin:      coin,
+		AccountID: accountID,
+		Summary:   summary,
+		CreatedAt: time.Now().Unix(),
+		Payload:   data,
+	}
+	e.Checksum, err = e.checksum()
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// envelopeStatus 所有KeySignature都有签名才算已签名
+func envelopeStatus(signatures map[string][]*KeySignature) string {
+	if len(signatures) == 0 {
+		return EnvelopeStatusUnsigned
+	}
+	for _, keySignatures := range signatures {
+		for _, ks := range keySignatures {
+			if ks == nil || len(ks.Signature) == 0 {
+				return EnvelopeStatusUnsigned
+			}
+		}
+	}
+	return EnvelopeStatusSigned
+}
+
+// checksum 对除Checksum外的字段计算sha256，Payload先压缩，不受文件缩进影响
+func (e *OfflineEnvelope) checksum() (string, error) {
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, e.Payload); err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(struct {
+		Version   int    `json:"version"`
+		Type      string `json:"type"`
+		Status    string `json:"status"`
+		Coin      Coin   `json:"coin"`
+		AccountID string `json:"accountID"`
+		Summary   string `json:"summary"`
+		CreatedAt int64  `json:"createdAt"`
+	}{e.Version, e.Type, e.Status, e.Coin, e.AccountID, e.Summary, e.CreatedAt})
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(header)
+	h.Write(payload.Bytes())
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Validate 检查版本、校验和，以及Summary与Payload重新生成的摘要一致。
+// 校验和没有密钥，只能发现传输损坏；Summary比对防止联网端展示与Payload不符的摘要。
+func (e *OfflineEnvelope) Validate() error {
+	if e.Version != OfflineEnvelopeVersion {
+		return fmt.Errorf("unsupported envelope version: %d", e.Version)
+	}
+	if e.Type != EnvelopeTypeRawTransaction && e.Type != EnvelopeTypeSmartContractRawTransaction {
+		return fmt.Errorf("unknown envelope type: %s", e.Type)
+	}
+	sum, err := e.checksum()
+	if err != nil {
+		return err
+	}
+	if sum != e.Checksum {
+		return fmt.Errorf("envelope checksum mismatch")
+	}
+	summary, err := e.PayloadSummary()
+	if err != nil {
+		return err
+	}
+	if summary != e.Summary {
+		return fmt.Errorf("envelope summary does not match payload")
+	}
+	return nil
+}
+
+// PayloadSummary 从Payload解出的交易单生成摘要，签名前应向核对人员展示此摘要
+func (e *OfflineEnvelope) PayloadSummary() (string, error) {
+	if e.Type == EnvelopeTypeSmartContractRawTransaction {
+		rawTx, err := e.SmartContractRawTransaction()
+		if err != nil {
+			return "", err
+		}
+		return SummarizeSmartContractRawTransaction(rawTx), nil
+	}
+	rawTx, err := e.RawTransaction()
+	if err != nil {
+		return "", err
+	}
+	return SummarizeRawTransaction(rawTx), nil
+}
+
+// RawTransaction 解出交易单
+func (e *OfflineEnvelope) RawTransaction() (*RawTransaction, error) {
+	if e.Type != EnvelopeTypeRawTransaction {
+		return nil, fmt.Errorf("envelope type is %s", e.Type)
+	}
+	var rawTx RawTransaction
+	if err := json.Unmarshal(e.Payload, &rawTx); err != nil {
+		return nil, err
+	}
+	if rawTx.AccountID != e.AccountID || rawTx.Coin != e.Coin {
+		return nil, fmt.Errorf("envelope header does not match raw transaction")
+	}
+	return &rawTx, nil
+}
+
+// SmartContractRawTransaction 解出智能合约交易单
+func (e *OfflineEnvelope) SmartContractRawTransaction() (*SmartContractRawTransaction, error) {
+	if e.Type != EnvelopeTypeSmartContractRawTransaction {
+		return nil, fmt.Errorf("envelope type is %s", e.Type)
+	}
+	var rawTx SmartContractRawTransaction
+	if err := json.Unmarshal(e.Payload, &rawTx); err != nil {
+		return nil, err
+	}
+	if rawTx.AccountID != e.AccountID || rawTx.Coin != e.Coin {
+		return nil, fmt.Errorf("envelope header does not match raw transaction")
+	}
+	return &rawTx, nil
+}
+
+// SignEnvelope 离线签名，返回新的已签名文件
+func SignEnvelope(e *OfflineEnvelope, key *hdkeystore.HDKey) (*OfflineEnvelope, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	switch e.Type {
+	case EnvelopeTypeSmartContractRawTransaction:
+		rawTx, err := e.SmartContractRawTransaction()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := SignTxHash(rawTx.Signatures, key); err != nil {
+			return nil, err
+		}
+		return NewSmartContractEnvelope(rawTx)
+	default:
+		rawTx, err := e.RawTransaction()
+		if err != nil {
+			return nil, err
+		}
+		if err := SignRawTransaction(rawTx, key); err != nil {
+			return nil, err
+		}
+		return NewRawTransactionEnvelope(rawTx)
+	}
+}
+
+// VerifySignedEnvelope 确认已签名文件与导出的文件是同一笔交易，离线端只填写了签名
+func VerifySignedEnvelope(unsigned, signed *OfflineEnvelope) error {
+	for _, e := range []*OfflineEnvelope{unsigned, signed} {
+		if err := e.Validate(); err != nil {
+			return err
+		}
+	}
+	if signed.Status != EnvelopeStatusSigned {
+		return fmt.Errorf("envelope is not signed")
+	}
+	if unsigned.Type != signed.Type {
+		return fmt.Errorf("envelope type mismatch")
+	}
+	a, err := unsignedPayload(unsigned)
+	if err != nil {
+		return err
+	}
+	b, err := unsignedPayload(signed)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(a, b) {
+		return fmt.Errorf("signed envelope does not match the exported transaction")
+	}
+	return nil
+}
+
+// unsignedPayload 清空签名后的交易单
+func unsignedPayload(e *OfflineEnvelope) ([]byte, error) {
+	if e.Type != EnvelopeTypeSmartContractRawTransaction {
+		rawTx, err := e.RawTransaction()
+		if err != nil {
+			return nil, err
+		}
+		return unsignedRawTransaction(rawTx)
+	}
+	rawTx, err := e.SmartContractRawTransaction()
+	if err != nil {
+		return nil, err
+	}
+	for _, keySignatures := range rawTx.Signatures {
+		for _, ks := range keySignatures {
+			if ks != nil {
+				ks.Signature = ""
+			}
+		}
+	}
+	return json.Marshal(rawTx)
+}
+
+// WriteEnvelope 写入离线签名文件
+func WriteEnvelope(w io.Writer, e *OfflineEnvelope) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadEnvelope 读取离线签名文件，检查版本和校验和
+func ReadEnvelope(r io.Reader) (*OfflineEnvelope, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var e OfflineEnvelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// WriteEnvelopeFile 写入离线签名文件
+func WriteEnvelopeFile(path string, e *OfflineEnvelope) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := WriteEnvelope(f, e); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadEnvelopeFile 读取离线签名文件
+func ReadEnvelopeFile(path string) (*OfflineEnvelope, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadEnvelope(f)
+}
+
+// SummarizeRawTransaction 交易单摘要
+func SummarizeRawTransaction(rawTx *RawTransaction) string {
+	var b strings.Builder
+	summarizeCoin(&b, rawTx.Coin, rawTx.AccountID, rawTx.Sid)
+	addresses := make([]string, 0, len(rawTx.To))
+	for address := range rawTx.To {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		fmt.Fprintf(&b, "To: %s %s\n", address, rawTx.To[address])
+	}
+	fmt.Fprintf(&b, "Fees: %s\n", rawTx.Fees)
+	if len(rawTx.FeeRate) > 0 {
+		fmt.Fprintf(&b, "FeeRate: %s\n", rawTx.FeeRate)
+	}
+	summarizeSignatures(&b, rawTx.Signatures)
+	return b.String()
+}
+
+// SummarizeSmartContractRawTransaction 智能合约交易单摘要
+func SummarizeSmartContractRawTransaction(rawTx *SmartContractRawTransaction) string {
+	var b strings.Builder
+	summarizeCoin(&b, rawTx.Coin, rawTx.AccountID, rawTx.Sid)
+	if len(rawTx.ABIParam) > 0 {
+		fmt.Fprintf(&b, "ABI: %s\n", strings.Join(rawTx.ABIParam, ", "))
+	}
+	if len(rawTx.Raw) > 0 {
+		fmt.Fprintf(&b, "Raw: %s\n", rawTx.Raw)
+	}
+	fmt.Fprintf(&b, "Value: %s\n", rawTx.Value)
+	fmt.Fprintf(&b, "Fees: %s\n", rawTx.Fees)
+	summarizeSignatures(&b, rawTx.Signatures)
+	return b.String()
+}
+
+func summarizeCoin(b *strings.Builder, coin Coin, accountID, sid string) {
+	fmt.Fprintf(b, "Symbol: %s\n", coin.Symbol)
+	if coin.IsContract {
+		fmt.Fprintf(b, "Contract: %s (%s)\n", coin.ContractID, coin.ContractAddress)
+	}
+	fmt.Fprintf(b, "Account: %s\n", accountID)
+	if len(sid) > 0 {
+		fmt.Fprintf(b, "Sid: %s\n", sid)
+	}
+}
+
+func summarizeSignatures(b *strings.Builder, signatures map[string][]*KeySignature) {
+	accountIDs := make([]string, 0, len(signatures))
+	for accountID := range signatures {
+		accountIDs = append(accountIDs, accountID)
+	}
+	sort.Strings(accountIDs)
+	for _, accountID := range accountIDs {
+		signed := 0
+		for _, ks := range signatures[accountID] {
+			if ks != nil && len(ks.Signature) > 0 {
+				signed++
+			}
+		}
+		fmt.Fprintf(b, "Signatures: %s %d/%d\n", accountID, signed, len(signatures[accountID]))
+	}
+}
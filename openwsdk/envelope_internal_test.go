@@ -0,0 +1,34 @@
+package openwsdk
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOfflineEnvelope_ForgedSummary(t *testing.T) {
+	rawTx := &RawTransaction{
+		Coin:      Coin{Symbol: "BTC"},
+		AccountID: "A1",
+		Sid:       "sid-1",
+		To:        map[string]string{"addr-y": "1"},
+		Fees:      "0.0001",
+	}
+	e, err := NewRawTransactionEnvelope(rawTx)
+	if err != nil {
+		t.Fatalf("NewRawTransactionEnvelope unexpected error: %v", err)
+	}
+	if err := e.Validate(); err != nil {
+		t.Fatalf("Validate unexpected error: %v", err)
+	}
+
+	//联网端展示的摘要与Payload不符，即使重新计算校验和也不能通过
+	e.Summary = strings.Replace(e.Summary, "To: addr-y 1", "To: addr-x 1", 1)
+	e.Checksum, _ = e.checksum()
+	if err := e.Validate(); err == nil || !strings.Contains(err.Error(), "summary does not match") {
+		t.Errorf("Validate expected summary mismatch, got: %v", err)
+	}
+	summary, err := e.PayloadSummary()
+	if err != nil || !strings.Contains(summary, "To: addr-y 1") {
+		t.Errorf("PayloadSummary expected payload address, got: %q, %v", summary, err)
+	}
+}
@@ -0,0 +1,89 @@
+package openwsdk_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-owcrypt"
+)
+
+func TestOfflineEnvelope(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envelope")
+	if err != nil {
+		t.Fatalf("TempDir unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := testHDKey(t)
+	accountKey, err := key.DerivedKeyWithPath("m/44'/88'/0'", owcrypt.ECC_CURVE_SECP256K1)
+	if err != nil {
+		t.Fatalf("DerivedKeyWithPath unexpected error: %v", err)
+	}
+	account := &openwsdk.Account{AccountID: "A1", PublicKey: accountKey.GetPublicKey().OWEncode(), HdPath: "m/44'/88'/0'"}
+	ks := testKeySignature(key)
+	rawTx := &openwsdk.RawTransaction{
+		Coin:       openwsdk.Coin{Symbol: "BTC"},
+		AccountID:  "A1",
+		Sid:        "sid-1",
+		To:         map[string]string{"addr-1": "0.1"},
+		Fees:       "0.0001",
+		Signatures: map[string][]*openwsdk.KeySignature{"A1": {ks}},
+	}
+
+	unsigned, err := openwsdk.NewRawTransactionEnvelope(rawTx)
+	if err != nil {
+		t.Fatalf("NewRawTransactionEnvelope unexpected error: %v", err)
+	}
+	if unsigned.Status != openwsdk.EnvelopeStatusUnsigned || !strings.Contains(unsigned.Summary, "To: addr-1 0.1") {
+		t.Errorf("unexpected envelope: %s, %q", unsigned.Status, unsigned.Summary)
+	}
+	path := filepath.Join(dir, "unsigned.json")
+	if err := openwsdk.WriteEnvelopeFile(path, unsigned); err != nil {
+		t.Fatalf("WriteEnvelopeFile unexpected error: %v", err)
+	}
+	loaded, err := openwsdk.ReadEnvelopeFile(path)
+	if err != nil {
+		t.Fatalf("ReadEnvelopeFile unexpected error: %v", err)
+	}
+
+	signed, err := openwsdk.SignEnvelope(loaded, key)
+	if err != nil {
+		t.Fatalf("SignEnvelope unexpected error: %v", err)
+	}
+	if signed.Status != openwsdk.EnvelopeStatusSigned {
+		t.Errorf("unexpected signed status: %s", signed.Status)
+	}
+	if err := openwsdk.VerifySignedEnvelope(unsigned, signed); err != nil {
+		t.Errorf("VerifySignedEnvelope unexpected error: %v", err)
+	}
+	signedTx, err := signed.RawTransaction()
+	if err != nil {
+		t.Fatalf("RawTransaction unexpected error: %v", err)
+	}
+	if err := openwsdk.VerifyRawTransactionSignatures(signedTx, openwsdk.AccountsResolver(account)); err != nil {
+		t.Errorf("VerifyRawTransactionSignatures unexpected error: %v", err)
+	}
+
+	//篡改金额后校验和不一致
+	var buf bytes.Buffer
+	openwsdk.WriteEnvelope(&buf, signed)
+	tampered := strings.Replace(buf.String(), `"addr-1": "0.1"`, `"addr-1": "9.1"`, 1)
+	if tampered == buf.String() {
+		t.Fatalf("tamper target not found in envelope: %s", buf.String())
+	}
+	if _, err := openwsdk.ReadEnvelope(strings.NewReader(tampered)); err == nil {
+		t.Errorf("ReadEnvelope expected checksum error")
+	}
+
+	//重新计算校验和也无法通过与导出文件的比对
+	signedTx.To["addr-1"] = "9.1"
+	forged, _ := openwsdk.NewRawTransactionEnvelope(signedTx)
+	if err := openwsdk.VerifySignedEnvelope(unsigned, forged); err == nil {
+		t.Errorf("VerifySignedEnvelope expected mismatch error")
+	}
+}
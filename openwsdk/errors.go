@@ -0,0 +1,172 @@
+package openwsdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// Error openw-server请求失败的错误信息
+type Error struct {
+	Status uint64                 `json:"status"` //owtp状态码或openwallet.Err*错误码
+	Method string                 `json:"method"` //请求方法
+	Msg    string                 `json:"msg"`    //服务端返回的消息
+	Params map[string]interface{} `json:"params"` //请求参数，敏感字段已脱敏
+	cause  error
+}
+
+// NewError 创建请求错误，params中的敏感字段会被脱敏
+func NewError(method string, status uint64, msg string, params map[string]interface{}) *Error {
+	return &Error{
+		Status: status,
+		Method: method,
+		Msg:    msg,
+		Params: redactParams(params),
+	}
+}
+
+// Error 错误信息
+func (err *Error) Error() string {
+	if len(err.Method) > 0 {
+		return fmt.Sprintf("%s: [%d]%s", err.Method, err.Status, err.Msg)
+	}
+	return fmt.Sprintf("[%d]%s", err.Status, err.Msg)
+}
+
+// Unwrap 返回引起错误的原因，例如context.DeadlineExceeded
+func (err *Error) Unwrap() error {
+	return err.cause
+}
+
+// Is 支持errors.Is与哨兵错误比较
+func (err *Error) Is(target error) bool {
+	switch t := target.(type) {
+	case *sentinelError:
+		return t.match(err)
+	case *Error:
+		if t.Status != err.Status {
+			return false
+		}
+		return len(t.Method) == 0 || t.Method == err.Method
+	}
+	return false
+}
+
+// sentinelError 哨兵错误，按错误码或消息归类
+type sentinelError struct {
+	text  string
+	match func(err *Error) bool
+}
+
+func (s *sentinelError) Error() string {
+	return s.text
+}
+
+func newStatusSentinel(text string, codes ...uint64) *sentinelError {
+	return &sentinelError{
+		text: text,
+		match: func(err *Error) bool {
+			for _, code := range codes {
+				if err.Status == code {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+var (
+	// ErrInsufficientBalance 账户、地址或代币余额不足
+	ErrInsufficientBalance error = newStatusSentinel("insufficient balance",
+		openwallet.ErrInsufficientBalanceOfAccount,
+		openwallet.ErrInsufficientBalanceOfAddress,
+		openwallet.ErrInsufficientTokenBalanceOfAddress)
+
+	// ErrUnknownAccount 账户不存在
+	ErrUnknownAccount error = newStatusSentinel("unknown account",
+		openwallet.ErrAccountNotFound)
+
+	// ErrUnauthorizedDevice 设备未授权，需要重新BindAppDevice
+	ErrUnauthorizedDevice error = newStatusSentinel("unauthorized device",
+		owtp.ErrUnauthorized)
+
+	// ErrTimeout 请求超时，包括owtp超时和context截止时间到期
+	ErrTimeout error = newStatusSentinel("timeout",
+		owtp.ErrRequestTimeout)
+
+	// ErrRateLimited 请求被限流，包括客户端限流的RateLimitError和服务端返回的限流状态
+	ErrRateLimited error = newStatusSentinel("rate limited",
+		owtp.ErrDenialOfService,
+		StatusTooManyRequests)
+
+	// ErrDuplicateSid 业务订单号重复
+	// openw-server没有独立的错误码，通过消息内容识别
+	ErrDuplicateSid error = &sentinelError{
+		text: "duplicate sid",
+		match: func(err *Error) bool {
+			msg := strings.ToLower(err.Msg)
+			if !strings.Contains(msg, "sid") {
+				return false
+			}
+			for _, keyword := range []string{"duplicate", "exist", "repeat", "重复", "已存在"} {
+				if strings.Contains(msg, keyword) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+)
+
+// sensitiveParamKeys 需要脱敏的参数名，小写比较
+var sensitiveParamKeys = map[string]bool{
+	"password":   true,
+	"appkey":     true,
+	"authkey":    true,
+	"keystore":   true,
+	"privatekey": true,
+	"sign":       true,
+}
+
+const redactedValue = "******"
+
+// redactParams 复制参数并脱敏，嵌套的结构体也会被展开处理
+func redactParams(params map[string]interface{}) map[string]interface{} {
+	if params == nil {
+		return nil
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return map[string]interface{}{}
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return map[string]interface{}{}
+	}
+	redactValue(obj)
+	return obj
+}
+
+func redactValue(v interface{}) {
+	switch o := v.(type) {
+	case map[string]interface{}:
+		for k, child := range o {
+			if sensitiveParamKeys[strings.ToLower(k)] {
+				if s, ok := child.(string); ok && len(s) == 0 {
+					continue
+				}
+				o[k] = redactedValue
+				continue
+			}
+			redactValue(child)
+		}
+	case []interface{}:
+		for _, child := range o {
+			redactValue(child)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package openwsdk
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func TestError_Is(t *testing.T) {
+	tests := []struct {
+		err    *Error
+		target error
+		want   bool
+	}{
+		{NewError("createTrade", openwallet.ErrInsufficientBalanceOfAccount, "balance not enough", nil), ErrInsufficientBalance, true},
+		{NewError("createTrade", openwallet.ErrInsufficientBalanceOfAddress, "balance not enough", nil), ErrInsufficientBalance, true},
+		{NewError("createTrade", openwallet.ErrInsufficientTokenBalanceOfAddress, "balance not enough", nil), ErrInsufficientBalance, true},
+		{NewError("createTrade", openwallet.ErrAccountNotFound, "account not found", nil), ErrInsufficientBalance, false},
+		{NewError("findAccountByAccountID", openwallet.ErrAccountNotFound, "account not found", nil), ErrUnknownAccount, true},
+		{NewError("bindAppDevice", owtp.ErrUnauthorized, "unauthorized", nil), ErrUnauthorizedDevice, true},
+		{NewError("submitTrade", owtp.ErrRequestTimeout, "timeout", nil), ErrTimeout, true},
+		{NewError("createTrade", openwallet.ErrCreateRawTransactionFailed, "sid: 123 is already exist", nil), ErrDuplicateSid, true},
+		{NewError("createTrade", openwallet.ErrCreateRawTransactionFailed, "fee rate is invalid", nil), ErrDuplicateSid, false},
+		{NewError("createTrade", 3001, "x", nil), &Error{Status: 3001}, true},
+		{NewError("createTrade", 3001, "x", nil), &Error{Status: 3001, Method: "submitTrade"}, false},
+	}
+
+	for i, test := range tests {
+		wrapped := fmt.Errorf("wrapped: %w", test.err)
+		if got := errors.Is(wrapped, test.target); got != test.want {
+			t.Errorf("case %d: errors.Is(%v, %v) = %v, want %v", i, test.err, test.target, got, test.want)
+		}
+	}
+}
+
+func TestNewError_RedactParams(t *testing.T) {
+	params := map[string]interface{}{
+		"appID":    "app",
+		"password": "1234qwer",
+		"sign":     "abcdef",
+		"summaryTask": &SummaryTask{
+			Wallets: []*SummaryWalletTask{
+				{WalletID: "W1", Password: "secret"},
+			},
+		},
+	}
+	err := NewError("startSummaryTaskViaTrustNode", 500, "failed", params)
+
+	if err.Params["appID"] != "app" {
+		t.Errorf("appID should be kept, got: %v", err.Params["appID"])
+	}
+	if err.Params["password"] != redactedValue || err.Params["sign"] != redactedValue {
+		t.Errorf("secrets are not redacted: %+v", err.Params)
+	}
+	wallets := err.Params["summaryTask"].(map[string]interface{})["wallets"].([]interface{})
+	if wallets[0].(map[string]interface{})["password"] != redactedValue {
+		t.Errorf("nested password is not redacted: %+v", wallets[0])
+	}
+	if params["password"] != "1234qwer" {
+		t.Errorf("original params should not be modified")
+	}
+	if err.Error() != "startSummaryTaskViaTrustNode: [500]failed" {
+		t.Errorf("unexpected error text: %s", err.Error())
+	}
+}
@@ -0,0 +1,266 @@
+package openwsdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/tidwall/gjson"
+)
+
+// ErrNotificationRejected 处理函数不接收该通知，openw-server会稍后重新推送
+var ErrNotificationRejected = errors.New("notification rejected")
+
+// Notification 一次通知的内容，只有与Method对应的字段不为空
+type Notification struct {
+	Method         string                //通知方法，如SubscribeToTrade
+	SubscribeToken string                //订阅时传入的token
+	Transaction    *Transaction          //SubscribeToTrade
+	BlockHeader    *BlockHeader          //SubscribeToBlock
+	Balance        *Balance              //SubscribeToAccount
+	TokenBalance   *TokenBalance         //SubscribeToAccount
+	Receipt        *SmartContractReceipt //SubscribeToSmartContractReceipt
+	NFTTransfer    *NFTTransfer          //SubscribeToNFTTransfer
+}
+
+// NotificationHandler 通知处理函数，返回nil表示已接收
+type NotificationHandler func(ctx context.Context, n *Notification) error
+
+// NotificationMiddleware 通知处理中间件，包装所有已注册的处理函数
+type NotificationMiddleware func(next NotificationHandler) NotificationHandler
+
+// HandlerRegistration 处理函数的注册句柄
+type HandlerRegistration struct {
+	api *APINode
+	id  uint64
+}
+
+// ID 注册序号，与HandlerOutcome.HandlerID对应
+func (r *HandlerRegistration) ID() uint64 {
+	if r == nil {
+		return 0
+	}
+	return r.id
+}
+
+// Unsubscribe 注销处理函数，重复调用无副作用
+func (r *HandlerRegistration) Unsubscribe() {
+	if r == nil || r.api == nil {
+		return
+	}
+	r.api.removeHandler(r.id)
+}
+
+// registeredHandler 已注册的处理函数，method为空表示处理所有通知
+type registeredHandler struct {
+	id      uint64
+	method  string
+	handler NotificationHandler
+}
+
+// Handle 注册指定通知方法的处理函数，method为空表示处理所有通知
+// 处理函数按注册顺序调用
+func (api *APINode) Handle(method string, handler NotificationHandler) *HandlerRegistration {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.addHandler(method, handler)
+}
+
+// addHandler 注册处理函数，调用者需持有api.mu
+func (api *APINode) addHandler(method string, handler NotificationHandler) *HandlerRegistration {
+	api.handlerSeq++
+	api.handlers = append(api.handlers, &registeredHandler{
+		id:      api.handlerSeq,
+		method:  method,
+		handler: handler,
+	})
+	return &HandlerRegistration{api: api, id: api.handlerSeq}
+}
+
+// OnTransaction 注册新交易单通知的处理函数
+func (api *APINode) OnTransaction(handler func(ctx context.Context, tx *Transaction, subscribeToken string) error) *HandlerRegistration {
+	return api.Handle(SubscribeToTrade, func(ctx context.Context, n *Notification) error {
+		return handler(ctx, n.Transaction, n.SubscribeToken)
+	})
+}
+
+// OnBlock 注册新区块头通知的处理函数
+func (api *APINode) OnBlock(handler func(ctx context.Context, header *BlockHeader, subscribeToken string) error) *HandlerRegistration {
+	return api.Handle(SubscribeToBlock, func(ctx context.Context, n *Notification) error {
+		return handler(ctx, n.BlockHeader, n.SubscribeToken)
+	})
+}
+
+// OnBalanceUpdate 注册余额更新通知的处理函数
+func (api *APINode) OnBalanceUpdate(handler func(ctx context.Context, balance *Balance, tokenBalance *TokenBalance, subscribeToken string) error) *HandlerRegistration {
+	return api.Handle(SubscribeToAccount, func(ctx context.Context, n *Notification) error {
+		return handler(ctx, n.Balance, n.TokenBalance, n.SubscribeToken)
+	})
+}
+
+// OnSmartContractReceipt 注册智能合约交易回执通知的处理函数
+func (api *APINode) OnSmartContractReceipt(handler func(ctx context.Context, receipt *SmartContractReceipt, subscribeToken string) error) *HandlerRegistration {
+	return api.Handle(SubscribeToSmartContractReceipt, func(ctx context.Context, n *Notification) error {
+		return handler(ctx, n.Receipt, n.SubscribeToken)
+	})
+}
+
+// OnNFTTransfer 注册NFT交易数据通知的处理函数
+func (api *APINode) OnNFTTransfer(handler func(ctx context.Context, transfer *NFTTransfer, subscribeToken string) error) *HandlerRegistration {
+	return api.Handle(SubscribeToNFTTransfer, func(ctx context.Context, n *Notification) error {
+		return handler(ctx, n.NFTTransfer, n.SubscribeToken)
+	})
+}
+
+// Use 添加中间件，先添加的在最外层
+func (api *APINode) Use(middlewares ...NotificationMiddleware) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.middlewares = append(api.middlewares, middlewares...)
+}
+
+func (api *APINode) removeHandler(id uint64) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for i, h := range api.handlers {
+		if h.id == id {
+			handlers := make([]*registeredHandler, 0, len(api.handlers)-1)
+			handlers = append(handlers, api.handlers[:i]...)
+			api.handlers = append(handlers, api.handlers[i+1:]...)
+			return
+		}
+	}
+}
+
+// notificationHandlers 处理该通知方法的函数快照，已用中间件包装
+func (api *APINode) notificationHandlers(method string) []*registeredHandler {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	handlers := make([]*registeredHandler, 0, len(api.handlers))
+	for _, h := range api.handlers {
+		if len(h.method) > 0 && h.method != method {
+			continue
+		}
+		handler := h.handler
+		for i := len(api.middlewares) - 1; i >= 0; i-- {
+			handler = api.middlewares[i](handler)
+		}
+		handlers = append(handlers, &registeredHandler{
+			id:      h.id,
+			method:  h.method,
+			handler: handler,
+		})
+	}
+	return handlers
+}
+
+// ObserverHandler 把OpenwNotificationObject适配为处理所有通知的处理函数
+// 观察者返回false时处理函数返回ErrNotificationRejected
+func ObserverHandler(obj OpenwNotificationObject) NotificationHandler {
+	return func(ctx context.Context, n *Notification) error {
+		var (
+			accepted bool
+			err      error
+		)
+		switch n.Method {
+		case SubscribeToAccount:
+			accepted, err = obj.OpenwBalanceUpdateNotify(n.Balance, n.TokenBalance, n.SubscribeToken)
+		case SubscribeToTrade:
+			accepted, err = obj.OpenwNewTransactionNotify(n.Transaction, n.SubscribeToken)
+		case SubscribeToBlock:
+			accepted, err = obj.OpenwNewBlockNotify(n.BlockHeader, n.SubscribeToken)
+		case SubscribeToSmartContractReceipt:
+			accepted, err = obj.OpenwNewSmartContractReceiptNotify(n.Receipt, n.SubscribeToken)
+		case SubscribeToNFTTransfer:
+			accepted, err = obj.OpenwNFTTransferNotify(n.NFTTransfer, n.SubscribeToken)
+		default:
+			return fmt.Errorf("unknown notify method: %s", n.Method)
+		}
+		if err != nil {
+			return err
+		}
+		if !accepted {
+			return ErrNotificationRejected
+		}
+		return nil
+	}
+}
+
+// decodeNotification 按通知方法解析通知数据
+func decodeNotification(method string, data gjson.Result) (*Notification, error) {
+	n := &Notification{
+		Method:         method,
+		SubscribeToken: data.Get("subscribeToken").String(),
+	}
+	var err error
+	switch method {
+	case SubscribeToAccount:
+		n.Balance = NewBalance(data)
+		n.TokenBalance = NewTokenBalance(data.Get("tokenBalance"))
+	case SubscribeToTrade:
+		n.Transaction = &Transaction{}
+		err = json.Unmarshal([]byte(data.Raw), n.Transaction)
+	case SubscribeToBlock:
+		n.BlockHeader = &BlockHeader{}
+		err = json.Unmarshal([]byte(data.Raw), n.BlockHeader)
+	case SubscribeToSmartContractReceipt:
+		n.Receipt = &SmartContractReceipt{}
+		err = json.Unmarshal([]byte(data.Raw), n.Receipt)
+	case SubscribeToNFTTransfer:
+		n.NFTTransfer = &NFTTransfer{}
+		err = json.Unmarshal([]byte(data.Raw), n.NFTTransfer)
+	default:
+		err = fmt.Errorf("unknown notify method: %s", method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// LoggingMiddleware 记录每次通知的处理结果和耗时
+func LoggingMiddleware() NotificationMiddleware {
+	return func(next NotificationHandler) NotificationHandler {
+		return func(ctx context.Context, n *Notification) error {
+			start := time.Now()
+			err := next(ctx, n)
+			if err != nil {
+				log.Warningf("handle %s notify failed, elapsed: %v, error: %v", n.Method, time.Since(start), err)
+			} else {
+				log.Debugf("handle %s notify success, elapsed: %v", n.Method, time.Since(start))
+			}
+			return err
+		}
+	}
+}
+
+// RecoveryMiddleware 捕获处理函数的panic，转为错误返回
+func RecoveryMiddleware() NotificationMiddleware {
+	return func(next NotificationHandler) NotificationHandler {
+		return func(ctx context.Context, n *Notification) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("handle %s notify panic: %v\n%s", n.Method, r, debug.Stack())
+					err = fmt.Errorf("handle %s notify panic: %v", n.Method, r)
+				}
+			}()
+			return next(ctx, n)
+		}
+	}
+}
+
+// MetricsMiddleware 每次通知处理完成后回调observe，用于统计次数、耗时和失败率
+func MetricsMiddleware(observe func(method string, elapsed time.Duration, err error)) NotificationMiddleware {
+	return func(next NotificationHandler) NotificationHandler {
+		return func(ctx context.Context, n *Notification) error {
+			start := time.Now()
+			err := next(ctx, n)
+			observe(n.Method, time.Since(start), err)
+			return err
+		}
+	}
+}
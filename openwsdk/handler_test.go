@@ -0,0 +1,119 @@
+package openwsdk_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func testSubscribeAPINode(t *testing.T, s *openwsdktest.Server, methods ...string) *openwsdk.APINode {
+	api, err := s.NewAPINode(owtp.Websocket)
+	if err != nil {
+		t.Fatalf("NewAPINode unexpected error: %v", err)
+	}
+	err = openwsdk.NewAPIClient(api).Subscribe(context.Background(), methods, "",
+		openwsdk.CallbackModeCurrentConnection, openwsdk.CallbackNode{
+			NodeID:      s.NodeID(),
+			ConnectType: owtp.Websocket,
+		}, "token")
+	if err != nil {
+		api.Close()
+		t.Fatalf("Subscribe unexpected error: %v", err)
+	}
+	return api
+}
+
+func TestAPINode_OnBlock(t *testing.T) {
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+	s.AddSymbol(&openwsdk.Symbol{Symbol: "BTC", MaxHeight: 10})
+
+	api := testSubscribeAPINode(t, s, openwsdk.SubscribeToBlock, openwsdk.SubscribeToTrade)
+	defer api.Close()
+
+	var (
+		mu      sync.Mutex
+		calls   []string
+		metrics = make(map[string]int)
+	)
+	record := func(call string) {
+		mu.Lock()
+		calls = append(calls, call)
+		mu.Unlock()
+	}
+	api.Use(openwsdk.RecoveryMiddleware(), openwsdk.MetricsMiddleware(func(method string, elapsed time.Duration, err error) {
+		mu.Lock()
+		metrics[method]++
+		mu.Unlock()
+	}))
+
+	registration := api.OnBlock(func(ctx context.Context, header *openwsdk.BlockHeader, subscribeToken string) error {
+		if subscribeToken != "token" {
+			t.Errorf("unexpected subscribeToken: %s", subscribeToken)
+		}
+		record("block")
+		return nil
+	})
+	api.OnTransaction(func(ctx context.Context, tx *openwsdk.Transaction, subscribeToken string) error {
+		record("tx")
+		return nil
+	})
+	observer := &blockObserver{blocks: make(chan *openwsdk.BlockHeader, 4)}
+	api.AddObserver(observer)
+
+	accepted, err := s.PushBlock(s.MineBlock("BTC"))
+	if err != nil || !accepted {
+		t.Fatalf("PushBlock unexpected result: %v, %v", accepted, err)
+	}
+	if len(calls) != 1 || calls[0] != "block" || len(observer.blocks) != 1 {
+		t.Errorf("unexpected calls: %v, observer: %d", calls, len(observer.blocks))
+	}
+	if metrics[openwsdk.SubscribeToBlock] != 2 {
+		t.Errorf("expected middleware to wrap 2 handlers, got %d", metrics[openwsdk.SubscribeToBlock])
+	}
+
+	//注销后不再收到通知
+	registration.Unsubscribe()
+	registration.Unsubscribe()
+	api.RemoveObserver(observer)
+	accepted, err = s.PushBlock(s.MineBlock("BTC"))
+	if err != nil || accepted {
+		t.Errorf("PushBlock without handlers expected not accepted, got: %v, %v", accepted, err)
+	}
+	if len(calls) != 1 || len(observer.blocks) != 1 {
+		t.Errorf("unexpected calls after unsubscribe: %v", calls)
+	}
+
+	//panic被恢复，通知未被接收
+	api.OnBlock(func(ctx context.Context, header *openwsdk.BlockHeader, subscribeToken string) error {
+		panic("boom")
+	})
+	accepted, err = s.PushBlock(s.MineBlock("BTC"))
+	if err != nil || accepted {
+		t.Errorf("PushBlock with panic handler expected not accepted, got: %v, %v", accepted, err)
+	}
+}
+
+func TestObserverHandler(t *testing.T) {
+	observer := &blockObserver{blocks: make(chan *openwsdk.BlockHeader, 1), reject: true}
+	handler := openwsdk.ObserverHandler(observer)
+	err := handler(context.Background(), &openwsdk.Notification{
+		Method:      openwsdk.SubscribeToBlock,
+		BlockHeader: &openwsdk.BlockHeader{Hash: "h1"},
+	})
+	if !errors.Is(err, openwsdk.ErrNotificationRejected) {
+		t.Errorf("expected ErrNotificationRejected, got: %v", err)
+	}
+	if got := <-observer.blocks; got.Hash != "h1" {
+		t.Errorf("unexpected block header: %+v", got)
+	}
+}
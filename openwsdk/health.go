@@ -0,0 +1,260 @@
+package openwsdk
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// TrustNodeHealthState 托管节点健康状态
+type TrustNodeHealthState int
+
+const (
+	TrustNodeOffline  TrustNodeHealthState = iota //连接断开
+	TrustNodeHealthy                              //正常应答
+	TrustNodeDegraded                             //连续探测失败达到FailureThreshold，连接可能已挂起
+)
+
+func (s TrustNodeHealthState) String() string {
+	switch s {
+	case TrustNodeOffline:
+		return "offline"
+	case TrustNodeHealthy:
+		return "healthy"
+	case TrustNodeDegraded:
+		return "degraded"
+	}
+	return fmt.Sprintf("TrustNodeHealthState(%d)", int(s))
+}
+
+// TrustNodeHealth 托管节点健康状况
+type TrustNodeHealth struct {
+	NodeID              string               `json:"nodeID"`
+	State               TrustNodeHealthState `json:"state"`
+	Latency             time.Duration        `json:"latency"`             //最近一次成功探测的延迟
+	LastOK              time.Time            `json:"lastOK"`              //最近一次应答时间，加入时视为应答
+	LastCheck           time.Time            `json:"lastCheck"`           //最近一次探测时间
+	ConsecutiveFailures int                  `json:"consecutiveFailures"` //连续探测失败次数
+	LastError           string               `json:"lastError"`           //最近一次探测失败的原因
+}
+
+// HealthCheckConfig 托管节点健康检查配置
+type HealthCheckConfig struct {
+	Interval         time.Duration //探测间隔，默认30秒
+	Timeout          time.Duration //单次探测的超时时间，默认5秒
+	FailureThreshold int           //连续失败达到该次数后标记为degraded，默认3
+	MaxResponseAge   time.Duration //转账和签名只路由到在该时间内应答过的节点，默认3倍Interval
+	//状态变化的回调，在探测协程中调用
+	OnStateChange func(nodeID string, from, to TrustNodeHealthState, health *TrustNodeHealth)
+}
+
+func (config HealthCheckConfig) withDefaults() HealthCheckConfig {
+	if config.Interval <= 0 {
+		config.Interval = 30 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 3
+	}
+	if config.MaxResponseAge <= 0 {
+		config.MaxResponseAge = 3 * config.Interval
+	}
+	return config
+}
+
+// healthTransition 待通知的状态变化
+type healthTransition struct {
+	from, to TrustNodeHealthState
+	health   TrustNodeHealth
+}
+
+// SetHealthCheckConfig 开启托管节点健康检查，定期通过GetTrustNodeInfo探测所有在线节点，config为空时关闭。
+// 开启后转账和签名不会路由到degraded或超过MaxResponseAge未应答的节点。
+func (transmit *TransmitNode) SetHealthCheckConfig(config *HealthCheckConfig) {
+	if transmit == nil {
+		return
+	}
+	r := transmit.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.healthStop != nil {
+		close(r.healthStop)
+		r.healthStop = nil
+	}
+	r.healthConfig = nil
+	if config == nil {
+		return
+	}
+	c := config.withDefaults()
+	r.healthConfig = &c
+	stop := make(chan struct{})
+	r.healthStop = stop
+	go func() {
+		ticker := time.NewTicker(c.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				r.checkAll()
+			}
+		}
+	}()
+}
+
+// Health 所有节点的健康状况，按首次加入顺序排列
+func (r *TrustNodeRegistry) Health() []*TrustNodeHealth {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := r.sortedLocked(func(*trustNodeEntry) bool { return true })
+	list := make([]*TrustNodeHealth, 0, len(entries))
+	for _, entry := range entries {
+		h := entry.status.Health
+		list = append(list, &h)
+	}
+	return list
+}
+
+// CheckHealth 立即探测节点，返回探测失败的原因
+func (r *TrustNodeRegistry) CheckHealth(nodeID string) error {
+	if r == nil {
+		return fmt.Errorf("TrustNodeRegistry is not inited")
+	}
+	r.mu.RLock()
+	_, ok := r.nodes[nodeID]
+	config := r.healthConfigLocked()
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("trust node %s is not registered", nodeID)
+	}
+
+	start := r.now()
+	err := r.probe(nodeID, config.Timeout)
+	latency := r.now().Sub(start)
+
+	r.mu.Lock()
+	entry, ok := r.nodes[nodeID]
+	var transition *healthTransition
+	if ok {
+		transition = r.updateHealthLocked(entry, func(h *TrustNodeHealth) {
+			h.LastCheck = start
+			if err != nil {
+				h.ConsecutiveFailures++
+				h.LastError = err.Error()
+				if h.State == TrustNodeHealthy && h.ConsecutiveFailures >= config.FailureThreshold {
+					h.State = TrustNodeDegraded
+				}
+				return
+			}
+			h.Latency = latency
+			h.LastOK = r.now()
+			h.ConsecutiveFailures = 0
+			h.LastError = ""
+			if h.State == TrustNodeDegraded {
+				h.State = TrustNodeHealthy
+			}
+		})
+	}
+	r.mu.Unlock()
+	r.notify(nodeID, transition)
+	return err
+}
+
+// probe 调用getTrustNodeInfo，超时未应答视为失败，探测请求不写审计日志
+func (r *TrustNodeRegistry) probe(nodeID string, timeout time.Duration) error {
+	done := make(chan error, 1)
+	err := r.transmit.getTrustNodeInfo(nodeID, false, false, func(status uint64, msg string, nodeInfo *TrustNodeInfo) {
+		if status != owtp.StatusSuccess {
+			done <- NewError("getTrustNodeInfo", status, msg, nil)
+			return
+		}
+		done <- nil
+	})
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err = <-done:
+		return err
+	case <-timer.C:
+		return NewError("getTrustNodeInfo", owtp.ErrRequestTimeout, fmt.Sprintf("no response in %v", timeout), nil)
+	}
+}
+
+// checkAll 并发探测所有在线节点
+func (r *TrustNodeRegistry) checkAll() {
+	r.mu.RLock()
+	var nodeIDs []string
+	for nodeID, entry := range r.nodes {
+		if entry.status.Online {
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+	}
+	r.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, nodeID := range nodeIDs {
+		wg.Add(1)
+		go func(nodeID string) {
+			defer wg.Done()
+			r.CheckHealth(nodeID)
+		}(nodeID)
+	}
+	wg.Wait()
+}
+
+// healthConfigLocked 当前的健康检查配置，未开启时使用默认值，仅用于手动探测
+func (r *TrustNodeRegistry) healthConfigLocked() HealthCheckConfig {
+	if r.healthConfig != nil {
+		return *r.healthConfig
+	}
+	return HealthCheckConfig{}.withDefaults()
+}
+
+// updateHealthLocked 修改节点健康状况，状态变化时返回待通知的变化
+func (r *TrustNodeRegistry) updateHealthLocked(entry *trustNodeEntry, update func(h *TrustNodeHealth)) *healthTransition {
+	h := &entry.status.Health
+	from := h.State
+	update(h)
+	if h.State == from {
+		return nil
+	}
+	return &healthTransition{from: from, to: h.State, health: *h}
+}
+
+// notify 在锁外调用状态变化回调
+func (r *TrustNodeRegistry) notify(nodeID string, transition *healthTransition) {
+	if transition == nil {
+		return
+	}
+	r.mu.RLock()
+	config := r.healthConfig
+	r.mu.RUnlock()
+	if config == nil || config.OnStateChange == nil {
+		return
+	}
+	health := transition.health
+	config.OnStateChange(nodeID, transition.from, transition.to, &health)
+}
+
+// routableLocked 节点是否可以接收转账和签名请求
+func (r *TrustNodeRegistry) routableLocked(entry *trustNodeEntry, now time.Time) bool {
+	h := entry.status.Health
+	if h.State != TrustNodeHealthy {
+		return false
+	}
+	if r.healthConfig != nil && now.Sub(h.LastOK) > r.healthConfig.MaxResponseAge {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package openwsdk_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+)
+
+func TestTransmitNode_HealthCheck(t *testing.T) {
+	transmit, addr := testTransmitNode(t)
+	defer transmit.Close()
+	registry := transmit.Registry()
+
+	key := testHDKey(t)
+	hung := openwsdktest.NewTrustNode("hung")
+	defer hung.Close()
+	hung.ImportKey(key, "")
+	good := openwsdktest.NewTrustNode("good")
+	defer good.Close()
+	good.ImportKey(key, "")
+	for _, n := range []*openwsdktest.TrustNode{hung, good} {
+		testConnectTrustNode(t, n, addr)
+	}
+	testWaitFor(t, "wallet lists", func() bool {
+		return len(registry.NodesForWallet(key.KeyID)) == 2
+	})
+
+	var (
+		mu          sync.Mutex
+		transitions []string
+	)
+	transmit.SetHealthCheckConfig(&openwsdk.HealthCheckConfig{
+		Interval:         20 * time.Millisecond,
+		Timeout:          50 * time.Millisecond,
+		FailureThreshold: 2,
+		OnStateChange: func(nodeID string, from, to openwsdk.TrustNodeHealthState, health *openwsdk.TrustNodeHealth) {
+			mu.Lock()
+			transitions = append(transitions, fmt.Sprintf("%s:%s->%s", nodeID, from, to))
+			mu.Unlock()
+		},
+	})
+
+	//连接未断开但不应答的节点被标记为degraded，不再接收转账
+	hung.SetDelay(time.Second)
+	testWaitFor(t, "hung node degraded", func() bool {
+		status, _ := registry.Node(hung.NodeID())
+		return status.Health.State == openwsdk.TrustNodeDegraded
+	})
+	if nodes := registry.NodesForWallet(key.KeyID); len(nodes) != 1 || nodes[0] != good.NodeID() {
+		t.Errorf("unexpected routable nodes: %v", nodes)
+	}
+	for _, h := range registry.Health() {
+		switch h.NodeID {
+		case good.NodeID():
+			if h.State != openwsdk.TrustNodeHealthy || h.Latency <= 0 || h.LastCheck.IsZero() {
+				t.Errorf("unexpected good node health: %+v", h)
+			}
+		case hung.NodeID():
+			if h.ConsecutiveFailures < 2 || len(h.LastError) == 0 {
+				t.Errorf("unexpected hung node health: %+v", h)
+			}
+		}
+	}
+
+	//恢复应答后重新可用
+	hung.SetDelay(0)
+	testWaitFor(t, "hung node recovered", func() bool {
+		return len(registry.NodesForWallet(key.KeyID)) == 2
+	})
+
+	good.Disconnect()
+	testWaitFor(t, "good node offline", func() bool {
+		status, _ := registry.Node(good.NodeID())
+		return status.Health.State == openwsdk.TrustNodeOffline
+	})
+
+	transmit.SetHealthCheckConfig(nil)
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{
+		hung.NodeID() + ":healthy->degraded",
+		hung.NodeID() + ":degraded->healthy",
+		good.NodeID() + ":healthy->offline",
+	}
+	if fmt.Sprint(transitions) != fmt.Sprint(expected) {
+		t.Errorf("unexpected transitions: %v", transitions)
+	}
+}
@@ -0,0 +1,426 @@
+package openwsdk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/tidwall/gjson"
+)
+
+// InboxStatus 收件箱消息的投递状态
+type InboxStatus int
+
+const (
+	InboxStatusPending   InboxStatus = iota + 1 //已持久化，等待观察者接收
+	InboxStatusDelivered                        //观察者已接收
+)
+
+// InboxMessage 收件箱中持久化的通知
+type InboxMessage struct {
+	ID          string      `json:"id" storm:"id"`         //去重键，method:唯一标识
+	Seq         int64       `json:"seq" storm:"index"`     //写入顺序
+	Method      string      `json:"method" storm:"index"`  //通知方法，如subscribeToTrade
+	Payload     string      `json:"payload"`               //原始通知数据
+	Status      InboxStatus `json:"status" storm:"index"`  //投递状态
+	Attempts    int         `json:"attempts"`              //已投递次数
+	LastError   string      `json:"lastError"`             //最近一次投递失败的原因
+	Received    int         `json:"received"`              //收到的次数，大于1表示有重复推送
+	ReceivedAt  time.Time   `json:"receivedAt"`            //首次收到时间
+	NextAttempt time.Time   `json:"nextAttempt"`           //下次重试时间
+	DeliveredAt time.Time   `json:"deliveredAt,omitempty"` //观察者接收时间
+}
+
+// InboxFilter 查询或重放收件箱消息的条件，零值表示不限制
+type InboxFilter struct {
+	Method      string    //通知方法
+	Since       time.Time //首次收到时间不早于
+	Until       time.Time //首次收到时间早于
+	PendingOnly bool      //只包含未投递成功的消息
+}
+
+// NotificationInbox 通知收件箱
+// 收到的通知先写入本地文件数据库再回复openw-server，按WxID/txid/区块hash去重，
+// 投递失败的消息按退避策略重试，保证观察者至少收到一次，同一消息同时只投递一次
+type NotificationInbox struct {
+	mu      sync.Mutex
+	db      *storm.DB
+	seq     int64
+	backoff Backoff
+	leased  map[string]bool //正在投递的消息
+}
+
+// OpenNotificationInbox 打开收件箱，文件不存在则创建
+func OpenNotificationInbox(path string) (*NotificationInbox, error) {
+	db, err := storm.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	inbox := &NotificationInbox{
+		db:      db,
+		backoff: DefaultBackoff,
+		leased:  make(map[string]bool),
+	}
+	var last []*InboxMessage
+	err = db.Select().OrderBy("Seq").Reverse().Limit(1).Find(&last)
+	if err != nil && err != storm.ErrNotFound {
+		db.Close()
+		return nil, err
+	}
+	if len(last) > 0 {
+		inbox.seq = last[0].Seq
+	}
+	return inbox, nil
+}
+
+// Close 关闭收件箱
+func (inbox *NotificationInbox) Close() error {
+	return inbox.db.Close()
+}
+
+// SetBackoff 设置投递失败后的重试策略
+func (inbox *NotificationInbox) SetBackoff(backoff Backoff) {
+	inbox.mu.Lock()
+	inbox.backoff = backoff
+	inbox.mu.Unlock()
+}
+
+// Put 写入通知，已存在相同去重键的消息则返回已有消息，duplicate为true
+func (inbox *NotificationInbox) Put(method string, payload string) (msg *InboxMessage, duplicate bool, err error) {
+	msg, duplicate, leased, err := inbox.put(method, payload)
+	if leased {
+		inbox.release(msg.ID)
+	}
+	return msg, duplicate, err
+}
+
+// put 写入通知，未投递的消息没有在投递中时占用投递，leased为true，投递后需要release
+func (inbox *NotificationInbox) put(method string, payload string) (msg *InboxMessage, duplicate, leased bool, err error) {
+	id := method + ":" + inboxKey(method, gjson.Parse(payload))
+
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+
+	var exist InboxMessage
+	err = inbox.db.One("ID", id, &exist)
+	if err == nil {
+		exist.Received++
+		if err = inbox.db.Update(&InboxMessage{ID: id, Received: exist.Received}); err != nil {
+			return nil, true, false, err
+		}
+		if exist.Status == InboxStatusPending && !inbox.leased[id] {
+			inbox.leased[id] = true
+			leased = true
+		}
+		return &exist, true, leased, nil
+	}
+	if err != storm.ErrNotFound {
+		return nil, false, false, err
+	}
+
+	now := time.Now()
+	msg = &InboxMessage{
+		ID:          id,
+		Seq:         inbox.seq + 1,
+		Method:      method,
+		Payload:     payload,
+		Status:      InboxStatusPending,
+		Received:    1,
+		ReceivedAt:  now,
+		NextAttempt: now,
+	}
+	if err = inbox.db.Save(msg); err != nil {
+		return nil, false, false, err
+	}
+	inbox.seq = msg.Seq
+	inbox.leased[id] = true
+	return msg, false, true, nil
+}
+
+// lease 占用消息的投递，消息正在投递时返回false
+func (inbox *NotificationInbox) lease(id string) bool {
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+	if inbox.leased[id] {
+		return false
+	}
+	inbox.leased[id] = true
+	return true
+}
+
+// release 投递结束后释放占用
+func (inbox *NotificationInbox) release(id string) {
+	inbox.mu.Lock()
+	delete(inbox.leased, id)
+	inbox.mu.Unlock()
+}
+
+// Get 按去重键查询消息
+func (inbox *NotificationInbox) Get(id string) (*InboxMessage, error) {
+	var msg InboxMessage
+	if err := inbox.db.One("ID", id, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// MarkDelivered 标记消息已被观察者接收
+func (inbox *NotificationInbox) MarkDelivered(id string) error {
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+	var msg InboxMessage
+	if err := inbox.db.One("ID", id, &msg); err != nil {
+		return err
+	}
+	msg.Attempts++
+	msg.Status = InboxStatusDelivered
+	msg.LastError = ""
+	msg.DeliveredAt = time.Now()
+	return inbox.db.Save(&msg)
+}
+
+// markFailed 记录投递失败，按退避策略安排下次重试
+func (inbox *NotificationInbox) markFailed(id string, cause error) error {
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+	var msg InboxMessage
+	if err := inbox.db.One("ID", id, &msg); err != nil {
+		return err
+	}
+	msg.Attempts++
+	if cause != nil {
+		msg.LastError = cause.Error()
+	} else {
+		msg.LastError = "notification not accepted"
+	}
+	msg.NextAttempt = time.Now().Add(inbox.backoff.Duration(msg.Attempts))
+	return inbox.db.Save(&msg)
+}
+
+// Pending 未投递成功的消息，按写入顺序排列
+func (inbox *NotificationInbox) Pending() ([]*InboxMessage, error) {
+	return inbox.Messages(&InboxFilter{PendingOnly: true})
+}
+
+// Messages 按条件查询消息，按写入顺序排列
+func (inbox *NotificationInbox) Messages(filter *InboxFilter) ([]*InboxMessage, error) {
+	if filter == nil {
+		filter = &InboxFilter{}
+	}
+	matchers := make([]q.Matcher, 0)
+	if len(filter.Method) > 0 {
+		matchers = append(matchers, q.Eq("Method", filter.Method))
+	}
+	if filter.PendingOnly {
+		matchers = append(matchers, q.Eq("Status", InboxStatusPending))
+	}
+	if !filter.Since.IsZero() {
+		matchers = append(matchers, q.Gte("ReceivedAt", filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		matchers = append(matchers, q.Lt("ReceivedAt", filter.Until))
+	}
+	messages := make([]*InboxMessage, 0)
+	err := inbox.db.Select(matchers...).OrderBy("Seq").Find(&messages)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// due 已到重试时间的未投递消息，不包括正在投递的消息
+func (inbox *NotificationInbox) due(now time.Time) ([]*InboxMessage, error) {
+	messages := make([]*InboxMessage, 0)
+	err := inbox.db.Select(
+		q.Eq("Status", InboxStatusPending),
+		q.Lte("NextAttempt", now),
+	).OrderBy("Seq").Find(&messages)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+	idle := messages[:0]
+	for _, msg := range messages {
+		if !inbox.leased[msg.ID] {
+			idle = append(idle, msg)
+		}
+	}
+	return idle, nil
+}
+
+// Purge 删除before之前已投递的消息，删除后相同的通知不再被去重
+func (inbox *NotificationInbox) Purge(before time.Time) (int, error) {
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+	query := inbox.db.Select(
+		q.Eq("Status", InboxStatusDelivered),
+		q.Lt("DeliveredAt", before),
+	)
+	count, err := query.Count(&InboxMessage{})
+	if err != nil || count == 0 {
+		return 0, err
+	}
+	if err := query.Delete(&InboxMessage{}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// inboxKey 通知的唯一标识：交易单和合约回执用WxID或txid加所在区块，区块用hash，其余用数据摘要。
+// 分叉后同一交易单的重新打包和回滚通知是不同的消息，不能被去重。
+func inboxKey(method string, data gjson.Result) string {
+	var key string
+	switch method {
+	case SubscribeToTrade:
+		id := data.Get("wxID").String()
+		if len(id) == 0 {
+			id = data.Get("txID").String()
+		}
+		key = blockScopedKey(id, data)
+	case SubscribeToBlock:
+		if hash := data.Get("hash").String(); len(hash) > 0 {
+			key = data.Get("symbol").String() + ":" + hash
+		}
+	case SubscribeToSmartContractReceipt:
+		key = blockScopedKey(data.Get("wxid").String(), data)
+	case SubscribeToNFTTransfer:
+		if txid := data.Get("txID").String(); len(txid) > 0 {
+			key = fmt.Sprintf("%s:%s:%s:%s:%s", data.Get("symbol").String(), txid,
+				data.Get("tokenID").String(), data.Get("from").String(), data.Get("to").String())
+		}
+	}
+	if len(key) == 0 {
+		sum := sha256.Sum256([]byte(data.Raw))
+		key = hex.EncodeToString(sum[:])
+	}
+	return key
+}
+
+// blockScopedKey id加上所在区块的hash和isMain，id为空时返回空
+func blockScopedKey(id string, data gjson.Result) string {
+	if len(id) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s:%d", id, data.Get("blockHash").String(), data.Get("isMain").Int())
+}
+
+// SetNotificationInbox 设置通知收件箱，nil表示关闭
+// 设置后收到的通知先写入收件箱再回复openw-server，并在后台重试投递失败的消息
+func (api *APINode) SetNotificationInbox(inbox *NotificationInbox) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.inboxStop != nil {
+		close(api.inboxStop)
+		api.inboxStop = nil
+	}
+	api.inbox = inbox
+	if inbox != nil {
+		api.inboxStop = make(chan struct{})
+		go api.redeliverLoop(inbox, api.inboxStop)
+	}
+}
+
+// NotificationInbox 当前的通知收件箱
+func (api *APINode) NotificationInbox() *NotificationInbox {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.inbox
+}
+
+// ReplayNotifications 把收件箱中符合条件的消息重新交给观察者，包括已投递的消息
+// 返回观察者接收的数量，遇到投递失败时停止，正在投递的消息跳过
+func (api *APINode) ReplayNotifications(filter *InboxFilter) (int, error) {
+	inbox := api.NotificationInbox()
+	if inbox == nil {
+		return 0, fmt.Errorf("notification inbox is not inited")
+	}
+	messages, err := inbox.Messages(filter)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, msg := range messages {
+		if !inbox.lease(msg.ID) {
+			continue
+		}
+		if err := api.deliverFromInbox(inbox, msg); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// receiveToInbox 通知写入收件箱后尝试投递，写入成功即回复已接收
+func (api *APINode) receiveToInbox(inbox *NotificationInbox, method string, data gjson.Result) (bool, error) {
+	msg, _, leased, err := inbox.put(method, data.Raw)
+	if err != nil {
+		log.Errorf("notification inbox put failed, unexpected error: %v", err)
+		return false, err
+	}
+	if !leased {
+		//已投递，或者正在由其他推送、后台重试投递
+		log.Debugf("duplicate notification ignored: %s", msg.ID)
+		return true, nil
+	}
+	if err := api.deliverFromInbox(inbox, msg); err != nil {
+		log.Warningf("deliver notification %s failed, it will be retried: %v", msg.ID, err)
+	}
+	return true, nil
+}
+
+// deliverFromInbox 投递一条已占用的收件箱消息，记录结果后释放占用
+func (api *APINode) deliverFromInbox(inbox *NotificationInbox, msg *InboxMessage) error {
+	defer inbox.release(msg.ID)
+	accepted, err := api.dispatchNotification(msg.Method, gjson.Parse(msg.Payload))
+	if err == nil && !accepted {
+		err = fmt.Errorf("notification not accepted")
+	}
+	if err != nil {
+		if markErr := inbox.markFailed(msg.ID, err); markErr != nil {
+			log.Errorf("notification inbox update failed, unexpected error: %v", markErr)
+		}
+		return err
+	}
+	return inbox.MarkDelivered(msg.ID)
+}
+
+// redeliverLoop 后台重试投递失败的消息
+func (api *APINode) redeliverLoop(inbox *NotificationInbox, stop chan struct{}) {
+	inbox.mu.Lock()
+	interval := inbox.backoff.InitialInterval
+	inbox.mu.Unlock()
+	if interval <= 0 {
+		interval = DefaultBackoff.InitialInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			messages, err := inbox.due(now)
+			if err != nil {
+				log.Errorf("notification inbox query failed, unexpected error: %v", err)
+				continue
+			}
+			for _, msg := range messages {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if inbox.lease(msg.ID) {
+					api.deliverFromInbox(inbox, msg)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package openwsdk_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+type tradeObserver struct {
+	mu     sync.Mutex
+	accept bool
+	txs    []*openwsdk.Transaction
+}
+
+func (o *tradeObserver) OpenwNewTransactionNotify(transaction *openwsdk.Transaction, subscribeToken string) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.accept {
+		return false, nil
+	}
+	o.txs = append(o.txs, transaction)
+	return true, nil
+}
+
+func (o *tradeObserver) OpenwNewBlockNotify(blockHeader *openwsdk.BlockHeader, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func (o *tradeObserver) OpenwBalanceUpdateNotify(balance *openwsdk.Balance, tokenBalance *openwsdk.TokenBalance, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func (o *tradeObserver) OpenwNewSmartContractReceiptNotify(receipt *openwsdk.SmartContractReceipt, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func (o *tradeObserver) OpenwNFTTransferNotify(transfer *openwsdk.NFTTransfer, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func (o *tradeObserver) setAccept(accept bool) {
+	o.mu.Lock()
+	o.accept = accept
+	o.mu.Unlock()
+}
+
+func (o *tradeObserver) received() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.txs)
+}
+
+func TestNotificationInbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inbox")
+	if err != nil {
+		t.Fatalf("TempDir unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "inbox.db")
+
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	api, err := s.NewAPINode(owtp.Websocket)
+	if err != nil {
+		t.Fatalf("NewAPINode unexpected error: %v", err)
+	}
+	defer api.Close()
+
+	inbox, err := openwsdk.OpenNotificationInbox(path)
+	if err != nil {
+		t.Fatalf("OpenNotificationInbox unexpected error: %v", err)
+	}
+	inbox.SetBackoff(openwsdk.Backoff{InitialInterval: 20 * time.Millisecond, MaxInterval: 20 * time.Millisecond})
+	api.SetNotificationInbox(inbox)
+
+	observer := &tradeObserver{}
+	api.AddObserver(observer)
+	err = openwsdk.NewAPIClient(api).Subscribe(context.Background(), []string{openwsdk.SubscribeToTrade}, "",
+		openwsdk.CallbackModeCurrentConnection, openwsdk.CallbackNode{
+			NodeID:      s.NodeID(),
+			ConnectType: owtp.Websocket,
+		}, "token")
+	if err != nil {
+		t.Fatalf("Subscribe unexpected error: %v", err)
+	}
+
+	//观察者拒绝接收，收件箱已持久化，仍回复已接收
+	tx := &openwsdk.Transaction{WxID: "wx-1", TxID: "tx-1", Symbol: "BTC", BlockHash: "B1", IsMain: 1}
+	txKey := openwsdk.SubscribeToTrade + ":wx-1:B1:1"
+	accepted, err := s.PushTrade(tx)
+	if err != nil || !accepted {
+		t.Fatalf("PushTrade unexpected result: %v, %v", accepted, err)
+	}
+	pending, err := inbox.Pending()
+	if err != nil || len(pending) != 1 || pending[0].ID != txKey {
+		t.Fatalf("Pending unexpected result: %v, %v", pending, err)
+	}
+
+	//后台重试投递
+	observer.setAccept(true)
+	deadline := time.Now().Add(2 * time.Second)
+	for observer.received() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if observer.received() != 1 {
+		t.Fatalf("expected 1 redelivered transaction, got %d", observer.received())
+	}
+
+	//重复推送被去重
+	if accepted, err := s.PushTrade(tx); err != nil || !accepted {
+		t.Fatalf("PushTrade unexpected result: %v, %v", accepted, err)
+	}
+	if observer.received() != 1 {
+		t.Errorf("duplicate notification should not be delivered again, got %d", observer.received())
+	}
+	msg, err := inbox.Get(txKey)
+	if err != nil || msg.Status != openwsdk.InboxStatusDelivered || msg.Received != 2 {
+		t.Errorf("Get unexpected result: %+v, %v", msg, err)
+	}
+
+	//重启后仍能去重和重放
+	api.SetNotificationInbox(nil)
+	inbox.Close()
+	inbox, err = openwsdk.OpenNotificationInbox(path)
+	if err != nil {
+		t.Fatalf("OpenNotificationInbox unexpected error: %v", err)
+	}
+	defer inbox.Close()
+	api.SetNotificationInbox(inbox)
+
+	if accepted, err := s.PushTrade(tx); err != nil || !accepted {
+		t.Fatalf("PushTrade unexpected result: %v, %v", accepted, err)
+	}
+	if accepted, err := s.PushTrade(&openwsdk.Transaction{WxID: "wx-2", TxID: "tx-2", Symbol: "BTC"}); err != nil || !accepted {
+		t.Fatalf("PushTrade unexpected result: %v, %v", accepted, err)
+	}
+	if observer.received() != 2 {
+		t.Fatalf("expected 2 transactions, got %d", observer.received())
+	}
+
+	count, err := api.ReplayNotifications(&openwsdk.InboxFilter{Method: openwsdk.SubscribeToTrade})
+	if err != nil || count != 2 {
+		t.Fatalf("ReplayNotifications unexpected result: %d, %v", count, err)
+	}
+	if observer.received() != 4 || observer.txs[2].WxID != "wx-1" || observer.txs[3].WxID != "wx-2" {
+		t.Errorf("unexpected replayed transactions: %d", observer.received())
+	}
+
+	//分叉回滚和重新打包的通知不会被去重
+	rolledBack := *tx
+	rolledBack.IsMain = 2
+	repacked := *tx
+	repacked.BlockHash = "B2"
+	for _, n := range []*openwsdk.Transaction{&rolledBack, &repacked} {
+		if accepted, err := s.PushTrade(n); err != nil || !accepted {
+			t.Fatalf("PushTrade unexpected result: %v, %v", accepted, err)
+		}
+	}
+	if observer.received() != 6 || observer.txs[4].IsMain != 2 || observer.txs[5].BlockHash != "B2" {
+		t.Errorf("expected rollback and repack notifications delivered, got %d", observer.received())
+	}
+}
+
+func TestNotificationInbox_InFlight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inbox")
+	if err != nil {
+		t.Fatalf("TempDir unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	api, err := s.NewAPINode(owtp.Websocket)
+	if err != nil {
+		t.Fatalf("NewAPINode unexpected error: %v", err)
+	}
+	defer api.Close()
+
+	inbox, err := openwsdk.OpenNotificationInbox(filepath.Join(dir, "inbox.db"))
+	if err != nil {
+		t.Fatalf("OpenNotificationInbox unexpected error: %v", err)
+	}
+	defer inbox.Close()
+	inbox.SetBackoff(openwsdk.Backoff{InitialInterval: 5 * time.Millisecond, MaxInterval: 5 * time.Millisecond})
+	api.SetNotificationInbox(inbox)
+
+	//处理较慢，前两次失败，期间有重复推送和后台重试
+	var (
+		mu        sync.Mutex
+		active    int
+		maxActive int
+		calls     int
+	)
+	api.OnTransaction(func(ctx context.Context, tx *openwsdk.Transaction, subscribeToken string) error {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		time.Sleep(30 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		active--
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("handler failed %d", calls)
+		}
+		return nil
+	})
+	err = openwsdk.NewAPIClient(api).Subscribe(context.Background(), []string{openwsdk.SubscribeToTrade}, "",
+		openwsdk.CallbackModeCurrentConnection, openwsdk.CallbackNode{
+			NodeID:      s.NodeID(),
+			ConnectType: owtp.Websocket,
+		}, "token")
+	if err != nil {
+		t.Fatalf("Subscribe unexpected error: %v", err)
+	}
+
+	tx := &openwsdk.Transaction{WxID: "wx-1", TxID: "tx-1", Symbol: "BTC", BlockHash: "B1", IsMain: 1}
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.PushTrade(tx)
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+
+	testWaitFor(t, "inbox delivered", func() bool {
+		msg, err := inbox.Get(openwsdk.SubscribeToTrade + ":wx-1:B1:1")
+		return err == nil && msg.Status == openwsdk.InboxStatusDelivered
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if maxActive != 1 {
+		t.Errorf("message delivered %d times in parallel", maxActive)
+	}
+	if calls != 3 {
+		t.Errorf("handler expected 3 calls, got %d", calls)
+	}
+}
@@ -0,0 +1,395 @@
+package openwsdk
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	// DefaultPageSize 迭代器默认每页数量
+	DefaultPageSize = 100
+)
+
+// IteratorConfig 迭代器配置
+type IteratorConfig struct {
+	PageSize int  //每页数量，默认DefaultPageSize
+	Prefetch bool //返回当前页时，并发预取下一页
+}
+
+// pageCursor 分页游标，lastID/limit接口使用LastID，offset/limit接口使用Offset
+type pageCursor struct {
+	LastID int64
+	Offset int
+}
+
+// pageResult 一页查询结果
+type pageResult struct {
+	items []interface{}
+	err   error
+}
+
+// pageFetcher 按游标查询一页，返回的记录数小于limit表示已到最后一页
+type pageFetcher func(ctx context.Context, cursor pageCursor, limit int) ([]interface{}, error)
+
+// iterator 通用的分页迭代器，隐藏lastID和offset两种分页方式
+type iterator struct {
+	fetch    pageFetcher
+	idOf     func(item interface{}) int64 //lastID分页时返回记录的id，offset分页时为nil
+	limit    int
+	prefetch bool
+
+	cursor  pageCursor
+	buf     []interface{}
+	pos     int
+	cur     interface{}
+	done    bool
+	err     error
+	pending chan pageResult
+}
+
+func newIterator(fetch pageFetcher, idOf func(item interface{}) int64, config *IteratorConfig) *iterator {
+	it := &iterator{
+		fetch: fetch,
+		idOf:  idOf,
+		limit: DefaultPageSize,
+	}
+	if config != nil {
+		if config.PageSize > 0 {
+			it.limit = config.PageSize
+		}
+		it.prefetch = config.Prefetch
+	}
+	return it
+}
+
+// next 移动到下一条记录，没有更多记录或出错时返回false
+func (it *iterator) next(ctx context.Context) bool {
+	for {
+		if it.pos < len(it.buf) {
+			it.cur = it.buf[it.pos]
+			it.pos++
+			return true
+		}
+		it.cur = nil
+		if it.done || it.err != nil {
+			return false
+		}
+
+		items, err := it.load(ctx)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.advance(items)
+		if it.prefetch && !it.done {
+			it.startPrefetch(ctx)
+		}
+		it.buf = items
+		it.pos = 0
+	}
+}
+
+// load 获取当前游标的一页，优先使用预取结果
+func (it *iterator) load(ctx context.Context) ([]interface{}, error) {
+	if it.pending != nil {
+		pending := it.pending
+		it.pending = nil
+		select {
+		case r := <-pending:
+			//预取时的ctx已结束，当前ctx仍有效则重新查询
+			if !isContextError(r.err) || ctx.Err() != nil {
+				return r.items, r.err
+			}
+		case <-ctx.Done():
+			return nil, contextError(ctx, "", nil)
+		}
+	}
+	return it.fetch(ctx, it.cursor, it.limit)
+}
+
+// advance 根据本页结果推进游标
+func (it *iterator) advance(items []interface{}) {
+	if len(items) < it.limit {
+		it.done = true
+	}
+	if len(items) == 0 {
+		return
+	}
+	if it.idOf != nil {
+		it.cursor.LastID = it.idOf(items[len(items)-1])
+	} else {
+		it.cursor.Offset += len(items)
+	}
+}
+
+func (it *iterator) startPrefetch(ctx context.Context) {
+	pending := make(chan pageResult, 1)
+	cursor := it.cursor
+	go func() {
+		items, err := it.fetch(ctx, cursor, it.limit)
+		pending <- pageResult{items: items, err: err}
+	}()
+	it.pending = pending
+}
+
+// isContextError 是否由context取消或超时引起的错误
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// WalletIterator 钱包迭代器
+type WalletIterator struct {
+	it *iterator
+}
+
+// Next 移动到下一个钱包
+func (i *WalletIterator) Next(ctx context.Context) bool { return i.it.next(ctx) }
+
+// Value 当前钱包
+func (i *WalletIterator) Value() *Wallet {
+	v, _ := i.it.cur.(*Wallet)
+	return v
+}
+
+// Err 迭代过程中的错误
+func (i *WalletIterator) Err() error { return i.it.err }
+
+// AccountIterator 资产账户迭代器
+type AccountIterator struct {
+	it *iterator
+}
+
+// Next 移动到下一个资产账户
+func (i *AccountIterator) Next(ctx context.Context) bool { return i.it.next(ctx) }
+
+// Value 当前资产账户
+func (i *AccountIterator) Value() *Account {
+	v, _ := i.it.cur.(*Account)
+	return v
+}
+
+// Err 迭代过程中的错误
+func (i *AccountIterator) Err() error { return i.it.err }
+
+// AddressIterator 地址迭代器
+type AddressIterator struct {
+	it *iterator
+}
+
+// Next 移动到下一个地址
+func (i *AddressIterator) Next(ctx context.Context) bool { return i.it.next(ctx) }
+
+// Value 当前地址
+func (i *AddressIterator) Value() *Address {
+	v, _ := i.it.cur.(*Address)
+	return v
+}
+
+// Err 迭代过程中的错误
+func (i *AddressIterator) Err() error { return i.it.err }
+
+// TokenContractIterator 代币合约迭代器
+type TokenContractIterator struct {
+	it *iterator
+}
+
+// Next 移动到下一个代币合约
+func (i *TokenContractIterator) Next(ctx context.Context) bool { return i.it.next(ctx) }
+
+// Value 当前代币合约
+func (i *TokenContractIterator) Value() *TokenContract {
+	v, _ := i.it.cur.(*TokenContract)
+	return v
+}
+
+// Err 迭代过程中的错误
+func (i *TokenContractIterator) Err() error { return i.it.err }
+
+// BalanceIterator 余额迭代器
+type BalanceIterator struct {
+	it *iterator
+}
+
+// Next 移动到下一条余额记录
+func (i *BalanceIterator) Next(ctx context.Context) bool { return i.it.next(ctx) }
+
+// Value 当前余额记录
+func (i *BalanceIterator) Value() *BalanceResult {
+	v, _ := i.it.cur.(*BalanceResult)
+	return v
+}
+
+// Err 迭代过程中的错误
+func (i *BalanceIterator) Err() error { return i.it.err }
+
+// TransactionIterator 交易记录迭代器
+type TransactionIterator struct {
+	it *iterator
+}
+
+// Next 移动到下一条交易记录
+func (i *TransactionIterator) Next(ctx context.Context) bool { return i.it.next(ctx) }
+
+// Value 当前交易记录
+func (i *TransactionIterator) Value() *Transaction {
+	v, _ := i.it.cur.(*Transaction)
+	return v
+}
+
+// Err 迭代过程中的错误
+func (i *TransactionIterator) Err() error { return i.it.err }
+
+// IterateWalletByParams 按条件遍历钱包，offset分页
+func (c *APIClient) IterateWalletByParams(params map[string]interface{}, config *IteratorConfig) *WalletIterator {
+	return &WalletIterator{it: newIterator(func(ctx context.Context, cursor pageCursor, limit int) ([]interface{}, error) {
+		wallets, err := c.FindWalletByParams(ctx, copyParams(params), cursor.Offset, limit)
+		items := make([]interface{}, 0, len(wallets))
+		for _, w := range wallets {
+			items = append(items, w)
+		}
+		return items, err
+	}, nil, config)}
+}
+
+// IterateAccountByWalletID 遍历钱包下的资产账户，lastID分页
+func (c *APIClient) IterateAccountByWalletID(symbol, walletID string, config *IteratorConfig) *AccountIterator {
+	return &AccountIterator{it: newIterator(func(ctx context.Context, cursor pageCursor, limit int) ([]interface{}, error) {
+		accounts, err := c.FindAccountByWalletID(ctx, symbol, walletID, cursor.LastID, int64(limit))
+		return accountItems(accounts), err
+	}, idOfAccount, config)}
+}
+
+// IterateAccountByParams 按条件遍历资产账户，offset分页
+func (c *APIClient) IterateAccountByParams(params map[string]interface{}, config *IteratorConfig) *AccountIterator {
+	return &AccountIterator{it: newIterator(func(ctx context.Context, cursor pageCursor, limit int) ([]interface{}, error) {
+		accounts, err := c.FindAccountByParams(ctx, copyParams(params), cursor.Offset, limit)
+		return accountItems(accounts), err
+	}, nil, config)}
+}
+
+// IterateAddressByAccountID 遍历资产账户下的地址，lastID分页
+func (c *APIClient) IterateAddressByAccountID(symbol, accountID string, config *IteratorConfig) *AddressIterator {
+	return &AddressIterator{it: newIterator(func(ctx context.Context, cursor pageCursor, limit int) ([]interface{}, error) {
+		addresses, err := c.FindAddressByAccountID(ctx, symbol, accountID, cursor.LastID, int64(limit))
+		return addressItems(addresses), err
+	}, idOfAddress, config)}
+}
+
+// IterateAddressByParams 按条件遍历地址，offset分页
+func (c *APIClient) IterateAddressByParams(params map[string]interface{}, config *IteratorConfig) *AddressIterator {
+	return &AddressIterator{it: newIterator(func(ctx context.Context, cursor pageCursor, limit int) ([]interface{}, error) {
+		addresses, err := c.FindAddressByParams(ctx, copyParams(params), cursor.Offset, limit)
+		return addressItems(addresses), err
+	}, nil, config)}
+}
+
+// IterateContracts 遍历代币合约，lastID分页
+func (c *APIClient) IterateContracts(symbol, contractID string, config *IteratorConfig) *TokenContractIterator {
+	return &TokenContractIterator{it: newIterator(func(ctx context.Context, cursor pageCursor, limit int) ([]interface{}, error) {
+		contracts, err := c.GetContracts(ctx, symbol, contractID, int(cursor.LastID), limit)
+		items := make([]interface{}, 0, len(contracts))
+		for _, contract := range contracts {
+			items = append(items, contract)
+		}
+		return items, err
+	}, idOfTokenContract, config)}
+}
+
+// IterateAccountBalanceList 遍历账户余额，lastID分页
+// opType 0: 所有，1：主币，2：代币
+func (c *APIClient) IterateAccountBalanceList(walletID, accountID, symbol, contractID string, opType int, config *IteratorConfig) *BalanceIterator {
+	return &BalanceIterator{it: newIterator(func(ctx context.Context, cursor pageCursor, limit int) ([]interface{}, error) {
+		balances, err := c.GetAccountBalanceList(ctx, walletID, accountID, symbol, contractID, opType, int(cursor.LastID), limit)
+		return balanceItems(balances), err
+	}, idOfBalance, config)}
+}
+
+// IterateAddressBalanceList 遍历地址余额，lastID分页
+// opType 0: 所有，1：主币，2：代币
+func (c *APIClient) IterateAddressBalanceList(walletID, accountID, address, symbol, contractID string, opType int, config *IteratorConfig) *BalanceIterator {
+	return &BalanceIterator{it: newIterator(func(ctx context.Context, cursor pageCursor, limit int) ([]interface{}, error) {
+		balances, err := c.GetAddressBalanceList(ctx, walletID, accountID, address, symbol, contractID, opType, int(cursor.LastID), limit)
+		return balanceItems(balances), err
+	}, idOfBalance, config)}
+}
+
+// IterateTradeLog 遍历交易记录，offset分页
+func (c *APIClient) IterateTradeLog(
+	walletID string,
+	accountID string,
+	symbol string,
+	txid string,
+	address string,
+	isTmp int,
+	orderType int,
+	startHeight int64,
+	endHeight int64,
+	height int64,
+	isDesc bool,
+	config *IteratorConfig,
+) *TransactionIterator {
+	return &TransactionIterator{it: newIterator(func(ctx context.Context, cursor pageCursor, limit int) ([]interface{}, error) {
+		txs, err := c.FindTradeLog(ctx, walletID, accountID, symbol, txid, address, isTmp, orderType,
+			startHeight, endHeight, height, isDesc, cursor.Offset, limit)
+		return transactionItems(txs), err
+	}, nil, config)}
+}
+
+// IterateTradeLogByParams 按条件遍历交易记录，offset分页
+func (c *APIClient) IterateTradeLogByParams(params map[string]interface{}, config *IteratorConfig) *TransactionIterator {
+	return &TransactionIterator{it: newIterator(func(ctx context.Context, cursor pageCursor, limit int) ([]interface{}, error) {
+		query := copyParams(params)
+		query["offset"] = cursor.Offset
+		query["limit"] = limit
+		txs, err := c.FindTradeLogByParams(ctx, query)
+		return transactionItems(txs), err
+	}, nil, config)}
+}
+
+func idOfAccount(item interface{}) int64 {
+	return item.(*Account).Id
+}
+
+func idOfAddress(item interface{}) int64 {
+	return item.(*Address).Id
+}
+
+func idOfTokenContract(item interface{}) int64 {
+	return item.(*TokenContract).Id
+}
+
+func idOfBalance(item interface{}) int64 {
+	return item.(*BalanceResult).ID
+}
+
+func accountItems(accounts []*Account) []interface{} {
+	items := make([]interface{}, 0, len(accounts))
+	for _, a := range accounts {
+		items = append(items, a)
+	}
+	return items
+}
+
+func addressItems(addresses []*Address) []interface{} {
+	items := make([]interface{}, 0, len(addresses))
+	for _, a := range addresses {
+		items = append(items, a)
+	}
+	return items
+}
+
+func balanceItems(balances []*BalanceResult) []interface{} {
+	items := make([]interface{}, 0, len(balances))
+	for _, b := range balances {
+		items = append(items, b)
+	}
+	return items
+}
+
+func transactionItems(txs []*Transaction) []interface{} {
+	items := make([]interface{}, 0, len(txs))
+	for _, tx := range txs {
+		items = append(items, tx)
+	}
+	return items
+}
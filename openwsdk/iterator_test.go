@@ -0,0 +1,90 @@
+package openwsdk_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func testNewIteratorClient(t *testing.T, addressCount int) (*openwsdktest.Server, *openwsdk.APIClient) {
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	s.AddAccount(&openwsdk.Account{WalletID: "W1", AccountID: "A1", Symbol: "BTC"})
+	for i := 0; i < addressCount; i++ {
+		s.AddAddress(&openwsdk.Address{WalletID: "W1", AccountID: "A1", Symbol: "BTC", Address: fmt.Sprintf("addr-%d", i)})
+	}
+	api, err := s.NewAPINode(owtp.HTTP)
+	if err != nil {
+		s.Close()
+		t.Fatalf("NewAPINode unexpected error: %v", err)
+	}
+	return s, openwsdk.NewAPIClient(api)
+}
+
+func TestAddressIterator(t *testing.T) {
+	s, client := testNewIteratorClient(t, 25)
+	defer s.Close()
+
+	ctx := context.Background()
+	iterators := map[string]*openwsdk.AddressIterator{
+		"lastID":          client.IterateAddressByAccountID("BTC", "A1", &openwsdk.IteratorConfig{PageSize: 10}),
+		"offset":          client.IterateAddressByParams(map[string]interface{}{"accountID": "A1"}, &openwsdk.IteratorConfig{PageSize: 10}),
+		"lastID prefetch": client.IterateAddressByAccountID("BTC", "A1", &openwsdk.IteratorConfig{PageSize: 10, Prefetch: true}),
+		"offset prefetch": client.IterateAddressByParams(map[string]interface{}{"accountID": "A1"}, &openwsdk.IteratorConfig{PageSize: 5, Prefetch: true}),
+		"exact page":      client.IterateAddressByAccountID("BTC", "A1", &openwsdk.IteratorConfig{PageSize: 25}),
+	}
+	for name, it := range iterators {
+		count := 0
+		for it.Next(ctx) {
+			if want := fmt.Sprintf("addr-%d", count); it.Value().Address != want {
+				t.Errorf("%s: expected %s, got %s", name, want, it.Value().Address)
+			}
+			count++
+		}
+		if it.Err() != nil {
+			t.Errorf("%s: unexpected error: %v", name, it.Err())
+		}
+		if count != 25 {
+			t.Errorf("%s: expected 25 addresses, got %d", name, count)
+		}
+		if it.Next(ctx) || it.Value() != nil {
+			t.Errorf("%s: iterator should stay exhausted", name)
+		}
+	}
+}
+
+func TestAddressIterator_Error(t *testing.T) {
+	s, client := testNewIteratorClient(t, 15)
+	defer s.Close()
+
+	calls := 0
+	s.HandleFunc("findAddressByAccountID", func(ctx *owtp.Context) {
+		calls++
+		if calls > 1 {
+			ctx.Response(nil, openwallet.ErrAccountNotFound, "account not found")
+			return
+		}
+		ctx.Response([]*openwsdk.Address{{Id: 1, Address: "addr-0"}, {Id: 2, Address: "addr-1"}}, owtp.StatusSuccess, "success")
+	})
+
+	ctx := context.Background()
+	it := client.IterateAddressByAccountID("BTC", "A1", &openwsdk.IteratorConfig{PageSize: 2})
+	count := 0
+	for it.Next(ctx) {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected 2 addresses before error, got %d", count)
+	}
+	if !errors.Is(it.Err(), openwsdk.ErrUnknownAccount) {
+		t.Errorf("expected ErrUnknownAccount, got: %v", it.Err())
+	}
+}
@@ -3,18 +3,20 @@ package openwsdk
 import (
 	"encoding/json"
 	"github.com/blocktree/go-owcrypt"
-	"github.com/blocktree/openwallet/v2/common/file"
 	"github.com/blocktree/openwallet/v2/hdkeystore"
 	"github.com/blocktree/openwallet/v2/log"
-	"path/filepath"
+	"io/ioutil"
+	"os"
 	"testing"
 )
 
 func TestWallet_CreateAccount(t *testing.T) {
 
-	keypath := filepath.Join("testkeys")
-
-	file.MkdirAll(keypath)
+	keypath, err := ioutil.TempDir("", "testkeys")
+	if err != nil {
+		t.Fatalf("TempDir unexpected error: %v", err)
+	}
+	defer os.RemoveAll(keypath)
 
 	name := "gooaglag"
 	password := "1234qwer"
@@ -0,0 +1,230 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package openwsdktest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// setupContractHandlers 注册智能合约相关方法
+func (s *Server) setupContractHandlers() {
+	s.node.HandleFunc("callSmartContractABI", s.callSmartContractABI)
+	s.node.HandleFunc("createSmartContractTrade", s.createSmartContractTrade)
+	s.node.HandleFunc("submitSmartContractTrade", s.submitSmartContractTrade)
+	s.node.HandleFunc("findSmartContractReceipt", s.findSmartContractReceipt)
+	s.node.HandleFunc("followSmartContractReceipt", s.followSmartContractReceipt)
+}
+
+func (s *Server) callSmartContractABI(ctx *owtp.Context) {
+	data := ctx.Params()
+	method := ""
+	if abiParam := data.Get("abiParam").Array(); len(abiParam) > 0 {
+		method = abiParam[0].String()
+	}
+	ctx.Response(&openwsdk.SmartContractCallResult{
+		Method: method,
+		Value:  "{}",
+		Status: 0,
+	}, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) createSmartContractTrade(ctx *owtp.Context) {
+	data := ctx.Params()
+	accountID := data.Get("accountID").String()
+	sid := data.Get("sid").String()
+
+	var coin openwsdk.Coin
+	json.Unmarshal([]byte(data.Get("coin").Raw), &coin)
+	abiParam := make([]string, 0)
+	for _, p := range data.Get("abiParam").Array() {
+		abiParam = append(abiParam, p.String())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account := s.findAccount(accountID)
+	if account == nil {
+		ctx.Response(nil, openwallet.ErrAccountNotFound, "account not found")
+		return
+	}
+	if len(sid) == 0 {
+		ctx.Response(nil, owtp.ErrBadRequest, "sid is empty")
+		return
+	}
+	if s.sids[sid] {
+		ctx.Response(nil, openwallet.ErrCreateRawTransactionFailed, ErrSidAlreadyExist)
+		return
+	}
+	symbol, ok := s.symbols[coin.Symbol]
+	if !ok {
+		ctx.Response(nil, openwallet.ErrCreateRawTransactionFailed, "symbol not support")
+		return
+	}
+
+	fromAddress := accountID
+	derivedPath := account.HdPath + "/0/0"
+	for _, a := range s.addresses {
+		if a.AccountID == accountID {
+			fromAddress = a.Address
+			derivedPath = a.HdPath
+			break
+		}
+	}
+
+	feeRate := data.Get("feeRate").String()
+	if len(feeRate) == 0 {
+		feeRate = symbol.FeeRate
+	}
+	msg := sha256.Sum256([]byte(accountID + sid + data.Get("raw").String() + data.Get("abiParam").Raw))
+	rawTx := &openwsdk.SmartContractRawTransaction{
+		Coin:      coin,
+		Sid:       sid,
+		AccountID: accountID,
+		Raw:       data.Get("raw").String(),
+		RawType:   data.Get("rawType").Uint(),
+		ABIParam:  abiParam,
+		Value:     data.Get("value").String(),
+		FeeRate:   feeRate,
+		Fees:      feeRate,
+		Signatures: map[string][]*openwsdk.KeySignature{
+			accountID: {
+				&openwsdk.KeySignature{
+					EccType:     uint32(symbol.Curve),
+					Address:     fromAddress,
+					Message:     hex.EncodeToString(msg[:]),
+					DerivedPath: derivedPath,
+					WalletID:    account.WalletID,
+				},
+			},
+		},
+	}
+	s.contractTxs[sid] = rawTx
+	ctx.Response(rawTx, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) submitSmartContractTrade(ctx *owtp.Context) {
+	var rawTxs []*openwsdk.SmartContractRawTransaction
+	if err := json.Unmarshal([]byte(ctx.Params().Get("rawTx").Raw), &rawTxs); err != nil {
+		ctx.Response(nil, owtp.ErrBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	success := make([]*openwsdk.SmartContractReceipt, 0)
+	failure := make([]*openwsdk.FailureSmartContractLog, 0)
+	for _, rawTx := range rawTxs {
+		receipt, reason := s.broadcastContract(rawTx)
+		if len(reason) > 0 {
+			failure = append(failure, &openwsdk.FailureSmartContractLog{RawTx: rawTx, Reason: reason})
+			continue
+		}
+		success = append(success, receipt)
+	}
+
+	ctx.Response(map[string]interface{}{
+		"success": success,
+		"failure": failure,
+	}, owtp.StatusSuccess, "success")
+}
+
+// broadcastContract 模拟广播智能合约交易单，调用方需持有写锁
+func (s *Server) broadcastContract(rawTx *openwsdk.SmartContractRawTransaction) (*openwsdk.SmartContractReceipt, string) {
+	if s.sids[rawTx.Sid] {
+		return nil, ErrSidAlreadyExist
+	}
+	if _, ok := s.contractTxs[rawTx.Sid]; !ok {
+		return nil, "raw transaction not found"
+	}
+	fromAddress := ""
+	for _, keySignatures := range rawTx.Signatures {
+		for _, ks := range keySignatures {
+			if ks == nil || len(ks.Signature) == 0 {
+				return nil, "raw transaction is not signed"
+			}
+			fromAddress = ks.Address
+		}
+	}
+	if len(fromAddress) == 0 {
+		return nil, "raw transaction is not signed"
+	}
+
+	now := time.Now().Unix()
+	txHash := sha256.Sum256([]byte(rawTx.Sid + rawTx.Raw))
+	txid := hex.EncodeToString(txHash[:])
+	receipt := &openwsdk.SmartContractReceipt{
+		WxID:         openwallet.GenTransactionWxID2(txid, rawTx.Coin.Symbol, rawTx.Coin.ContractID),
+		TxID:         txid,
+		FromAddress:  fromAddress,
+		ToAddress:    rawTx.Coin.ContractAddress,
+		Value:        rawTx.Value,
+		Fees:         rawTx.Fees,
+		Symbol:       rawTx.Coin.Symbol,
+		ContractID:   rawTx.Coin.ContractID,
+		ContractAddr: rawTx.Coin.ContractAddress,
+		IsMain:       1,
+		Applytime:    now,
+		SubmitTime:   now,
+		Succtime:     now,
+		Dealstate:    2,
+		Status:       "1",
+		Success:      "1",
+		RawReceipt:   "{}",
+		Events:       make([]*openwsdk.SmartContractEvent, 0),
+	}
+
+	s.receipts = append(s.receipts, receipt)
+	s.receiptSids[receipt.WxID] = rawTx.Sid
+	s.sids[rawTx.Sid] = true
+	delete(s.contractTxs, rawTx.Sid)
+	rawTx.TxID = txid
+	return receipt, ""
+}
+
+func (s *Server) findSmartContractReceipt(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	sid := data.Get("sid").String()
+	result := make([]*openwsdk.SmartContractReceipt, 0)
+	for _, r := range s.receipts {
+		if len(sid) > 0 && s.receiptSids[r.WxID] != sid {
+			continue
+		}
+		if matchParams(r, data, "wxid", "txid", "symbol", "contractID", "contractAddr", "fromAddress") {
+			result = append(result, r)
+		}
+	}
+	s.mu.RUnlock()
+	ctx.Response(pageOffset(result, data), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) followSmartContractReceipt(ctx *owtp.Context) {
+	s.mu.Lock()
+	for _, c := range ctx.Params().Get("followContracts").Array() {
+		s.followed[c.String()] = true
+	}
+	s.mu.Unlock()
+	ctx.Response(nil, owtp.StatusSuccess, "success")
+}
@@ -0,0 +1,1001 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package openwsdktest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/openwallet/v2/owtp"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// ErrSidAlreadyExist 业务订单号重复时返回的消息
+	ErrSidAlreadyExist = "sid already exist"
+)
+
+// setupHandlers 注册服务端方法
+func (s *Server) setupHandlers() {
+	s.node.HandlePrepareFunc(s.prepare)
+	s.node.HandleFinishFunc(s.finish)
+
+	s.node.HandleFunc("bindAppDevice", s.bindAppDevice)
+	s.node.HandleFunc("getNodeInfo", s.getNodeInfo)
+	s.node.HandleFunc("subscribe", s.subscribe)
+	s.node.HandleFunc("getSymbolBlockList", s.getSymbolBlockList)
+	s.node.HandleFunc("createWallet", s.createWallet)
+	s.node.HandleFunc("findWalletByWalletID", s.findWalletByWalletID)
+	s.node.HandleFunc("findWalletByParams", s.findWalletByParams)
+	s.node.HandleFunc("createAccount", s.createAccount)
+	s.node.HandleFunc("findAccountByAccountID", s.findAccountByAccountID)
+	s.node.HandleFunc("findAccountByWalletID", s.findAccountByWalletID)
+	s.node.HandleFunc("findAccountByParams", s.findAccountByParams)
+	s.node.HandleFunc("createAddress", s.createAddress)
+	s.node.HandleFunc("createBatchAddress", s.createBatchAddress)
+	s.node.HandleFunc("findAddressByAddress", s.findAddressByAddress)
+	s.node.HandleFunc("findAddressByAccountID", s.findAddressByAccountID)
+	s.node.HandleFunc("findAddressByParams", s.findAddressByParams)
+	s.node.HandleFunc("createTrade", s.createTrade)
+	s.node.HandleFunc("submitTrade", s.submitTrade)
+	s.node.HandleFunc("findTradeLog", s.findTradeLog)
+	s.node.HandleFunc("getContracts", s.getContracts)
+	s.node.HandleFunc("getBalanceByAccount", s.getBalanceByAccount)
+	s.node.HandleFunc("getBalanceByAddress", s.getBalanceByAddress)
+	s.node.HandleFunc("getAccountBalanceList", s.getAccountBalanceList)
+	s.node.HandleFunc("getAddressBalanceList", s.getAddressBalanceList)
+	s.node.HandleFunc("getFeeRate", s.getFeeRate)
+	s.node.HandleFunc("getFeeRateList", s.getFeeRateList)
+	s.node.HandleFunc("verifyAddress", s.verifyAddress)
+
+	s.setupContractHandlers()
+}
+
+// prepare 记录调用次数，注入处理前的故障，检查设备绑定
+func (s *Server) prepare(ctx *owtp.Context) {
+	s.mu.Lock()
+	s.calls[ctx.Method]++
+	f := s.takeFault(ctx.Method, false)
+	s.mu.Unlock()
+	if f != nil {
+		ctx.ResponseStopRun(nil, f.status, "injected failure")
+		return
+	}
+	s.checkDevice(ctx)
+}
+
+// finish 注入处理后的故障，覆盖已处理请求的响应
+func (s *Server) finish(ctx *owtp.Context) {
+	s.mu.Lock()
+	f := s.takeFault(ctx.Method, true)
+	s.mu.Unlock()
+	if f != nil {
+		ctx.Response(nil, f.status, "injected failure after process")
+	}
+}
+
+// takeFault 取出一次待注入的故障，调用方需持有写锁
+func (s *Server) takeFault(method string, processed bool) *fault {
+	f, ok := s.faults[method]
+	if !ok || f.processed != processed {
+		return nil
+	}
+	f.count--
+	if f.count <= 0 {
+		delete(s.faults, method)
+	}
+	return f
+}
+
+// checkDevice 未绑定的设备不能调用业务方法
+func (s *Server) checkDevice(ctx *owtp.Context) {
+	if ctx.Method == "bindAppDevice" || ctx.Method == "getNodeInfo" {
+		return
+	}
+	//同一设备可以绑定多个应用，请求的appID需要已绑定
+	appID := ctx.Params().Get("appID").String()
+	s.mu.RLock()
+	apps := s.devices[ctx.PID]
+	bound := len(apps) > 0 && (len(appID) == 0 || apps[appID])
+	require := s.requireBind
+	s.mu.RUnlock()
+	if require && !bound {
+		ctx.ResponseStopRun(nil, owtp.ErrUnauthorized, "device is not bound")
+	}
+}
+
+func (s *Server) appKey(appID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	appKey, ok := s.apps[appID]
+	return appKey, ok
+}
+
+func (s *Server) bindAppDevice(ctx *owtp.Context) {
+	data := ctx.Params()
+	appID := data.Get("appID").String()
+	deviceID := data.Get("deviceID").String()
+	if deviceID != ctx.PID {
+		ctx.Response(nil, owtp.ErrUnauthorized, "invalid device")
+		return
+	}
+	if err := s.verifier.VerifyContext(ctx, "accessTime", "deviceID"); err != nil {
+		ctx.Response(nil, owtp.ErrUnauthorized, err.Error())
+		return
+	}
+	s.mu.Lock()
+	if s.devices[deviceID] == nil {
+		s.devices[deviceID] = make(map[string]bool)
+	}
+	s.devices[deviceID][appID] = true
+	s.mu.Unlock()
+	ctx.Response(nil, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) getNodeInfo(ctx *owtp.Context) {
+	if err := s.verifier.VerifyContext(ctx, "time"); err != nil {
+		ctx.Response(nil, owtp.ErrUnauthorized, err.Error())
+		return
+	}
+	//websocket客户端以HostNodeID识别当前连接的推送，HTTP客户端只能由服务节点新建连接推送
+	nodeID := s.node.NodeID()
+	if ctx.Peer != nil && ctx.Peer.ConnectConfig().ConnectType == owtp.Websocket {
+		nodeID = openwsdk.HostNodeID
+	}
+	_, pubKey := s.node.Certificate().KeyPair()
+	s.mu.RLock()
+	now := s.clock.Now()
+	s.mu.RUnlock()
+	ctx.Response(map[string]interface{}{
+		"pubKey": pubKey,
+		"nodeID": nodeID,
+		"time":   now.UnixNano(),
+	}, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) subscribe(ctx *owtp.Context) {
+	data := ctx.Params()
+	sub := &subscription{
+		AppID:          data.Get("appID").String(),
+		PeerID:         ctx.PID,
+		Methods:        make(map[string]bool),
+		CallbackMode:   int(data.Get("callbackMode").Int()),
+		CallbackNode:   *openwsdk.NewCallbackNode(data.Get("callbackNode")),
+		SubscribeToken: data.Get("subscribeToken").String(),
+	}
+	for _, m := range data.Get("subscribeMethod").Array() {
+		sub.Methods[m.String()] = true
+	}
+	if sub.CallbackMode == openwsdk.CallbackModeNewConnection && len(sub.CallbackNode.Address) == 0 {
+		ctx.Response(nil, owtp.ErrBadRequest, "callbackNode address is empty")
+		return
+	}
+	s.mu.Lock()
+	s.subscriptions[ctx.PID] = sub
+	s.mu.Unlock()
+	ctx.Response(nil, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) getSymbolBlockList(ctx *owtp.Context) {
+	data := ctx.Params()
+	symbol := data.Get("symbol").String()
+
+	s.mu.RLock()
+	names := make([]string, 0, len(s.symbols))
+	for name := range s.symbols {
+		if len(symbol) == 0 || symbol == name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	result := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		//同时返回主链信息和最新区块头
+		obj := toMap(s.symbols[name])
+		height := s.heights[name]
+		obj["height"] = height
+		obj["maxHeight"] = height
+		hash, ok := s.blocks[name][height]
+		if !ok {
+			hash = blockHash(name, height, 0)
+		}
+		obj["hash"] = hash
+		result = append(result, obj)
+	}
+	s.mu.RUnlock()
+
+	ctx.Response(pageOffset(result, data), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) createWallet(ctx *owtp.Context) {
+	data := ctx.Params()
+	walletID := data.Get("walletID").String()
+	if len(walletID) == 0 {
+		ctx.Response(nil, owtp.ErrBadRequest, "walletID is empty")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.findWallet(walletID) != nil {
+		ctx.Response(nil, openwallet.ErrUnknownException, "wallet already exist")
+		return
+	}
+	wallet := &openwsdk.Wallet{
+		AppID:    data.Get("appID").String(),
+		WalletID: walletID,
+		Alias:    data.Get("alias").String(),
+		IsTrust:  data.Get("isTrust").Int(),
+		AuthKey:  data.Get("authKey").String(),
+		RootPath: data.Get("rootPath").String(),
+	}
+	s.wallets = append(s.wallets, wallet)
+	ctx.Response(wallet, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) findWalletByWalletID(ctx *owtp.Context) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	wallet := s.findWallet(ctx.Params().Get("walletID").String())
+	if wallet == nil {
+		ctx.Response(nil, openwallet.ErrUnknownException, "wallet not found")
+		return
+	}
+	ctx.Response(wallet, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) findWalletByParams(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	result := make([]*openwsdk.Wallet, 0)
+	for _, w := range s.wallets {
+		if matchParams(w, data, "walletID", "alias", "appID") {
+			result = append(result, w)
+		}
+	}
+	s.mu.RUnlock()
+	ctx.Response(pageOffset(result, data), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) createAccount(ctx *owtp.Context) {
+	data := ctx.Params()
+	accountID := data.Get("accountID").String()
+	symbol := data.Get("symbol").String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findWallet(data.Get("walletID").String()) == nil {
+		ctx.Response(nil, openwallet.ErrUnknownException, "wallet not found")
+		return
+	}
+	if _, ok := s.symbols[symbol]; !ok {
+		ctx.Response(nil, openwallet.ErrUnknownException, "symbol not support")
+		return
+	}
+	if len(accountID) == 0 || s.findAccount(accountID) != nil {
+		ctx.Response(nil, openwallet.ErrUnknownException, "accountID is empty or already exist")
+		return
+	}
+
+	account := &openwsdk.Account{
+		Id:           s.nextID(),
+		AppID:        data.Get("appID").String(),
+		WalletID:     data.Get("walletID").String(),
+		AccountID:    accountID,
+		Alias:        data.Get("alias").String(),
+		Symbol:       symbol,
+		MainSymbol:   symbol,
+		ReqSigs:      data.Get("reqSigs").Int(),
+		IsTrust:      data.Get("isTrust").Int(),
+		PublicKey:    data.Get("publicKey").String(),
+		HdPath:       data.Get("hdPath").String(),
+		AccountIndex: data.Get("accountIndex").Int(),
+	}
+	s.accounts = append(s.accounts, account)
+
+	addresses := make([]*openwsdk.Address, 0)
+	if data.Get("onlyAccount").Int() != 1 {
+		addresses = append(addresses, s.newAddress(account))
+	}
+
+	ctx.Response(map[string]interface{}{
+		"account":   account,
+		"addresses": addresses,
+	}, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) findAccountByAccountID(ctx *owtp.Context) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	account := s.findAccount(ctx.Params().Get("accountID").String())
+	if account == nil {
+		ctx.Response(nil, openwallet.ErrAccountNotFound, "account not found")
+		return
+	}
+	ctx.Response(account, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) findAccountByWalletID(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	result := make([]*openwsdk.Account, 0)
+	for _, a := range s.accounts {
+		if matchParams(a, data, "walletID", "remark") && matchSymbol(a.Symbol, data) {
+			result = append(result, a)
+		}
+	}
+	s.mu.RUnlock()
+	ctx.Response(pageLastID(result, data), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) findAccountByParams(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	result := make([]*openwsdk.Account, 0)
+	for _, a := range s.accounts {
+		if matchParams(a, data, "walletID", "accountID", "alias", "remark") && matchSymbol(a.Symbol, data) {
+			result = append(result, a)
+		}
+	}
+	s.mu.RUnlock()
+	ctx.Response(pageOffset(result, data), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) createAddress(ctx *owtp.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addresses, ok := s.createAddresses(ctx)
+	if !ok {
+		return
+	}
+	ctx.Response(addresses, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) createBatchAddress(ctx *owtp.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addresses, ok := s.createAddresses(ctx)
+	if !ok {
+		return
+	}
+	result := make([]string, 0, len(addresses))
+	for _, a := range addresses {
+		result = append(result, a.Address)
+	}
+	ctx.Response(result, owtp.StatusSuccess, "success")
+}
+
+// createAddresses 调用方需持有写锁
+func (s *Server) createAddresses(ctx *owtp.Context) ([]*openwsdk.Address, bool) {
+	data := ctx.Params()
+	account := s.findAccount(data.Get("accountID").String())
+	if account == nil {
+		ctx.Response(nil, openwallet.ErrAccountNotFound, "account not found")
+		return nil, false
+	}
+	count := data.Get("count").Int()
+	if count <= 0 {
+		count = 1
+	}
+	addresses := make([]*openwsdk.Address, 0, count)
+	for i := int64(0); i < count; i++ {
+		addresses = append(addresses, s.newAddress(account))
+	}
+	return addresses, true
+}
+
+func (s *Server) findAddressByAddress(ctx *owtp.Context) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	address := s.findAddress(ctx.Params().Get("address").String())
+	if address == nil {
+		ctx.Response(nil, openwallet.ErrAddressNotFound, "address not found")
+		return
+	}
+	ctx.Response(address, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) findAddressByAccountID(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	result := make([]*openwsdk.Address, 0)
+	for _, a := range s.addresses {
+		if matchParams(a, data, "accountID") && matchSymbol(a.Symbol, data) {
+			result = append(result, a)
+		}
+	}
+	s.mu.RUnlock()
+	ctx.Response(pageLastID(result, data), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) findAddressByParams(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	result := make([]*openwsdk.Address, 0)
+	for _, a := range s.addresses {
+		if matchParams(a, data, "walletID", "accountID", "address", "alias") && matchSymbol(a.Symbol, data) {
+			result = append(result, a)
+		}
+	}
+	s.mu.RUnlock()
+	ctx.Response(pageOffset(result, data), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) createTrade(ctx *owtp.Context) {
+	data := ctx.Params()
+	accountID := data.Get("accountID").String()
+	sid := data.Get("sid").String()
+
+	var coin openwsdk.Coin
+	json.Unmarshal([]byte(data.Get("coin").Raw), &coin)
+	to := make(map[string]string)
+	for addr, amount := range data.Get("to").Map() {
+		to[addr] = amount.String()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account := s.findAccount(accountID)
+	if account == nil {
+		ctx.Response(nil, openwallet.ErrAccountNotFound, "account not found")
+		return
+	}
+	if len(sid) == 0 {
+		ctx.Response(nil, owtp.ErrBadRequest, "sid is empty")
+		return
+	}
+	if s.sids[sid] {
+		ctx.Response(nil, openwallet.ErrCreateRawTransactionFailed, ErrSidAlreadyExist)
+		return
+	}
+	if len(to) == 0 {
+		ctx.Response(nil, owtp.ErrBadRequest, "to is empty")
+		return
+	}
+	symbol, ok := s.symbols[coin.Symbol]
+	if !ok {
+		ctx.Response(nil, openwallet.ErrCreateRawTransactionFailed, "symbol not support")
+		return
+	}
+
+	amount := decimal.Zero
+	for _, v := range to {
+		d, err := decimal.NewFromString(v)
+		if err != nil || d.Sign() <= 0 {
+			ctx.Response(nil, owtp.ErrBadRequest, "invalid amount: "+v)
+			return
+		}
+		amount = amount.Add(d)
+	}
+
+	feeRate := data.Get("feeRate").String()
+	if len(feeRate) == 0 {
+		feeRate = symbol.FeeRate
+	}
+	fees, _ := decimal.NewFromString(feeRate)
+
+	//余额检查
+	if coin.IsContract {
+		if s.decimalBalance(accountID, coin.ContractID).LessThan(amount) {
+			ctx.Response(nil, openwallet.ErrInsufficientTokenBalanceOfAddress, "insufficient token balance")
+			return
+		}
+		if s.decimalBalance(accountID, "").LessThan(fees) {
+			ctx.Response(nil, openwallet.ErrInsufficientFees, "insufficient fees")
+			return
+		}
+	} else if s.decimalBalance(accountID, "").LessThan(amount.Add(fees)) {
+		ctx.Response(nil, openwallet.ErrInsufficientBalanceOfAccount, "insufficient balance")
+		return
+	}
+
+	derivedPath := account.HdPath + "/0/0"
+	fromAddress := accountID
+	for _, a := range s.addresses {
+		if a.AccountID == accountID {
+			fromAddress = a.Address
+			if len(a.HdPath) > 0 {
+				derivedPath = a.HdPath
+			}
+			break
+		}
+	}
+
+	msg := sha256.Sum256([]byte(fmt.Sprintf("%s%s%v%s", accountID, sid, to, fees.String())))
+	rawTx := &openwsdk.RawTransaction{
+		Coin:      coin,
+		Sid:       sid,
+		RawHex:    hex.EncodeToString(msg[:]),
+		FeeRate:   feeRate,
+		To:        to,
+		AccountID: accountID,
+		Required:  1,
+		Fees:      fees.String(),
+		Signatures: map[string][]*openwsdk.KeySignature{
+			accountID: {
+				&openwsdk.KeySignature{
+					EccType:     uint32(symbol.Curve),
+					Address:     fromAddress,
+					Message:     hex.EncodeToString(msg[:]),
+					DerivedPath: derivedPath,
+					WalletID:    account.WalletID,
+				},
+			},
+		},
+	}
+	s.rawTxs[sid] = rawTx
+	ctx.Response(rawTx, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) submitTrade(ctx *owtp.Context) {
+	var rawTxs []*openwsdk.RawTransaction
+	if err := json.Unmarshal([]byte(ctx.Params().Get("rawTx").Raw), &rawTxs); err != nil {
+		ctx.Response(nil, owtp.ErrBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	success := make([]*openwsdk.Transaction, 0)
+	failure := make([]*openwsdk.FailedRawTransaction, 0)
+	for _, rawTx := range rawTxs {
+		tx, reason := s.broadcast(rawTx)
+		if len(reason) > 0 {
+			failure = append(failure, &openwsdk.FailedRawTransaction{RawTx: rawTx, Reason: reason})
+			continue
+		}
+		success = append(success, tx)
+	}
+
+	ctx.Response(map[string]interface{}{
+		"success": success,
+		"failure": failure,
+	}, owtp.StatusSuccess, "success")
+}
+
+// broadcast 模拟广播交易单，调用方需持有写锁
+func (s *Server) broadcast(rawTx *openwsdk.RawTransaction) (*openwsdk.Transaction, string) {
+	if s.sids[rawTx.Sid] {
+		return nil, ErrSidAlreadyExist
+	}
+	created, ok := s.rawTxs[rawTx.Sid]
+	if !ok || created.AccountID != rawTx.AccountID || created.RawHex != rawTx.RawHex {
+		return nil, "raw transaction not found"
+	}
+	if len(rawTx.Signatures) == 0 {
+		return nil, "raw transaction is not signed"
+	}
+	for _, keySignatures := range rawTx.Signatures {
+		for _, ks := range keySignatures {
+			if ks == nil || len(ks.Signature) == 0 {
+				return nil, "raw transaction is not signed"
+			}
+		}
+	}
+
+	account := s.findAccount(rawTx.AccountID)
+	symbol := s.symbols[rawTx.Coin.Symbol]
+	amount := decimal.Zero
+	toAddress := make([]string, 0, len(rawTx.To))
+	for addr := range rawTx.To {
+		toAddress = append(toAddress, addr)
+	}
+	sort.Strings(toAddress)
+	toAddressV := make([]string, 0, len(toAddress))
+	for _, addr := range toAddress {
+		d, _ := decimal.NewFromString(rawTx.To[addr])
+		amount = amount.Add(d)
+		toAddressV = append(toAddressV, rawTx.To[addr])
+	}
+	fees, _ := decimal.NewFromString(rawTx.Fees)
+
+	//扣减余额
+	if rawTx.Coin.IsContract {
+		s.addBalance(rawTx.AccountID, rawTx.Coin.ContractID, amount.Neg())
+		s.addBalance(rawTx.AccountID, "", fees.Neg())
+	} else {
+		s.addBalance(rawTx.AccountID, "", amount.Add(fees).Neg())
+	}
+
+	now := time.Now().Unix()
+	txHash := sha256.Sum256([]byte(rawTx.Sid + rawTx.RawHex))
+	txid := hex.EncodeToString(txHash[:])
+	fromAddress := ""
+	for _, ks := range rawTx.Signatures[rawTx.AccountID] {
+		fromAddress = ks.Address
+	}
+	isContract := int64(0)
+	if rawTx.Coin.IsContract {
+		isContract = 1
+	}
+
+	tx := &openwsdk.Transaction{
+		Id:           s.nextID(),
+		AppID:        account.AppID,
+		WalletID:     account.WalletID,
+		AccountID:    rawTx.AccountID,
+		Sid:          rawTx.Sid,
+		TxID:         txid,
+		WxID:         openwallet.GenTransactionWxID2(txid, rawTx.Coin.Symbol, rawTx.Coin.ContractID),
+		FromAddress:  []string{fromAddress},
+		FromAddressV: []string{amount.String()},
+		ToAddress:    toAddress,
+		ToAddressV:   toAddressV,
+		Amount:       amount.String(),
+		Fees:         fees.String(),
+		Type:         1,
+		Symbol:       rawTx.Coin.Symbol,
+		ContractID:   rawTx.Coin.ContractID,
+		IsContract:   isContract,
+		IsMain:       1,
+		Applytime:    now,
+		SubmitTime:   now,
+		Succtime:     now,
+		Decimals:     symbol.Decimals,
+		Dealstate:    2,
+		Success:      "1",
+		BalanceMode:  symbol.BalanceMode,
+	}
+
+	s.trades = append(s.trades, tx)
+	s.sids[rawTx.Sid] = true
+	delete(s.rawTxs, rawTx.Sid)
+	rawTx.TxID = txid
+	return tx, ""
+}
+
+func (s *Server) findTradeLog(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	result := make([]*openwsdk.Transaction, 0)
+	for _, tx := range s.trades {
+		if !matchParams(tx, data, "walletID", "accountID", "symbol", "txID", "sid", "contractID") {
+			continue
+		}
+		if txid := data.Get("txid").String(); len(txid) > 0 && txid != tx.TxID {
+			continue
+		}
+		if h := data.Get("blockHeight").Int(); h > 0 && h != tx.BlockHeight {
+			continue
+		}
+		if h := data.Get("start_height").Int(); h > 0 && tx.BlockHeight < h {
+			continue
+		}
+		if h := data.Get("end_height").Int(); h > 0 && tx.BlockHeight > h {
+			continue
+		}
+		result = append(result, tx)
+	}
+	s.mu.RUnlock()
+
+	if data.Get("lastID").Exists() {
+		ctx.Response(pageLastID(result, data), owtp.StatusSuccess, "success")
+		return
+	}
+	if data.Get("sortby").Int() == 1 {
+		//倒序
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+	}
+	ctx.Response(pageOffset(result, data), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) getContracts(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	result := make([]*openwsdk.TokenContract, 0)
+	for _, c := range s.contracts {
+		if matchParams(c, data, "symbol", "contractID") {
+			result = append(result, c)
+		}
+	}
+	s.mu.RUnlock()
+	ctx.Response(pageLastID(result, data), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) getBalanceByAccount(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	account := s.findAccount(data.Get("accountID").String())
+	if account == nil {
+		ctx.Response(nil, openwallet.ErrAccountNotFound, "account not found")
+		return
+	}
+	ctx.Response(s.balanceResult(account.WalletID, account.AccountID, "", account.Symbol,
+		data.Get("contractID").String()), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) getBalanceByAddress(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	address := s.findAddress(data.Get("address").String())
+	if address == nil {
+		ctx.Response(nil, openwallet.ErrAddressNotFound, "address not found")
+		return
+	}
+	ctx.Response(s.balanceResult(address.WalletID, address.AccountID, address.Address, address.Symbol,
+		data.Get("contractID").String()), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) getAccountBalanceList(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	result := make([]*openwsdk.BalanceResult, 0)
+	for _, a := range s.accounts {
+		if !matchParams(a, data, "walletID", "accountID") || !matchSymbol(a.Symbol, data) {
+			continue
+		}
+		result = append(result, s.balanceList(a.WalletID, a.AccountID, "", a.Symbol, data)...)
+	}
+	s.mu.RUnlock()
+	ctx.Response(pageLastID(result, data), owtp.StatusSuccess, "success")
+}
+
+func (s *Server) getAddressBalanceList(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	result := make([]*openwsdk.BalanceResult, 0)
+	for _, a := range s.addresses {
+		if !matchParams(a, data, "walletID", "accountID", "address") || !matchSymbol(a.Symbol, data) {
+			continue
+		}
+		result = append(result, s.balanceList(a.WalletID, a.AccountID, a.Address, a.Symbol, data)...)
+	}
+	s.mu.RUnlock()
+	ctx.Response(pageLastID(result, data), owtp.StatusSuccess, "success")
+}
+
+// balanceList 按type筛选主币和代币余额，0: 所有，1：主币，2：代币
+func (s *Server) balanceList(walletID, accountID, address, symbol string, data gjson.Result) []*openwsdk.BalanceResult {
+	opType := data.Get("type").Int()
+	contractID := data.Get("contractID").String()
+	result := make([]*openwsdk.BalanceResult, 0)
+	if opType != 2 && len(contractID) == 0 {
+		result = append(result, s.balanceResult(walletID, accountID, address, symbol, ""))
+	}
+	if opType == 1 {
+		return result
+	}
+	for _, c := range s.contracts {
+		if c.Symbol != symbol || (len(contractID) > 0 && c.ContractID != contractID) {
+			continue
+		}
+		result = append(result, s.balanceResult(walletID, accountID, address, symbol, c.ContractID))
+	}
+	return result
+}
+
+func (s *Server) balanceResult(walletID, accountID, address, symbol, contractID string) *openwsdk.BalanceResult {
+	owner := accountID
+	if len(address) > 0 {
+		owner = address
+	}
+	balance := s.decimalBalance(owner, contractID).String()
+	b := &openwsdk.BalanceResult{
+		AppID:            DefaultAppID,
+		WalletID:         walletID,
+		AccountID:        accountID,
+		Address:          address,
+		MainSymbol:       symbol,
+		Symbol:           symbol,
+		ContractID:       contractID,
+		Balance:          balance,
+		ConfirmBalance:   balance,
+		UnconfirmBalance: "0",
+	}
+	for _, c := range s.contracts {
+		if c.ContractID == contractID {
+			b.ContractAddr = c.Address
+			b.ContractToken = c.Token
+			b.ID = c.Id
+		}
+	}
+	return b
+}
+
+func (s *Server) getFeeRate(ctx *owtp.Context) {
+	symbol := ctx.Params().Get("symbol").String()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sym, ok := s.symbols[symbol]
+	if !ok {
+		ctx.Response(nil, openwallet.ErrUnknownException, "symbol not support")
+		return
+	}
+	ctx.Response(map[string]interface{}{
+		"symbol":  sym.Symbol,
+		"feeRate": sym.FeeRate,
+		"unit":    sym.Unit,
+	}, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) getFeeRateList(ctx *owtp.Context) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.symbols))
+	for name := range s.symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		sym := s.symbols[name]
+		result = append(result, map[string]interface{}{
+			"symbol":  sym.Symbol,
+			"feeRate": sym.FeeRate,
+			"unit":    sym.Unit,
+		})
+	}
+	s.mu.RUnlock()
+	ctx.Response(result, owtp.StatusSuccess, "success")
+}
+
+func (s *Server) verifyAddress(ctx *owtp.Context) {
+	data := ctx.Params()
+	s.mu.RLock()
+	_, ok := s.symbols[data.Get("symbol").String()]
+	s.mu.RUnlock()
+	ctx.Response(ok && len(data.Get("address").String()) > 0, owtp.StatusSuccess, "success")
+}
+
+// findWallet 调用方需持有锁
+func (s *Server) findWallet(walletID string) *openwsdk.Wallet {
+	for _, w := range s.wallets {
+		if w.WalletID == walletID {
+			return w
+		}
+	}
+	return nil
+}
+
+// findAccount 调用方需持有锁
+func (s *Server) findAccount(accountID string) *openwsdk.Account {
+	for _, a := range s.accounts {
+		if a.AccountID == accountID {
+			return a
+		}
+	}
+	return nil
+}
+
+// findAddress 调用方需持有锁
+func (s *Server) findAddress(address string) *openwsdk.Address {
+	for _, a := range s.addresses {
+		if a.Address == address {
+			return a
+		}
+	}
+	return nil
+}
+
+// newAddress 为账户生成新地址，调用方需持有写锁
+func (s *Server) newAddress(account *openwsdk.Account) *openwsdk.Address {
+	account.AddressIndex++
+	index := account.AddressIndex - 1
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s%d", account.AccountID, index)))
+	address := &openwsdk.Address{
+		Id:        s.nextID(),
+		AppID:     account.AppID,
+		WalletID:  account.WalletID,
+		AccountID: account.AccountID,
+		Symbol:    account.Symbol,
+		AddrIndex: index,
+		Address:   account.Symbol + hex.EncodeToString(hash[:20]),
+		HdPath:    fmt.Sprintf("%s/0/%d", account.HdPath, index),
+	}
+	s.addresses = append(s.addresses, address)
+	return address
+}
+
+// decimalBalance 调用方需持有锁
+func (s *Server) decimalBalance(owner, contractID string) decimal.Decimal {
+	d, err := decimal.NewFromString(s.balances[balanceKey(owner, contractID)])
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
+
+// addBalance 调用方需持有写锁
+func (s *Server) addBalance(owner, contractID string, amount decimal.Decimal) {
+	s.balances[balanceKey(owner, contractID)] = s.decimalBalance(owner, contractID).Add(amount).String()
+}
+
+// toMap 对象转为json对象
+func toMap(obj interface{}) map[string]interface{} {
+	m := make(map[string]interface{})
+	json.Unmarshal(mustMarshal(obj), &m)
+	return m
+}
+
+// matchParams 请求参数中非空的字段需要与对象json字段一致
+func matchParams(obj interface{}, data gjson.Result, keys ...string) bool {
+	o := gjson.ParseBytes(mustMarshal(obj))
+	for _, key := range keys {
+		want := data.Get(key).String()
+		if len(want) == 0 {
+			continue
+		}
+		if o.Get(key).String() != want {
+			return false
+		}
+	}
+	return true
+}
+
+func matchSymbol(symbol string, data gjson.Result) bool {
+	want := data.Get("symbol").String()
+	return len(want) == 0 || want == symbol
+}
+
+// pageOffset 按offset/limit分页，limit为0返回剩余所有
+func pageOffset(list interface{}, data gjson.Result) interface{} {
+	v := reflect.ValueOf(list)
+	offset := int(data.Get("offset").Int())
+	limit := int(data.Get("limit").Int())
+	if offset < 0 || offset > v.Len() {
+		offset = v.Len()
+	}
+	end := v.Len()
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return v.Slice(offset, end).Interface()
+}
+
+// pageLastID 返回id大于lastID的记录，按id升序，limit为0返回剩余所有
+func pageLastID(list interface{}, data gjson.Result) interface{} {
+	v := reflect.ValueOf(list)
+	lastID := data.Get("lastID").Int()
+	limit := int(data.Get("limit").Int())
+	ids := make([]int64, v.Len())
+	index := make([]int, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		ids[i] = gjson.GetBytes(mustMarshal(v.Index(i).Interface()), "id").Int()
+		if ids[i] > lastID {
+			index = append(index, i)
+		}
+	}
+	sort.SliceStable(index, func(a, b int) bool {
+		return ids[index[a]] < ids[index[b]]
+	})
+	if limit > 0 && len(index) > limit {
+		index = index[:limit]
+	}
+	result := reflect.MakeSlice(v.Type(), 0, len(index))
+	for _, i := range index {
+		result = reflect.Append(result, v.Index(i))
+	}
+	return result.Interface()
+}
+
+func mustMarshal(obj interface{}) []byte {
+	raw, _ := json.Marshal(obj)
+	return raw
+}
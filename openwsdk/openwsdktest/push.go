@@ -0,0 +1,134 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package openwsdktest
+
+import (
+	"fmt"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// PushTrade 向订阅者推送新交易单通知，返回是否所有订阅者都已接收
+func (s *Server) PushTrade(tx *openwsdk.Transaction) (bool, error) {
+	return s.push(openwsdk.SubscribeToTrade, toMap(tx))
+}
+
+// PushBlock 向订阅者推送新区块头通知
+func (s *Server) PushBlock(header *openwsdk.BlockHeader) (bool, error) {
+	return s.push(openwsdk.SubscribeToBlock, toMap(header))
+}
+
+// PushBalance 向订阅者推送余额更新通知，tokenBalance可为空
+func (s *Server) PushBalance(balance *openwsdk.Balance, tokenBalance *openwsdk.TokenBalance) (bool, error) {
+	params := toMap(balance)
+	if tokenBalance != nil {
+		params["tokenBalance"] = map[string]interface{}{
+			"isContract":      tokenBalance.IsContract,
+			"contractID":      tokenBalance.ContractID,
+			"token":           tokenBalance.Token,
+			"contractAddress": tokenBalance.Address,
+			"accountID":       tokenBalance.Balance.AccountID,
+			"balance":         tokenBalance.Balance.Balance,
+		}
+	}
+	return s.push(openwsdk.SubscribeToAccount, params)
+}
+
+// PushSmartContractReceipt 向订阅者推送智能合约交易回执通知
+func (s *Server) PushSmartContractReceipt(receipt *openwsdk.SmartContractReceipt) (bool, error) {
+	return s.push(openwsdk.SubscribeToSmartContractReceipt, toMap(receipt))
+}
+
+// PushNFTTransfer 向订阅者推送NFT交易数据通知
+func (s *Server) PushNFTTransfer(transfer *openwsdk.NFTTransfer) (bool, error) {
+	return s.push(openwsdk.SubscribeToNFTTransfer, toMap(transfer))
+}
+
+// Subscribers 订阅了指定方法的节点ID
+func (s *Server) Subscribers(method string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	peers := make([]string, 0)
+	for pid, sub := range s.subscriptions {
+		if sub.Methods[method] {
+			peers = append(peers, pid)
+		}
+	}
+	return peers
+}
+
+// push 逐个推送给订阅者，不能在服务端方法处理过程中调用
+func (s *Server) push(method string, params map[string]interface{}) (bool, error) {
+	s.mu.RLock()
+	subs := make([]*subscription, 0)
+	for _, sub := range s.subscriptions {
+		if sub.Methods[method] {
+			subs = append(subs, sub)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(subs) == 0 {
+		return false, fmt.Errorf("no subscriber for %s", method)
+	}
+
+	accepted := true
+	for _, sub := range subs {
+		obj := make(map[string]interface{}, len(params)+1)
+		for k, v := range params {
+			obj[k] = v
+		}
+		obj["subscribeToken"] = sub.SubscribeToken
+
+		resp, err := s.call(sub, method, obj)
+		if err != nil {
+			return false, err
+		}
+		if resp.Status != owtp.StatusSuccess {
+			return false, fmt.Errorf("push %s to %s failed: [%d]%s", method, sub.PeerID, resp.Status, resp.Msg)
+		}
+		if !resp.JsonData().Get("accepted").Bool() {
+			accepted = false
+		}
+	}
+	return accepted, nil
+}
+
+// call 按订阅的回调模式请求订阅者
+func (s *Server) call(sub *subscription, method string, params interface{}) (*owtp.Response, error) {
+	if sub.CallbackMode != openwsdk.CallbackModeNewConnection {
+		return s.node.CallSync(sub.PeerID, method, params)
+	}
+
+	var (
+		resp     owtp.Response
+		callback = sub.CallbackNode
+	)
+	err := s.node.ConnectAndCall(sub.PeerID, owtp.ConnectConfig{
+		Address:            callback.Address,
+		ConnectType:        callback.ConnectType,
+		EnableSignature:    callback.EnableSignature,
+		EnableSSL:          callback.EnableSSL,
+		EnableKeyAgreement: callback.EnableKeyAgreement,
+	}, method, params, true, func(r owtp.Response) {
+		resp = r
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
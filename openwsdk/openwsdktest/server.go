@@ -0,0 +1,490 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+// Package openwsdktest 提供内存版的openw-server，用于离线测试SDK。
+//
+// Server同时监听HTTP和websocket，实现SDK调用的服务端方法，数据保存在内存中。
+// 测试可以通过Push*方法向已订阅的APINode推送通知。
+package openwsdktest
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+const (
+	DefaultAppID  = "openwsdktest"
+	DefaultAppKey = "openwsdktest-appkey"
+)
+
+// subscription 订阅记录
+type subscription struct {
+	AppID          string
+	PeerID         string
+	Methods        map[string]bool
+	CallbackMode   int
+	CallbackNode   openwsdk.CallbackNode
+	SubscribeToken string
+}
+
+// fault 注入的请求故障
+type fault struct {
+	status    uint64
+	count     int
+	processed bool //先正常处理再返回错误
+}
+
+// Server 内存版openw-server
+type Server struct {
+	mu            sync.RWMutex
+	node          *owtp.OWTPNode
+	httpAddr      string
+	wsAddr        string
+	apps          map[string]string          //appID -> appKey
+	devices       map[string]map[string]bool //deviceID -> 已绑定的appID
+	subscriptions map[string]*subscription   //peerID -> 订阅
+	symbols       map[string]*openwsdk.Symbol
+	heights       map[string]uint64            //symbol -> 当前高度
+	blocks        map[string]map[uint64]string //symbol -> 高度 -> 区块哈希
+	forks         map[string]int               //symbol -> 分叉次数
+	forkTips      map[string]uint64            //symbol -> 最近一次分叉前的高度
+	wallets       []*openwsdk.Wallet
+	accounts      []*openwsdk.Account
+	addresses     []*openwsdk.Address
+	contracts     []*openwsdk.TokenContract
+	balances      map[string]string //accountID/address + contractID -> 余额
+	trades        []*openwsdk.Transaction
+	sids          map[string]bool
+	rawTxs        map[string]*openwsdk.RawTransaction //sid -> 待广播交易单
+	contractTxs   map[string]*openwsdk.SmartContractRawTransaction
+	receipts      []*openwsdk.SmartContractReceipt
+	receiptSids   map[string]string //wxid -> sid
+	followed      map[string]bool   //关注的合约回执
+	lastID        int64
+	requireBind   bool
+	handlers      map[string]owtp.HandlerFunc
+	faults        map[string]*fault //method -> 待注入的故障
+	calls         map[string]int    //method -> 调用次数
+	clock         openwsdk.Clock
+	verifier      *openwsdk.RequestVerifier
+}
+
+// NewServer 创建并启动内存版openw-server，HTTP和websocket都监听随机端口
+func NewServer() (*Server, error) {
+
+	httpAddr, err := freeAddress()
+	if err != nil {
+		return nil, err
+	}
+	wsAddr, err := freeAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	node := owtp.NewNode(owtp.NodeConfig{
+		Cert:       owtp.NewRandomCertificate(),
+		TimeoutSEC: 30,
+	})
+
+	s := &Server{
+		node:          node,
+		httpAddr:      httpAddr,
+		wsAddr:        wsAddr,
+		apps:          map[string]string{DefaultAppID: DefaultAppKey},
+		devices:       make(map[string]map[string]bool),
+		subscriptions: make(map[string]*subscription),
+		symbols:       make(map[string]*openwsdk.Symbol),
+		heights:       make(map[string]uint64),
+		blocks:        make(map[string]map[uint64]string),
+		forks:         make(map[string]int),
+		forkTips:      make(map[string]uint64),
+		balances:      make(map[string]string),
+		sids:          make(map[string]bool),
+		rawTxs:        make(map[string]*openwsdk.RawTransaction),
+		contractTxs:   make(map[string]*openwsdk.SmartContractRawTransaction),
+		receiptSids:   make(map[string]string),
+		followed:      make(map[string]bool),
+		requireBind:   true,
+		handlers:      make(map[string]owtp.HandlerFunc),
+		faults:        make(map[string]*fault),
+		calls:         make(map[string]int),
+		clock:         openwsdk.SystemClock,
+	}
+	s.verifier = openwsdk.NewRequestVerifier(s.appKey)
+
+	s.setupHandlers()
+	node.SetCloseHandler(s.onPeerClose)
+
+	if err := node.Listen(owtp.ConnectConfig{Address: httpAddr, ConnectType: owtp.HTTP}); err != nil {
+		return nil, err
+	}
+	if err := node.Listen(owtp.ConnectConfig{Address: wsAddr, ConnectType: owtp.Websocket}); err != nil {
+		node.Close()
+		return nil, err
+	}
+
+	//等待监听就绪
+	for _, addr := range []string{httpAddr, wsAddr} {
+		if err := waitListening(addr); err != nil {
+			node.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// freeAddress 获取本地可用端口
+func freeAddress() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+func waitListening(addr string) error {
+	for i := 0; i < 50; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("server %s is not listening", addr)
+}
+
+// Close 关闭服务
+func (s *Server) Close() {
+	s.node.Close()
+}
+
+// NodeID 服务节点ID，即通知者节点ID
+func (s *Server) NodeID() string {
+	return s.node.NodeID()
+}
+
+// OWTPNode 底层owtp节点
+func (s *Server) OWTPNode() *owtp.OWTPNode {
+	return s.node
+}
+
+// Address 指定连接方式的监听地址
+func (s *Server) Address(connectType string) string {
+	if connectType == owtp.Websocket {
+		return s.wsAddr
+	}
+	return s.httpAddr
+}
+
+// SetRequireBind 设置是否要求先调用bindAppDevice，默认要求
+func (s *Server) SetRequireBind(require bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requireBind = require
+}
+
+// SetClock 设置服务端时钟，用于模拟与客户端的时钟偏差
+func (s *Server) SetClock(clock openwsdk.Clock) {
+	s.mu.Lock()
+	s.clock = clock
+	s.mu.Unlock()
+	s.verifier.SetClock(clock)
+}
+
+// AddApp 添加应用授权，appID已存在时更换密钥
+func (s *Server) AddApp(appID, appKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apps[appID] = appKey
+}
+
+// HandleFunc 覆盖或新增服务端方法，便于模拟异常
+func (s *Server) HandleFunc(method string, handler owtp.HandlerFunc) {
+	s.mu.Lock()
+	s.handlers[method] = handler
+	s.mu.Unlock()
+	s.node.HandleFunc(method, handler)
+}
+
+// FailNext 指定方法接下来的count次请求返回status错误。
+// processed为true时先正常处理再返回错误，模拟服务端已处理但响应超时或丢失
+func (s *Server) FailNext(method string, status uint64, count int, processed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[method] = &fault{status: status, count: count, processed: processed}
+}
+
+// Calls 方法被调用的次数
+func (s *Server) Calls(method string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.calls[method]
+}
+
+// APINodeConfig 生成连接本服务的APINode配置，使用随机证书
+func (s *Server) APINodeConfig(connectType string) *openwsdk.APINodeConfig {
+	return &openwsdk.APINodeConfig{
+		Host:        s.Address(connectType),
+		AppID:       DefaultAppID,
+		AppKey:      DefaultAppKey,
+		ConnectType: connectType,
+		Cert:        owtp.NewRandomCertificate(),
+		TimeoutSEC:  30,
+	}
+}
+
+// NewAPINode 创建连接本服务的APINode，并完成设备绑定
+func (s *Server) NewAPINode(connectType string) (*openwsdk.APINode, error) {
+	api, err := openwsdk.NewAPINodeWithError(s.APINodeConfig(connectType))
+	if err != nil {
+		return nil, err
+	}
+	if err := api.BindAppDevice(); err != nil {
+		return nil, err
+	}
+	return api, nil
+}
+
+// DisconnectPeer 断开指定节点的连接，用于模拟网络中断
+func (s *Server) DisconnectPeer(peerID string) {
+	s.node.ClosePeer(peerID)
+}
+
+// onPeerClose websocket连接断开后，设备绑定和当前连接模式的订阅失效
+func (s *Server) onPeerClose(n *owtp.OWTPNode, peer owtp.PeerInfo) {
+	if peer.Config.ConnectType != owtp.Websocket {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.devices, peer.ID)
+	if sub, ok := s.subscriptions[peer.ID]; ok && sub.CallbackMode == openwsdk.CallbackModeCurrentConnection {
+		delete(s.subscriptions, peer.ID)
+	}
+}
+
+// nextID 生成自增ID
+func (s *Server) nextID() int64 {
+	s.lastID++
+	return s.lastID
+}
+
+// AddSymbol 添加主链信息
+func (s *Server) AddSymbol(symbol *openwsdk.Symbol) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbols[symbol.Symbol] = symbol
+	if symbol.MaxHeight > 0 {
+		s.heights[symbol.Symbol] = uint64(symbol.MaxHeight)
+	}
+}
+
+// AddWallet 添加钱包
+func (s *Server) AddWallet(wallet *openwsdk.Wallet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(wallet.AppID) == 0 {
+		wallet.AppID = DefaultAppID
+	}
+	s.wallets = append(s.wallets, wallet)
+}
+
+// AddAccount 添加资产账户
+func (s *Server) AddAccount(account *openwsdk.Account) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if account.Id == 0 {
+		account.Id = s.nextID()
+	}
+	if len(account.AppID) == 0 {
+		account.AppID = DefaultAppID
+	}
+	s.accounts = append(s.accounts, account)
+}
+
+// AddAddress 添加地址
+func (s *Server) AddAddress(address *openwsdk.Address) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if address.Id == 0 {
+		address.Id = s.nextID()
+	}
+	if len(address.AppID) == 0 {
+		address.AppID = DefaultAppID
+	}
+	s.addresses = append(s.addresses, address)
+}
+
+// AddContract 添加代币合约
+func (s *Server) AddContract(contract *openwsdk.TokenContract) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if contract.Id == 0 {
+		contract.Id = s.nextID()
+	}
+	s.contracts = append(s.contracts, contract)
+}
+
+// AddTransaction 添加交易记录
+func (s *Server) AddTransaction(tx *openwsdk.Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tx.Id == 0 {
+		tx.Id = s.nextID()
+	}
+	s.trades = append(s.trades, tx)
+}
+
+// AddSmartContractReceipt 添加智能合约交易回执
+func (s *Server) AddSmartContractReceipt(receipt *openwsdk.SmartContractReceipt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receipts = append(s.receipts, receipt)
+}
+
+// SmartContractReceipts 所有智能合约交易回执
+func (s *Server) SmartContractReceipts() []*openwsdk.SmartContractReceipt {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	receipts := make([]*openwsdk.SmartContractReceipt, len(s.receipts))
+	copy(receipts, s.receipts)
+	return receipts
+}
+
+// SetBalance 设置账户或地址余额，contractID为空表示主币
+func (s *Server) SetBalance(accountIDOrAddress, contractID, balance string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances[balanceKey(accountIDOrAddress, contractID)] = balance
+}
+
+// Balance 查询账户或地址余额
+func (s *Server) Balance(accountIDOrAddress, contractID string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.balances[balanceKey(accountIDOrAddress, contractID)]
+	if !ok {
+		return "0"
+	}
+	return b
+}
+
+func balanceKey(owner, contractID string) string {
+	return owner + "/" + contractID
+}
+
+// Transactions 所有交易记录
+func (s *Server) Transactions() []*openwsdk.Transaction {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	txs := make([]*openwsdk.Transaction, len(s.trades))
+	copy(txs, s.trades)
+	return txs
+}
+
+// Height 主链当前高度
+func (s *Server) Height(symbol string) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.heights[symbol]
+}
+
+// MineBlock 模拟主链出块，未确认的交易打包进新区块，返回新区块头
+// 不会主动推送，可配合PushBlock使用
+func (s *Server) MineBlock(symbol string) *openwsdk.BlockHeader {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prevHeight := s.heights[symbol]
+	height := prevHeight + 1
+	s.heights[symbol] = height
+
+	chain := s.blocks[symbol]
+	if chain == nil {
+		chain = make(map[uint64]string)
+		s.blocks[symbol] = chain
+	}
+	prevHash, ok := chain[prevHeight]
+	if !ok {
+		prevHash = blockHash(symbol, prevHeight, 0)
+	}
+	chain[height] = blockHash(symbol, height, s.forks[symbol])
+
+	header := &openwsdk.BlockHeader{
+		Hash:              chain[height],
+		Previousblockhash: prevHash,
+		Height:            height,
+		Time:              uint64(time.Now().Unix()),
+		Fork:              height <= s.forkTips[symbol],
+		Symbol:            symbol,
+	}
+
+	for _, tx := range s.trades {
+		if tx.Symbol != symbol {
+			continue
+		}
+		if tx.BlockHeight == 0 {
+			tx.BlockHeight = int64(height)
+			tx.BlockHash = header.Hash
+			tx.ConfirmTime = time.Now().Unix()
+			tx.IsMain = 1
+		}
+		tx.Confirm = int64(height) - tx.BlockHeight + 1
+	}
+
+	return header
+}
+
+// ForkChain 模拟链分叉，从height开始的区块被丢弃，之后产生的区块哈希与原链不同，
+// 被丢弃区块中的交易单变为未确认，IsMain标记为2，在下一个区块重新打包后恢复为1
+func (s *Server) ForkChain(symbol string, height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if height == 0 || height > s.heights[symbol] {
+		return
+	}
+	if s.heights[symbol] > s.forkTips[symbol] {
+		s.forkTips[symbol] = s.heights[symbol]
+	}
+	for h := height; h <= s.heights[symbol]; h++ {
+		delete(s.blocks[symbol], h)
+	}
+	s.heights[symbol] = height - 1
+	s.forks[symbol]++
+
+	for _, tx := range s.trades {
+		if tx.Symbol != symbol || tx.BlockHeight < int64(height) {
+			continue
+		}
+		tx.BlockHeight = 0
+		tx.BlockHash = ""
+		tx.Confirm = 0
+		tx.IsMain = 2
+	}
+}
+
+// blockHash 模拟区块哈希，fork不同得到不同的哈希
+func blockHash(symbol string, height uint64, fork int) string {
+	return fmt.Sprintf("%s-%d-%d", symbol, height, fork)
+}
@@ -0,0 +1,242 @@
+package openwsdktest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func testNewServer(t *testing.T) *Server {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	s.AddSymbol(&openwsdk.Symbol{
+		Name:      "Bitcoin",
+		Symbol:    "BTC",
+		Curve:     int64(owcrypt.ECC_CURVE_SECP256K1),
+		Confirm:   6,
+		Decimals:  8,
+		MaxHeight: 100,
+		FeeRate:   "0.0001",
+		Unit:      "BTC",
+	})
+	return s
+}
+
+func testNewClient(t *testing.T, s *Server, connectType string) *openwsdk.APIClient {
+	api, err := s.NewAPINode(connectType)
+	if err != nil {
+		t.Fatalf("NewAPINode unexpected error: %v", err)
+	}
+	return openwsdk.NewAPIClient(api)
+}
+
+func TestServer_Unauthorized(t *testing.T) {
+	s := testNewServer(t)
+	defer s.Close()
+
+	api, err := openwsdk.NewAPINodeWithError(s.APINodeConfig(owtp.HTTP))
+	if err != nil {
+		t.Fatalf("NewAPINodeWithError unexpected error: %v", err)
+	}
+	_, err = openwsdk.NewAPIClient(api).GetFeeRate(context.Background(), "BTC")
+	if !errors.Is(err, openwsdk.ErrUnauthorizedDevice) {
+		t.Errorf("GetFeeRate expected ErrUnauthorizedDevice, got: %v", err)
+	}
+}
+
+func TestServer_Trade(t *testing.T) {
+	s := testNewServer(t)
+	defer s.Close()
+
+	ctx := context.Background()
+	client := testNewClient(t, s, owtp.HTTP)
+
+	seed, _ := hdkeystore.GenerateSeed(32)
+	key, err := hdkeystore.NewHDKey(seed, "test", hdkeystore.OpenwCoinTypePath)
+	if err != nil {
+		t.Fatalf("NewHDKey unexpected error: %v", err)
+	}
+	wallet, err := client.CreateWallet(ctx, &openwsdk.Wallet{WalletID: key.KeyID, Alias: "test"})
+	if err != nil {
+		t.Fatalf("CreateWallet unexpected error: %v", err)
+	}
+	newAccount, err := wallet.CreateAccount("main", &openwsdk.Symbol{Symbol: "BTC", Curve: int64(owcrypt.ECC_CURVE_SECP256K1)}, key)
+	if err != nil {
+		t.Fatalf("CreateAccount unexpected error: %v", err)
+	}
+	account, addresses, err := client.CreateNormalAccount(ctx, newAccount)
+	if err != nil {
+		t.Fatalf("CreateNormalAccount unexpected error: %v", err)
+	}
+	if len(addresses) != 1 {
+		t.Fatalf("CreateNormalAccount expected 1 address, got: %d", len(addresses))
+	}
+
+	coin := openwsdk.Coin{Symbol: "BTC"}
+	to := map[string]string{"1xyz": "1.5"}
+	_, err = client.CreateTrade(ctx, account.AccountID, "sid-1", coin, to, "", "", "")
+	if !errors.Is(err, openwsdk.ErrInsufficientBalance) {
+		t.Fatalf("CreateTrade expected ErrInsufficientBalance, got: %v", err)
+	}
+
+	s.SetBalance(account.AccountID, "", "2")
+	rawTx, err := client.CreateTrade(ctx, account.AccountID, "sid-1", coin, to, "", "", "")
+	if err != nil {
+		t.Fatalf("CreateTrade unexpected error: %v", err)
+	}
+
+	//未签名的交易单广播失败
+	_, failed, err := client.SubmitTrade(ctx, []*openwsdk.RawTransaction{rawTx})
+	if err != nil || len(failed) != 1 {
+		t.Fatalf("SubmitTrade expected 1 failure, got: %v, %v", failed, err)
+	}
+
+	if err := openwsdk.SignRawTransaction(rawTx, key); err != nil {
+		t.Fatalf("SignRawTransaction unexpected error: %v", err)
+	}
+	txs, failed, err := client.SubmitTrade(ctx, []*openwsdk.RawTransaction{rawTx})
+	if err != nil || len(txs) != 1 || len(failed) != 0 {
+		t.Fatalf("SubmitTrade unexpected result: %v, %v, %v", txs, failed, err)
+	}
+	if s.Balance(account.AccountID, "") != "0.4999" {
+		t.Errorf("unexpected balance after submit: %s", s.Balance(account.AccountID, ""))
+	}
+
+	//重复的sid
+	_, err = client.CreateTrade(ctx, account.AccountID, "sid-1", coin, to, "", "", "")
+	if !errors.Is(err, openwsdk.ErrDuplicateSid) {
+		t.Errorf("CreateTrade expected ErrDuplicateSid, got: %v", err)
+	}
+
+	logs, err := client.FindTradeLogByParams(ctx, map[string]interface{}{"sid": "sid-1"})
+	if err != nil || len(logs) != 1 || logs[0].TxID != txs[0].TxID {
+		t.Errorf("FindTradeLogByParams unexpected result: %v, %v", logs, err)
+	}
+}
+
+func TestServer_Paging(t *testing.T) {
+	s := testNewServer(t)
+	defer s.Close()
+
+	s.AddWallet(&openwsdk.Wallet{WalletID: "W1"})
+	for i := 0; i < 5; i++ {
+		s.AddAccount(&openwsdk.Account{WalletID: "W1", AccountID: string(rune('a' + i)), Symbol: "BTC"})
+	}
+
+	ctx := context.Background()
+	client := testNewClient(t, s, owtp.HTTP)
+
+	accounts, err := client.FindAccountByWalletID(ctx, "BTC", "W1", 0, 2)
+	if err != nil || len(accounts) != 2 {
+		t.Fatalf("FindAccountByWalletID unexpected result: %v, %v", accounts, err)
+	}
+	accounts, err = client.FindAccountByWalletID(ctx, "BTC", "W1", accounts[1].Id, 10)
+	if err != nil || len(accounts) != 3 || accounts[0].AccountID != "c" {
+		t.Fatalf("FindAccountByWalletID unexpected result: %v, %v", accounts, err)
+	}
+
+	accounts, err = client.FindAccountByParams(ctx, map[string]interface{}{"walletID": "W1"}, 4, 10)
+	if err != nil || len(accounts) != 1 || accounts[0].AccountID != "e" {
+		t.Fatalf("FindAccountByParams unexpected result: %v, %v", accounts, err)
+	}
+}
+
+type testObserver struct {
+	blocks chan *openwsdk.BlockHeader
+}
+
+func (o *testObserver) OpenwNewTransactionNotify(transaction *openwsdk.Transaction, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func (o *testObserver) OpenwNewBlockNotify(blockHeader *openwsdk.BlockHeader, subscribeToken string) (bool, error) {
+	o.blocks <- blockHeader
+	return true, nil
+}
+
+func (o *testObserver) OpenwBalanceUpdateNotify(balance *openwsdk.Balance, tokenBalance *openwsdk.TokenBalance, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func (o *testObserver) OpenwNewSmartContractReceiptNotify(receipt *openwsdk.SmartContractReceipt, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func (o *testObserver) OpenwNFTTransferNotify(transfer *openwsdk.NFTTransfer, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func TestServer_PushBlock(t *testing.T) {
+	s := testNewServer(t)
+	defer s.Close()
+
+	client := testNewClient(t, s, owtp.Websocket)
+	observer := &testObserver{blocks: make(chan *openwsdk.BlockHeader, 1)}
+	client.APINode().AddObserver(observer)
+
+	err := client.Subscribe(context.Background(), []string{openwsdk.SubscribeToBlock}, "",
+		openwsdk.CallbackModeCurrentConnection, openwsdk.CallbackNode{
+			NodeID:      s.NodeID(),
+			ConnectType: owtp.Websocket,
+		}, "token")
+	if err != nil {
+		t.Fatalf("Subscribe unexpected error: %v", err)
+	}
+
+	header := s.MineBlock("BTC")
+	accepted, err := s.PushBlock(header)
+	if err != nil || !accepted {
+		t.Fatalf("PushBlock unexpected result: %v, %v", accepted, err)
+	}
+
+	select {
+	case got := <-observer.blocks:
+		if got.Height != 101 || got.Hash != header.Hash {
+			t.Errorf("unexpected block header: %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("block notify not received")
+	}
+}
+
+func TestServer_PushBlockNewConnection(t *testing.T) {
+	s := testNewServer(t)
+	defer s.Close()
+
+	client := testNewClient(t, s, owtp.HTTP)
+	observer := &testObserver{blocks: make(chan *openwsdk.BlockHeader, 1)}
+	client.APINode().AddObserver(observer)
+
+	listenAddr, err := freeAddress()
+	if err != nil {
+		t.Fatalf("freeAddress unexpected error: %v", err)
+	}
+	err = client.Subscribe(context.Background(), []string{openwsdk.SubscribeToBlock}, listenAddr,
+		openwsdk.CallbackModeNewConnection, openwsdk.CallbackNode{
+			NodeID:      client.APINode().NodeID(),
+			Address:     listenAddr,
+			ConnectType: owtp.Websocket,
+		}, "token")
+	if err != nil {
+		t.Fatalf("Subscribe unexpected error: %v", err)
+	}
+	defer client.APINode().StopServeNotification(owtp.Websocket)
+
+	accepted, err := s.PushBlock(s.MineBlock("BTC"))
+	if err != nil || !accepted {
+		t.Fatalf("PushBlock unexpected result: %v, %v", accepted, err)
+	}
+	select {
+	case <-observer.blocks:
+	case <-time.After(time.Second):
+		t.Fatal("block notify not received")
+	}
+}
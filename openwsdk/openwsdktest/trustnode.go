@@ -0,0 +1,271 @@
+/*
+ * Copyright 2019 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package openwsdktest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// TransmitPeerID 托管节点连接转发节点时使用的对端ID
+const TransmitPeerID = "transmit-node"
+
+// TrustNode 内存版托管节点，连接到TransmitNode后响应*ViaTrustNode请求
+type TrustNode struct {
+	mu       sync.RWMutex
+	node     *owtp.OWTPNode
+	info     openwsdk.TrustNodeInfo
+	keys     map[string]*hdkeystore.HDKey //walletID -> HDKey
+	password string
+	curves   map[string]uint32 //symbol -> 曲线类型
+	calls    map[string]int    //method -> 调用次数
+	delay    time.Duration     //getTrustNodeInfo响应前的等待时间，模拟挂起的节点
+	drop     map[string]bool   //处理后不响应并断开连接的方法
+}
+
+// NewTrustNode 创建内存版托管节点
+func NewTrustNode(nodeName string) *TrustNode {
+	node := owtp.NewNode(owtp.NodeConfig{
+		Cert:       owtp.NewRandomCertificate(),
+		TimeoutSEC: 30,
+	})
+	t := &TrustNode{
+		node: node,
+		info: openwsdk.TrustNodeInfo{
+			NodeID:      node.NodeID(),
+			NodeName:    nodeName,
+			ConnectType: owtp.Websocket,
+			Version:     "openwsdktest",
+		},
+		keys:   make(map[string]*hdkeystore.HDKey),
+		curves: make(map[string]uint32),
+		calls:  make(map[string]int),
+		drop:   make(map[string]bool),
+	}
+	node.HandleFunc("getTrustNodeInfo", t.getTrustNodeInfo)
+	node.HandleFunc("signHashViaTrustNode", t.signHashViaTrustNode)
+	node.HandleFunc("getLocalWalletListViaTrustNode", t.getLocalWalletListViaTrustNode)
+	node.HandleFunc("sendTransactionViaTrustNode", t.sendTransactionViaTrustNode)
+	node.HandleFunc("startSummaryTaskViaTrustNode", t.summaryTaskViaTrustNode)
+	node.HandleFunc("appendSummaryTaskViaTrustNode", t.summaryTaskViaTrustNode)
+	return t
+}
+
+// NodeID 节点ID，即TransmitNode看到的对端ID
+func (t *TrustNode) NodeID() string {
+	return t.info.NodeID
+}
+
+// Info 节点信息
+func (t *TrustNode) Info() *openwsdk.TrustNodeInfo {
+	info := t.info
+	return &info
+}
+
+// ImportKey 导入托管钱包的根密钥，password为空时不校验解锁密码
+func (t *TrustNode) ImportKey(key *hdkeystore.HDKey, password string) {
+	t.mu.Lock()
+	t.keys[key.KeyID] = key
+	t.password = password
+	t.mu.Unlock()
+}
+
+// SetCurve 设置主链使用的曲线，默认secp256k1
+func (t *TrustNode) SetCurve(symbol string, eccType uint32) {
+	t.mu.Lock()
+	t.curves[symbol] = eccType
+	t.mu.Unlock()
+}
+
+// SetDelay 设置getTrustNodeInfo响应前的等待时间，用于模拟连接未断开但不应答的节点
+func (t *TrustNode) SetDelay(delay time.Duration) {
+	t.mu.Lock()
+	t.delay = delay
+	t.mu.Unlock()
+}
+
+// SetDropAfter 处理method的请求后不响应并断开连接，模拟节点已执行但连接中断
+func (t *TrustNode) SetDropAfter(method string) {
+	t.mu.Lock()
+	t.drop[method] = true
+	t.mu.Unlock()
+}
+
+// dropped 是否需要断开连接代替响应
+func (t *TrustNode) dropped(method string) bool {
+	t.mu.RLock()
+	drop := t.drop[method]
+	t.mu.RUnlock()
+	if drop {
+		t.Disconnect()
+	}
+	return drop
+}
+
+// Calls 方法被调用的次数
+func (t *TrustNode) Calls(method string) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.calls[method]
+}
+
+// Connect 连接到TransmitNode的监听地址，并通知newNodeJoin
+func (t *TrustNode) Connect(address string) error {
+	_, err := t.node.Connect(TransmitPeerID, owtp.ConnectConfig{
+		Address:     address,
+		ConnectType: owtp.Websocket,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = t.node.CallSync(TransmitPeerID, "newNodeJoin", map[string]interface{}{
+		"nodeInfo": t.info,
+	})
+	return err
+}
+
+// Disconnect 断开与TransmitNode的连接
+func (t *TrustNode) Disconnect() {
+	t.node.ClosePeer(TransmitPeerID)
+}
+
+// Close 关闭节点
+func (t *TrustNode) Close() {
+	t.node.Close()
+}
+
+func (t *TrustNode) called(method string) {
+	t.mu.Lock()
+	t.calls[method]++
+	t.mu.Unlock()
+}
+
+func (t *TrustNode) getTrustNodeInfo(ctx *owtp.Context) {
+	t.called(ctx.Method)
+	t.mu.RLock()
+	delay := t.delay
+	t.mu.RUnlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	ctx.Response(t.info, owtp.StatusSuccess, "success")
+}
+
+func (t *TrustNode) getLocalWalletListViaTrustNode(ctx *owtp.Context) {
+	t.called(ctx.Method)
+	t.mu.RLock()
+	wallets := make([]*openwsdk.Wallet, 0, len(t.keys))
+	for walletID, key := range t.keys {
+		wallets = append(wallets, &openwsdk.Wallet{
+			AppID:    ctx.Params().Get("appID").String(),
+			WalletID: walletID,
+			Alias:    key.Alias,
+			IsTrust:  1,
+			RootPath: key.RootPath,
+		})
+	}
+	t.mu.RUnlock()
+	ctx.Response(wallets, owtp.StatusSuccess, "success")
+}
+
+// sendTransactionViaTrustNode 不构建真实交易，只按参数返回成功的交易记录
+func (t *TrustNode) sendTransactionViaTrustNode(ctx *owtp.Context) {
+	t.called(ctx.Method)
+	params := ctx.Params()
+	t.mu.RLock()
+	password := t.password
+	t.mu.RUnlock()
+	if len(password) > 0 && params.Get("password").String() != password {
+		ctx.Response(nil, openwallet.ErrUnknownException, "wallet password is incorrect")
+		return
+	}
+	tx := &openwsdk.Transaction{
+		AppID:     params.Get("appID").String(),
+		AccountID: params.Get("accountID").String(),
+		Sid:       params.Get("sid").String(),
+		Symbol:    params.Get("symbol").String(),
+		Amount:    params.Get("amount").String(),
+		ToAddress: []string{params.Get("address").String()},
+		Memo:      params.Get("memo").String(),
+	}
+	if t.dropped(ctx.Method) {
+		return
+	}
+	ctx.Response(map[string]interface{}{
+		"success": []*openwsdk.Transaction{tx},
+		"failure": []*openwsdk.FailedRawTransaction{},
+	}, owtp.StatusSuccess, "success")
+}
+
+// summaryTaskViaTrustNode 不执行汇总，只校验每个钱包的解锁密码
+func (t *TrustNode) summaryTaskViaTrustNode(ctx *owtp.Context) {
+	t.called(ctx.Method)
+	t.mu.RLock()
+	password := t.password
+	t.mu.RUnlock()
+	for _, w := range ctx.Params().Get("summaryTask.wallets").Array() {
+		if len(password) > 0 && w.Get("password").String() != password {
+			ctx.Response(nil, openwallet.ErrUnknownException, "wallet password is incorrect")
+			return
+		}
+	}
+	ctx.Response(nil, owtp.StatusSuccess, "success")
+}
+
+func (t *TrustNode) signHashViaTrustNode(ctx *owtp.Context) {
+	t.called(ctx.Method)
+	params := ctx.Params()
+	walletID := params.Get("walletID").String()
+	symbol := params.Get("symbol").String()
+
+	t.mu.RLock()
+	key, ok := t.keys[walletID]
+	password := t.password
+	eccType, hasCurve := t.curves[symbol]
+	t.mu.RUnlock()
+
+	if !ok {
+		ctx.Response(nil, openwallet.ErrUnknownException, "wallet not found")
+		return
+	}
+	if len(password) > 0 && params.Get("password").String() != password {
+		ctx.Response(nil, openwallet.ErrUnknownException, "wallet password is incorrect")
+		return
+	}
+	if !hasCurve {
+		eccType = owcrypt.ECC_CURVE_SECP256K1
+	}
+
+	keySignature := &openwsdk.KeySignature{
+		EccType:     eccType,
+		Address:     params.Get("address").String(),
+		Message:     params.Get("message").String(),
+		DerivedPath: params.Get("hdPath").String(),
+		RSV:         params.Get("rsv").Bool(),
+	}
+	if err := openwsdk.NewHDKeySigner(key).Sign(context.Background(), keySignature); err != nil {
+		ctx.Response(nil, openwallet.ErrSignRawTransactionFailed, err.Error())
+		return
+	}
+	ctx.Response(map[string]interface{}{"signature": keySignature.Signature}, owtp.StatusSuccess, "success")
+}
@@ -0,0 +1,352 @@
+package openwsdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	/* 签名策略规则 */
+	PolicyRuleInvalidTx    = "invalidTx"    //交易单无法解析
+	PolicyRuleDailyLimit   = "dailyLimit"   //币种每日限额
+	PolicyRuleTrustAddress = "trustAddress" //目标地址不在信任列表
+	PolicyRuleFeeRatio     = "feeRatio"     //手续费占转账金额比例过高
+	PolicyRuleVelocity     = "velocity"     //账户签名频率过高
+)
+
+// PolicyRules 签名前的交易单检查规则，可从JSON/YAML文件加载
+type PolicyRules struct {
+	// DailyLimits 每日转账限额（UTC自然日），key为币种：主币用Symbol，代币用Symbol:ContractID
+	DailyLimits map[string]string `json:"dailyLimits" yaml:"dailyLimits"`
+	// RequireTrustAddress 目标地址必须在TrustAddresses中
+	RequireTrustAddress bool `json:"requireTrustAddress" yaml:"requireTrustAddress"`
+	// TrustAddresses 信任地址列表，按GenTrustAddressID匹配
+	TrustAddresses []*TrustAddress `json:"trustAddresses" yaml:"trustAddresses"`
+	// MaxFeeRatio 主币交易的手续费/转账总额上限，如0.01
+	MaxFeeRatio string `json:"maxFeeRatio" yaml:"maxFeeRatio"`
+	// Velocity 每个账户在时间窗口内的签名次数上限
+	Velocity []*VelocityLimit `json:"velocity" yaml:"velocity"`
+}
+
+// VelocityLimit 时间窗口内的签名次数上限
+type VelocityLimit struct {
+	Window   int64 `json:"window" yaml:"window"`     //时间窗口，秒
+	MaxCount int   `json:"maxCount" yaml:"maxCount"` //窗口内最多签名次数
+}
+
+// LoadPolicyRules 从文件加载规则，.yaml/.yml按YAML解析，其余按JSON解析
+func LoadPolicyRules(path string) (*PolicyRules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules PolicyRules
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &rules)
+	default:
+		err = json.Unmarshal(data, &rules)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse policy rules %s failed: %v", path, err)
+	}
+	return &rules, nil
+}
+
+// PolicyViolation 交易单违反的规则
+type PolicyViolation struct {
+	Rule      string `json:"rule"`
+	Reason    string `json:"reason"`
+	AccountID string `json:"accountID,omitempty"`
+	Symbol    string `json:"symbol,omitempty"`
+	Address   string `json:"address,omitempty"`
+	Limit     string `json:"limit,omitempty"`
+	Actual    string `json:"actual,omitempty"`
+}
+
+// Error 实现error接口，拒绝签名时返回
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("policy %s refused: %s", v.Rule, v.Reason)
+}
+
+// PolicyAuditRecord 每次决策的审计记录
+type PolicyAuditRecord struct {
+	Time       time.Time          `json:"time"`
+	Sid        string             `json:"sid"`
+	AccountID  string             `json:"accountID"`
+	Symbol     string             `json:"symbol"`
+	ContractID string             `json:"contractID,omitempty"`
+	To         map[string]string  `json:"to"`
+	Amount     string             `json:"amount"`
+	Fees       string             `json:"fees"`
+	FeeRate    string             `json:"feeRate,omitempty"`
+	Allowed    bool               `json:"allowed"`
+	Violations []*PolicyViolation `json:"violations,omitempty"`
+}
+
+// Err 被拒绝时返回第一个违反的规则
+func (r *PolicyAuditRecord) Err() error {
+	if r.Allowed || len(r.Violations) == 0 {
+		return nil
+	}
+	return r.Violations[0]
+}
+
+// NewPolicyAuditWriter 把审计记录按JSON行写入w
+func NewPolicyAuditWriter(w io.Writer) func(record *PolicyAuditRecord) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(record *PolicyAuditRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(record)
+	}
+}
+
+// PolicyEngine 签名前检查交易单，通过后记录当日用量和签名次数。
+// 用量只保存在内存中，进程重启后重新计算。
+type PolicyEngine struct {
+	mu           sync.Mutex
+	rules        *PolicyRules
+	dailyLimits  map[string]decimal.Decimal
+	maxFeeRatio  decimal.Decimal
+	trusted      map[string]bool
+	day          string
+	spent        map[string]decimal.Decimal //币种 -> 当日已签名金额
+	history      map[string][]time.Time     //accountID -> 签名时间
+	auditHandler func(record *PolicyAuditRecord)
+	now          func() time.Time
+}
+
+// NewPolicyEngine 创建签名策略引擎
+func NewPolicyEngine(rules *PolicyRules) (*PolicyEngine, error) {
+	if rules == nil {
+		rules = &PolicyRules{}
+	}
+	e := &PolicyEngine{
+		rules:       rules,
+		dailyLimits: make(map[string]decimal.Decimal),
+		spent:       make(map[string]decimal.Decimal),
+		history:     make(map[string][]time.Time),
+		now:         time.Now,
+	}
+	for asset, limit := range rules.DailyLimits {
+		d, err := decimal.NewFromString(limit)
+		if err != nil || d.IsNegative() {
+			return nil, fmt.Errorf("invalid daily limit of %s: %s", asset, limit)
+		}
+		e.dailyLimits[asset] = d
+	}
+	if len(rules.MaxFeeRatio) > 0 {
+		d, err := decimal.NewFromString(rules.MaxFeeRatio)
+		if err != nil || d.IsNegative() {
+			return nil, fmt.Errorf("invalid max fee ratio: %s", rules.MaxFeeRatio)
+		}
+		e.maxFeeRatio = d
+	}
+	for _, v := range rules.Velocity {
+		if v == nil || v.Window <= 0 || v.MaxCount <= 0 {
+			return nil, fmt.Errorf("invalid velocity limit: %+v", v)
+		}
+	}
+	e.SetTrustAddresses(rules.TrustAddresses)
+	return e, nil
+}
+
+// SetTrustAddresses 替换信任地址列表，可用于同步托管节点的GetTrustAddressList
+func (e *PolicyEngine) SetTrustAddresses(addresses []*TrustAddress) {
+	trusted := make(map[string]bool, len(addresses))
+	for _, a := range addresses {
+		if a == nil {
+			continue
+		}
+		trusted[GenTrustAddressID(a.Address, a.Symbol)] = true
+	}
+	e.mu.Lock()
+	e.trusted = trusted
+	e.mu.Unlock()
+}
+
+// SetAuditHandler 设置审计记录的处理函数
+func (e *PolicyEngine) SetAuditHandler(h func(record *PolicyAuditRecord)) {
+	e.mu.Lock()
+	e.auditHandler = h
+	e.mu.Unlock()
+}
+
+// SetClock 设置时钟，用于测试
+func (e *PolicyEngine) SetClock(now func() time.Time) {
+	e.mu.Lock()
+	e.now = now
+	e.mu.Unlock()
+}
+
+// Evaluate 检查交易单但不记录用量
+func (e *PolicyEngine) Evaluate(rawTx *RawTransaction) *PolicyAuditRecord {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	record, _ := e.evaluate(rawTx)
+	return record
+}
+
+// Authorize 检查交易单，通过后记录用量，违反规则时返回*PolicyViolation
+func (e *PolicyEngine) Authorize(rawTx *RawTransaction) error {
+	e.mu.Lock()
+	record, amount := e.evaluate(rawTx)
+	if record.Allowed {
+		asset := policyAsset(&rawTx.Coin)
+		e.spent[asset] = e.spent[asset].Add(amount)
+		e.history[rawTx.AccountID] = append(e.history[rawTx.AccountID], record.Time)
+	}
+	h := e.auditHandler
+	e.mu.Unlock()
+
+	if h != nil {
+		h(record)
+	}
+	return record.Err()
+}
+
+// SignRawTransaction 检查通过后使用signer签名交易单
+func (e *PolicyEngine) SignRawTransaction(ctx context.Context, rawTx *RawTransaction, signer Signer) error {
+	if rawTx == nil {
+		return fmt.Errorf("raw transaction is nil")
+	}
+	if err := e.Authorize(rawTx); err != nil {
+		return err
+	}
+	return SignRawTransactionWithSigner(ctx, rawTx, signer)
+}
+
+// evaluate 检查所有规则，返回审计记录和转账总额，调用者持有锁
+func (e *PolicyEngine) evaluate(rawTx *RawTransaction) (*PolicyAuditRecord, decimal.Decimal) {
+	now := e.now()
+	record := &PolicyAuditRecord{Time: now}
+	if rawTx == nil {
+		record.Violations = []*PolicyViolation{{Rule: PolicyRuleInvalidTx, Reason: "raw transaction is nil"}}
+		return record, decimal.Zero
+	}
+	record.Sid = rawTx.Sid
+	record.AccountID = rawTx.AccountID
+	record.Symbol = rawTx.Coin.Symbol
+	record.ContractID = rawTx.Coin.ContractID
+	record.Fees = rawTx.Fees
+	record.FeeRate = rawTx.FeeRate
+	record.To = make(map[string]string, len(rawTx.To))
+	for address, amount := range rawTx.To {
+		record.To[address] = amount
+	}
+
+	violate := func(v *PolicyViolation) {
+		v.AccountID = rawTx.AccountID
+		v.Symbol = rawTx.Coin.Symbol
+		record.Violations = append(record.Violations, v)
+	}
+
+	//按地址排序，保证违规顺序稳定
+	addresses := make([]string, 0, len(rawTx.To))
+	for address := range rawTx.To {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	total := decimal.Zero
+	for _, address := range addresses {
+		amount, err := decimal.NewFromString(rawTx.To[address])
+		if err != nil || !amount.IsPositive() {
+			violate(&PolicyViolation{Rule: PolicyRuleInvalidTx, Reason: "invalid amount", Address: address, Actual: rawTx.To[address]})
+			continue
+		}
+		total = total.Add(amount)
+	}
+	record.Amount = total.String()
+	if len(addresses) == 0 {
+		violate(&PolicyViolation{Rule: PolicyRuleInvalidTx, Reason: "no destination"})
+	}
+
+	if e.rules.RequireTrustAddress {
+		for _, address := range addresses {
+			if !e.trusted[GenTrustAddressID(address, rawTx.Coin.Symbol)] {
+				violate(&PolicyViolation{Rule: PolicyRuleTrustAddress, Reason: "destination is not a trust address", Address: address})
+			}
+		}
+	}
+
+	asset := policyAsset(&rawTx.Coin)
+	if day := now.UTC().Format("2006-01-02"); day != e.day {
+		e.day = day
+		e.spent = make(map[string]decimal.Decimal)
+	}
+	if limit, ok := e.dailyLimits[asset]; ok {
+		if spent := e.spent[asset].Add(total); spent.GreaterThan(limit) {
+			violate(&PolicyViolation{Rule: PolicyRuleDailyLimit, Reason: "daily limit exceeded of " + asset,
+				Limit: limit.String(), Actual: spent.String()})
+		}
+	}
+
+	//代币交易的手续费以主币计算，不与转账金额比较
+	if len(e.rules.MaxFeeRatio) > 0 && !rawTx.Coin.IsContract {
+		fees, err := decimal.NewFromString(rawTx.Fees)
+		switch {
+		case err != nil || fees.IsNegative():
+			violate(&PolicyViolation{Rule: PolicyRuleFeeRatio, Reason: "invalid fees", Actual: rawTx.Fees})
+		case total.IsPositive():
+			if ratio := fees.Div(total); ratio.GreaterThan(e.maxFeeRatio) {
+				violate(&PolicyViolation{Rule: PolicyRuleFeeRatio, Reason: "fee ratio too high",
+					Limit: e.maxFeeRatio.String(), Actual: ratio.String()})
+			}
+		}
+	}
+
+	if len(e.rules.Velocity) > 0 {
+		history := e.history[rawTx.AccountID]
+		var longest time.Duration
+		for _, v := range e.rules.Velocity {
+			window := time.Duration(v.Window) * time.Second
+			if window > longest {
+				longest = window
+			}
+			count := 0
+			for _, t := range history {
+				if now.Sub(t) < window {
+					count++
+				}
+			}
+			if count >= v.MaxCount {
+				violate(&PolicyViolation{Rule: PolicyRuleVelocity,
+					Reason: fmt.Sprintf("more than %d signings in %s", v.MaxCount, window),
+					Limit:  fmt.Sprint(v.MaxCount), Actual: fmt.Sprint(count + 1)})
+			}
+		}
+		//清理超出最长窗口的记录
+		kept := history[:0]
+		for _, t := range history {
+			if now.Sub(t) < longest {
+				kept = append(kept, t)
+			}
+		}
+		e.history[rawTx.AccountID] = kept
+	}
+
+	record.Allowed = len(record.Violations) == 0
+	return record, total
+}
+
+// policyAsset 限额使用的币种标识
+func policyAsset(coin *Coin) string {
+	if coin.IsContract {
+		return coin.Symbol + ":" + coin.ContractID
+	}
+	return coin.Symbol
+}
@@ -0,0 +1,119 @@
+package openwsdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testPolicyYAML = `
+dailyLimits:
+  BTC: "1.5"
+requireTrustAddress: true
+trustAddresses:
+  - address: addr-1
+    symbol: BTC
+  - address: addr-2
+    symbol: BTC
+maxFeeRatio: "0.01"
+velocity:
+  - window: 60
+    maxCount: 2
+`
+
+func TestPolicyEngine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	if err != nil {
+		t.Fatalf("TempDir unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.yaml")
+	if err := ioutil.WriteFile(path, []byte(testPolicyYAML), 0600); err != nil {
+		t.Fatalf("WriteFile unexpected error: %v", err)
+	}
+	rules, err := LoadPolicyRules(path)
+	if err != nil {
+		t.Fatalf("LoadPolicyRules unexpected error: %v", err)
+	}
+	engine, err := NewPolicyEngine(rules)
+	if err != nil {
+		t.Fatalf("NewPolicyEngine unexpected error: %v", err)
+	}
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	engine.SetClock(func() time.Time { return now })
+	var audit bytes.Buffer
+	engine.SetAuditHandler(NewPolicyAuditWriter(&audit))
+
+	newTx := func(accountID, address, amount, fees string) *RawTransaction {
+		return &RawTransaction{
+			Coin:      Coin{Symbol: "BTC"},
+			AccountID: accountID,
+			To:        map[string]string{address: amount},
+			Fees:      fees,
+		}
+	}
+	rule := func(err error) string {
+		if v, ok := err.(*PolicyViolation); ok {
+			return v.Rule
+		}
+		return ""
+	}
+
+	tests := []struct {
+		tx   *RawTransaction
+		rule string
+	}{
+		{newTx("A1", "addr-1", "1", "0.001"), ""},
+		{newTx("A2", "addr-3", "0.1", "0.0001"), PolicyRuleTrustAddress},
+		{newTx("A2", "addr-2", "0.1", "0.01"), PolicyRuleFeeRatio},
+		{newTx("A2", "addr-2", "0.6", "0.001"), PolicyRuleDailyLimit},
+		{newTx("A1", "addr-2", "0.1", "0.0001"), ""},
+		{newTx("A1", "addr-2", "0.1", "0.0001"), PolicyRuleVelocity},
+		{newTx("A1", "addr-2", "-1", "0"), PolicyRuleInvalidTx},
+	}
+	for i, test := range tests {
+		if got := rule(engine.Authorize(test.tx)); got != test.rule {
+			t.Errorf("case %d: unexpected rule: %q, want: %q", i, got, test.rule)
+		}
+	}
+
+	//次日限额和频率重置
+	now = now.Add(24 * time.Hour)
+	if err := engine.Authorize(newTx("A1", "addr-1", "1.5", "0.001")); err != nil {
+		t.Errorf("Authorize unexpected error on next day: %v", err)
+	}
+
+	var records []*PolicyAuditRecord
+	dec := json.NewDecoder(&audit)
+	for dec.More() {
+		var record PolicyAuditRecord
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("decode audit record unexpected error: %v", err)
+		}
+		records = append(records, &record)
+	}
+	if len(records) != len(tests)+1 {
+		t.Fatalf("unexpected audit records: %d", len(records))
+	}
+	if r := records[3]; r.Allowed || r.Violations[0].Limit != "1.5" || r.Violations[0].Actual != "1.6" {
+		t.Errorf("unexpected daily limit audit record: %+v", r.Violations[0])
+	}
+
+	//拒绝时不签名
+	signed := false
+	signer := SignerFunc(func(ctx context.Context, ks *KeySignature) error {
+		signed = true
+		return nil
+	})
+	rawTx := newTx("A3", "addr-3", "0.1", "0")
+	rawTx.Signatures = map[string][]*KeySignature{"A3": {{}}}
+	if err := engine.SignRawTransaction(context.Background(), rawTx, signer); err == nil || signed {
+		t.Errorf("SignRawTransaction expected refused, err: %v, signed: %v", err, signed)
+	}
+}
@@ -16,6 +16,7 @@
 package openwsdk
 
 import (
+	"errors"
 	"fmt"
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/owtp"
@@ -75,11 +76,16 @@ func (api *APINode) ServeProxyNode(address string) (*ProxyNode, error) {
 		return nil, fmt.Errorf("proxy node is inited")
 	}
 
+	creds, err := api.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
 	proxyNode := NewProxyNode(&APINodeConfig{
 		Host:               address,
 		ConnectType:        owtp.HTTP,
-		AppID:              api.config.AppID,
-		AppKey:             api.config.AppKey,
+		AppID:              creds.AppID,
+		AppKey:             creds.AppKey,
 		Cert:               api.config.Cert,
 		EnableSignature:    api.config.EnableSignature,
 		EnableKeyAgreement: api.config.EnableKeyAgreement,
@@ -163,9 +169,13 @@ func (proxyNode *ProxyNode) proxyServerHandler(ctx *owtp.Context) {
 	}
 
 	if pass {
-		resp, err := proxyNode.parent.OWTPNode().CallSync(HostNodeID, ctx.Method, ctx.Params().Raw)
+		resp, err := proxyNode.parent.callSync(ctx.Method, ctx.Params().Raw)
 		if err != nil {
-			ctx.ResponseStopRun(nil, owtp.ErrBadRequest, err.Error())
+			status := owtp.ErrBadRequest
+			if errors.Is(err, ErrRateLimited) {
+				status = owtp.ErrDenialOfService
+			}
+			ctx.ResponseStopRun(nil, status, err.Error())
 			return
 		}
 
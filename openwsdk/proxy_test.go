@@ -1,3 +1,8 @@
+//go:build live
+// +build live
+
+// 需要conf/test.ini配置的openw-server和托管节点，使用 go test -tags live 运行
+
 /*
  * Copyright 2019 The openwallet Authors
  * This file is part of the openwallet library.
@@ -0,0 +1,458 @@
+package openwsdk
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// StatusTooManyRequests openw-server限流时返回的状态码，与owtp.ErrDenialOfService一样视为限流
+const StatusTooManyRequests uint64 = 429
+
+// Priority 请求优先级，等待令牌和同时请求数时高优先级的请求先发出
+type Priority int
+
+const (
+	PriorityLow    Priority = iota - 1 //批量查询，如余额扫描
+	PriorityNormal                     //默认优先级
+	PriorityHigh                       //提现等交易请求
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+// DefaultMethodPriorities 方法的默认优先级，未列出的方法为PriorityNormal
+var DefaultMethodPriorities = map[string]Priority{
+	"createTrade":              PriorityHigh,
+	"createBatchTrade":         PriorityHigh,
+	"submitTrade":              PriorityHigh,
+	"createSmartContractTrade": PriorityHigh,
+	"submitSmartContractTrade": PriorityHigh,
+	"createSummaryTx":          PriorityHigh,
+	"getAccountBalanceList":    PriorityLow,
+	"getAddressBalanceList":    PriorityLow,
+	"getBalanceByAccount":      PriorityLow,
+	"getBalanceByAddress":      PriorityLow,
+	"findAddressByAccountID":   PriorityLow,
+	"findAddressByParams":      PriorityLow,
+}
+
+// RateLimit 令牌桶参数
+type RateLimit struct {
+	Rate  float64 //每秒请求数，0表示不限制
+	Burst int     //可以瞬时发出的请求数，0表示Rate向上取整
+}
+
+// RateLimitConfig 客户端限流配置
+type RateLimitConfig struct {
+	Global           RateLimit            //所有方法共享的令牌桶
+	Methods          map[string]RateLimit //按方法的令牌桶，与Global同时生效
+	MaxInFlight      int                  //同时等待响应的请求上限，0表示不限制
+	ReservedInFlight int                  //MaxInFlight中只留给PriorityHigh请求的数量
+	Priorities       map[string]Priority  //方法的优先级，未设置的使用DefaultMethodPriorities
+	Block            bool                 //true阻塞等待，false立即返回RateLimitError
+	MaxWait          time.Duration        //阻塞等待的上限，超过后返回RateLimitError，0表示只受APIClient的ctx限制
+	ThrottleBackoff  Backoff              //服务端返回限流状态后暂停发送的时间，连续限流时递增，默认DefaultBackoff
+}
+
+// RateLimitError 请求被客户端限流，未发送到openw-server
+type RateLimitError struct {
+	Method     string
+	Priority   Priority
+	RetryAfter time.Duration //预计可以发送的等待时间，0表示需要等待其他请求完成
+	Throttled  bool          //是否因为服务端返回限流状态而暂停发送
+}
+
+// Error 实现error接口
+func (e *RateLimitError) Error() string {
+	reason := "client rate limit"
+	if e.Throttled {
+		reason = "server throttling"
+	}
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s: rate limited by %s, retry after %v", e.Method, reason, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s: rate limited by %s", e.Method, reason)
+}
+
+// Is 支持errors.Is(err, ErrRateLimited)
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// SetRateLimitConfig 设置客户端限流，config为空时关闭限流。
+// WithAppID得到的节点与原节点共享限流；已经在等待的请求按原配置处理。
+func (api *APINode) SetRateLimitConfig(config *RateLimitConfig) error {
+	if api == nil {
+		return fmt.Errorf("APINode is not inited")
+	}
+	var limiter *rateLimiter
+	if config != nil {
+		if err := config.validate(); err != nil {
+			return err
+		}
+		limiter = newRateLimiter(*config)
+	}
+	root := api.root()
+	root.mu.Lock()
+	root.limiter = limiter
+	root.mu.Unlock()
+	return nil
+}
+
+func (config *RateLimitConfig) validate() error {
+	limits := map[string]RateLimit{"global": config.Global}
+	for method, limit := range config.Methods {
+		limits[method] = limit
+	}
+	for name, limit := range limits {
+		if limit.Rate < 0 || limit.Burst < 0 {
+			return fmt.Errorf("rate limit of %s must not be negative", name)
+		}
+	}
+	if config.MaxInFlight < 0 || config.ReservedInFlight < 0 || config.MaxWait < 0 {
+		return fmt.Errorf("maxInFlight, reservedInFlight and maxWait must not be negative")
+	}
+	if config.ReservedInFlight > 0 && config.ReservedInFlight >= config.MaxInFlight {
+		return fmt.Errorf("reservedInFlight %d must be less than maxInFlight %d", config.ReservedInFlight, config.MaxInFlight)
+	}
+	return nil
+}
+
+// call 经过限流后发送请求，响应回调前释放占用的同时请求数，发送失败的错误见callError
+func (api *APINode) call(method string, params interface{}, sync bool, reqFunc owtp.RequestFunc) error {
+	release, err := api.acquire(method)
+	if err != nil {
+		return err
+	}
+	err = owtpCall(api.node, HostNodeID, method, params, sync, func(resp owtp.Response) {
+		release(resp.Status)
+		reqFunc(resp)
+	})
+	if err != nil {
+		release(0)
+	}
+	return err
+}
+
+// callSync 经过限流后发送同步请求
+func (api *APINode) callSync(method string, params interface{}) (*owtp.Response, error) {
+	release, err := api.acquire(method)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := owtpCallSync(api.node, HostNodeID, method, params)
+	if err != nil {
+		release(0)
+		return nil, err
+	}
+	release(resp.Status)
+	return resp, nil
+}
+
+// acquire 获取发送请求的许可，未设置限流时直接返回，排队时api.ctx结束则放弃
+func (api *APINode) acquire(method string) (func(status uint64), error) {
+	root := api.root()
+	root.mu.RLock()
+	limiter := root.limiter
+	root.mu.RUnlock()
+	if limiter == nil {
+		return func(uint64) {}, nil
+	}
+	ctx := api.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return limiter.acquire(ctx, method)
+}
+
+// tokenBucket 令牌桶
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst <= 0 {
+		burst = math.Ceil(limit.Rate)
+	}
+	return &tokenBucket{rate: limit.Rate, burst: burst, tokens: burst, last: now}
+}
+
+// wait 距离有可用令牌的时间，0表示现在可用
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	if now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - b.tokens) / b.rate * float64(time.Second)))
+}
+
+func (b *tokenBucket) take() {
+	if b != nil {
+		b.tokens--
+	}
+}
+
+// rateWaiter 等待许可的请求
+type rateWaiter struct {
+	method   string
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+	granted  bool
+}
+
+// rateLimiter 全局和按方法的令牌桶、同时请求数和服务端限流暂停。
+// 等待中的请求按优先级和先后顺序排列，排在前面的请求等待全局资源时，后面的请求不会越过它。
+type rateLimiter struct {
+	mu          sync.Mutex
+	config      RateLimitConfig
+	global      *tokenBucket
+	methods     map[string]*tokenBucket
+	inFlight    int
+	waiters     []*rateWaiter
+	seq         uint64
+	pausedUntil time.Time //服务端限流后暂停发送到该时间
+	throttled   int       //连续收到限流状态的次数
+	timer       *time.Timer
+	now         func() time.Time
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	l := &rateLimiter{
+		config:  config,
+		methods: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+	now := l.now()
+	l.global = newTokenBucket(config.Global, now)
+	for method, limit := range config.Methods {
+		if b := newTokenBucket(limit, now); b != nil {
+			l.methods[method] = b
+		}
+	}
+	if l.config.ThrottleBackoff == (Backoff{}) {
+		l.config.ThrottleBackoff = DefaultBackoff
+	}
+	return l
+}
+
+func (l *rateLimiter) priority(method string) Priority {
+	if p, ok := l.config.Priorities[method]; ok {
+		return p
+	}
+	return DefaultMethodPriorities[method]
+}
+
+// acquire 获取许可，返回的release在收到响应后调用，status为响应状态码，未收到响应时为0
+func (l *rateLimiter) acquire(ctx context.Context, method string) (func(status uint64), error) {
+	l.mu.Lock()
+	l.seq++
+	w := &rateWaiter{
+		method:   method,
+		priority: l.priority(method),
+		seq:      l.seq,
+		ready:    make(chan struct{}),
+	}
+	l.enqueue(w)
+	l.dispatch()
+	if w.granted {
+		l.mu.Unlock()
+		return l.releaser(), nil
+	}
+	if !l.config.Block {
+		err := l.rejectLocked(w)
+		l.mu.Unlock()
+		return nil, err
+	}
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if l.config.MaxWait > 0 {
+		timer := time.NewTimer(l.config.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-w.ready:
+		return l.releaser(), nil
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ctx.Err() != nil {
+		//调用方已经放弃，已获得的许可也要归还
+		if w.granted {
+			l.inFlight--
+		} else {
+			l.dequeue(w)
+		}
+		l.dispatch()
+		return nil, ctx.Err()
+	}
+	if w.granted {
+		return l.releaser(), nil
+	}
+	err := l.rejectLocked(w)
+	//排在前面的请求离开后，后面的请求可能可以发送
+	l.dispatch()
+	return nil, err
+}
+
+// rejectLocked 移出等待队列并返回限流错误
+func (l *rateLimiter) rejectLocked(w *rateWaiter) error {
+	l.dequeue(w)
+	now := l.now()
+	err := &RateLimitError{Method: w.method, Priority: w.priority}
+	if now.Before(l.pausedUntil) {
+		err.Throttled = true
+		err.RetryAfter = l.pausedUntil.Sub(now)
+		return err
+	}
+	if d := l.methods[w.method].wait(now); d > err.RetryAfter {
+		err.RetryAfter = d
+	}
+	if d := l.global.wait(now); d > err.RetryAfter {
+		err.RetryAfter = d
+	}
+	if !l.slotFree(w.priority) {
+		err.RetryAfter = 0
+	}
+	return err
+}
+
+// releaser 释放同时请求数，根据响应状态更新服务端限流暂停
+func (l *rateLimiter) releaser() func(status uint64) {
+	var once sync.Once
+	return func(status uint64) {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.inFlight--
+			switch status {
+			case owtp.ErrDenialOfService, StatusTooManyRequests:
+				l.throttled++
+				l.pausedUntil = l.now().Add(l.config.ThrottleBackoff.Duration(l.throttled))
+			case owtp.StatusSuccess:
+				l.throttled = 0
+			}
+			l.dispatch()
+		})
+	}
+}
+
+func (l *rateLimiter) enqueue(w *rateWaiter) {
+	i := sort.Search(len(l.waiters), func(i int) bool {
+		o := l.waiters[i]
+		return o.priority < w.priority || (o.priority == w.priority && o.seq > w.seq)
+	})
+	l.waiters = append(l.waiters, nil)
+	copy(l.waiters[i+1:], l.waiters[i:])
+	l.waiters[i] = w
+}
+
+func (l *rateLimiter) dequeue(w *rateWaiter) {
+	for i, o := range l.waiters {
+		if o == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// slotFree 是否还有该优先级可用的同时请求数
+func (l *rateLimiter) slotFree(p Priority) bool {
+	if l.config.MaxInFlight <= 0 {
+		return true
+	}
+	limit := l.config.MaxInFlight
+	if p < PriorityHigh {
+		limit -= l.config.ReservedInFlight
+	}
+	return l.inFlight < limit
+}
+
+// dispatch 按顺序放行等待的请求，需要等待令牌时设置定时器
+func (l *rateLimiter) dispatch() {
+	now := l.now()
+	if now.Before(l.pausedUntil) {
+		l.schedule(l.pausedUntil.Sub(now))
+		return
+	}
+	var next time.Duration
+	for i := 0; i < len(l.waiters); {
+		w := l.waiters[i]
+		method := l.methods[w.method]
+		if d := method.wait(now); d > 0 {
+			//只受自身方法限制，不阻挡后面的请求
+			if next == 0 || d < next {
+				next = d
+			}
+			i++
+			continue
+		}
+		if !l.slotFree(w.priority) {
+			//队列按优先级排列，后面的请求可用的同时请求数不会更多
+			break
+		}
+		if d := l.global.wait(now); d > 0 {
+			if next == 0 || d < next {
+				next = d
+			}
+			break
+		}
+		method.take()
+		l.global.take()
+		l.inFlight++
+		w.granted = true
+		close(w.ready)
+		l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+	}
+	if next > 0 && len(l.waiters) > 0 {
+		l.schedule(next)
+	}
+}
+
+// schedule 到时后重新放行，旧的定时器提前触发也没有影响
+func (l *rateLimiter) schedule(d time.Duration) {
+	if len(l.waiters) == 0 {
+		return
+	}
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.timer = time.AfterFunc(d, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.dispatch()
+	})
+}
@@ -0,0 +1,214 @@
+package openwsdk_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func testRateLimitNode(t *testing.T) (*openwsdktest.Server, *openwsdk.APINode) {
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	api, err := s.NewAPINode(owtp.HTTP)
+	if err != nil {
+		s.Close()
+		t.Fatalf("NewAPINode unexpected error: %v", err)
+	}
+	return s, api
+}
+
+func testGetSymbolList(api *openwsdk.APINode) (uint64, error) {
+	var status uint64
+	err := api.GetSymbolList("", 0, 10, 0, true, func(s uint64, msg string, total int, symbols []*openwsdk.Symbol) {
+		status = s
+	})
+	return status, err
+}
+
+func TestRateLimit_NonBlocking(t *testing.T) {
+	s, api := testRateLimitNode(t)
+	defer s.Close()
+
+	err := api.SetRateLimitConfig(&openwsdk.RateLimitConfig{
+		Global: openwsdk.RateLimit{Rate: 1, Burst: 2},
+	})
+	if err != nil {
+		t.Fatalf("SetRateLimitConfig unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if status, err := testGetSymbolList(api); err != nil || status != owtp.StatusSuccess {
+			t.Fatalf("GetSymbolList %d unexpected result: %d, %v", i, status, err)
+		}
+	}
+	_, err = testGetSymbolList(api)
+	var rateErr *openwsdk.RateLimitError
+	if !errors.Is(err, openwsdk.ErrRateLimited) || !errors.As(err, &rateErr) || rateErr.RetryAfter <= 0 {
+		t.Fatalf("GetSymbolList expected RateLimitError, got: %v", err)
+	}
+	if calls := s.Calls("getSymbolBlockList"); calls != 2 {
+		t.Errorf("getSymbolBlockList expected 2 calls, got: %d", calls)
+	}
+
+	//按方法限制
+	err = api.SetRateLimitConfig(&openwsdk.RateLimitConfig{
+		Methods: map[string]openwsdk.RateLimit{"getSymbolBlockList": {Rate: 1}},
+	})
+	if err != nil {
+		t.Fatalf("SetRateLimitConfig unexpected error: %v", err)
+	}
+	testGetSymbolList(api)
+	if _, err := testGetSymbolList(api); !errors.Is(err, openwsdk.ErrRateLimited) {
+		t.Errorf("GetSymbolList expected ErrRateLimited, got: %v", err)
+	}
+	if err := api.FindWalletByWalletID("W1", true, func(uint64, string, *openwsdk.Wallet) {}); err != nil {
+		t.Errorf("FindWalletByWalletID unexpected error: %v", err)
+	}
+
+	if err := api.SetRateLimitConfig(&openwsdk.RateLimitConfig{MaxInFlight: 1, ReservedInFlight: 1}); err == nil {
+		t.Errorf("SetRateLimitConfig expected error with reservedInFlight >= maxInFlight")
+	}
+	api.SetRateLimitConfig(nil)
+	if _, err := testGetSymbolList(api); err != nil {
+		t.Errorf("GetSymbolList unexpected error after disabling: %v", err)
+	}
+}
+
+func TestRateLimit_BlockingPriority(t *testing.T) {
+	s, api := testRateLimitNode(t)
+	defer s.Close()
+
+	err := api.SetRateLimitConfig(&openwsdk.RateLimitConfig{
+		Global: openwsdk.RateLimit{Rate: 10, Burst: 1},
+		Block:  true,
+		Priorities: map[string]openwsdk.Priority{
+			"getSymbolBlockList":   openwsdk.PriorityLow,
+			"findWalletByWalletID": openwsdk.PriorityHigh,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SetRateLimitConfig unexpected error: %v", err)
+	}
+	testGetSymbolList(api)
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	record := func(name string) {
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		testGetSymbolList(api)
+		record("low")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		defer wg.Done()
+		api.FindWalletByWalletID("W1", true, func(uint64, string, *openwsdk.Wallet) {
+			record("high")
+		})
+	}()
+	wg.Wait()
+	if len(order) != 2 || order[0] != "high" {
+		t.Errorf("high priority request expected first, got: %v", order)
+	}
+
+	//等待超过MaxWait返回错误
+	err = api.SetRateLimitConfig(&openwsdk.RateLimitConfig{
+		Global:  openwsdk.RateLimit{Rate: 1, Burst: 1},
+		Block:   true,
+		MaxWait: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("SetRateLimitConfig unexpected error: %v", err)
+	}
+	testGetSymbolList(api)
+	start := time.Now()
+	if _, err := testGetSymbolList(api); !errors.Is(err, openwsdk.ErrRateLimited) {
+		t.Errorf("GetSymbolList expected ErrRateLimited, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("GetSymbolList returned before MaxWait: %v", elapsed)
+	}
+}
+
+func TestRateLimit_ContextCancel(t *testing.T) {
+	s, api := testRateLimitNode(t)
+	defer s.Close()
+
+	//MaxWait为0时只受ctx限制
+	err := api.SetRateLimitConfig(&openwsdk.RateLimitConfig{
+		Global: openwsdk.RateLimit{Rate: 5, Burst: 1},
+		Block:  true,
+	})
+	if err != nil {
+		t.Fatalf("SetRateLimitConfig unexpected error: %v", err)
+	}
+	testGetSymbolList(api)
+
+	client := openwsdk.NewAPIClient(api)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.GetSymbolList(ctx, "", 0, 10, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetSymbolList expected deadline exceeded, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("GetSymbolList did not return at deadline, elapsed: %v", elapsed)
+	}
+
+	//放弃的请求离开队列，令牌恢复后不会再发出
+	time.Sleep(300 * time.Millisecond)
+	if calls := s.Calls("getSymbolBlockList"); calls != 1 {
+		t.Errorf("getSymbolBlockList expected 1 call, got: %d", calls)
+	}
+	if _, err := client.GetSymbolList(context.Background(), "", 0, 10, 0); err != nil {
+		t.Errorf("GetSymbolList unexpected error: %v", err)
+	}
+}
+
+func TestRateLimit_ServerThrottling(t *testing.T) {
+	s, api := testRateLimitNode(t)
+	defer s.Close()
+
+	err := api.SetRateLimitConfig(&openwsdk.RateLimitConfig{
+		ThrottleBackoff: openwsdk.Backoff{InitialInterval: 100 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("SetRateLimitConfig unexpected error: %v", err)
+	}
+	s.FailNext("getSymbolBlockList", openwsdk.StatusTooManyRequests, 1, false)
+	if status, _ := testGetSymbolList(api); status != openwsdk.StatusTooManyRequests {
+		t.Fatalf("GetSymbolList expected status 429, got: %d", status)
+	}
+
+	_, err = testGetSymbolList(api)
+	var rateErr *openwsdk.RateLimitError
+	if !errors.As(err, &rateErr) || !rateErr.Throttled {
+		t.Fatalf("GetSymbolList expected throttled RateLimitError, got: %v", err)
+	}
+	time.Sleep(rateErr.RetryAfter)
+	if status, err := testGetSymbolList(api); err != nil || status != owtp.StatusSuccess {
+		t.Errorf("GetSymbolList unexpected result after pause: %d, %v", status, err)
+	}
+
+	if !errors.Is(openwsdk.NewError("submitTrade", owtp.ErrDenialOfService, "busy", nil), openwsdk.ErrRateLimited) {
+		t.Errorf("ErrDenialOfService expected to match ErrRateLimited")
+	}
+	if !openwsdk.IsRetryable(rateErr) {
+		t.Errorf("RateLimitError expected to be retryable")
+	}
+}
@@ -0,0 +1,213 @@
+package openwsdk
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// ConnectionState 与openw-server的连接状态
+type ConnectionState int
+
+const (
+	ConnectionStateConnected    ConnectionState = iota //已连接
+	ConnectionStateDisconnected                        //连接断开
+	ConnectionStateReconnecting                        //正在重连
+	ConnectionStateResubscribed                        //重连成功，并已恢复设备绑定和订阅
+	ConnectionStateFailed                              //重连次数用尽，停止重连
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnectionStateConnected:
+		return "connected"
+	case ConnectionStateDisconnected:
+		return "disconnected"
+	case ConnectionStateReconnecting:
+		return "reconnecting"
+	case ConnectionStateResubscribed:
+		return "resubscribed"
+	case ConnectionStateFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("ConnectionState(%d)", int(s))
+}
+
+// ConnectionEvent 连接状态变化事件
+type ConnectionEvent struct {
+	State   ConnectionState
+	Attempt int   //第几次重连，连接断开时为0
+	Err     error //本次重连失败的原因
+	Time    time.Time
+}
+
+// ReconnectConfig 自动重连配置
+type ReconnectConfig struct {
+	Backoff Backoff //重连退避策略，零值使用DefaultBackoff
+}
+
+// subscribeArgs 订阅参数，重连后重放
+type subscribeArgs struct {
+	subscribeMethod []string
+	listenAddr      string
+	callbackMode    int
+	callbackNode    CallbackNode
+	subscribeToken  string
+}
+
+// reconnector 连接监管者，断线后按退避策略重连，并恢复设备绑定和订阅
+type reconnector struct {
+	mu           sync.Mutex
+	api          *APINode
+	backoff      Backoff
+	enabled      bool
+	running      bool
+	stop         chan struct{}
+	state        ConnectionState
+	stateHandler func(api *APINode, event *ConnectionEvent)
+}
+
+func newReconnector(api *APINode) *reconnector {
+	return &reconnector{
+		api:     api,
+		backoff: DefaultBackoff,
+		stop:    make(chan struct{}),
+		state:   ConnectionStateConnected,
+	}
+}
+
+// EnableAutoReconnect 开启自动重连
+// 与openw-server的连接断开后，按退避策略重连，重新BindAppDevice，并重放最近一次Subscribe的参数
+func (api *APINode) EnableAutoReconnect(config *ReconnectConfig) error {
+	if api == nil {
+		return fmt.Errorf("APINode is not inited")
+	}
+	r := api.reconnector
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if config != nil && config.Backoff.InitialInterval > 0 {
+		r.backoff = config.Backoff
+	}
+	if !r.enabled {
+		r.enabled = true
+		r.stop = make(chan struct{})
+	}
+	return nil
+}
+
+// DisableAutoReconnect 关闭自动重连，正在进行的重连会被中止
+func (api *APINode) DisableAutoReconnect() {
+	if api == nil {
+		return
+	}
+	r := api.reconnector
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.enabled {
+		r.enabled = false
+		close(r.stop)
+	}
+}
+
+// SetConnectionStateHandler 设置连接状态变化的通知
+func (api *APINode) SetConnectionStateHandler(h func(api *APINode, event *ConnectionEvent)) {
+	r := api.reconnector
+	r.mu.Lock()
+	r.stateHandler = h
+	r.mu.Unlock()
+}
+
+// ConnectionState 当前连接状态
+func (api *APINode) ConnectionState() ConnectionState {
+	r := api.reconnector
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// onClose 节点断开连接的回调
+func (r *reconnector) onClose(n *owtp.OWTPNode, peer owtp.PeerInfo) {
+	if peer.ID != HostNodeID {
+		return
+	}
+
+	r.emit(&ConnectionEvent{State: ConnectionStateDisconnected})
+
+	r.mu.Lock()
+	if !r.enabled || r.running {
+		r.mu.Unlock()
+		return
+	}
+	r.running = true
+	stop, backoff := r.stop, r.backoff
+	r.mu.Unlock()
+
+	go r.run(stop, backoff)
+}
+
+// run 按退避策略重连，直到成功、次数用尽或关闭自动重连，期间修改的退避策略从下次断开起生效
+func (r *reconnector) run(stop chan struct{}, backoff Backoff) {
+	defer func() {
+		r.mu.Lock()
+		r.running = false
+		r.mu.Unlock()
+	}()
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-time.After(backoff.Duration(attempt)):
+		case <-stop:
+			return
+		}
+
+		r.emit(&ConnectionEvent{State: ConnectionStateReconnecting, Attempt: attempt})
+
+		err := r.api.reconnect()
+		if err == nil {
+			r.emit(&ConnectionEvent{State: ConnectionStateResubscribed, Attempt: attempt})
+			return
+		}
+		log.Warningf("reconnect to %s failed, attempt: %d, unexpected error: %v", HostNodeID, attempt, err)
+
+		if backoff.Exhausted(attempt) {
+			r.emit(&ConnectionEvent{State: ConnectionStateFailed, Attempt: attempt, Err: err})
+			return
+		}
+		r.emit(&ConnectionEvent{State: ConnectionStateDisconnected, Attempt: attempt, Err: err})
+	}
+}
+
+func (r *reconnector) emit(event *ConnectionEvent) {
+	event.Time = time.Now()
+	r.mu.Lock()
+	r.state = event.State
+	h := r.stateHandler
+	r.mu.Unlock()
+	if h != nil {
+		h(r.api, event)
+	}
+}
+
+// reconnect 重新连接openw-server，重新绑定各应用的设备，重放订阅
+func (api *APINode) reconnect() error {
+	_, err := api.node.Connect(HostNodeID, api.getConnectCfg(api.node, HostNodeID).Config)
+	if err != nil {
+		return err
+	}
+	api.reconnector.emit(&ConnectionEvent{State: ConnectionStateConnected})
+
+	if err := api.bindAppDevices(); err != nil {
+		return err
+	}
+
+	api.mu.RLock()
+	args := api.subscribeArgs
+	api.mu.RUnlock()
+	if args == nil {
+		return nil
+	}
+	return api.subscribe(args, true)
+}
@@ -0,0 +1,103 @@
+package openwsdk_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+type blockObserver struct {
+	blocks chan *openwsdk.BlockHeader
+	reject bool
+}
+
+func (o *blockObserver) OpenwNewTransactionNotify(transaction *openwsdk.Transaction, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func (o *blockObserver) OpenwNewBlockNotify(blockHeader *openwsdk.BlockHeader, subscribeToken string) (bool, error) {
+	o.blocks <- blockHeader
+	return !o.reject, nil
+}
+
+func (o *blockObserver) OpenwBalanceUpdateNotify(balance *openwsdk.Balance, tokenBalance *openwsdk.TokenBalance, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func (o *blockObserver) OpenwNewSmartContractReceiptNotify(receipt *openwsdk.SmartContractReceipt, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func (o *blockObserver) OpenwNFTTransferNotify(transfer *openwsdk.NFTTransfer, subscribeToken string) (bool, error) {
+	return true, nil
+}
+
+func TestAPINode_AutoReconnect(t *testing.T) {
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+	s.AddSymbol(&openwsdk.Symbol{Symbol: "BTC", MaxHeight: 10})
+
+	api, err := s.NewAPINode(owtp.Websocket)
+	if err != nil {
+		t.Fatalf("NewAPINode unexpected error: %v", err)
+	}
+	defer api.Close()
+
+	events := make(chan *openwsdk.ConnectionEvent, 16)
+	api.SetConnectionStateHandler(func(api *openwsdk.APINode, event *openwsdk.ConnectionEvent) {
+		events <- event
+	})
+	api.EnableAutoReconnect(&openwsdk.ReconnectConfig{
+		Backoff: openwsdk.Backoff{InitialInterval: 10 * time.Millisecond, Multiplier: 2, MaxAttempts: 5},
+	})
+
+	observer := &blockObserver{blocks: make(chan *openwsdk.BlockHeader, 1)}
+	api.AddObserver(observer)
+	err = api.Subscribe([]string{openwsdk.SubscribeToBlock}, "", openwsdk.CallbackModeCurrentConnection,
+		openwsdk.CallbackNode{ConnectType: owtp.Websocket}, "token")
+	if err != nil {
+		t.Fatalf("Subscribe unexpected error: %v", err)
+	}
+
+	s.DisconnectPeer(api.NodeID())
+
+	states := make([]openwsdk.ConnectionState, 0)
+	timeout := time.After(5 * time.Second)
+	for resubscribed := false; !resubscribed; {
+		select {
+		case event := <-events:
+			states = append(states, event.State)
+			resubscribed = event.State == openwsdk.ConnectionStateResubscribed
+		case <-timeout:
+			t.Fatalf("reconnect timeout, states: %v", states)
+		}
+	}
+	if states[0] != openwsdk.ConnectionStateDisconnected {
+		t.Errorf("unexpected state events: %v", states)
+	}
+	if api.ConnectionState() != openwsdk.ConnectionStateResubscribed {
+		t.Errorf("unexpected connection state: %v", api.ConnectionState())
+	}
+
+	accepted, err := s.PushBlock(s.MineBlock("BTC"))
+	if err != nil || !accepted {
+		t.Fatalf("PushBlock after reconnect unexpected result: %v, %v", accepted, err)
+	}
+	select {
+	case <-observer.blocks:
+	case <-time.After(time.Second):
+		t.Fatal("block notify not received after reconnect")
+	}
+
+	//重连后已重新绑定设备，业务请求可用
+	if _, err := openwsdk.NewAPIClient(api).GetFeeRateList(context.Background()); err != nil {
+		t.Errorf("GetFeeRateList after reconnect unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,337 @@
+package openwsdk
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// DefaultConfirmDepth 默认的交易确认数
+const DefaultConfirmDepth = 6
+
+// ReorgConfig 链重组处理配置
+type ReorgConfig struct {
+	ConfirmDepth  uint64            //交易达到该确认数后通过OnConfirmed再次推送，0表示DefaultConfirmDepth
+	SymbolConfirm map[string]uint64 //按币种设置确认数，覆盖ConfirmDepth
+	KeepBlocks    uint64            //每个币种在本地保留的区块数量，0表示256，不能小于确认数
+}
+
+// Rollback 链重组回滚事件，[FromHeight, ToHeight]的区块已不在主链上
+type Rollback struct {
+	Symbol      string
+	FromHeight  uint64         //被回滚的最低高度
+	ToHeight    uint64         //被回滚的最高高度
+	OrphanedTxs []*Transaction //被回滚区块中的交易单
+}
+
+// ReorgTracker 根据区块头和交易单通知维护每个币种的本地主链
+// 发现分叉时推送Rollback事件，交易单达到确认数时通过OnConfirmed推送
+type ReorgTracker struct {
+	mu                sync.Mutex
+	config            ReorgConfig
+	chains            map[string]*localChain
+	rollbackHandlers  []func(ctx context.Context, rollback *Rollback) error
+	confirmedHandlers []func(ctx context.Context, tx *Transaction, confirmations uint64) error
+}
+
+// localChain 币种的本地主链
+type localChain struct {
+	tip       uint64
+	blocks    map[uint64]*BlockHeader
+	headers   map[string]*BlockHeader //最近收到的区块头，包括分叉链上的，用于沿Previousblockhash回溯
+	txs       map[string]*Transaction //交易单唯一标识 -> 交易单
+	confirmed map[string]bool         //已推送OnConfirmed的交易单
+}
+
+// NewReorgTracker 创建链重组跟踪器
+func NewReorgTracker(config *ReorgConfig) *ReorgTracker {
+	t := &ReorgTracker{
+		chains: make(map[string]*localChain),
+	}
+	if config != nil {
+		t.config = *config
+	}
+	if t.config.ConfirmDepth == 0 {
+		t.config.ConfirmDepth = DefaultConfirmDepth
+	}
+	if t.config.KeepBlocks == 0 {
+		t.config.KeepBlocks = 256
+	}
+	for _, depth := range append([]uint64{t.config.ConfirmDepth}, symbolDepths(t.config.SymbolConfirm)...) {
+		if t.config.KeepBlocks < depth {
+			t.config.KeepBlocks = depth
+		}
+	}
+	return t
+}
+
+// OnRollback 注册回滚事件的处理函数，返回错误时本地主链不变，通知不被接收
+func (t *ReorgTracker) OnRollback(handler func(ctx context.Context, rollback *Rollback) error) {
+	t.mu.Lock()
+	t.rollbackHandlers = append(t.rollbackHandlers, handler)
+	t.mu.Unlock()
+}
+
+// OnConfirmed 注册交易单达到确认数的处理函数，返回错误时在下次收到区块时重新推送
+func (t *ReorgTracker) OnConfirmed(handler func(ctx context.Context, tx *Transaction, confirmations uint64) error) {
+	t.mu.Lock()
+	t.confirmedHandlers = append(t.confirmedHandlers, handler)
+	t.mu.Unlock()
+}
+
+// Attach 处理APINode的区块头和交易单通知
+func (t *ReorgTracker) Attach(api *APINode) *HandlerRegistration {
+	return api.Handle("", func(ctx context.Context, n *Notification) error {
+		switch n.Method {
+		case SubscribeToBlock:
+			return t.HandleBlock(ctx, n.BlockHeader)
+		case SubscribeToTrade:
+			return t.HandleTransaction(ctx, n.Transaction)
+		}
+		return nil
+	})
+}
+
+// Tip 币种本地主链的最新高度
+func (t *ReorgTracker) Tip(symbol string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c := t.chains[symbol]; c != nil {
+		return c.tip
+	}
+	return 0
+}
+
+// HandleBlock 处理新区块头，区块哈希与本地主链冲突或Fork为true时，沿Previousblockhash找到共同祖先并先推送Rollback
+// 处理函数在持有锁时调用，不能再调用ReorgTracker的方法
+func (t *ReorgTracker) HandleBlock(ctx context.Context, header *BlockHeader) error {
+	if header == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.chain(header.Symbol)
+	if exist := c.blocks[header.Height]; exist == nil || exist.Hash != header.Hash || (header.Fork && header.Height < c.tip) {
+		from, branch, ok := c.forkPoint(header)
+		if ok {
+			if err := t.rollback(ctx, header.Symbol, c, from); err != nil {
+				return err
+			}
+		}
+		//回溯经过的分叉链区块成为主链
+		for _, b := range branch {
+			c.blocks[b.Height] = b
+		}
+		c.blocks[header.Height] = header
+		c.headers[header.Hash] = header
+		if header.Height > c.tip || header.Fork {
+			c.tip = header.Height
+		}
+		c.prune(t.config.KeepBlocks)
+	}
+
+	return t.notifyConfirmed(ctx, header.Symbol, c)
+}
+
+// HandleTransaction 记录交易单，交易单被重新打包到其他区块时重新计算确认数。
+// IsMain为2表示交易单所在区块已被重扫或分叉，本地主链上的该区块及之后的区块被回滚。
+func (t *ReorgTracker) HandleTransaction(ctx context.Context, tx *Transaction) error {
+	if tx == nil {
+		return nil
+	}
+	key := tx.WxID
+	if len(key) == 0 {
+		key = tx.TxID
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.chain(tx.Symbol)
+	if tx.IsMain == 2 {
+		if block := c.blocks[uint64(tx.BlockHeight)]; tx.BlockHeight > 0 && block != nil && block.Hash == tx.BlockHash {
+			if err := t.rollback(ctx, tx.Symbol, c, block.Height); err != nil {
+				return err
+			}
+		}
+		delete(c.txs, key)
+		delete(c.confirmed, key)
+		return nil
+	}
+	if exist := c.txs[key]; exist != nil && exist.BlockHash != tx.BlockHash {
+		delete(c.confirmed, key)
+	}
+	c.txs[key] = tx
+
+	return t.notifyConfirmed(ctx, tx.Symbol, c)
+}
+
+// rollback 推送Rollback后丢弃高度不低于from的区块，处理函数返回错误时本地主链不变
+func (t *ReorgTracker) rollback(ctx context.Context, symbol string, c *localChain, from uint64) error {
+	rollback := &Rollback{
+		Symbol:      symbol,
+		FromHeight:  from,
+		ToHeight:    c.tip,
+		OrphanedTxs: c.txsFrom(from),
+	}
+	for _, h := range t.rollbackHandlers {
+		if err := h(ctx, rollback); err != nil {
+			return err
+		}
+	}
+	c.rollback(from)
+	return nil
+}
+
+func (t *ReorgTracker) chain(symbol string) *localChain {
+	c := t.chains[symbol]
+	if c == nil {
+		c = &localChain{
+			blocks:    make(map[uint64]*BlockHeader),
+			headers:   make(map[string]*BlockHeader),
+			txs:       make(map[string]*Transaction),
+			confirmed: make(map[string]bool),
+		}
+		t.chains[symbol] = c
+	}
+	return c
+}
+
+// notifyConfirmed 推送在主链上达到确认数的交易单
+func (t *ReorgTracker) notifyConfirmed(ctx context.Context, symbol string, c *localChain) error {
+	depth := t.config.ConfirmDepth
+	if d, ok := t.config.SymbolConfirm[symbol]; ok && d > 0 {
+		depth = d
+	}
+
+	keys := make([]string, 0)
+	for key, tx := range c.txs {
+		if c.confirmed[key] || tx.BlockHeight <= 0 || uint64(tx.BlockHeight) > c.tip {
+			continue
+		}
+		if block := c.blocks[uint64(tx.BlockHeight)]; block == nil || block.Hash != tx.BlockHash {
+			continue
+		}
+		if c.tip-uint64(tx.BlockHeight)+1 >= depth {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if c.txs[keys[i]].BlockHeight != c.txs[keys[j]].BlockHeight {
+			return c.txs[keys[i]].BlockHeight < c.txs[keys[j]].BlockHeight
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, key := range keys {
+		tx := c.txs[key]
+		confirmations := c.tip - uint64(tx.BlockHeight) + 1
+		for _, h := range t.confirmedHandlers {
+			if err := h(ctx, tx, confirmations); err != nil {
+				return err
+			}
+		}
+		c.confirmed[key] = true
+	}
+	return nil
+}
+
+func symbolDepths(depths map[string]uint64) []uint64 {
+	values := make([]uint64, 0, len(depths))
+	for _, depth := range depths {
+		values = append(values, depth)
+	}
+	return values
+}
+
+// forkPoint 新区块与本地主链冲突时，沿Previousblockhash回溯到与本地主链的共同祖先，
+// 返回需要回滚的最低高度，以及回溯经过的已收到的分叉链区块。
+// 分叉链上未收到的父区块无法回溯，只回滚能确认不在新链上的高度。
+func (c *localChain) forkPoint(header *BlockHeader) (from uint64, branch []*BlockHeader, ok bool) {
+	if c.tip == 0 || header.Height == 0 {
+		return 0, nil, false
+	}
+	if header.Height <= c.tip {
+		if exist := c.blocks[header.Height]; exist == nil || exist.Hash != header.Hash {
+			from = header.Height
+		} else if header.Fork {
+			//分叉区块之上的本地区块已不在主链上
+			from = header.Height + 1
+		}
+	}
+	for cur := header; cur.Height > 1 && len(cur.Previousblockhash) > 0; {
+		prev := c.blocks[cur.Height-1]
+		if prev != nil && prev.Hash == cur.Previousblockhash {
+			break
+		}
+		parent := c.headers[cur.Previousblockhash]
+		if parent != nil && parent.Height != cur.Height-1 {
+			parent = nil
+		}
+		if prev == nil && parent == nil {
+			break
+		}
+		from = cur.Height - 1
+		if parent == nil {
+			break
+		}
+		branch = append(branch, parent)
+		cur = parent
+	}
+	return from, branch, from > 0 && from <= c.tip
+}
+
+// txsFrom 高度不低于from的交易单
+func (c *localChain) txsFrom(from uint64) []*Transaction {
+	txs := make([]*Transaction, 0)
+	for _, tx := range c.txs {
+		if tx.BlockHeight > 0 && uint64(tx.BlockHeight) >= from {
+			txs = append(txs, tx)
+		}
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].BlockHeight != txs[j].BlockHeight {
+			return txs[i].BlockHeight < txs[j].BlockHeight
+		}
+		return txs[i].TxID < txs[j].TxID
+	})
+	return txs
+}
+
+// rollback 丢弃高度不低于from的区块和交易单
+func (c *localChain) rollback(from uint64) {
+	for h := from; h <= c.tip; h++ {
+		delete(c.blocks, h)
+	}
+	for key, tx := range c.txs {
+		if tx.BlockHeight > 0 && uint64(tx.BlockHeight) >= from {
+			delete(c.txs, key)
+			delete(c.confirmed, key)
+		}
+	}
+	c.tip = from - 1
+}
+
+// prune 只保留最近keep个区块，以及其中未确认的交易单
+func (c *localChain) prune(keep uint64) {
+	if c.tip <= keep {
+		return
+	}
+	lowest := c.tip - keep
+	for h := range c.blocks {
+		if h <= lowest {
+			delete(c.blocks, h)
+		}
+	}
+	for hash, b := range c.headers {
+		if b.Height <= lowest {
+			delete(c.headers, hash)
+		}
+	}
+	for key, tx := range c.txs {
+		if tx.BlockHeight > 0 && uint64(tx.BlockHeight) <= lowest {
+			delete(c.txs, key)
+			delete(c.confirmed, key)
+		}
+	}
+}
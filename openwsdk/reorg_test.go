@@ -0,0 +1,157 @@
+package openwsdk_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+)
+
+func TestReorgTracker(t *testing.T) {
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+	s.AddSymbol(&openwsdk.Symbol{Symbol: "BTC", MaxHeight: 10})
+
+	api := testSubscribeAPINode(t, s, openwsdk.SubscribeToBlock, openwsdk.SubscribeToTrade)
+	defer api.Close()
+
+	var (
+		rollbacks   []*openwsdk.Rollback
+		confirmed   []string
+		rejectReorg = true
+	)
+	tracker := openwsdk.NewReorgTracker(&openwsdk.ReorgConfig{ConfirmDepth: 3})
+	tracker.OnRollback(func(ctx context.Context, rollback *openwsdk.Rollback) error {
+		if rejectReorg {
+			return fmt.Errorf("credits not reversed")
+		}
+		rollbacks = append(rollbacks, rollback)
+		return nil
+	})
+	tracker.OnConfirmed(func(ctx context.Context, tx *openwsdk.Transaction, confirmations uint64) error {
+		confirmed = append(confirmed, fmt.Sprintf("%s@%s:%d", tx.TxID, tx.BlockHash, confirmations))
+		return nil
+	})
+	tracker.Attach(api)
+
+	push := func(header *openwsdk.BlockHeader) bool {
+		accepted, err := s.PushBlock(header)
+		if err != nil {
+			t.Fatalf("PushBlock unexpected error: %v", err)
+		}
+		return accepted
+	}
+
+	push(s.MineBlock("BTC"))
+	s.AddTransaction(&openwsdk.Transaction{WxID: "wx-1", TxID: "tx-1", Symbol: "BTC"})
+	push(s.MineBlock("BTC"))
+	if accepted, err := s.PushTrade(s.Transactions()[0]); err != nil || !accepted {
+		t.Fatalf("PushTrade unexpected result: %v, %v", accepted, err)
+	}
+	push(s.MineBlock("BTC"))
+	if len(confirmed) != 0 {
+		t.Fatalf("transaction confirmed too early: %v", confirmed)
+	}
+	push(s.MineBlock("BTC"))
+	if len(confirmed) != 1 || confirmed[0] != "tx-1@BTC-12-0:3" {
+		t.Fatalf("unexpected confirmed transactions: %v", confirmed)
+	}
+
+	//分叉，交易单被重新打包
+	s.ForkChain("BTC", 12)
+	fork := s.MineBlock("BTC")
+	if push(fork) {
+		t.Fatalf("PushBlock expected not accepted when rollback handler fails")
+	}
+	if tracker.Tip("BTC") != 14 {
+		t.Fatalf("local chain should not change when rollback handler fails, tip: %d", tracker.Tip("BTC"))
+	}
+	rejectReorg = false
+	if !push(fork) {
+		t.Fatalf("PushBlock expected accepted")
+	}
+	if len(rollbacks) != 1 {
+		t.Fatalf("expected 1 rollback, got %d", len(rollbacks))
+	}
+	rollback := rollbacks[0]
+	if rollback.Symbol != "BTC" || rollback.FromHeight != 12 || rollback.ToHeight != 14 ||
+		len(rollback.OrphanedTxs) != 1 || rollback.OrphanedTxs[0].TxID != "tx-1" {
+		t.Errorf("unexpected rollback: %+v", rollback)
+	}
+
+	s.PushTrade(s.Transactions()[0])
+	push(s.MineBlock("BTC"))
+	push(s.MineBlock("BTC"))
+	if len(confirmed) != 2 || confirmed[1] != "tx-1@BTC-12-1:3" {
+		t.Errorf("unexpected confirmed transactions after reorg: %v", confirmed)
+	}
+	if tracker.Tip("BTC") != 14 {
+		t.Errorf("unexpected tip: %d", tracker.Tip("BTC"))
+	}
+}
+
+func TestReorgTracker_DeepReorg(t *testing.T) {
+	ctx := context.Background()
+	var rollbacks []*openwsdk.Rollback
+	tracker := openwsdk.NewReorgTracker(&openwsdk.ReorgConfig{ConfirmDepth: 3})
+	tracker.OnRollback(func(ctx context.Context, rollback *openwsdk.Rollback) error {
+		rollbacks = append(rollbacks, rollback)
+		return nil
+	})
+	block := func(branch string, height uint64, prev string) *openwsdk.BlockHeader {
+		return &openwsdk.BlockHeader{Symbol: "BTC", Height: height, Hash: fmt.Sprintf("%s%d", branch, height), Previousblockhash: prev}
+	}
+	handle := func(header *openwsdk.BlockHeader) {
+		if err := tracker.HandleBlock(ctx, header); err != nil {
+			t.Fatalf("HandleBlock unexpected error: %v", err)
+		}
+	}
+	for h := uint64(1); h <= 10; h++ {
+		handle(block("a", h, fmt.Sprintf("a%d", h-1)))
+	}
+	tracker.HandleTransaction(ctx, &openwsdk.Transaction{TxID: "tx-8", Symbol: "BTC", BlockHeight: 8, BlockHash: "a8", IsMain: 1})
+
+	//新链只推送了b9和b11，沿父区块回溯到a7
+	handle(block("b", 9, "b8"))
+	if len(rollbacks) != 1 || rollbacks[0].FromHeight != 8 || rollbacks[0].ToHeight != 10 ||
+		len(rollbacks[0].OrphanedTxs) != 1 || rollbacks[0].OrphanedTxs[0].TxID != "tx-8" {
+		t.Fatalf("unexpected rollback: %+v", rollbacks)
+	}
+	handle(block("b", 10, "b9"))
+	handle(block("b", 11, "b10"))
+
+	//切回原链：a11的父区块a10、a9、a8都已收到，回溯到共同祖先a7
+	handle(block("a", 11, "a10"))
+	if len(rollbacks) != 2 || rollbacks[1].FromHeight != 8 || rollbacks[1].ToHeight != 11 {
+		t.Fatalf("unexpected rollback: %+v", rollbacks[1:])
+	}
+	if tip := tracker.Tip("BTC"); tip != 11 {
+		t.Errorf("unexpected tip: %d", tip)
+	}
+	var confirmed []string
+	tracker.OnConfirmed(func(ctx context.Context, tx *openwsdk.Transaction, confirmations uint64) error {
+		confirmed = append(confirmed, fmt.Sprintf("%s:%d", tx.TxID, confirmations))
+		return nil
+	})
+	tracker.HandleTransaction(ctx, &openwsdk.Transaction{TxID: "tx-8", Symbol: "BTC", BlockHeight: 8, BlockHash: "a8", IsMain: 1})
+	if len(confirmed) != 1 || confirmed[0] != "tx-8:4" {
+		t.Errorf("expected transaction confirmed on restored chain, got: %v", confirmed)
+	}
+
+	//Fork标记的区块之上的本地区块被回滚
+	handle(&openwsdk.BlockHeader{Symbol: "BTC", Height: 9, Hash: "a9", Previousblockhash: "a8", Fork: true})
+	if len(rollbacks) != 3 || rollbacks[2].FromHeight != 10 || tracker.Tip("BTC") != 9 {
+		t.Fatalf("unexpected fork rollback: %+v, tip: %d", rollbacks[2:], tracker.Tip("BTC"))
+	}
+
+	//交易单IsMain为2，所在区块及之后的区块被回滚
+	tracker.HandleTransaction(ctx, &openwsdk.Transaction{TxID: "tx-8", Symbol: "BTC", BlockHeight: 8, BlockHash: "a8", IsMain: 2})
+	if len(rollbacks) != 4 || rollbacks[3].FromHeight != 8 || rollbacks[3].ToHeight != 9 || tracker.Tip("BTC") != 7 {
+		t.Errorf("unexpected rollback for isMain 2: %+v, tip: %d", rollbacks[3:], tracker.Tip("BTC"))
+	}
+}
@@ -0,0 +1,304 @@
+package openwsdk
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+const (
+	// DefaultRequestWindow 请求时间戳的有效范围，超出视为过期或时钟偏差
+	DefaultRequestWindow = 5 * time.Minute
+	// DefaultMaxClockSkew 与服务端时间允许的最大偏差
+	DefaultMaxClockSkew = 30 * time.Second
+)
+
+var (
+	// ErrNonceReused nonce在有效期内重复使用，可能是重放请求
+	ErrNonceReused = errors.New("nonce has been used")
+	// ErrInvalidSign 签名不正确
+	ErrInvalidSign = errors.New("invalid app sign")
+)
+
+// Clock 时钟，测试时可替换
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc 函数形式的时钟
+type ClockFunc func() time.Time
+
+// Now 当前时间
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock 系统时钟
+var SystemClock Clock = ClockFunc(time.Now)
+
+// ClockSkewError 请求时间与本地时间偏差过大
+type ClockSkewError struct {
+	Skew    time.Duration //对方时间 - 本地时间
+	Allowed time.Duration
+}
+
+// Error 实现error接口
+func (e *ClockSkewError) Error() string {
+	return fmt.Sprintf("clock skew %s exceeds %s", e.Skew, e.Allowed)
+}
+
+// SignedRequest HmacSHA256签名的请求，明文为Fields+Nonce+Time依次拼接
+type SignedRequest struct {
+	AppID  string
+	Fields []string //AppID之后参与签名的字段，如deviceID
+	Nonce  string
+	Time   int64 //UnixNano
+	Sign   string
+}
+
+// PlainText 签名明文
+func (r *SignedRequest) PlainText() string {
+	var b strings.Builder
+	b.WriteString(r.AppID)
+	for _, f := range r.Fields {
+		b.WriteString(f)
+	}
+	b.WriteString(r.Nonce)
+	b.WriteString(strconv.FormatInt(r.Time, 10))
+	return b.String()
+}
+
+// NonceCache 记录有效期内使用过的nonce
+type NonceCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	seen      map[string]time.Time //nonce -> 过期时间
+	lastPrune int
+}
+
+// NewNonceCache 创建nonce记录，ttl应不小于请求有效期
+func NewNonceCache(ttl time.Duration) *NonceCache {
+	return &NonceCache{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+// Use 记录nonce，有效期内重复时返回false
+func (c *NonceCache) Use(nonce string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if expiry, ok := c.seen[nonce]; ok && now.Before(expiry) {
+		return false
+	}
+	c.seen[nonce] = now.Add(c.ttl)
+	//记录数翻倍时清理过期的nonce
+	if len(c.seen) > 2*c.lastPrune+64 {
+		for n, expiry := range c.seen {
+			if !now.Before(expiry) {
+				delete(c.seen, n)
+			}
+		}
+		c.lastPrune = len(c.seen)
+	}
+	return true
+}
+
+// Len 记录的nonce数量
+func (c *NonceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.seen)
+}
+
+// nonceReader 生成nonce的随机数来源
+var nonceReader io.Reader = rand.Reader
+
+// SecureNonce 使用crypto/rand生成18位十进制nonce，兼容原来的数字格式
+func SecureNonce() (string, error) {
+	min := big.NewInt(100000000000000000)
+	n, err := rand.Int(nonceReader, big.NewInt(900000000000000000))
+	if err != nil {
+		return "", fmt.Errorf("generate nonce failed: %w", err)
+	}
+	return n.Add(n, min).String(), nil
+}
+
+// RequestSigner 生成HmacSHA256签名请求，保证nonce不重复，并按服务端时间校正时间戳
+type RequestSigner struct {
+	mu      sync.RWMutex
+	appID   string
+	appKey  string
+	clock   Clock
+	offset  time.Duration //服务端时间 - 本地时间
+	maxSkew time.Duration
+	nonces  *NonceCache
+}
+
+// NewRequestSigner 创建请求签名者
+func NewRequestSigner(appID, appKey string) *RequestSigner {
+	return &RequestSigner{
+		appID:   appID,
+		appKey:  appKey,
+		clock:   SystemClock,
+		maxSkew: DefaultMaxClockSkew,
+		nonces:  NewNonceCache(DefaultRequestWindow),
+	}
+}
+
+// withAppKey 使用新密钥的签名者，沿用时钟、时间校正和nonce记录
+func (s *RequestSigner) withAppKey(appKey string) *RequestSigner {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &RequestSigner{
+		appID:   s.appID,
+		appKey:  appKey,
+		clock:   s.clock,
+		offset:  s.offset,
+		maxSkew: s.maxSkew,
+		nonces:  s.nonces,
+	}
+}
+
+// SetClock 设置时钟
+func (s *RequestSigner) SetClock(clock Clock) {
+	s.mu.Lock()
+	s.clock = clock
+	s.mu.Unlock()
+}
+
+// SetMaxClockSkew 设置与服务端时间允许的最大偏差
+func (s *RequestSigner) SetMaxClockSkew(d time.Duration) {
+	s.mu.Lock()
+	s.maxSkew = d
+	s.mu.Unlock()
+}
+
+// Now 校正后的当前时间
+func (s *RequestSigner) Now() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.clock.Now().Add(s.offset)
+}
+
+// ClockOffset 服务端时间 - 本地时间
+func (s *RequestSigner) ClockOffset() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.offset
+}
+
+// ObserveServerTime 记录服务端时间，之后的时间戳按偏差校正；偏差超过上限时返回*ClockSkewError
+func (s *RequestSigner) ObserveServerTime(serverTime time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = serverTime.Sub(s.clock.Now())
+	if s.maxSkew > 0 && (s.offset > s.maxSkew || s.offset < -s.maxSkew) {
+		return &ClockSkewError{Skew: s.offset, Allowed: s.maxSkew}
+	}
+	return nil
+}
+
+// Sign 生成签名请求，fields为AppID之后参与签名的字段
+func (s *RequestSigner) Sign(fields ...string) (*SignedRequest, error) {
+	now := s.Now()
+	var nonce string
+	for {
+		n, err := SecureNonce()
+		if err != nil {
+			return nil, err
+		}
+		if s.nonces.Use(n, now) {
+			nonce = n
+			break
+		}
+	}
+	req := &SignedRequest{
+		AppID:  s.appID,
+		Fields: fields,
+		Nonce:  nonce,
+		Time:   now.UnixNano(),
+	}
+	req.Sign = HmacSHA256([]byte(req.PlainText()), []byte(s.appKey))
+	return req, nil
+}
+
+// RequestVerifier 校验HmacSHA256签名请求的签名、时间戳和nonce，服务端处理owtp请求时使用
+type RequestVerifier struct {
+	mu     sync.RWMutex
+	appKey func(appID string) (string, bool)
+	clock  Clock
+	window time.Duration
+	nonces *NonceCache
+}
+
+// NewRequestVerifier 创建请求校验者，appKey按appID查找密钥
+func NewRequestVerifier(appKey func(appID string) (string, bool)) *RequestVerifier {
+	return &RequestVerifier{
+		appKey: appKey,
+		clock:  SystemClock,
+		window: DefaultRequestWindow,
+		nonces: NewNonceCache(2 * DefaultRequestWindow),
+	}
+}
+
+// SetClock 设置时钟
+func (v *RequestVerifier) SetClock(clock Clock) {
+	v.mu.Lock()
+	v.clock = clock
+	v.mu.Unlock()
+}
+
+// SetWindow 设置请求时间戳的有效范围
+func (v *RequestVerifier) SetWindow(window time.Duration) {
+	v.mu.Lock()
+	v.window = window
+	v.nonces = NewNonceCache(2 * window)
+	v.mu.Unlock()
+}
+
+// Verify 校验签名请求
+func (v *RequestVerifier) Verify(req *SignedRequest) error {
+	v.mu.RLock()
+	clock, window, nonces := v.clock, v.window, v.nonces
+	v.mu.RUnlock()
+
+	appKey, ok := v.appKey(req.AppID)
+	if !ok {
+		return ErrInvalidSign
+	}
+	expected := HmacSHA256([]byte(req.PlainText()), []byte(appKey))
+	if !hmac.Equal([]byte(expected), []byte(req.Sign)) {
+		return ErrInvalidSign
+	}
+	now := clock.Now()
+	skew := time.Unix(0, req.Time).Sub(now)
+	if skew > window || skew < -window {
+		return &ClockSkewError{Skew: skew, Allowed: window}
+	}
+	if !nonces.Use(req.Nonce, now) {
+		return ErrNonceReused
+	}
+	return nil
+}
+
+// VerifyContext 从owtp请求参数中读取appID、nonce、sign和timeField，fields为参与签名的其他参数名
+func (v *RequestVerifier) VerifyContext(ctx *owtp.Context, timeField string, fields ...string) error {
+	params := ctx.Params()
+	req := &SignedRequest{
+		AppID: params.Get("appID").String(),
+		Nonce: params.Get("nonce").String(),
+		Time:  params.Get(timeField).Int(),
+		Sign:  params.Get("sign").String(),
+	}
+	for _, f := range fields {
+		req.Fields = append(req.Fields, params.Get(f).String())
+	}
+	return v.Verify(req)
+}
@@ -0,0 +1,29 @@
+package openwsdk
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+type failReader struct{}
+
+func (failReader) Read(p []byte) (int, error) {
+	return 0, errors.New("entropy unavailable")
+}
+
+func TestRequestSigner_NonceError(t *testing.T) {
+	nonceReader = failReader{}
+	defer func() { nonceReader = rand.Reader }()
+
+	if _, err := SecureNonce(); err == nil {
+		t.Errorf("SecureNonce expected error")
+	}
+	//RandNonce保持原来的签名，退回到伪随机数
+	if nonce := RandNonce(); len(nonce) != 18 {
+		t.Errorf("RandNonce expected 18 digit fallback, got: %q", nonce)
+	}
+	if req, err := NewRequestSigner("app1", "key").Sign("device"); err == nil || req != nil {
+		t.Errorf("Sign expected nonce error, got: %v, %v", req, err)
+	}
+}
@@ -0,0 +1,90 @@
+package openwsdk_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func TestRequestVerifier(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := openwsdk.ClockFunc(func() time.Time { return now })
+
+	signer := openwsdk.NewRequestSigner("app", "key")
+	signer.SetClock(clock)
+	verifier := openwsdk.NewRequestVerifier(func(appID string) (string, bool) {
+		return "key", appID == "app"
+	})
+	verifier.SetClock(clock)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		req, err := signer.Sign("device")
+		if err != nil {
+			t.Fatalf("Sign unexpected error: %v", err)
+		}
+		if seen[req.Nonce] || len(req.Nonce) != 18 {
+			t.Fatalf("unexpected nonce: %s", req.Nonce)
+		}
+		seen[req.Nonce] = true
+	}
+
+	req, _ := signer.Sign("device")
+	if err := verifier.Verify(req); err != nil {
+		t.Fatalf("Verify unexpected error: %v", err)
+	}
+	if err := verifier.Verify(req); err != openwsdk.ErrNonceReused {
+		t.Errorf("Verify expected ErrNonceReused, got: %v", err)
+	}
+
+	tampered, _ := signer.Sign("device")
+	tampered.Fields = []string{"other"}
+	if err := verifier.Verify(tampered); err != openwsdk.ErrInvalidSign {
+		t.Errorf("Verify expected ErrInvalidSign, got: %v", err)
+	}
+
+	late, _ := signer.Sign("device")
+	now = now.Add(10 * time.Minute)
+	if _, ok := verifier.Verify(late).(*openwsdk.ClockSkewError); !ok {
+		t.Errorf("Verify expected ClockSkewError")
+	}
+
+	if err := signer.ObserveServerTime(now.Add(time.Minute)); err == nil {
+		t.Errorf("ObserveServerTime expected ClockSkewError")
+	}
+	if offset := signer.ClockOffset(); offset != time.Minute {
+		t.Errorf("unexpected clock offset: %v", offset)
+	}
+}
+
+func TestAPINode_ClockSkew(t *testing.T) {
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	//服务端时间超出请求有效期，绑定失败
+	s.SetClock(openwsdk.ClockFunc(func() time.Time { return time.Now().Add(time.Hour) }))
+	if _, err := s.NewAPINode(owtp.Websocket); err == nil {
+		t.Fatalf("NewAPINode expected error with clock skew")
+	}
+
+	//偏差在有效期内时，getNodeInfo返回的服务端时间用于校正
+	s.SetClock(openwsdk.ClockFunc(func() time.Time { return time.Now().Add(2 * time.Minute) }))
+	api, err := s.NewAPINode(owtp.Websocket)
+	if err != nil {
+		t.Fatalf("NewAPINode unexpected error: %v", err)
+	}
+	defer api.Close()
+	if _, _, err := api.GetNotifierNodeInfo(); err != nil {
+		t.Fatalf("GetNotifierNodeInfo unexpected error: %v", err)
+	}
+	offset := api.RequestSigner().ClockOffset()
+	if offset < time.Minute || offset > 3*time.Minute {
+		t.Errorf("unexpected clock offset: %v", offset)
+	}
+}
@@ -0,0 +1,376 @@
+package openwsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// RetryPolicy 交易创建和提交的重试策略
+type RetryPolicy struct {
+	Backoff        Backoff              //重试等待时间，MaxAttempts为最多尝试次数，0表示直到ctx结束
+	AttemptTimeout time.Duration        //每次请求的超时时间，0表示只受ctx限制
+	Retryable      func(err error) bool //判断错误是否可以重试，为空时使用IsRetryable
+}
+
+// DefaultRetryPolicy 默认重试策略：最多尝试3次，500ms起每次翻倍
+var DefaultRetryPolicy = RetryPolicy{
+	Backoff: Backoff{
+		InitialInterval: 500 * time.Millisecond,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+		MaxAttempts:     3,
+	},
+}
+
+// IsRetryable 是否可以用同一sid重试。
+// 只有确定安全的错误可以重试：请求确定未发出（连接失败、websocket发送失败、HTTP建立连接前失败）、客户端限流，
+// 以及超时、网络断开、服务端内部错误和服务端限流的状态码；HTTP请求发出后失败返回*UnknownOutcomeError，不重试。
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var rateErr *RateLimitError
+	if isUndelivered(err) || errors.As(err, &rateErr) {
+		return true
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.Status {
+	case owtp.ErrRequestTimeout, owtp.ErrNetworkDisconnected, owtp.ErrInternalServerError, owtp.ErrDenialOfService, StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// RetryError 重试后仍然失败
+type RetryError struct {
+	Method     string
+	Attempts   int
+	Unresolved []string //无法确认是否已广播的sid，确认之前不要换新的sid重新发起交易
+	Err        error    //最后一次失败的原因
+}
+
+// Error 实现error接口
+func (e *RetryError) Error() string {
+	msg := fmt.Sprintf("%s failed after %d attempts: %v", e.Method, e.Attempts, e.Err)
+	if len(e.Unresolved) > 0 {
+		msg += fmt.Sprintf(", unresolved sid: %s", strings.Join(e.Unresolved, ","))
+	}
+	return msg
+}
+
+// Unwrap 返回最后一次失败的原因
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// RetryClient 带重试的交易创建和提交，以业务sid作为幂等键。
+// 创建交易单用同一sid重试；提交交易单超时后，先按sid查询交易记录，
+// 只重新提交确认未广播的交易单，查询失败时不会重新提交。
+type RetryClient struct {
+	client *APIClient
+	policy RetryPolicy
+}
+
+// NewRetryClient 创建带重试的客户端
+func NewRetryClient(client *APIClient, policy RetryPolicy) *RetryClient {
+	return &RetryClient{
+		client: client,
+		policy: policy,
+	}
+}
+
+// APIClient 底层的API客户端
+func (r *RetryClient) APIClient() *APIClient {
+	if r == nil {
+		return nil
+	}
+	return r.client
+}
+
+func (r *RetryClient) retryable(err error) bool {
+	if r.policy.Retryable != nil {
+		return r.policy.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// attempt 执行一次请求，按AttemptTimeout限制时间
+func (r *RetryClient) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if r.policy.AttemptTimeout <= 0 {
+		return fn(ctx)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, r.policy.AttemptTimeout)
+	defer cancel()
+	return fn(attemptCtx)
+}
+
+// wait 第attempt次失败后等待，返回false表示次数用尽或ctx结束
+func (r *RetryClient) wait(ctx context.Context, attempt int) bool {
+	if r.policy.Backoff.Exhausted(attempt) || ctx.Err() != nil {
+		return false
+	}
+	timer := time.NewTimer(r.policy.Backoff.Duration(attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// do 不可重试的错误直接返回，次数用尽时返回*RetryError
+func (r *RetryClient) do(ctx context.Context, method string, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := r.attempt(ctx, fn)
+		if err == nil || !r.retryable(err) {
+			return err
+		}
+		log.Warningf("%s attempt %d failed: %v", method, attempt, err)
+		if !r.wait(ctx, attempt) {
+			return &RetryError{Method: method, Attempts: attempt, Err: err}
+		}
+	}
+}
+
+// CreateTrade 创建转账交易订单，失败时用同一sid重试
+func (r *RetryClient) CreateTrade(ctx context.Context, accountID, sid string, coin Coin, to map[string]string, feeRate, memo, extParam string) (*RawTransaction, error) {
+	if len(sid) == 0 {
+		return nil, fmt.Errorf("sid is required for retry")
+	}
+	var result *RawTransaction
+	err := r.do(ctx, "createTrade", func(ctx context.Context) (err error) {
+		result, err = r.client.CreateTrade(ctx, accountID, sid, coin, to, feeRate, memo, extParam)
+		return err
+	})
+	return result, err
+}
+
+// CreateBatchTrade 创建多笔转账交易订单，失败时用同一sid重试
+func (r *RetryClient) CreateBatchTrade(ctx context.Context, accountID, sid string, coin Coin, to map[string]string, feeRate, memo, extParam string) (*RawTransaction, error) {
+	if len(sid) == 0 {
+		return nil, fmt.Errorf("sid is required for retry")
+	}
+	var result *RawTransaction
+	err := r.do(ctx, "createBatchTrade", func(ctx context.Context) (err error) {
+		result, err = r.client.CreateBatchTrade(ctx, accountID, sid, coin, to, feeRate, memo, extParam)
+		return err
+	})
+	return result, err
+}
+
+// CreateSmartContractTrade 创建智能合约交易单，失败时用同一sid重试
+func (r *RetryClient) CreateSmartContractTrade(
+	ctx context.Context,
+	sid string,
+	accountID string,
+	coin Coin,
+	abiParam []string,
+	raw string,
+	rawType uint64,
+	feeRate string,
+	value string,
+) (*SmartContractRawTransaction, error) {
+	if len(sid) == 0 {
+		return nil, fmt.Errorf("sid is required for retry")
+	}
+	var result *SmartContractRawTransaction
+	err := r.do(ctx, "createSmartContractTrade", func(ctx context.Context) (err error) {
+		result, err = r.client.CreateSmartContractTrade(ctx, sid, accountID, coin, abiParam, raw, rawType, feeRate, value)
+		return err
+	})
+	return result, err
+}
+
+// SubmitTrade 广播转账交易订单。
+// 请求失败且可重试时，先用FindTradeLogByParams按sid查询，已有交易记录的视为广播成功，
+// 只重新提交确认未广播的交易单。
+func (r *RetryClient) SubmitTrade(ctx context.Context, rawTx []*RawTransaction) ([]*Transaction, []*FailedRawTransaction, error) {
+	var (
+		success []*Transaction
+		failed  []*FailedRawTransaction
+		bySid   = make(map[string]*RawTransaction, len(rawTx))
+		sids    = make([]string, 0, len(rawTx))
+	)
+	for _, tx := range rawTx {
+		sids = append(sids, tx.Sid)
+		bySid[tx.Sid] = tx
+	}
+	if err := checkRetrySids(sids); err != nil {
+		return nil, nil, err
+	}
+
+	find := func(ctx context.Context, sid string) (bool, error) {
+		params := map[string]interface{}{"sid": sid, "accountID": bySid[sid].AccountID}
+		txs, err := r.client.FindTradeLogByParams(ctx, params)
+		if err != nil || len(txs) == 0 {
+			return false, err
+		}
+		success = append(success, txs...)
+		return true, nil
+	}
+	submit := func(ctx context.Context, sids []string, resubmit bool) error {
+		pending := make([]*RawTransaction, 0, len(sids))
+		for _, sid := range sids {
+			pending = append(pending, bySid[sid])
+		}
+		txs, failures, err := r.client.SubmitTrade(ctx, pending)
+		if err != nil {
+			return err
+		}
+		success = append(success, txs...)
+		for _, f := range failures {
+			//重新提交时sid重复，说明之前的提交已广播
+			if resubmit && f.RawTx != nil && isDuplicateSidReason(f.Reason) {
+				if ok, err := find(ctx, f.RawTx.Sid); err == nil && ok {
+					continue
+				}
+			}
+			failed = append(failed, f)
+		}
+		return nil
+	}
+
+	err := r.submitWithRetry(ctx, "submitTrade", sids, submit, find)
+	return success, failed, err
+}
+
+// SubmitSmartContractTrade 广播智能合约交易单。
+// 请求失败且可重试时，先用FindSmartContractReceiptByParams按sid查询，已有回执的视为广播成功，
+// 只重新提交确认未广播的交易单。
+func (r *RetryClient) SubmitSmartContractTrade(ctx context.Context, rawTx []*SmartContractRawTransaction) ([]*SmartContractReceipt, []*FailureSmartContractLog, error) {
+	var (
+		success []*SmartContractReceipt
+		failed  []*FailureSmartContractLog
+		bySid   = make(map[string]*SmartContractRawTransaction, len(rawTx))
+		sids    = make([]string, 0, len(rawTx))
+	)
+	for _, tx := range rawTx {
+		sids = append(sids, tx.Sid)
+		bySid[tx.Sid] = tx
+	}
+	if err := checkRetrySids(sids); err != nil {
+		return nil, nil, err
+	}
+
+	find := func(ctx context.Context, sid string) (bool, error) {
+		receipts, err := r.client.FindSmartContractReceiptByParams(ctx, map[string]interface{}{"sid": sid})
+		if err != nil || len(receipts) == 0 {
+			return false, err
+		}
+		success = append(success, receipts...)
+		return true, nil
+	}
+	submit := func(ctx context.Context, sids []string, resubmit bool) error {
+		pending := make([]*SmartContractRawTransaction, 0, len(sids))
+		for _, sid := range sids {
+			pending = append(pending, bySid[sid])
+		}
+		receipts, failures, err := r.client.SubmitSmartContractTrade(ctx, pending)
+		if err != nil {
+			return err
+		}
+		success = append(success, receipts...)
+		for _, f := range failures {
+			if resubmit && f.RawTx != nil && isDuplicateSidReason(f.Reason) {
+				if ok, err := find(ctx, f.RawTx.Sid); err == nil && ok {
+					continue
+				}
+			}
+			failed = append(failed, f)
+		}
+		return nil
+	}
+
+	err := r.submitWithRetry(ctx, "submitSmartContractTrade", sids, submit, find)
+	return success, failed, err
+}
+
+// submitWithRetry 提交失败后按sid查询广播结果，只重新提交确认未广播的sid。
+// 任何一个sid查询失败时都不重新提交，等待后再次查询。
+func (r *RetryClient) submitWithRetry(
+	ctx context.Context,
+	method string,
+	sids []string,
+	submit func(ctx context.Context, sids []string, resubmit bool) error,
+	find func(ctx context.Context, sid string) (bool, error),
+) error {
+	pending := sids
+	confirmed := true //pending中的sid已确认未广播
+	for attempt := 1; ; attempt++ {
+		var err error
+		if !confirmed {
+			pending, err = r.confirm(ctx, pending, find)
+			confirmed = err == nil
+			if confirmed && len(pending) == 0 {
+				return nil
+			}
+		}
+		if confirmed {
+			err = r.attempt(ctx, func(ctx context.Context) error {
+				return submit(ctx, pending, attempt > 1)
+			})
+			if err == nil {
+				return nil
+			}
+			if !r.retryable(err) {
+				return err
+			}
+			confirmed = false
+		} else if !r.retryable(err) {
+			return &RetryError{Method: method, Attempts: attempt, Unresolved: pending, Err: err}
+		}
+		log.Warningf("%s attempt %d failed: %v", method, attempt, err)
+		if !r.wait(ctx, attempt) {
+			return &RetryError{Method: method, Attempts: attempt, Unresolved: pending, Err: err}
+		}
+	}
+}
+
+// confirm 按sid查询上次提交的结果，返回确认未广播的sid；查询失败时一并返回还未确认的sid
+func (r *RetryClient) confirm(ctx context.Context, sids []string, find func(ctx context.Context, sid string) (bool, error)) ([]string, error) {
+	unsent := make([]string, 0, len(sids))
+	for i, sid := range sids {
+		var found bool
+		err := r.attempt(ctx, func(ctx context.Context) (err error) {
+			found, err = find(ctx, sid)
+			return err
+		})
+		if err != nil {
+			return append(unsent, sids[i:]...), err
+		}
+		if !found {
+			unsent = append(unsent, sid)
+		}
+	}
+	return unsent, nil
+}
+
+func checkRetrySids(sids []string) error {
+	seen := make(map[string]bool, len(sids))
+	for _, sid := range sids {
+		if len(sid) == 0 {
+			return fmt.Errorf("sid is required for retry")
+		}
+		if seen[sid] {
+			return fmt.Errorf("duplicate sid %s in one submission", sid)
+		}
+		seen[sid] = true
+	}
+	return nil
+}
+
+// isDuplicateSidReason 广播失败原因是否为sid重复
+func isDuplicateSidReason(reason string) bool {
+	return errors.Is(&Error{Msg: reason}, ErrDuplicateSid)
+}
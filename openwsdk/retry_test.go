@@ -0,0 +1,197 @@
+package openwsdk_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{openwsdk.NewError("submitTrade", owtp.ErrRequestTimeout, "timeout", nil), true},
+		{openwsdk.NewError("submitTrade", owtp.ErrInternalServerError, "error", nil), true},
+		{errors.New("unexpected response"), false},
+		{&openwsdk.RateLimitError{Method: "submitTrade"}, true},
+		{openwsdk.NewError("createTrade", 2001, "insufficient balance", nil), false},
+		{openwsdk.NewError("createTrade", owtp.ErrBadRequest, "sid is empty", nil), false},
+		{openwsdk.NewError("createTrade", owtp.ErrUnauthorized, "device is not bound", nil), false},
+		{context.Canceled, false},
+	}
+	for _, c := range cases {
+		if got := openwsdk.IsRetryable(c.err); got != c.retryable {
+			t.Errorf("IsRetryable(%v) = %v, want %v", c.err, got, c.retryable)
+		}
+	}
+
+	//节点不在线，请求未发出，可以重试
+	transmit, _ := testTransmitNode(t)
+	defer transmit.Close()
+	err := transmit.GetTrustNodeInfo("offline", true, func(uint64, string, *openwsdk.TrustNodeInfo) {})
+	if err == nil || !openwsdk.IsRetryable(err) {
+		t.Errorf("IsRetryable expected true for undelivered request, got: %v", err)
+	}
+}
+
+func TestIsRetryable_HTTPSendError(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	config := &openwsdk.APINodeConfig{
+		Host:        server.Listener.Addr().String(),
+		AppID:       openwsdktest.DefaultAppID,
+		AppKey:      openwsdktest.DefaultAppKey,
+		ConnectType: owtp.HTTP,
+		Cert:        owtp.NewRandomCertificate(),
+		TimeoutSEC:  30,
+	}
+	api, err := openwsdk.NewAPINodeWithError(config)
+	if err != nil {
+		t.Fatalf("NewAPINode unexpected error: %v", err)
+	}
+	defer api.Close()
+
+	//HTTP请求已经发出，服务端可能已经处理，结果未知，不能重试
+	err = api.BindAppDevice()
+	if !errors.Is(err, openwsdk.ErrUnknownOutcome) || openwsdk.IsRetryable(err) {
+		t.Errorf("BindAppDevice expected unknown outcome, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("server hits = %d, want 1", n)
+	}
+
+	//连接被拒绝，请求没有发出，可以重试
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	config.Host = closed.Listener.Addr().String()
+	config.Cert = owtp.NewRandomCertificate()
+	refused, err := openwsdk.NewAPINodeWithError(config)
+	if err != nil {
+		t.Fatalf("NewAPINode unexpected error: %v", err)
+	}
+	defer refused.Close()
+	err = refused.BindAppDevice()
+	if err == nil || errors.Is(err, openwsdk.ErrUnknownOutcome) || !openwsdk.IsRetryable(err) {
+		t.Errorf("BindAppDevice expected undelivered error, got: %v", err)
+	}
+}
+
+func TestRetryClient_Trade(t *testing.T) {
+	s, err := openwsdktest.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer unexpected error: %v", err)
+	}
+	defer s.Close()
+	s.AddSymbol(&openwsdk.Symbol{Symbol: "BTC", Curve: int64(owcrypt.ECC_CURVE_SECP256K1), FeeRate: "0.0001"})
+
+	api, err := s.NewAPINode(owtp.HTTP)
+	if err != nil {
+		t.Fatalf("NewAPINode unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	client := openwsdk.NewAPIClient(api)
+
+	seed, _ := hdkeystore.GenerateSeed(32)
+	key, _ := hdkeystore.NewHDKey(seed, "test", hdkeystore.OpenwCoinTypePath)
+	wallet, err := client.CreateWallet(ctx, &openwsdk.Wallet{WalletID: key.KeyID, Alias: "test"})
+	if err != nil {
+		t.Fatalf("CreateWallet unexpected error: %v", err)
+	}
+	newAccount, _ := wallet.CreateAccount("main", &openwsdk.Symbol{Symbol: "BTC", Curve: int64(owcrypt.ECC_CURVE_SECP256K1)}, key)
+	account, _, err := client.CreateNormalAccount(ctx, newAccount)
+	if err != nil {
+		t.Fatalf("CreateNormalAccount unexpected error: %v", err)
+	}
+	s.SetBalance(account.AccountID, "", "10")
+
+	retry := openwsdk.NewRetryClient(client, openwsdk.RetryPolicy{
+		Backoff: openwsdk.Backoff{InitialInterval: time.Millisecond, MaxAttempts: 3},
+	})
+	coin := openwsdk.Coin{Symbol: "BTC"}
+	signedTrade := func(sid string) *openwsdk.RawTransaction {
+		rawTx, err := retry.CreateTrade(ctx, account.AccountID, sid, coin, map[string]string{"1xyz": "1"}, "", "", "")
+		if err != nil {
+			t.Fatalf("CreateTrade unexpected error: %v", err)
+		}
+		if err := openwsdk.SignRawTransaction(rawTx, key); err != nil {
+			t.Fatalf("SignRawTransaction unexpected error: %v", err)
+		}
+		return rawTx
+	}
+
+	//创建交易单失败后用同一sid重试
+	s.FailNext("createTrade", owtp.ErrInternalServerError, 1, true)
+	rawTx := signedTrade("sid-1")
+	if calls := s.Calls("createTrade"); calls != 2 {
+		t.Errorf("createTrade expected 2 calls, got: %d", calls)
+	}
+
+	//服务端已广播但响应超时，查询到交易记录后不再重复提交
+	s.FailNext("submitTrade", owtp.ErrRequestTimeout, 1, true)
+	txs, failed, err := retry.SubmitTrade(ctx, []*openwsdk.RawTransaction{rawTx})
+	if err != nil || len(txs) != 1 || len(failed) != 0 || txs[0].Sid != "sid-1" {
+		t.Fatalf("SubmitTrade unexpected result: %v, %v, %v", txs, failed, err)
+	}
+	if calls := s.Calls("submitTrade"); calls != 1 {
+		t.Errorf("submitTrade expected 1 call, got: %d", calls)
+	}
+
+	//请求未送达时查询不到交易记录，重新提交
+	rawTx = signedTrade("sid-2")
+	s.FailNext("submitTrade", owtp.ErrNetworkDisconnected, 1, false)
+	txs, failed, err = retry.SubmitTrade(ctx, []*openwsdk.RawTransaction{rawTx})
+	if err != nil || len(txs) != 1 || len(failed) != 0 {
+		t.Fatalf("SubmitTrade unexpected result: %v, %v, %v", txs, failed, err)
+	}
+	if calls := s.Calls("submitTrade"); calls != 3 {
+		t.Errorf("submitTrade expected 3 calls, got: %d", calls)
+	}
+
+	//无法确认是否已广播时不重新提交
+	rawTx = signedTrade("sid-3")
+	s.FailNext("submitTrade", owtp.ErrRequestTimeout, 1, true)
+	s.FailNext("findTradeLog", owtp.ErrInternalServerError, 3, false)
+	_, _, err = retry.SubmitTrade(ctx, []*openwsdk.RawTransaction{rawTx})
+	retryErr, ok := err.(*openwsdk.RetryError)
+	if !ok || len(retryErr.Unresolved) != 1 || retryErr.Unresolved[0] != "sid-3" {
+		t.Fatalf("SubmitTrade expected RetryError with unresolved sid, got: %v", err)
+	}
+	if calls := s.Calls("submitTrade"); calls != 4 {
+		t.Errorf("submitTrade expected 4 calls, got: %d", calls)
+	}
+
+	//业务错误不重试
+	_, err = retry.CreateTrade(ctx, account.AccountID, "sid-4", coin, map[string]string{"1xyz": "100"}, "", "", "")
+	if !errors.Is(err, openwsdk.ErrInsufficientBalance) {
+		t.Errorf("CreateTrade expected ErrInsufficientBalance, got: %v", err)
+	}
+	if calls := s.Calls("createTrade"); calls != 5 {
+		t.Errorf("createTrade expected 5 calls, got: %d", calls)
+	}
+
+	var sent int
+	for _, tx := range s.Transactions() {
+		if tx.Sid == "sid-1" {
+			sent++
+		}
+	}
+	if sent != 1 {
+		t.Errorf("sid-1 expected 1 transaction, got: %d", sent)
+	}
+}
@@ -0,0 +1,249 @@
+package openwsdk
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// Secret 可清零的敏感数据，如钱包解锁密码。
+// 内容只保存在字节数组中，不会转换为字符串，String和json序列化只输出脱敏值。
+type Secret struct {
+	mu sync.Mutex
+	b  []byte
+}
+
+// NewSecret 使用b创建Secret，b归Secret所有，Zero时会被清零
+func NewSecret(b []byte) *Secret {
+	return &Secret{b: b}
+}
+
+// Bytes 敏感数据的内容，Zero后为空，调用方不要保留返回的数组
+func (s *Secret) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b
+}
+
+// Len 敏感数据的长度
+func (s *Secret) Len() int {
+	return len(s.Bytes())
+}
+
+// Zero 清零敏感数据
+func (s *Secret) Zero() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.b {
+		s.b[i] = 0
+	}
+	s.b = nil
+}
+
+// String 只输出脱敏值，避免被打印到日志
+func (s *Secret) String() string {
+	return redactedValue
+}
+
+// GoString 只输出脱敏值，避免被%#v打印到日志
+func (s *Secret) GoString() string {
+	return redactedValue
+}
+
+// MarshalJSON 只输出脱敏值，发送请求时使用secretParam
+func (s *Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redactedValue + `"`), nil
+}
+
+// secretParam 请求参数中的敏感数据，序列化时直接从字节数组写入json字符串
+type secretParam struct {
+	secret *Secret
+}
+
+func (p secretParam) MarshalJSON() ([]byte, error) {
+	const hex = "0123456789abcdef"
+	b := p.secret.Bytes()
+	buf := make([]byte, 0, len(b)+2)
+	buf = append(buf, '"')
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c < 0x20:
+			buf = append(buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			buf = append(buf, c)
+		}
+	}
+	buf = append(buf, '"')
+	return buf, nil
+}
+
+// SecretProvider 按钱包ID即时获取钱包解锁密码。
+// 每次调用都要返回新的Secret，SDK发送请求后会将其清零；没有密码时返回nil。
+type SecretProvider interface {
+	WalletPassword(walletID string) (*Secret, error)
+}
+
+// SecretProviderFunc 函数形式的SecretProvider
+type SecretProviderFunc func(walletID string) (*Secret, error)
+
+// WalletPassword 调用f
+func (f SecretProviderFunc) WalletPassword(walletID string) (*Secret, error) {
+	return f(walletID)
+}
+
+// SetSecretProvider 设置钱包密码的提供者，调用托管节点时密码参数为空则通过provider获取，nil关闭
+func (transmit *TransmitNode) SetSecretProvider(provider SecretProvider) {
+	transmit.secrets = provider
+}
+
+// passwordParam 密码参数，password为空时通过SecretProvider获取walletID的密码。
+// 请求发送后调用release清零密码。
+func (transmit *TransmitNode) passwordParam(walletID, password string) (param interface{}, release func(), err error) {
+	release = func() {}
+	if len(password) > 0 || transmit.secrets == nil || len(walletID) == 0 {
+		return password, release, nil
+	}
+	secret, err := transmit.secrets.WalletPassword(walletID)
+	if err != nil {
+		return nil, release, fmt.Errorf("wallet %s: get password failed: %w", walletID, err)
+	}
+	if secret == nil {
+		return password, release, nil
+	}
+	return secretParam{secret: secret}, secret.Zero, nil
+}
+
+// summaryTaskParam 汇总任务参数，钱包密码为空时通过SecretProvider获取
+type summaryTaskParam struct {
+	Wallets []*summaryWalletTaskParam `json:"wallets"`
+}
+
+// summaryWalletTaskParam 覆盖SummaryWalletTask的密码字段
+type summaryWalletTaskParam struct {
+	*SummaryWalletTask
+	Password interface{} `json:"password,omitempty"`
+}
+
+// summaryTaskParam 汇总任务参数，请求发送后调用release清零密码
+func (transmit *TransmitNode) summaryTaskParam(task *SummaryTask) (param interface{}, release func(), err error) {
+	var releases []func()
+	release = func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	if task == nil {
+		return task, release, nil
+	}
+	p := &summaryTaskParam{Wallets: make([]*summaryWalletTaskParam, 0, len(task.Wallets))}
+	for _, w := range task.Wallets {
+		if w == nil {
+			continue
+		}
+		pw, r, err := transmit.passwordParam(w.WalletID, w.Password)
+		if err != nil {
+			release()
+			return nil, func() {}, err
+		}
+		releases = append(releases, r)
+		wp := &summaryWalletTaskParam{SummaryWalletTask: w}
+		if s, ok := pw.(string); !ok || len(s) > 0 {
+			wp.Password = pw
+		}
+		p.Wallets = append(p.Wallets, wp)
+	}
+	return p, release, nil
+}
+
+// scrubPasswords 汇总任务交给托管节点后清除钱包密码
+func (task *SummaryTask) scrubPasswords() {
+	if task == nil {
+		return
+	}
+	for _, w := range task.Wallets {
+		if w != nil {
+			w.Password = ""
+		}
+	}
+}
+
+// LogAdapterRedactConsole 脱敏后输出到控制台的日志适配器，可通过log.SetLogger使用
+const LogAdapterRedactConsole = "openwsdk-redact-console"
+
+// sensitiveLogPattern 匹配日志中json格式的敏感字段，如owtp调试模式输出的数据包
+var sensitiveLogPattern = func() *regexp.Regexp {
+	keys := make([]string, 0, len(sensitiveParamKeys))
+	for k := range sensitiveParamKeys {
+		keys = append(keys, regexp.QuoteMeta(k))
+	}
+	sort.Strings(keys)
+	return regexp.MustCompile(`(?i)("(?:` + strings.Join(keys, "|") + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+}()
+
+// RedactLogMessage 脱敏日志中的password、authKey、keystore等json字段
+func RedactLogMessage(msg string) string {
+	if !strings.Contains(msg, `"`) {
+		return msg
+	}
+	return sensitiveLogPattern.ReplaceAllString(msg, `${1}"`+redactedValue+`"`)
+}
+
+// redactLogger 脱敏后写入下层的日志适配器
+type redactLogger struct {
+	logs.Logger
+}
+
+// NewRedactLogger 包装日志适配器，写入前调用RedactLogMessage
+func NewRedactLogger(l logs.Logger) logs.Logger {
+	return &redactLogger{Logger: l}
+}
+
+func (l *redactLogger) WriteMsg(when time.Time, msg string, level int) error {
+	return l.Logger.WriteMsg(when, RedactLogMessage(msg), level)
+}
+
+// logRedactionMu 避免并发创建节点时重复替换控制台输出
+var logRedactionMu sync.Mutex
+
+// EnableLogRedaction 将log.Std的控制台输出替换为LogAdapterRedactConsole，写入前调用RedactLogMessage。
+// owtp.Debug开启时NewAPINode和NewTransmitNode会自动调用；已经替换或没有控制台输出时不做修改，
+// 通过log.SetLogger添加的其他适配器需要在logs.Register时用NewRedactLogger包装。
+func EnableLogRedaction() error {
+	logRedactionMu.Lock()
+	defer logRedactionMu.Unlock()
+	if err := log.Std.DelLogger(logs.AdapterConsole); err != nil {
+		return nil
+	}
+	return log.Std.SetLogger(LogAdapterRedactConsole)
+}
+
+// enableDebugLogRedaction owtp.Debug开启后会输出完整的数据包，包括钱包密码和appkey，创建节点时自动脱敏
+func enableDebugLogRedaction() {
+	if !owtp.Debug {
+		return
+	}
+	if err := EnableLogRedaction(); err != nil {
+		log.Warningf("EnableLogRedaction failed: %v", err)
+	}
+}
+
+func init() {
+	logs.Register(LogAdapterRedactConsole, func() logs.Logger {
+		return NewRedactLogger(logs.NewConsole())
+	})
+}
@@ -0,0 +1,172 @@
+package openwsdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func TestSecret(t *testing.T) {
+	b := []byte("12345678")
+	s := openwsdk.NewSecret(b)
+	if string(s.Bytes()) != "12345678" || s.Len() != 8 {
+		t.Fatalf("unexpected secret bytes: %q", s.Bytes())
+	}
+	out, _ := json.Marshal(map[string]interface{}{"password": s})
+	if str := fmt.Sprintf("%v %s %#v %s", s, s, s, out); strings.Contains(str, "12345678") {
+		t.Errorf("secret leaked: %s", str)
+	}
+	s.Zero()
+	if s.Len() != 0 || string(b) != string(make([]byte, 8)) {
+		t.Errorf("Zero expected to clear buffer, got: %q", b)
+	}
+}
+
+type testLogWriter struct {
+	msgs []string
+}
+
+func (w *testLogWriter) Init(config string) error { return nil }
+func (w *testLogWriter) WriteMsg(when time.Time, msg string, level int) error {
+	w.msgs = append(w.msgs, msg)
+	return nil
+}
+func (w *testLogWriter) Destroy() {}
+func (w *testLogWriter) Flush()   {}
+
+func TestRedactLogger(t *testing.T) {
+	w := &testLogWriter{}
+	l := openwsdk.NewRedactLogger(w)
+	msg := `Send: {"d":{"walletID":"W1","password": "pa\"ss","authKey":"K1","Keystore":"{\"crypto\":{}}"},"m":"signHashViaTrustNode"}`
+	l.WriteMsg(time.Now(), msg, 7)
+	expected := `Send: {"d":{"walletID":"W1","password": "******","authKey":"******","Keystore":"******"},"m":"signHashViaTrustNode"}`
+	if len(w.msgs) != 1 || w.msgs[0] != expected {
+		t.Errorf("unexpected redacted message: %v", w.msgs)
+	}
+	if plain := "walletID W1 created"; openwsdk.RedactLogMessage(plain) != plain {
+		t.Errorf("RedactLogMessage changed plain message")
+	}
+}
+
+func TestEnableLogRedaction(t *testing.T) {
+	owtp.Debug = true
+	defer func() { owtp.Debug = false }()
+	defer log.Std.SetLogger(logs.AdapterConsole)
+
+	//开启owtp.Debug后创建节点，控制台输出自动替换为脱敏输出
+	transmit, _ := testTransmitNode(t)
+	transmit.Close()
+	if err := openwsdk.EnableLogRedaction(); err != nil {
+		t.Fatalf("EnableLogRedaction unexpected error: %v", err)
+	}
+	if err := log.Std.DelLogger(logs.AdapterConsole); err == nil {
+		t.Errorf("console logger expected to be replaced")
+	}
+	if err := log.Std.DelLogger(openwsdk.LogAdapterRedactConsole); err != nil {
+		t.Errorf("redact console logger expected to be set: %v", err)
+	}
+}
+
+func TestTransmitNode_SecretProvider(t *testing.T) {
+	transmit, addr := testTransmitNode(t)
+	defer transmit.Close()
+	key := testHDKey(t)
+	trustNode := openwsdktest.NewTrustNode("trust")
+	defer trustNode.Close()
+	trustNode.ImportKey(key, "12345678")
+	testConnectTrustNode(t, trustNode, addr)
+	testWaitFor(t, "wallet list", func() bool {
+		return len(transmit.Registry().NodesForWallet(key.KeyID)) == 1
+	})
+
+	var (
+		mu      sync.Mutex
+		secrets []*openwsdk.Secret
+	)
+	transmit.SetSecretProvider(openwsdk.SecretProviderFunc(func(walletID string) (*openwsdk.Secret, error) {
+		if walletID != key.KeyID {
+			return nil, errors.New("unknown wallet")
+		}
+		s := openwsdk.NewSecret([]byte("12345678"))
+		mu.Lock()
+		secrets = append(secrets, s)
+		mu.Unlock()
+		return s, nil
+	}))
+
+	//签名时即时获取密码
+	signer := &openwsdk.TrustNodeSigner{Transmit: transmit}
+	ks := testKeySignature(key)
+	if err := signer.Sign(context.Background(), ks); err != nil {
+		t.Fatalf("Sign unexpected error: %v", err)
+	}
+	verifyKeySignature(t, key, ks)
+
+	req := &openwsdk.SendTransactionRequest{
+		WalletID:  key.KeyID,
+		AccountID: "A1",
+		Sid:       "sid-1",
+		Symbol:    "BTC",
+		Amount:    "0.1",
+		Address:   "1xyz",
+	}
+	var sendStatus uint64
+	err := transmit.SendTransactionViaTrustNodeWithRequest(trustNode.NodeID(), req, true,
+		func(status uint64, msg string, successTx []*openwsdk.Transaction, failedRawTxs []*openwsdk.FailedRawTransaction) {
+			sendStatus = status
+		})
+	if err != nil || sendStatus != owtp.StatusSuccess {
+		t.Fatalf("SendTransactionViaTrustNodeWithRequest unexpected result: %d, %v", sendStatus, err)
+	}
+
+	//汇总任务交给节点后不再保留密码
+	task := &openwsdk.SummaryTask{Wallets: []*openwsdk.SummaryWalletTask{
+		{WalletID: key.KeyID},
+		{WalletID: "W-other", Password: "12345678"},
+	}}
+	var taskStatus uint64
+	err = transmit.StartSummaryTaskViaTrustNode(trustNode.NodeID(), 60, task, openwsdk.SummaryTaskOperateTypeReset, true,
+		func(status uint64, msg string) {
+			taskStatus = status
+		})
+	if err != nil || taskStatus != owtp.StatusSuccess {
+		t.Fatalf("StartSummaryTaskViaTrustNode unexpected result: %d, %v", taskStatus, err)
+	}
+	for _, w := range task.Wallets {
+		if len(w.Password) > 0 {
+			t.Errorf("wallet %s password expected to be cleared", w.WalletID)
+		}
+	}
+
+	mu.Lock()
+	if len(secrets) != 3 {
+		t.Errorf("provider expected 3 calls, got: %d", len(secrets))
+	}
+	for i, s := range secrets {
+		if s.Len() != 0 {
+			t.Errorf("secret %d expected to be zeroed", i)
+		}
+	}
+	mu.Unlock()
+
+	req.WalletID = "W-unknown"
+	err = transmit.SendTransactionViaTrustNodeWithRequest(trustNode.NodeID(), req, true,
+		func(uint64, string, []*openwsdk.Transaction, []*openwsdk.FailedRawTransaction) {})
+	if err == nil || !strings.Contains(err.Error(), "unknown wallet") {
+		t.Errorf("SendTransactionViaTrustNodeWithRequest expected provider error, got: %v", err)
+	}
+	if n := trustNode.Calls("sendTransactionViaTrustNode"); n != 1 {
+		t.Errorf("expected 1 send, got: %d", n)
+	}
+}
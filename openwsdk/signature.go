@@ -1,8 +1,10 @@
 package openwsdk
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
+
 	"github.com/blocktree/go-owcrypt"
 	"github.com/blocktree/openwallet/v2/hdkeystore"
 	"github.com/blocktree/openwallet/v2/openwallet"
@@ -10,151 +12,103 @@ import (
 
 //SignRawTransaction 签名交易单
 func SignRawTransaction(rawTx *RawTransaction, key *hdkeystore.HDKey) error {
+	return SignRawTransactionWithSigner(context.Background(), rawTx, NewHDKeySigner(key))
+}
 
-	for accountID, keySignatures := range rawTx.Signatures {
-		if keySignatures != nil {
-
-			for _, keySignature := range keySignatures {
-
-				if keySignature.EccType == owcrypt.ECC_CURVE_BLS12381_G2_XMD_SHA_256_SSWU_RO_AUG {
-
-					childKey, err := key.DerivedKeyWithPath(keySignature.DerivedPath, keySignature.EccType)
-					keyBytes, err := childKey.GetPrivateKeyBytes()
-					if err != nil {
-						return openwallet.NewError(openwallet.ErrSignRawTransactionFailed, err.Error())
-					}
-					message, err := hex.DecodeString(keySignature.Message)
-					if err != nil {
-						return err
-					}
-					key2 := Calculate_synthetic_secret_key(keyBytes)
-					newKey := make([]byte,0)
-					if len(key2) != 32{
-						for i:=0 ;i< 32 - len(key2) ;i++{
-							newKey = append(newKey, 0)
-						}
-					}
-					newKey = append(newKey, key2...)
-					signature, _, sigErr := owcrypt.Signature(newKey, nil, message, keySignature.EccType)
-					if sigErr != owcrypt.SUCCESS {
-						return fmt.Errorf("transaction hash sign failed")
-					}
-					keySignature.Signature = hex.EncodeToString(signature)
-					continue
-				}
-
-				childKey, err := key.DerivedKeyWithPath(keySignature.DerivedPath, keySignature.EccType)
-				keyBytes, err := childKey.GetPrivateKeyBytes()
-				if err != nil {
-					return err
-				}
-				//log.Debug("privateKey:", hex.EncodeToString(keyBytes))
-
-				//privateKeys = append(privateKeys, keyBytes)
-				txHash, err := hex.DecodeString(keySignature.Message)
-				//transHash = append(transHash, txHash)
-
-				//log.Infof("sign hash: %s", txHash)
-
-				//签名交易
-				/////////交易单哈希签名
-
-				//signature, err := signatureSet.SignTxHash(rawTx.Coin.Symbol, txHash, keyBytes, keySignature.EccType)
-				//if err != nil {
-				//	return fmt.Errorf("transaction hash sign failed, unexpected error: %v", err)
-				//}
-
-				signature, v, sigErr := owcrypt.Signature(keyBytes, nil, txHash, keySignature.EccType)
-				if sigErr != owcrypt.SUCCESS {
-					return fmt.Errorf("transaction hash sign failed")
-				}
-
-				if keySignature.RSV {
-					signature = append(signature, v)
-				}
-
-				//log.Debug("Signature:", txHash)
+//SignTxHash 签名交易单Hash
+func SignTxHash(signatures map[string][]*KeySignature, key *hdkeystore.HDKey) (map[string][]*KeySignature, error) {
+	return SignTxHashWithSigner(context.Background(), signatures, NewHDKeySigner(key))
+}
 
-				keySignature.Signature = hex.EncodeToString(signature)
-			}
+//SignRawTransactionWithSigner 通过签名者签名交易单，ctx中带有交易单的SigningInfo
+func SignRawTransactionWithSigner(ctx context.Context, rawTx *RawTransaction, signer Signer) error {
+	info := &SigningInfo{
+		Symbol:     rawTx.Coin.Symbol,
+		ContractID: rawTx.Coin.ContractID,
+		Sid:        rawTx.Sid,
+		RawTx:      rawTx,
+	}
+	for accountID, keySignatures := range rawTx.Signatures {
+		info.AccountID = accountID
+		if err := signKeySignatures(ctx, info, keySignatures, signer); err != nil {
+			return err
 		}
-		rawTx.Signatures[accountID] = keySignatures
 	}
-
 	return nil
 }
 
-//SignTxHash 签名交易单Hash
-func SignTxHash(signatures map[string][]*KeySignature, key *hdkeystore.HDKey) (map[string][]*KeySignature, error) {
-
+//SignTxHashWithSigner 通过签名者签名交易单Hash
+func SignTxHashWithSigner(ctx context.Context, signatures map[string][]*KeySignature, signer Signer) (map[string][]*KeySignature, error) {
+	info := &SigningInfo{}
+	if exist, ok := SigningInfoFromContext(ctx); ok {
+		*info = *exist
+	}
 	for accountID, keySignatures := range signatures {
-		//log.Infof("accountID: %s", accountID)
-		if keySignatures != nil {
-			for _, keySignature := range keySignatures {
-				if keySignature.EccType == owcrypt.ECC_CURVE_BLS12381_G2_XMD_SHA_256_SSWU_RO_AUG {
-
-					childKey, err := key.DerivedKeyWithPath(keySignature.DerivedPath, keySignature.EccType)
-					keyBytes, err := childKey.GetPrivateKeyBytes()
-					if err != nil {
-						return nil,openwallet.NewError(openwallet.ErrSignRawTransactionFailed, err.Error())
-					}
-					message, err := hex.DecodeString(keySignature.Message)
-					if err != nil {
-						return  nil,err
-					}
-
-					key2 := Calculate_synthetic_secret_key(keyBytes)
-					newKey := make([]byte,0)
-					if len(key2) != 32{
-						for i:=0 ;i< 32 - len(key2) ;i++{
-							newKey = append(newKey, 0)
-						}
-					}
-					newKey = append(newKey, key2...)
-					signature, _, sigErr := owcrypt.Signature(newKey, nil, message, keySignature.EccType)
-					if sigErr != owcrypt.SUCCESS {
-						return  nil,fmt.Errorf("transaction hash sign failed："+keySignature.Message)
-					}
-					keySignature.Signature = hex.EncodeToString(signature)
-					continue
-				}
-				childKey, err := key.DerivedKeyWithPath(keySignature.DerivedPath, keySignature.EccType)
-				keyBytes, err := childKey.GetPrivateKeyBytes()
-				if err != nil {
-					return nil, err
-				}
-				//log.Debug("privateKey:", hex.EncodeToString(keyBytes))
-
-				//privateKeys = append(privateKeys, keyBytes)
-				txHash, err := hex.DecodeString(keySignature.Message)
-				//transHash = append(transHash, txHash)
-
-				//log.Infof("sign hash: %s", txHash)
-
-				//签名交易
-				/////////交易单哈希签名
-
-				//signature, err := signatureSet.SignTxHash(rawTx.Coin.Symbol, txHash, keyBytes, keySignature.EccType)
-				//if err != nil {
-				//	return fmt.Errorf("transaction hash sign failed, unexpected error: %v", err)
-				//}
-
-				signature, v, sigErr := owcrypt.Signature(keyBytes, nil, txHash, keySignature.EccType)
-				if sigErr != owcrypt.SUCCESS {
-					return nil, fmt.Errorf("transaction hash sign failed")
-				}
-
-				if keySignature.RSV {
-					signature = append(signature, v)
-				}
+		info.AccountID = accountID
+		if err := signKeySignatures(ctx, info, keySignatures, signer); err != nil {
+			return nil, err
+		}
+	}
+	return signatures, nil
+}
 
-				//log.Debug("Signature:", txHash)
+func signKeySignatures(ctx context.Context, info *SigningInfo, keySignatures []*KeySignature, signer Signer) error {
+	signInfo := *info
+	ctx = WithSigningInfo(ctx, &signInfo)
+	for _, keySignature := range keySignatures {
+		if keySignature == nil {
+			continue
+		}
+		if err := signer.Sign(ctx, keySignature); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-				keySignature.Signature = hex.EncodeToString(signature)
-			}
+//signHash 使用私钥签名消息，BLS曲线先转换为合成私钥，返回签名和v
+func signHash(keyBytes []byte, message []byte, eccType uint32) ([]byte, byte, error) {
+	if eccType == owcrypt.ECC_CURVE_BLS12381_G2_XMD_SHA_256_SSWU_RO_AUG {
+		key2 := Calculate_synthetic_secret_key(keyBytes)
+		newKey := make([]byte, 0, 32)
+		for i := len(key2); i < 32; i++ {
+			newKey = append(newKey, 0)
 		}
-		signatures[accountID] = keySignatures
+		keyBytes = append(newKey, key2...)
 	}
+	signature, v, sigErr := owcrypt.Signature(keyBytes, nil, message, eccType)
+	if sigErr != owcrypt.SUCCESS {
+		return nil, 0, fmt.Errorf("transaction hash sign failed")
+	}
+	return signature, v, nil
+}
 
-	return signatures, nil
+//fillSignature 按KeySignature的要求填写签名，RSV模式拼接v，BLS曲线不拼接
+func fillSignature(keySignature *KeySignature, signature []byte, v byte) {
+	if keySignature.RSV && keySignature.EccType != owcrypt.ECC_CURVE_BLS12381_G2_XMD_SHA_256_SSWU_RO_AUG {
+		signature = append(signature, v)
+	}
+	keySignature.Signature = hex.EncodeToString(signature)
+}
+
+//signWithHDKey 派生子私钥签名
+func signWithHDKey(key *hdkeystore.HDKey, keySignature *KeySignature) error {
+	childKey, err := key.DerivedKeyWithPath(keySignature.DerivedPath, keySignature.EccType)
+	if err != nil {
+		return openwallet.NewError(openwallet.ErrSignRawTransactionFailed, err.Error())
+	}
+	keyBytes, err := childKey.GetPrivateKeyBytes()
+	if err != nil {
+		return openwallet.NewError(openwallet.ErrSignRawTransactionFailed, err.Error())
+	}
+	message, err := hex.DecodeString(keySignature.Message)
+	if err != nil {
+		return err
+	}
+	signature, v, err := signHash(keyBytes, message, keySignature.EccType)
+	if err != nil {
+		return err
+	}
+	fillSignature(keySignature, signature, v)
+	return nil
 }
@@ -0,0 +1,330 @@
+package openwsdk
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// Signer 交易签名者，为KeySignature.Message签名并填写KeySignature.Signature
+type Signer interface {
+	Sign(ctx context.Context, keySignature *KeySignature) error
+}
+
+// SignerFunc 函数形式的签名者
+type SignerFunc func(ctx context.Context, keySignature *KeySignature) error
+
+// Sign 签名
+func (f SignerFunc) Sign(ctx context.Context, keySignature *KeySignature) error {
+	return f(ctx, keySignature)
+}
+
+// SigningInfo 签名时的交易单信息，通过ctx传给Signer
+type SigningInfo struct {
+	AccountID  string          //签名的账户，RawTransaction.Signatures的key
+	Symbol     string          //主链标识
+	ContractID string          //合约ID
+	Sid        string          //业务订单号
+	RawTx      *RawTransaction //签名的交易单，签名交易单Hash时为空
+}
+
+type signingInfoKey struct{}
+
+// WithSigningInfo 在ctx中带上签名时的交易单信息
+func WithSigningInfo(ctx context.Context, info *SigningInfo) context.Context {
+	return context.WithValue(ctx, signingInfoKey{}, info)
+}
+
+// SigningInfoFromContext 获取签名时的交易单信息
+func SigningInfoFromContext(ctx context.Context) (*SigningInfo, bool) {
+	info, ok := ctx.Value(signingInfoKey{}).(*SigningInfo)
+	return info, ok && info != nil
+}
+
+// HDKeySigner 使用进程内已解密的HDKey签名
+type HDKeySigner struct {
+	key *hdkeystore.HDKey
+}
+
+// NewHDKeySigner 创建本地HDKey签名者
+func NewHDKeySigner(key *hdkeystore.HDKey) *HDKeySigner {
+	return &HDKeySigner{key: key}
+}
+
+// Sign 按KeySignature.DerivedPath派生子私钥签名
+func (s *HDKeySigner) Sign(ctx context.Context, keySignature *KeySignature) error {
+	if s == nil || s.key == nil {
+		return fmt.Errorf("HDKey is not inited")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return signWithHDKey(s.key, keySignature)
+}
+
+// TrustNodeSigner 通过TransmitNode.SignHashViaTrustNode由托管节点签名，私钥不进入当前进程
+type TrustNodeSigner struct {
+	Transmit  *TransmitNode
+	NodeID    string //托管节点ID，为空时从注册表选择持有钱包的节点
+	WalletID  string //钱包ID，为空时使用KeySignature.WalletID
+	AccountID string //账户ID，为空时使用ctx中SigningInfo.AccountID
+	Password  string //钱包解锁密码，可选，为空时通过Transmit的SecretProvider获取
+	Symbol    string //主链标识，为空时使用ctx中SigningInfo.Symbol
+}
+
+// Sign 请求托管节点签名，ctx结束时放弃等待
+func (s *TrustNodeSigner) Sign(ctx context.Context, keySignature *KeySignature) error {
+	if s == nil || s.Transmit == nil {
+		return fmt.Errorf("TransmitNode is not inited")
+	}
+	walletID, accountID, symbol := s.WalletID, s.AccountID, s.Symbol
+	if len(walletID) == 0 {
+		walletID = keySignature.WalletID
+	}
+	if info, ok := SigningInfoFromContext(ctx); ok {
+		if len(accountID) == 0 {
+			accountID = info.AccountID
+		}
+		if len(symbol) == 0 {
+			symbol = info.Symbol
+		}
+	}
+	if ctx.Err() != nil {
+		return contextError(ctx, "signHashViaTrustNode", nil)
+	}
+	if len(s.NodeID) > 0 {
+		return s.sign(ctx, s.NodeID, walletID, accountID, symbol, keySignature)
+	}
+	return s.Transmit.Registry().Route(walletID, func(nodeID string) error {
+		return s.sign(ctx, nodeID, walletID, accountID, symbol, keySignature)
+	})
+}
+
+func (s *TrustNodeSigner) sign(ctx context.Context, nodeID, walletID, accountID, symbol string, keySignature *KeySignature) error {
+	type result struct {
+		signature string
+		err       error
+	}
+	done := make(chan result, 1)
+	err := s.Transmit.SignHashViaTrustNode(nodeID, walletID, accountID, keySignature.Address,
+		keySignature.Message, s.Password, symbol, keySignature.DerivedPath, keySignature.RSV, false,
+		func(status uint64, msg string, signature string) {
+			if status != owtp.StatusSuccess {
+				done <- result{err: NewError("signHashViaTrustNode", status, msg, nil)}
+				return
+			}
+			done <- result{signature: signature}
+		})
+	if err != nil {
+		return err
+	}
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return r.err
+		}
+		keySignature.Signature = r.signature
+		return nil
+	case <-ctx.Done():
+		return contextError(ctx, "signHashViaTrustNode", nil)
+	}
+}
+
+// HSMObjectHandle 外部签名模块中的密钥句柄
+type HSMObjectHandle uint64
+
+// HSMModule PKCS#11风格的外部签名模块，私钥只存在于模块内部
+type HSMModule interface {
+	// OpenSession 打开指定槽位的会话
+	OpenSession(slot uint) (HSMSession, error)
+}
+
+// HSMSession 外部签名模块的会话
+type HSMSession interface {
+	// Login 使用PIN登录
+	Login(pin string) error
+	// FindKey 按标签查找私钥
+	FindKey(label string) (HSMObjectHandle, error)
+	// Sign 使用私钥签名消息，返回签名和v
+	Sign(key HSMObjectHandle, eccType uint32, message []byte) ([]byte, byte, error)
+	// Close 关闭会话
+	Close() error
+}
+
+// HSMSigner 通过外部签名模块签名
+type HSMSigner struct {
+	mu      sync.Mutex
+	module  HSMModule
+	slot    uint
+	pin     string
+	session HSMSession
+	// KeyLabel 私钥在模块中的标签，默认为HSMKeyLabel
+	KeyLabel func(keySignature *KeySignature) string
+}
+
+// NewHSMSigner 创建外部签名模块签名者，首次签名时打开会话并登录
+func NewHSMSigner(module HSMModule, slot uint, pin string) *HSMSigner {
+	return &HSMSigner{
+		module:   module,
+		slot:     slot,
+		pin:      pin,
+		KeyLabel: HSMKeyLabel,
+	}
+}
+
+// HSMKeyLabel 默认的私钥标签：walletID:eccType:derivedPath，同一路径在不同曲线下是不同的私钥
+func HSMKeyLabel(keySignature *KeySignature) string {
+	return fmt.Sprintf("%s:%d:%s", keySignature.WalletID, keySignature.EccType, keySignature.DerivedPath)
+}
+
+// Sign 在模块中查找私钥并签名
+func (s *HSMSigner) Sign(ctx context.Context, keySignature *KeySignature) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	message, err := hex.DecodeString(keySignature.Message)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.session == nil {
+		session, err := s.module.OpenSession(s.slot)
+		if err != nil {
+			return err
+		}
+		if err := session.Login(s.pin); err != nil {
+			session.Close()
+			return err
+		}
+		s.session = session
+	}
+	handle, err := s.session.FindKey(s.KeyLabel(keySignature))
+	if err != nil {
+		return err
+	}
+	signature, v, err := s.session.Sign(handle, keySignature.EccType, message)
+	if err != nil {
+		return err
+	}
+	fillSignature(keySignature, signature, v)
+	return nil
+}
+
+// Close 关闭会话
+func (s *HSMSigner) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.session == nil {
+		return nil
+	}
+	err := s.session.Close()
+	s.session = nil
+	return err
+}
+
+// SoftwareHSM 软件实现的外部签名模块，用于测试和开发环境替代真实的HSM
+type SoftwareHSM struct {
+	mu      sync.Mutex
+	pin     string
+	keys    map[string]*hdkeystore.HDKey //walletID -> HDKey
+	objects map[HSMObjectHandle][]byte   //句柄 -> 私钥
+	labels  map[string]HSMObjectHandle
+	next    HSMObjectHandle
+}
+
+// NewSoftwareHSM 创建软件签名模块
+func NewSoftwareHSM(pin string) *SoftwareHSM {
+	return &SoftwareHSM{
+		pin:     pin,
+		keys:    make(map[string]*hdkeystore.HDKey),
+		objects: make(map[HSMObjectHandle][]byte),
+		labels:  make(map[string]HSMObjectHandle),
+	}
+}
+
+// ImportKey 导入钱包根密钥，按HSMKeyLabel派生的标签查找子私钥
+func (m *SoftwareHSM) ImportKey(key *hdkeystore.HDKey) {
+	m.mu.Lock()
+	m.keys[key.KeyID] = key
+	m.mu.Unlock()
+}
+
+// OpenSession 打开会话
+func (m *SoftwareHSM) OpenSession(slot uint) (HSMSession, error) {
+	return &softwareHSMSession{module: m}, nil
+}
+
+type softwareHSMSession struct {
+	module   *SoftwareHSM
+	loggedIn bool
+}
+
+func (s *softwareHSMSession) Login(pin string) error {
+	if pin != s.module.pin {
+		return fmt.Errorf("incorrect pin")
+	}
+	s.loggedIn = true
+	return nil
+}
+
+func (s *softwareHSMSession) FindKey(label string) (HSMObjectHandle, error) {
+	if !s.loggedIn {
+		return 0, fmt.Errorf("session is not logged in")
+	}
+	m := s.module
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if handle, ok := m.labels[label]; ok {
+		return handle, nil
+	}
+	parts := strings.SplitN(label, ":", 3)
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid key label: %s", label)
+	}
+	key, ok := m.keys[parts[0]]
+	if !ok {
+		return 0, fmt.Errorf("key not found: %s", label)
+	}
+	eccType, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key label: %s", label)
+	}
+	childKey, err := key.DerivedKeyWithPath(parts[2], uint32(eccType))
+	if err != nil {
+		return 0, err
+	}
+	keyBytes, err := childKey.GetPrivateKeyBytes()
+	if err != nil {
+		return 0, err
+	}
+	m.next++
+	m.objects[m.next] = keyBytes
+	m.labels[label] = m.next
+	return m.next, nil
+}
+
+func (s *softwareHSMSession) Sign(key HSMObjectHandle, eccType uint32, message []byte) ([]byte, byte, error) {
+	if !s.loggedIn {
+		return nil, 0, fmt.Errorf("session is not logged in")
+	}
+	s.module.mu.Lock()
+	keyBytes, ok := s.module.objects[key]
+	s.module.mu.Unlock()
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid key handle: %d", key)
+	}
+	return signHash(keyBytes, message, eccType)
+}
+
+func (s *softwareHSMSession) Close() error {
+	s.loggedIn = false
+	return nil
+}
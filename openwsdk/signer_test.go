@@ -0,0 +1,174 @@
+package openwsdk_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+const testDerivedPath = "m/44'/88'/0'/0/0"
+
+func testHDKey(t *testing.T) *hdkeystore.HDKey {
+	seed, err := hdkeystore.GenerateSeed(32)
+	if err != nil {
+		t.Fatalf("GenerateSeed unexpected error: %v", err)
+	}
+	key, err := hdkeystore.NewHDKey(seed, "test", hdkeystore.OpenwCoinTypePath)
+	if err != nil {
+		t.Fatalf("NewHDKey unexpected error: %v", err)
+	}
+	return key
+}
+
+func testKeySignature(key *hdkeystore.HDKey) *openwsdk.KeySignature {
+	return &openwsdk.KeySignature{
+		EccType:     owcrypt.ECC_CURVE_SECP256K1,
+		RSV:         true,
+		Message:     hex.EncodeToString(owcrypt.Hash([]byte("message"), 0, owcrypt.HASH_ALG_SHA256)),
+		DerivedPath: testDerivedPath,
+		WalletID:    key.KeyID,
+	}
+}
+
+func verifyKeySignature(t *testing.T, key *hdkeystore.HDKey, ks *openwsdk.KeySignature) {
+	t.Helper()
+	childKey, err := key.DerivedKeyWithPath(ks.DerivedPath, ks.EccType)
+	if err != nil {
+		t.Fatalf("DerivedKeyWithPath unexpected error: %v", err)
+	}
+	prikey, _ := childKey.GetPrivateKeyBytes()
+	pubkey, _ := owcrypt.GenPubkey(prikey, ks.EccType)
+	message, _ := hex.DecodeString(ks.Message)
+	signature, err := hex.DecodeString(ks.Signature)
+	if err != nil || len(signature) != 65 {
+		t.Fatalf("unexpected signature: %s", ks.Signature)
+	}
+	if owcrypt.Verify(pubkey, nil, message, signature[:64], ks.EccType) != owcrypt.SUCCESS {
+		t.Errorf("signature verify failed")
+	}
+}
+
+func TestHDKeySigner(t *testing.T) {
+	key := testHDKey(t)
+	ks := testKeySignature(key)
+	if err := openwsdk.NewHDKeySigner(key).Sign(context.Background(), ks); err != nil {
+		t.Fatalf("Sign unexpected error: %v", err)
+	}
+	verifyKeySignature(t, key, ks)
+
+	if err := openwsdk.NewHDKeySigner(nil).Sign(context.Background(), ks); err == nil {
+		t.Errorf("Sign expected error with nil key")
+	}
+}
+
+func TestHSMSigner(t *testing.T) {
+	key := testHDKey(t)
+	hsm := openwsdk.NewSoftwareHSM("1234")
+	hsm.ImportKey(key)
+
+	signer := openwsdk.NewHSMSigner(hsm, 0, "1234")
+	defer signer.Close()
+	ks := testKeySignature(key)
+	if err := signer.Sign(context.Background(), ks); err != nil {
+		t.Fatalf("Sign unexpected error: %v", err)
+	}
+	verifyKeySignature(t, key, ks)
+
+	wrongPin := openwsdk.NewHSMSigner(hsm, 0, "0000")
+	if err := wrongPin.Sign(context.Background(), testKeySignature(key)); err == nil {
+		t.Errorf("Sign expected error with incorrect pin")
+	}
+}
+
+func TestSignRawTransactionWithSigner(t *testing.T) {
+	key := testHDKey(t)
+	rawTx := &openwsdk.RawTransaction{
+		Coin: openwsdk.Coin{Symbol: "BTC"},
+		Sid:  "sid-1",
+		Signatures: map[string][]*openwsdk.KeySignature{
+			"A1": {testKeySignature(key)},
+		},
+	}
+
+	var infos []openwsdk.SigningInfo
+	local := openwsdk.NewHDKeySigner(key)
+	signer := openwsdk.SignerFunc(func(ctx context.Context, ks *openwsdk.KeySignature) error {
+		if info, ok := openwsdk.SigningInfoFromContext(ctx); ok {
+			infos = append(infos, *info)
+		}
+		return local.Sign(ctx, ks)
+	})
+	if err := openwsdk.SignRawTransactionWithSigner(context.Background(), rawTx, signer); err != nil {
+		t.Fatalf("SignRawTransactionWithSigner unexpected error: %v", err)
+	}
+	verifyKeySignature(t, key, rawTx.Signatures["A1"][0])
+	if len(infos) != 1 || infos[0].AccountID != "A1" || infos[0].Symbol != "BTC" ||
+		infos[0].Sid != "sid-1" || infos[0].RawTx != rawTx {
+		t.Errorf("unexpected signing info: %+v", infos)
+	}
+}
+
+func TestTrustNodeSigner(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen unexpected error: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	transmit, err := openwsdk.NewTransmitNode(&openwsdk.APINodeConfig{
+		Host:        addr,
+		ConnectType: owtp.Websocket,
+		AppID:       openwsdktest.DefaultAppID,
+		Cert:        owtp.NewRandomCertificate(),
+		TimeoutSEC:  10,
+	})
+	if err != nil {
+		t.Fatalf("NewTransmitNode unexpected error: %v", err)
+	}
+	transmit.Listen()
+	defer transmit.Close()
+
+	key := testHDKey(t)
+	trustNode := openwsdktest.NewTrustNode("trust")
+	defer trustNode.Close()
+	trustNode.ImportKey(key, "12345678")
+
+	signer := &openwsdk.TrustNodeSigner{Transmit: transmit, NodeID: trustNode.NodeID(), Password: "12345678"}
+	if err := signer.Sign(context.Background(), testKeySignature(key)); err == nil {
+		t.Fatalf("Sign expected error when trust node is not connected")
+	}
+
+	for i := 0; ; i++ {
+		if err = trustNode.Connect(addr); err == nil {
+			break
+		}
+		if i == 50 {
+			t.Fatalf("Connect unexpected error: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	ks := testKeySignature(key)
+	ctx := openwsdk.WithSigningInfo(context.Background(), &openwsdk.SigningInfo{AccountID: "A1", Symbol: "BTC"})
+	if err := signer.Sign(ctx, ks); err != nil {
+		t.Fatalf("Sign unexpected error: %v", err)
+	}
+	verifyKeySignature(t, key, ks)
+
+	signer.Password = "wrong"
+	if err := signer.Sign(ctx, testKeySignature(key)); err == nil {
+		t.Errorf("Sign expected error with incorrect password")
+	}
+	if n := trustNode.Calls("signHashViaTrustNode"); n != 2 {
+		t.Errorf("unexpected signHashViaTrustNode calls: %d", n)
+	}
+}
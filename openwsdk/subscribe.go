@@ -1,10 +1,10 @@
 package openwsdk
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/owtp"
+	"github.com/tidwall/gjson"
 )
 
 const (
@@ -55,7 +55,7 @@ func (api *APINode) StopServeNotification(connectType string) {
 	api.node.CloseListener(connectType)
 }
 
-// AddObserver 添加观测者
+// AddObserver 添加观测者，通过ObserverHandler适配为处理所有通知的处理函数
 func (api *APINode) AddObserver(obj OpenwNotificationObject) error {
 	api.mu.Lock()
 
@@ -69,7 +69,7 @@ func (api *APINode) AddObserver(obj OpenwNotificationObject) error {
 		return nil
 	}
 
-	api.observers[obj] = true
+	api.observers[obj] = api.addHandler("", ObserverHandler(obj))
 
 	return nil
 }
@@ -77,157 +77,63 @@ func (api *APINode) AddObserver(obj OpenwNotificationObject) error {
 // RemoveObserver 移除观测者
 func (api *APINode) RemoveObserver(obj OpenwNotificationObject) error {
 	api.mu.Lock()
-	defer api.mu.Unlock()
-
+	registration := api.observers[obj]
 	delete(api.observers, obj)
+	api.mu.Unlock()
+
+	registration.Unsubscribe()
 
 	return nil
 }
 
+// subscribeToAccount 处理余额更新通知
 func (api *APINode) subscribeToAccount(ctx *owtp.Context) {
-	data := ctx.Params()
-
-	var (
-		msg      string
-		accepted bool
-		err      error
-	)
-
-	if ctx.Peer.PID() != api.subscribeInfo.notifierNodeID {
-		ctx.Response(map[string]interface{}{
-			"accepted": false,
-		}, owtp.StatusSuccess, msg)
-		log.Warningf("get balance update notify by unknown notifier NodeID: %s", ctx.Peer.PID())
-		return
-	}
-
-	balance := NewBalance(data)
-	tokenBalance := NewTokenBalance(data.Get("tokenBalance"))
-	subscribeToken := data.Get("subscribeToken").String()
-	for o, _ := range api.observers {
-		accepted, err = o.OpenwBalanceUpdateNotify(balance, tokenBalance, subscribeToken)
-		if err != nil {
-			msg = err.Error()
-			accepted = false
-		}
-		if accepted == false {
-			break
-		}
-	}
-
-	ctx.Response(map[string]interface{}{
-		"accepted": accepted,
-	}, owtp.StatusSuccess, msg)
-
+	api.handleNotification(ctx, SubscribeToAccount, "balance update")
 }
 
 // subscribeToTrade 处理新交易记录通知
 func (api *APINode) subscribeToTrade(ctx *owtp.Context) {
-	data := ctx.Params()
-
-	var msg string
-	var accepted bool
-	var tx Transaction
-
-	if ctx.Peer.PID() != api.subscribeInfo.notifierNodeID {
-		ctx.Response(map[string]interface{}{
-			"accepted": false,
-		}, owtp.StatusSuccess, msg)
-		log.Warningf("get transaction notify by unknown notifier NodeID: %s", ctx.Peer.PID())
-		return
-	}
-
-	subscribeToken := data.Get("subscribeToken").String()
-	err := json.Unmarshal([]byte(data.Raw), &tx)
-	if err != nil {
-		accepted = false
-	} else {
-		for o, _ := range api.observers {
-			accepted, err = o.OpenwNewTransactionNotify(&tx, subscribeToken)
-			if err != nil {
-				msg = err.Error()
-				accepted = false
-			}
-			if accepted == false {
-				break
-			}
-		}
-	}
-
-	ctx.Response(map[string]interface{}{
-		"accepted": accepted,
-	}, owtp.StatusSuccess, msg)
+	api.handleNotification(ctx, SubscribeToTrade, "transaction")
 }
 
 // subscribeToBlock 处理新区块头通知
 func (api *APINode) subscribeToBlock(ctx *owtp.Context) {
-	data := ctx.Params()
-
-	var msg string
-	var accepted bool
-	var header BlockHeader
-
-	if ctx.Peer.PID() != api.subscribeInfo.notifierNodeID {
-		ctx.Response(map[string]interface{}{
-			"accepted": false,
-		}, owtp.StatusSuccess, msg)
-		log.Warningf("get new block notify by unknown notifier NodeID: %s", ctx.Peer.PID())
-		return
-	}
-
-	subscribeToken := data.Get("subscribeToken").String()
-	err := json.Unmarshal([]byte(data.Raw), &header)
-	if err != nil {
-		accepted = false
-	} else {
-		for o, _ := range api.observers {
-			accepted, err = o.OpenwNewBlockNotify(&header, subscribeToken)
-			if err != nil {
-				msg = err.Error()
-				accepted = false
-			}
-			if accepted == false {
-				break
-			}
-		}
-	}
-
-	ctx.Response(map[string]interface{}{
-		"accepted": accepted,
-	}, owtp.StatusSuccess, msg)
+	api.handleNotification(ctx, SubscribeToBlock, "new block")
 }
 
 // subscribeToSmartContractReceipt 处理新智能合约交易回执通知
 func (api *APINode) subscribeToSmartContractReceipt(ctx *owtp.Context) {
-	data := ctx.Params()
+	api.handleNotification(ctx, SubscribeToSmartContractReceipt, "smart contract receipt")
+}
 
-	var msg string
-	var accepted bool
-	var receipt SmartContractReceipt
+// subscribeToNFTTransfer 处理NFT相关交易记录通知
+func (api *APINode) subscribeToNFTTransfer(ctx *owtp.Context) {
+	api.handleNotification(ctx, SubscribeToNFTTransfer, "nft transfer")
+}
 
+// handleNotification 校验通知者身份，有收件箱时先持久化再分发给观察者
+func (api *APINode) handleNotification(ctx *owtp.Context, method, name string) {
 	if ctx.Peer.PID() != api.subscribeInfo.notifierNodeID {
 		ctx.Response(map[string]interface{}{
 			"accepted": false,
-		}, owtp.StatusSuccess, msg)
-		log.Warningf("get smart contract receipt notify by unknown notifier NodeID: %s", ctx.Peer.PID())
+		}, owtp.StatusSuccess, "")
+		log.Warningf("get %s notify by unknown notifier NodeID: %s", name, ctx.Peer.PID())
 		return
 	}
 
-	subscribeToken := data.Get("subscribeToken").String()
-	err := json.Unmarshal([]byte(data.Raw), &receipt)
-	if err != nil {
-		accepted = false
+	var (
+		msg      string
+		accepted bool
+		err      error
+	)
+
+	if inbox := api.NotificationInbox(); inbox != nil {
+		accepted, err = api.receiveToInbox(inbox, method, ctx.Params())
 	} else {
-		for o, _ := range api.observers {
-			accepted, err = o.OpenwNewSmartContractReceiptNotify(&receipt, subscribeToken)
-			if err != nil {
-				msg = err.Error()
-				accepted = false
-			}
-			if accepted == false {
-				break
-			}
-		}
+		accepted, err = api.dispatchNotification(method, ctx.Params())
+	}
+	if err != nil {
+		msg = err.Error()
 	}
 
 	ctx.Response(map[string]interface{}{
@@ -235,44 +141,19 @@ func (api *APINode) subscribeToSmartContractReceipt(ctx *owtp.Context) {
 	}, owtp.StatusSuccess, msg)
 }
 
-func (api *APINode) checkNodeIsOnline(ctx *owtp.Context) {
-	ctx.Response(nil, owtp.StatusSuccess, "success")
-}
-
-// subscribeToNFTTransfer 处理NFT相关交易记录通知
-func (api *APINode) subscribeToNFTTransfer(ctx *owtp.Context) {
-	data := ctx.Params()
-
-	var msg string
-	var accepted bool
-	var tx NFTTransfer
-
-	if ctx.Peer.PID() != api.subscribeInfo.notifierNodeID {
-		ctx.Response(map[string]interface{}{
-			"accepted": false,
-		}, owtp.StatusSuccess, msg)
-		log.Warningf("get transaction notify by unknown notifier NodeID: %s", ctx.Peer.PID())
-		return
-	}
-
-	subscribeToken := data.Get("subscribeToken").String()
-	err := json.Unmarshal([]byte(data.Raw), &tx)
+// dispatchNotification 解析通知数据，交给处理函数，按接收策略返回是否接收
+func (api *APINode) dispatchNotification(method string, data gjson.Result) (bool, error) {
+	n, err := decodeNotification(method, data)
 	if err != nil {
-		accepted = false
-	} else {
-		for o, _ := range api.observers {
-			accepted, err = o.OpenwNFTTransferNotify(&tx, subscribeToken)
-			if err != nil {
-				msg = err.Error()
-				accepted = false
-			}
-			if accepted == false {
-				break
-			}
-		}
+		return false, err
 	}
+	result := api.dispatch(n)
+	if result.Accepted {
+		return true, nil
+	}
+	return false, result.Err()
+}
 
-	ctx.Response(map[string]interface{}{
-		"accepted": accepted,
-	}, owtp.StatusSuccess, msg)
+func (api *APINode) checkNodeIsOnline(ctx *owtp.Context) {
+	ctx.Response(nil, owtp.StatusSuccess, "success")
 }
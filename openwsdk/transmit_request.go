@@ -0,0 +1,294 @@
+package openwsdk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// CreateWalletRequest 托管节点创建钱包的参数
+type CreateWalletRequest struct {
+	Alias    string `json:"alias"`    //@required 钱包别名
+	Password string `json:"password"` //@required 钱包解锁密码
+}
+
+// Validate 检查必填字段
+func (req *CreateWalletRequest) Validate() error {
+	v := newRequestValidator("CreateWalletRequest", req == nil)
+	if req != nil {
+		v.required("alias", req.Alias)
+		v.required("password", req.Password)
+	}
+	return v.err()
+}
+
+// CreateAccountRequest 托管节点创建账户的参数
+type CreateAccountRequest struct {
+	WalletID string `json:"walletID"` //@required 钱包ID
+	Alias    string `json:"alias"`    //@required 账户别名
+	Password string `json:"password"` //可选 钱包解锁密码
+	Symbol   string `json:"symbol"`   //@required 主链标识
+}
+
+// Validate 检查必填字段
+func (req *CreateAccountRequest) Validate() error {
+	v := newRequestValidator("CreateAccountRequest", req == nil)
+	if req != nil {
+		v.required("walletID", req.WalletID)
+		v.required("alias", req.Alias)
+		v.required("symbol", req.Symbol)
+	}
+	return v.err()
+}
+
+// SendTransactionRequest 托管节点转账的参数
+type SendTransactionRequest struct {
+	WalletID        string `json:"walletID"`        //可选 钱包ID，Password为空时通过SecretProvider获取该钱包的密码
+	AccountID       string `json:"accountID"`       //@required 账户ID
+	Password        string `json:"password"`        //可选 钱包解锁密码
+	Sid             string `json:"sid"`             //@required 业务订单号
+	Symbol          string `json:"symbol"`          //@required 主链标识
+	ContractAddress string `json:"contractAddress"` //可选 代币合约地址
+	Amount          string `json:"amount"`          //@required 转账数量，必须大于0
+	Address         string `json:"address"`         //@required 目标地址
+	FeeRate         string `json:"feeRate"`         //可选 自定义手续费率
+	Memo            string `json:"memo"`            //可选 备注
+	ExtParam        string `json:"extParam"`        //可选 扩展参数，json字符串
+}
+
+// Validate 检查必填字段，数量和手续费率必须是数字
+func (req *SendTransactionRequest) Validate() error {
+	v := newRequestValidator("SendTransactionRequest", req == nil)
+	if req != nil {
+		v.required("accountID", req.AccountID)
+		v.required("sid", req.Sid)
+		v.required("symbol", req.Symbol)
+		v.required("address", req.Address)
+		v.amount("amount", req.Amount, true)
+		v.amount("feeRate", req.FeeRate, false)
+		if len(req.Address) > 0 && req.Address == req.Amount {
+			v.problem("address must not equal amount")
+		}
+	}
+	return v.err()
+}
+
+// SignTransactionRequest 托管节点签名交易单的参数
+type SignTransactionRequest struct {
+	WalletID string          `json:"walletID"` //@required 钱包ID
+	RawTx    *RawTransaction `json:"rawTx"`    //@required 待签名的交易单
+	Password string          `json:"password"` //可选 钱包解锁密码
+}
+
+// Validate 检查必填字段
+func (req *SignTransactionRequest) Validate() error {
+	v := newRequestValidator("SignTransactionRequest", req == nil)
+	if req != nil {
+		v.required("walletID", req.WalletID)
+		if req.RawTx == nil {
+			v.problem("rawTx is required")
+		}
+	}
+	return v.err()
+}
+
+// TriggerABIRequest 托管节点触发ABI上链调用的参数
+type TriggerABIRequest struct {
+	WalletID        string   `json:"walletID"`        //可选 钱包ID，Password为空时通过SecretProvider获取该钱包的密码
+	AccountID       string   `json:"accountID"`       //@required 账户ID
+	Password        string   `json:"password"`        //可选 钱包解锁密码
+	Sid             string   `json:"sid"`             //@required 业务订单号
+	Symbol          string   `json:"symbol"`          //@required 主链标识
+	ContractAddress string   `json:"contractAddress"` //@required 合约地址
+	ContractABI     string   `json:"contractABI"`     //可选 ABI定义
+	Amount          string   `json:"amount"`          //可选 主币数量
+	FeeRate         string   `json:"feeRate"`         //可选 自定义手续费率
+	ABIParam        []string `json:"abiParam"`        //可选 ABI参数组
+	Raw             string   `json:"raw"`             //可选 原始交易单
+	RawType         uint64   `json:"rawType"`         //可选 原始交易单编码类型，0：hex字符串，1：json字符串，2：base64字符串
+	AwaitResult     bool     `json:"awaitResult"`     //可选 是否等待上链结果
+}
+
+// Validate 检查必填字段，数量和手续费率必须是数字
+func (req *TriggerABIRequest) Validate() error {
+	v := newRequestValidator("TriggerABIRequest", req == nil)
+	if req != nil {
+		v.required("accountID", req.AccountID)
+		v.required("sid", req.Sid)
+		v.required("symbol", req.Symbol)
+		v.required("contractAddress", req.ContractAddress)
+		v.amount("amount", req.Amount, false)
+		v.amount("feeRate", req.FeeRate, false)
+		if req.RawType > 2 {
+			v.problem(fmt.Sprintf("rawType %d must be 0, 1 or 2", req.RawType))
+		}
+	}
+	return v.err()
+}
+
+// SignHashRequest 托管节点签名哈希消息的参数
+type SignHashRequest struct {
+	WalletID  string `json:"walletID"`  //@required 钱包ID
+	AccountID string `json:"accountID"` //@required 账户ID
+	Address   string `json:"address"`   //@required 签名使用的地址
+	Message   string `json:"message"`   //@required 哈希消息，hex编码
+	Password  string `json:"password"`  //可选 钱包解锁密码
+	Symbol    string `json:"symbol"`    //可选 主链标识
+	HDPath    string `json:"hdPath"`    //可选 子密钥路径
+	RSV       bool   `json:"rsv"`       //是否rsv模式
+}
+
+// Validate 检查必填字段
+func (req *SignHashRequest) Validate() error {
+	v := newRequestValidator("SignHashRequest", req == nil)
+	if req != nil {
+		v.required("walletID", req.WalletID)
+		v.required("accountID", req.AccountID)
+		v.required("address", req.Address)
+		v.required("message", req.Message)
+	}
+	return v.err()
+}
+
+// StartSummaryTaskRequest 托管节点启动汇总任务的参数
+type StartSummaryTaskRequest struct {
+	CycleSec    int          `json:"cycleSec"`    //@required 任务周期间隔，必须大于0
+	SummaryTask *SummaryTask `json:"summaryTask"` //@required 汇总任务
+	OperateType int          `json:"operateType"` //操作类型：SummaryTaskOperateTypeReset或SummaryTaskOperateTypeAdd
+}
+
+// Validate 检查必填字段
+func (req *StartSummaryTaskRequest) Validate() error {
+	v := newRequestValidator("StartSummaryTaskRequest", req == nil)
+	if req != nil {
+		if req.CycleSec <= 0 {
+			v.problem(fmt.Sprintf("cycleSec %d must be positive", req.CycleSec))
+		}
+		if req.SummaryTask == nil {
+			v.problem("summaryTask is required")
+		}
+		if req.OperateType != SummaryTaskOperateTypeReset && req.OperateType != SummaryTaskOperateTypeAdd {
+			v.problem(fmt.Sprintf("operateType %d is unknown", req.OperateType))
+		}
+	}
+	return v.err()
+}
+
+// CreateWalletViaTrustNodeWithRequest 校验参数后调用CreateWalletViaTrustNode
+func (transmit *TransmitNode) CreateWalletViaTrustNodeWithRequest(nodeID string, req *CreateWalletRequest,
+	sync bool, reqFunc func(status uint64, msg string, wallet *Wallet)) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	return transmit.CreateWalletViaTrustNode(nodeID, req.Alias, req.Password, sync, reqFunc)
+}
+
+// CreateAccountViaTrustNodeWithRequest 校验参数后调用CreateAccountViaTrustNode
+func (transmit *TransmitNode) CreateAccountViaTrustNodeWithRequest(nodeID string, req *CreateAccountRequest,
+	sync bool, reqFunc func(status uint64, msg string, account *Account, addresses []*Address)) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	return transmit.CreateAccountViaTrustNode(nodeID, req.WalletID, req.Alias, req.Password, req.Symbol, sync, reqFunc)
+}
+
+// SendTransactionViaTrustNodeWithRequest 校验参数后调用SendTransactionViaTrustNode
+func (transmit *TransmitNode) SendTransactionViaTrustNodeWithRequest(nodeID string, req *SendTransactionRequest,
+	sync bool, reqFunc func(status uint64, msg string, successTx []*Transaction, failedRawTxs []*FailedRawTransaction)) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	return transmit.sendTransactionViaTrustNode(nodeID, req.WalletID, req.AccountID, req.Password, req.Sid, req.Symbol,
+		req.ContractAddress, req.Amount, req.Address, req.FeeRate, req.Memo, req.ExtParam, sync, reqFunc)
+}
+
+// SignTransactionViaTrustNodeWithRequest 校验参数后调用SignTransactionViaTrustNode
+func (transmit *TransmitNode) SignTransactionViaTrustNodeWithRequest(nodeID string, req *SignTransactionRequest,
+	sync bool, reqFunc func(status uint64, msg string, signedRawTx *RawTransaction)) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	return transmit.SignTransactionViaTrustNode(nodeID, req.WalletID, req.RawTx, req.Password, sync, reqFunc)
+}
+
+// TriggerABIViaTrustNodeWithRequest 校验参数后调用TriggerABIViaTrustNode
+func (transmit *TransmitNode) TriggerABIViaTrustNodeWithRequest(nodeID string, req *TriggerABIRequest,
+	sync bool, reqFunc func(status uint64, msg string, receipt *SmartContractReceipt)) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	return transmit.triggerABIViaTrustNode(nodeID, req.WalletID, req.AccountID, req.Password, req.Sid, req.Symbol,
+		req.ContractAddress, req.ContractABI, req.Amount, req.FeeRate, req.ABIParam, req.Raw, req.RawType,
+		req.AwaitResult, sync, reqFunc)
+}
+
+// SignHashViaTrustNodeWithRequest 校验参数后调用SignHashViaTrustNode
+func (transmit *TransmitNode) SignHashViaTrustNodeWithRequest(nodeID string, req *SignHashRequest,
+	sync bool, reqFunc func(status uint64, msg string, signature string)) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	return transmit.SignHashViaTrustNode(nodeID, req.WalletID, req.AccountID, req.Address, req.Message,
+		req.Password, req.Symbol, req.HDPath, req.RSV, sync, reqFunc)
+}
+
+// StartSummaryTaskViaTrustNodeWithRequest 校验参数后调用StartSummaryTaskViaTrustNode
+func (transmit *TransmitNode) StartSummaryTaskViaTrustNodeWithRequest(nodeID string, req *StartSummaryTaskRequest,
+	sync bool, reqFunc func(status uint64, msg string)) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	return transmit.StartSummaryTaskViaTrustNode(nodeID, req.CycleSec, req.SummaryTask, req.OperateType, sync, reqFunc)
+}
+
+// requestValidator 收集请求参数的所有问题
+type requestValidator struct {
+	name     string
+	problems []string
+}
+
+func newRequestValidator(name string, isNil bool) *requestValidator {
+	v := &requestValidator{name: name}
+	if isNil {
+		v.problem("request is nil")
+	}
+	return v
+}
+
+func (v *requestValidator) problem(p string) {
+	v.problems = append(v.problems, p)
+}
+
+func (v *requestValidator) required(field, value string) {
+	if len(strings.TrimSpace(value)) == 0 {
+		v.problem(field + " is required")
+	}
+}
+
+// amount 数量必须是数字，必填时必须大于0，选填时不能为负数
+func (v *requestValidator) amount(field, value string, required bool) {
+	if len(value) == 0 {
+		if required {
+			v.problem(field + " is required")
+		}
+		return
+	}
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		v.problem(fmt.Sprintf("%s %q is not a number", field, value))
+		return
+	}
+	if required && !d.IsPositive() {
+		v.problem(fmt.Sprintf("%s %s must be positive", field, value))
+	} else if d.IsNegative() {
+		v.problem(fmt.Sprintf("%s %s must not be negative", field, value))
+	}
+}
+
+func (v *requestValidator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid %s: %s", v.name, strings.Join(v.problems, "; "))
+}
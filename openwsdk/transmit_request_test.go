@@ -0,0 +1,110 @@
+package openwsdk_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func TestTransmitRequest_Validate(t *testing.T) {
+	send := func() *openwsdk.SendTransactionRequest {
+		return &openwsdk.SendTransactionRequest{
+			AccountID: "A1",
+			Sid:       "sid-1",
+			Symbol:    "BTC",
+			Amount:    "0.1",
+			Address:   "1xyz",
+		}
+	}
+	cases := []struct {
+		name    string
+		req     interface{ Validate() error }
+		problem string
+	}{
+		{"valid send", send(), ""},
+		{"nil send", (*openwsdk.SendTransactionRequest)(nil), "request is nil"},
+		{"swapped amount and address", func() *openwsdk.SendTransactionRequest {
+			r := send()
+			r.Amount, r.Address = r.Address, r.Amount
+			return r
+		}(), `amount "1xyz" is not a number`},
+		{"zero amount", func() *openwsdk.SendTransactionRequest {
+			r := send()
+			r.Amount = "0"
+			return r
+		}(), "amount 0 must be positive"},
+		{"missing sid", func() *openwsdk.SendTransactionRequest {
+			r := send()
+			r.Sid = ""
+			return r
+		}(), "sid is required"},
+		{"negative fee rate", func() *openwsdk.SendTransactionRequest {
+			r := send()
+			r.FeeRate = "-1"
+			return r
+		}(), "feeRate -1 must not be negative"},
+		{"valid trigger", &openwsdk.TriggerABIRequest{AccountID: "A1", Sid: "s", Symbol: "ETH", ContractAddress: "0x1", ABIParam: []string{"transfer"}}, ""},
+		{"trigger raw type", &openwsdk.TriggerABIRequest{AccountID: "A1", Sid: "s", Symbol: "ETH", ContractAddress: "0x1", RawType: 3}, "rawType 3"},
+		{"trigger contract", &openwsdk.TriggerABIRequest{AccountID: "A1", Sid: "s", Symbol: "ETH"}, "contractAddress is required"},
+		{"valid sign hash", &openwsdk.SignHashRequest{WalletID: "W1", AccountID: "A1", Address: "1xyz", Message: "00"}, ""},
+		{"sign hash message", &openwsdk.SignHashRequest{WalletID: "W1", AccountID: "A1", Address: "1xyz"}, "message is required"},
+		{"sign transaction", &openwsdk.SignTransactionRequest{WalletID: "W1"}, "rawTx is required"},
+		{"create wallet", &openwsdk.CreateWalletRequest{Alias: "w"}, "password is required"},
+		{"create account", &openwsdk.CreateAccountRequest{WalletID: "W1", Alias: "a"}, "symbol is required"},
+		{"summary cycle", &openwsdk.StartSummaryTaskRequest{SummaryTask: &openwsdk.SummaryTask{}}, "cycleSec 0 must be positive"},
+		{"summary operate", &openwsdk.StartSummaryTaskRequest{CycleSec: 60, SummaryTask: &openwsdk.SummaryTask{}, OperateType: 5}, "operateType 5"},
+	}
+	for _, c := range cases {
+		err := c.req.Validate()
+		if len(c.problem) == 0 {
+			if err != nil {
+				t.Errorf("%s: Validate unexpected error: %v", c.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.problem) {
+			t.Errorf("%s: Validate expected %q, got: %v", c.name, c.problem, err)
+		}
+	}
+}
+
+func TestTransmitNode_SendTransactionWithRequest(t *testing.T) {
+	transmit, addr := testTransmitNode(t)
+	defer transmit.Close()
+	trustNode := openwsdktest.NewTrustNode("trust")
+	defer trustNode.Close()
+	testConnectTrustNode(t, trustNode, addr)
+
+	req := &openwsdk.SendTransactionRequest{
+		AccountID: "A1",
+		Sid:       "sid-1",
+		Symbol:    "BTC",
+		Amount:    "1xyz",
+		Address:   "0.1",
+	}
+	noop := func(uint64, string, []*openwsdk.Transaction, []*openwsdk.FailedRawTransaction) {}
+	if err := transmit.SendTransactionViaTrustNodeWithRequest(trustNode.NodeID(), req, true, noop); err == nil {
+		t.Fatalf("SendTransactionViaTrustNodeWithRequest expected validation error")
+	}
+	if n := trustNode.Calls("sendTransactionViaTrustNode"); n != 0 {
+		t.Fatalf("invalid request expected not to be sent, got %d calls", n)
+	}
+
+	req.Amount, req.Address = req.Address, req.Amount
+	var tx *openwsdk.Transaction
+	err := transmit.SendTransactionViaTrustNodeWithRequest(trustNode.NodeID(), req, true,
+		func(status uint64, msg string, successTx []*openwsdk.Transaction, failedRawTxs []*openwsdk.FailedRawTransaction) {
+			if status == owtp.StatusSuccess && len(successTx) == 1 {
+				tx = successTx[0]
+			}
+		})
+	if err != nil {
+		t.Fatalf("SendTransactionViaTrustNodeWithRequest unexpected error: %v", err)
+	}
+	if tx == nil || tx.Amount != "0.1" || tx.ToAddress[0] != "1xyz" || tx.Sid != "sid-1" {
+		t.Errorf("unexpected transaction: %+v", tx)
+	}
+}
@@ -0,0 +1,410 @@
+package openwsdk
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+// MaxTrustNodeHistory 每个托管节点保留的上下线记录条数
+const MaxTrustNodeHistory = 100
+
+// ErrNoTrustNode 没有可用的托管节点持有该钱包
+var ErrNoTrustNode = errors.New("no trust node available")
+
+// TrustNodeEvent 托管节点上下线记录
+type TrustNodeEvent struct {
+	Online bool      `json:"online"`
+	Time   time.Time `json:"time"`
+}
+
+// TrustNodeStatus 托管节点在注册表中的状态
+type TrustNodeStatus struct {
+	Info            TrustNodeInfo    `json:"info"`
+	Online          bool             `json:"online"`
+	JoinedAt        time.Time        `json:"joinedAt"`        //首次加入时间
+	History         []TrustNodeEvent `json:"history"`         //上下线记录，最多保留MaxTrustNodeHistory条
+	Wallets         []string         `json:"wallets"`         //节点持有的钱包ID
+	WalletsSyncedAt time.Time        `json:"walletsSyncedAt"` //最近一次同步钱包列表的时间
+	Health          TrustNodeHealth  `json:"health"`          //健康状况
+}
+
+// trustNodeEntry 注册表中的节点，seq为首次加入的顺序
+type trustNodeEntry struct {
+	status TrustNodeStatus
+	seq    uint64
+}
+
+// TrustNodeRegistry 托管节点注册表，记录加入TransmitNode的节点信息、上下线记录和持有的钱包。
+// 节点加入时自动同步钱包列表，按钱包ID选择节点时只选择可以接收请求的节点，按首次加入顺序排列。
+type TrustNodeRegistry struct {
+	mu           sync.RWMutex
+	transmit     *TransmitNode
+	nodes        map[string]*trustNodeEntry
+	seq          uint64
+	now          func() time.Time
+	healthConfig *HealthCheckConfig //健康检查配置，为空时未开启
+	healthStop   chan struct{}      //停止健康检查
+}
+
+func newTrustNodeRegistry(transmit *TransmitNode) *TrustNodeRegistry {
+	return &TrustNodeRegistry{
+		transmit: transmit,
+		nodes:    make(map[string]*trustNodeEntry),
+		now:      time.Now,
+	}
+}
+
+// Registry 托管节点注册表
+func (transmit *TransmitNode) Registry() *TrustNodeRegistry {
+	if transmit == nil {
+		return nil
+	}
+	return transmit.registry
+}
+
+// join 记录节点加入，已记录的节点更新节点信息
+func (r *TrustNodeRegistry) join(nodeID string, info *TrustNodeInfo) {
+	r.mu.Lock()
+	entry, ok := r.nodes[nodeID]
+	if !ok {
+		r.seq++
+		entry = &trustNodeEntry{seq: r.seq}
+		entry.status.JoinedAt = r.now()
+		r.nodes[nodeID] = entry
+	}
+	if info != nil {
+		entry.status.Info = *info
+	}
+	entry.status.Info.NodeID = nodeID
+	r.recordLocked(entry, true)
+	transition := r.updateHealthLocked(entry, func(h *TrustNodeHealth) {
+		*h = TrustNodeHealth{NodeID: nodeID, State: TrustNodeHealthy, LastOK: r.now()}
+	})
+	r.mu.Unlock()
+	r.notify(nodeID, transition)
+}
+
+// leave 记录节点断开
+func (r *TrustNodeRegistry) leave(nodeID string) {
+	r.mu.Lock()
+	var transition *healthTransition
+	if entry, ok := r.nodes[nodeID]; ok {
+		r.recordLocked(entry, false)
+		transition = r.updateHealthLocked(entry, func(h *TrustNodeHealth) {
+			h.State = TrustNodeOffline
+		})
+	}
+	r.mu.Unlock()
+	r.notify(nodeID, transition)
+}
+
+func (r *TrustNodeRegistry) recordLocked(entry *trustNodeEntry, online bool) {
+	entry.status.Online = online
+	entry.status.History = append(entry.status.History, TrustNodeEvent{Online: online, Time: r.now()})
+	if n := len(entry.status.History); n > MaxTrustNodeHistory {
+		entry.status.History = append([]TrustNodeEvent(nil), entry.status.History[n-MaxTrustNodeHistory:]...)
+	}
+}
+
+// setWallets 覆盖节点持有的钱包
+func (r *TrustNodeRegistry) setWallets(nodeID string, walletIDs []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.nodes[nodeID]; ok {
+		entry.status.Wallets = walletIDs
+		entry.status.WalletsSyncedAt = r.now()
+	}
+}
+
+// addWallet 节点创建钱包后加入钱包列表
+func (r *TrustNodeRegistry) addWallet(nodeID, walletID string) {
+	if len(walletID) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.nodes[nodeID]
+	if !ok {
+		return
+	}
+	for _, id := range entry.status.Wallets {
+		if id == walletID {
+			return
+		}
+	}
+	entry.status.Wallets = append(entry.status.Wallets, walletID)
+}
+
+// RefreshWallets 通过GetLocalWalletListViaTrustNode同步节点持有的钱包
+func (r *TrustNodeRegistry) RefreshWallets(nodeID string) error {
+	if r == nil {
+		return fmt.Errorf("TrustNodeRegistry is not inited")
+	}
+	var (
+		walletIDs []string
+		callErr   error
+	)
+	err := r.transmit.GetLocalWalletListViaTrustNode(nodeID, true, func(status uint64, msg string, wallets []*Wallet) {
+		if status != owtp.StatusSuccess {
+			callErr = NewError("getLocalWalletListViaTrustNode", status, msg, nil)
+			return
+		}
+		walletIDs = make([]string, 0, len(wallets))
+		for _, w := range wallets {
+			walletIDs = append(walletIDs, w.WalletID)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if callErr != nil {
+		return callErr
+	}
+	r.setWallets(nodeID, walletIDs)
+	return nil
+}
+
+// Node 节点状态
+func (r *TrustNodeRegistry) Node(nodeID string) (*TrustNodeStatus, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.nodes[nodeID]
+	if !ok {
+		return nil, false
+	}
+	return entry.snapshot(), true
+}
+
+// Nodes 所有记录过的节点，按首次加入顺序排列
+func (r *TrustNodeRegistry) Nodes() []*TrustNodeStatus {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := r.sortedLocked(func(*trustNodeEntry) bool { return true })
+	nodes := make([]*TrustNodeStatus, 0, len(entries))
+	for _, entry := range entries {
+		nodes = append(nodes, entry.snapshot())
+	}
+	return nodes
+}
+
+// NodesForWallet 持有钱包且可以接收请求的节点ID，按首次加入顺序排列。
+// 不包括断开、degraded和开启健康检查后超过MaxResponseAge未应答的节点。
+func (r *TrustNodeRegistry) NodesForWallet(walletID string) []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	now := r.now()
+	entries := r.sortedLocked(func(entry *trustNodeEntry) bool {
+		if !entry.status.Online || !r.routableLocked(entry, now) {
+			return false
+		}
+		for _, id := range entry.status.Wallets {
+			if id == walletID {
+				return true
+			}
+		}
+		return false
+	})
+	r.mu.RUnlock()
+
+	nodeIDs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		nodeID := entry.status.Info.NodeID
+		//注册表可能还未收到断开通知
+		if r.transmit.node.GetOnlinePeer(nodeID) == nil {
+			continue
+		}
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	return nodeIDs
+}
+
+func (r *TrustNodeRegistry) sortedLocked(match func(entry *trustNodeEntry) bool) []*trustNodeEntry {
+	entries := make([]*trustNodeEntry, 0, len(r.nodes))
+	for _, entry := range r.nodes {
+		if match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+	return entries
+}
+
+func (entry *trustNodeEntry) snapshot() *TrustNodeStatus {
+	s := entry.status
+	s.History = append([]TrustNodeEvent(nil), entry.status.History...)
+	s.Wallets = append([]string(nil), entry.status.Wallets...)
+	return &s
+}
+
+// Route 依次在持有钱包的在线节点上执行fn，请求没有发送到节点时切换到下一个节点。
+// 请求已发送后的任何错误（包括超时和连接断开）都直接返回，避免重复执行转账。
+func (r *TrustNodeRegistry) Route(walletID string, fn func(nodeID string) error) error {
+	if r == nil {
+		return fmt.Errorf("TrustNodeRegistry is not inited")
+	}
+	nodeIDs := r.NodesForWallet(walletID)
+	if len(nodeIDs) == 0 {
+		return fmt.Errorf("wallet %s: %w", walletID, ErrNoTrustNode)
+	}
+	var err error
+	for _, nodeID := range nodeIDs {
+		err = fn(nodeID)
+		if err == nil || !isUndelivered(err) {
+			return err
+		}
+		log.Warningf("wallet %s: trust node %s is unavailable: %v", walletID, nodeID, err)
+	}
+	return err
+}
+
+// undeliveredError 请求确定没有发送到对端：节点不在线、建立连接失败或数据发出前失败
+type undeliveredError struct {
+	NodeID string
+	Err    error
+}
+
+func (e *undeliveredError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *undeliveredError) Unwrap() error {
+	return e.Err
+}
+
+// isUndelivered 请求是否确定没有发送到节点，其他错误都视为节点可能已处理
+func isUndelivered(err error) bool {
+	var e *undeliveredError
+	return errors.As(err, &e)
+}
+
+// ErrUnknownOutcome 请求已发送但连接断开或响应异常，无法确定对方节点是否已处理
+var ErrUnknownOutcome = errors.New("request outcome unknown")
+
+// UnknownOutcomeError 请求发送后连接断开或响应异常，节点可能已经执行。
+// 通过交易记录确认之前，不要换节点或换新的sid重新发起。
+type UnknownOutcomeError struct {
+	Method string
+	NodeID string
+	Sid    string //sendTransactionViaTrustNode的业务订单号
+	Err    error  //owtp返回的网络断开或HTTP请求错误
+}
+
+// Error 实现error接口
+func (e *UnknownOutcomeError) Error() string {
+	msg := fmt.Sprintf("%s to node %s: outcome unknown", e.Method, e.NodeID)
+	if len(e.Sid) > 0 {
+		msg += fmt.Sprintf(", unresolved sid: %s", e.Sid)
+	}
+	return msg + fmt.Sprintf(": %v", e.Err)
+}
+
+// Is 匹配ErrUnknownOutcome
+func (e *UnknownOutcomeError) Is(target error) bool {
+	return target == ErrUnknownOutcome
+}
+
+// Unwrap 返回owtp的错误
+func (e *UnknownOutcomeError) Unwrap() error {
+	return e.Err
+}
+
+// checkOnline 节点不在线时返回undeliveredError，可以切换到其他节点
+func (transmit *TransmitNode) checkOnline(nodeID string) error {
+	if transmit.node.GetOnlinePeer(nodeID) == nil {
+		return &undeliveredError{NodeID: nodeID, Err: fmt.Errorf("Node ID: %s is not connected ", nodeID)}
+	}
+	return nil
+}
+
+// SendTransactionFromWallet 由持有钱包的托管节点创建转账交易，请求没有发送到节点时切换到其他持有该钱包的节点。
+// 请求发送后连接断开返回*UnknownOutcomeError，不会在其他节点重新发起。
+func (transmit *TransmitNode) SendTransactionFromWallet(
+	walletID string,
+	accountID string,
+	password string,
+	sid string,
+	symbol string,
+	contractAddress string,
+	amount string,
+	address string,
+	feeRate string,
+	memo string,
+	extParam string,
+	reqFunc func(nodeID string, status uint64, msg string, successTx []*Transaction, failedRawTxs []*FailedRawTransaction),
+) error {
+	if transmit == nil {
+		return fmt.Errorf("TransmitNode is not inited")
+	}
+	return transmit.registry.Route(walletID, func(nodeID string) error {
+		if err := transmit.checkOnline(nodeID); err != nil {
+			return err
+		}
+		var callErr error
+		err := transmit.sendTransactionViaTrustNode(nodeID, walletID, accountID, password, sid, symbol, contractAddress,
+			amount, address, feeRate, memo, extParam, true,
+			func(status uint64, msg string, successTx []*Transaction, failedRawTxs []*FailedRawTransaction) {
+				if status == owtp.ErrNetworkDisconnected {
+					callErr = &UnknownOutcomeError{Method: "sendTransactionViaTrustNode", NodeID: nodeID, Sid: sid,
+						Err: NewError("sendTransactionViaTrustNode", status, msg, nil)}
+					return
+				}
+				reqFunc(nodeID, status, msg, successTx, failedRawTxs)
+			})
+		var unknown *UnknownOutcomeError
+		if errors.As(err, &unknown) {
+			unknown.Sid = sid
+		}
+		if err != nil {
+			return err
+		}
+		return callErr
+	})
+}
+
+// SignTransactionFromWallet 由持有钱包的托管节点签名交易单，请求没有发送到节点时切换到其他持有该钱包的节点。
+// 请求发送后连接断开返回*UnknownOutcomeError。
+func (transmit *TransmitNode) SignTransactionFromWallet(
+	walletID string,
+	rawTx *RawTransaction,
+	password string,
+	reqFunc func(nodeID string, status uint64, msg string, signedRawTx *RawTransaction),
+) error {
+	if transmit == nil {
+		return fmt.Errorf("TransmitNode is not inited")
+	}
+	return transmit.registry.Route(walletID, func(nodeID string) error {
+		if err := transmit.checkOnline(nodeID); err != nil {
+			return err
+		}
+		var callErr error
+		err := transmit.SignTransactionViaTrustNode(nodeID, walletID, rawTx, password, true,
+			func(status uint64, msg string, signedRawTx *RawTransaction) {
+				if status == owtp.ErrNetworkDisconnected {
+					callErr = &UnknownOutcomeError{Method: "signTransactionViaTrustNode", NodeID: nodeID,
+						Err: NewError("signTransactionViaTrustNode", status, msg, nil)}
+					return
+				}
+				reqFunc(nodeID, status, msg, signedRawTx)
+			})
+		if err != nil {
+			return err
+		}
+		return callErr
+	})
+}
@@ -0,0 +1,178 @@
+package openwsdk_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk/openwsdktest"
+	"github.com/blocktree/openwallet/v2/owtp"
+)
+
+func testTransmitNode(t *testing.T) (*openwsdk.TransmitNode, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen unexpected error: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	transmit, err := openwsdk.NewTransmitNode(&openwsdk.APINodeConfig{
+		Host:        addr,
+		ConnectType: owtp.Websocket,
+		AppID:       openwsdktest.DefaultAppID,
+		Cert:        owtp.NewRandomCertificate(),
+		TimeoutSEC:  10,
+	})
+	if err != nil {
+		t.Fatalf("NewTransmitNode unexpected error: %v", err)
+	}
+	transmit.Listen()
+	return transmit, addr
+}
+
+func testConnectTrustNode(t *testing.T, trustNode *openwsdktest.TrustNode, addr string) {
+	var err error
+	for i := 0; i < 50; i++ {
+		if err = trustNode.Connect(addr); err == nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Connect unexpected error: %v", err)
+}
+
+func testWaitFor(t *testing.T, what string, cond func() bool) {
+	for i := 0; i < 250; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %s", what)
+}
+
+func TestTrustNodeRegistry(t *testing.T) {
+	transmit, addr := testTransmitNode(t)
+	defer transmit.Close()
+	registry := transmit.Registry()
+
+	key := testHDKey(t)
+	primary := openwsdktest.NewTrustNode("primary")
+	defer primary.Close()
+	primary.ImportKey(key, "12345678")
+	backup := openwsdktest.NewTrustNode("backup")
+	defer backup.Close()
+	backup.ImportKey(key, "12345678")
+	other := openwsdktest.NewTrustNode("other")
+	defer other.Close()
+
+	for _, n := range []*openwsdktest.TrustNode{primary, backup, other} {
+		testConnectTrustNode(t, n, addr)
+	}
+	testWaitFor(t, "wallet lists", func() bool {
+		return len(registry.NodesForWallet(key.KeyID)) == 2
+	})
+
+	nodes := registry.Nodes()
+	if len(nodes) != 3 || nodes[0].Info.NodeName != "primary" || !nodes[0].Online || nodes[0].Info.Version != "openwsdktest" {
+		t.Fatalf("unexpected nodes: %+v", nodes)
+	}
+	if got := registry.NodesForWallet(key.KeyID); got[0] != primary.NodeID() || got[1] != backup.NodeID() {
+		t.Errorf("unexpected nodes for wallet: %v", got)
+	}
+
+	send := func() (string, error) {
+		var nodeID string
+		err := transmit.SendTransactionFromWallet(key.KeyID, "A1", "12345678", "sid-1", "BTC", "", "1", "1xyz", "", "", "",
+			func(n string, status uint64, msg string, successTx []*openwsdk.Transaction, failedRawTxs []*openwsdk.FailedRawTransaction) {
+				if status != owtp.StatusSuccess || len(successTx) != 1 || successTx[0].Sid != "sid-1" {
+					t.Errorf("unexpected send result: %d, %s, %v", status, msg, successTx)
+				}
+				nodeID = n
+			})
+		return nodeID, err
+	}
+	if nodeID, err := send(); err != nil || nodeID != primary.NodeID() {
+		t.Fatalf("SendTransactionFromWallet expected primary node, got: %s, %v", nodeID, err)
+	}
+
+	//主节点断开后切换到备用节点
+	primary.Disconnect()
+	testWaitFor(t, "primary offline", func() bool {
+		status, _ := registry.Node(primary.NodeID())
+		return !status.Online
+	})
+	if nodeID, err := send(); err != nil || nodeID != backup.NodeID() {
+		t.Fatalf("SendTransactionFromWallet expected backup node, got: %s, %v", nodeID, err)
+	}
+	if n := primary.Calls("sendTransactionViaTrustNode"); n != 1 {
+		t.Errorf("primary expected 1 send, got: %d", n)
+	}
+	status, _ := registry.Node(primary.NodeID())
+	if len(status.History) != 2 || !status.History[0].Online || status.History[1].Online || len(status.Wallets) != 1 {
+		t.Errorf("unexpected primary status: %+v", status)
+	}
+
+	//不指定节点时按钱包选择
+	signer := &openwsdk.TrustNodeSigner{Transmit: transmit, Password: "12345678"}
+	ks := testKeySignature(key)
+	if err := signer.Sign(context.Background(), ks); err != nil {
+		t.Fatalf("Sign unexpected error: %v", err)
+	}
+	verifyKeySignature(t, key, ks)
+	if n := backup.Calls("signHashViaTrustNode"); n != 1 {
+		t.Errorf("backup expected 1 sign, got: %d", n)
+	}
+
+	err := transmit.SendTransactionFromWallet("W-unknown", "A1", "", "sid-2", "BTC", "", "1", "1xyz", "", "", "",
+		func(string, uint64, string, []*openwsdk.Transaction, []*openwsdk.FailedRawTransaction) {})
+	if !errors.Is(err, openwsdk.ErrNoTrustNode) {
+		t.Errorf("SendTransactionFromWallet expected ErrNoTrustNode, got: %v", err)
+	}
+}
+
+func TestTrustNodeRegistry_UnknownOutcome(t *testing.T) {
+	transmit, addr := testTransmitNode(t)
+	defer transmit.Close()
+	registry := transmit.Registry()
+
+	key := testHDKey(t)
+	primary := openwsdktest.NewTrustNode("primary")
+	defer primary.Close()
+	primary.ImportKey(key, "")
+	backup := openwsdktest.NewTrustNode("backup")
+	defer backup.Close()
+	backup.ImportKey(key, "")
+	for _, n := range []*openwsdktest.TrustNode{primary, backup} {
+		testConnectTrustNode(t, n, addr)
+	}
+	testWaitFor(t, "wallet lists", func() bool {
+		return len(registry.NodesForWallet(key.KeyID)) == 2
+	})
+
+	//节点执行转账后连接断开，不能在备用节点重新发起
+	primary.SetDropAfter("sendTransactionViaTrustNode")
+	called := false
+	err := transmit.SendTransactionFromWallet(key.KeyID, "A1", "", "sid-1", "BTC", "", "1", "1xyz", "", "", "",
+		func(string, uint64, string, []*openwsdk.Transaction, []*openwsdk.FailedRawTransaction) {
+			called = true
+		})
+	var unknown *openwsdk.UnknownOutcomeError
+	if !errors.Is(err, openwsdk.ErrUnknownOutcome) || !errors.As(err, &unknown) ||
+		unknown.Sid != "sid-1" || unknown.NodeID != primary.NodeID() {
+		t.Fatalf("SendTransactionFromWallet expected unknown outcome, got: %v", err)
+	}
+	if called {
+		t.Errorf("reqFunc expected not to be called")
+	}
+	if n := primary.Calls("sendTransactionViaTrustNode"); n != 1 {
+		t.Errorf("primary expected 1 send, got: %d", n)
+	}
+	if n := backup.Calls("sendTransactionViaTrustNode"); n != 0 {
+		t.Errorf("backup expected no send, got: %d", n)
+	}
+}
@@ -0,0 +1,182 @@
+package openwsdk
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/blocktree/go-owcdrivers/owkeychain"
+	"github.com/blocktree/go-owcrypt"
+)
+
+// PubKeyResolver 查找签名账户，账户的PublicKey和HdPath用于派生签名公钥
+type PubKeyResolver func(accountID string) (*Account, error)
+
+// AccountsResolver 从已知账户中查找
+func AccountsResolver(accounts ...*Account) PubKeyResolver {
+	m := make(map[string]*Account, len(accounts))
+	for _, a := range accounts {
+		m[a.AccountID] = a
+	}
+	return func(accountID string) (*Account, error) {
+		a, ok := m[accountID]
+		if !ok {
+			return nil, fmt.Errorf("account %s not found", accountID)
+		}
+		return a, nil
+	}
+}
+
+// PubKeyResolver 通过FindAccountByAccountID查找，同一账户只请求一次
+func (c *APIClient) PubKeyResolver(ctx context.Context, symbol string) PubKeyResolver {
+	cache := make(map[string]*Account)
+	return func(accountID string) (*Account, error) {
+		if a, ok := cache[accountID]; ok {
+			return a, nil
+		}
+		a, err := c.FindAccountByAccountID(ctx, symbol, accountID, 0)
+		if err != nil {
+			return nil, err
+		}
+		cache[accountID] = a
+		return a, nil
+	}
+}
+
+// SignatureError 签名校验失败
+type SignatureError struct {
+	AccountID   string
+	InputIndex  uint32
+	Address     string
+	DerivedPath string
+	Reason      string
+}
+
+// Error 实现error接口
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("signature of account %s input %d [%s] is invalid: %s", e.AccountID, e.InputIndex, e.DerivedPath, e.Reason)
+}
+
+// VerifyRawTransactionSignatures 校验交易单的所有签名，在SubmitTrade前发现错误的密钥
+func VerifyRawTransactionSignatures(rawTx *RawTransaction, resolver PubKeyResolver) error {
+	if rawTx == nil {
+		return fmt.Errorf("raw transaction is nil")
+	}
+	return verifySignatures(rawTx.Signatures, resolver)
+}
+
+// VerifySmartContractRawTransactionSignatures 校验智能合约交易单的所有签名
+func VerifySmartContractRawTransactionSignatures(rawTx *SmartContractRawTransaction, resolver PubKeyResolver) error {
+	if rawTx == nil {
+		return fmt.Errorf("raw transaction is nil")
+	}
+	return verifySignatures(rawTx.Signatures, resolver)
+}
+
+func verifySignatures(signatures map[string][]*KeySignature, resolver PubKeyResolver) error {
+	if len(signatures) == 0 {
+		return fmt.Errorf("raw transaction has no signatures")
+	}
+	for accountID, keySignatures := range signatures {
+		account, err := resolver(accountID)
+		if err != nil {
+			return err
+		}
+		for _, keySignature := range keySignatures {
+			if err := VerifyKeySignature(account, keySignature); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyKeySignature 按账户扩展公钥派生子公钥，校验KeySignature.Signature
+func VerifyKeySignature(account *Account, keySignature *KeySignature) error {
+	fail := func(reason string) error {
+		return &SignatureError{
+			AccountID:   account.AccountID,
+			InputIndex:  keySignature.InputIndex,
+			Address:     keySignature.Address,
+			DerivedPath: keySignature.DerivedPath,
+			Reason:      reason,
+		}
+	}
+	if len(keySignature.Signature) == 0 {
+		return fail("not signed")
+	}
+	pubkey, err := derivePublicKey(account, keySignature.DerivedPath)
+	if err != nil {
+		return fail(err.Error())
+	}
+	message, err := hex.DecodeString(keySignature.Message)
+	if err != nil {
+		return fail("invalid message")
+	}
+	signature, err := hex.DecodeString(keySignature.Signature)
+	if err != nil {
+		return fail("invalid signature encoding")
+	}
+
+	eccType := keySignature.EccType
+	switch eccType {
+	case owcrypt.ECC_CURVE_SECP256K1, owcrypt.ECC_CURVE_SECP256R1, owcrypt.ECC_CURVE_SM2_STANDARD:
+		//RSV模式签名后拼接了v
+		if keySignature.RSV && len(signature) == 65 {
+			signature = signature[:64]
+		}
+		point := owcrypt.PointDecompress(pubkey, eccType)
+		if len(point) != 65 {
+			return fail("invalid public key")
+		}
+		pubkey = point[1:]
+	case owcrypt.ECC_CURVE_BLS12381_G2_XMD_SHA_256_SSWU_RO_AUG:
+		pubkey = Calculate_synthetic_public_key(pubkey)
+		if pubkey == nil {
+			return fail("invalid public key")
+		}
+	}
+
+	if owcrypt.Verify(pubkey, nil, message, signature, eccType) != owcrypt.SUCCESS {
+		return fail("signature mismatch")
+	}
+	return nil
+}
+
+// derivePublicKey 从账户扩展公钥按DerivedPath相对HdPath的部分派生子公钥
+func derivePublicKey(account *Account, derivedPath string) ([]byte, error) {
+	if len(account.PublicKey) == 0 || len(account.HdPath) == 0 {
+		return nil, fmt.Errorf("account public key is empty")
+	}
+	hdPath := strings.TrimSuffix(account.HdPath, "/")
+	if !strings.HasPrefix(derivedPath, hdPath+"/") {
+		return nil, fmt.Errorf("derived path is not under account path %s", account.HdPath)
+	}
+	key, err := owkeychain.OWDecode(account.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	child, err := key.DerivedPublicKeyFromPath(strings.TrimPrefix(derivedPath, hdPath))
+	if err != nil {
+		return nil, err
+	}
+	return child.GetPublicKeyBytes(), nil
+}
+
+// Calculate_synthetic_public_key 计算Calculate_synthetic_secret_key对应的公钥
+func Calculate_synthetic_public_key(pubkey []byte) []byte {
+	default_hidden_puzzle_hash, _ := hex.DecodeString(DEFAULT_HIDDEN_PUZZLE_HASH)
+	synthetic_offset := calculate_synthetic_offset(pubkey, default_hidden_puzzle_hash)
+	if synthetic_offset.Sign() == 0 {
+		return pubkey
+	}
+	offset := make([]byte, 32)
+	offset_bytes := synthetic_offset.Bytes()
+	copy(offset[32-len(offset_bytes):], offset_bytes)
+	point, isinfinity := owcrypt.Point_mulBaseG_add(pubkey, offset, owcrypt.ECC_CURVE_BLS12381_G2_XMD_SHA_256_SSWU_RO_AUG)
+	if isinfinity {
+		return nil
+	}
+	return point
+}
@@ -0,0 +1,68 @@
+package openwsdk_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/blocktree/go-openw-sdk/v2/openwsdk"
+	"github.com/blocktree/go-owcrypt"
+)
+
+func TestVerifyRawTransactionSignatures(t *testing.T) {
+	key := testHDKey(t)
+	message := hex.EncodeToString(owcrypt.Hash([]byte("message"), 0, owcrypt.HASH_ALG_SHA256))
+
+	curves := []struct {
+		name    string
+		eccType uint32
+		rsv     bool
+	}{
+		{"secp256k1", owcrypt.ECC_CURVE_SECP256K1, true},
+		{"secp256k1", owcrypt.ECC_CURVE_SECP256K1, false},
+		{"ed25519", owcrypt.ECC_CURVE_ED25519, false},
+		{"bls", owcrypt.ECC_CURVE_BLS12381_G2_XMD_SHA_256_SSWU_RO_AUG, false},
+	}
+	for _, curve := range curves {
+		accountKey, err := key.DerivedKeyWithPath("m/44'/88'/0'", curve.eccType)
+		if err != nil {
+			t.Fatalf("%s: DerivedKeyWithPath unexpected error: %v", curve.name, err)
+		}
+		account := &openwsdk.Account{
+			AccountID: "A1",
+			PublicKey: accountKey.GetPublicKey().OWEncode(),
+			HdPath:    "m/44'/88'/0'",
+		}
+		rawTx := &openwsdk.RawTransaction{
+			Signatures: map[string][]*openwsdk.KeySignature{
+				"A1": {{EccType: curve.eccType, RSV: curve.rsv, Message: message, DerivedPath: "m/44'/88'/0'/0/1"}},
+			},
+		}
+		resolver := openwsdk.AccountsResolver(account)
+
+		err = openwsdk.VerifyRawTransactionSignatures(rawTx, resolver)
+		if _, ok := err.(*openwsdk.SignatureError); !ok {
+			t.Errorf("%s: expected SignatureError before signing, got: %v", curve.name, err)
+		}
+		if err := openwsdk.SignRawTransactionWithSigner(context.Background(), rawTx, openwsdk.NewHDKeySigner(key)); err != nil {
+			t.Fatalf("%s: sign unexpected error: %v", curve.name, err)
+		}
+		if err := openwsdk.VerifyRawTransactionSignatures(rawTx, resolver); err != nil {
+			t.Errorf("%s: VerifyRawTransactionSignatures unexpected error: %v", curve.name, err)
+		}
+
+		//签名路径与账户不一致
+		ks := rawTx.Signatures["A1"][0]
+		ks.DerivedPath = "m/44'/88'/0'/0/2"
+		if err := openwsdk.VerifyKeySignature(account, ks); err == nil {
+			t.Errorf("%s: VerifyKeySignature expected error with wrong derived path", curve.name)
+		}
+	}
+
+	contractTx := &openwsdk.SmartContractRawTransaction{
+		Signatures: map[string][]*openwsdk.KeySignature{"A2": {{Signature: "00"}}},
+	}
+	if err := openwsdk.VerifySmartContractRawTransactionSignatures(contractTx, openwsdk.AccountsResolver()); err == nil {
+		t.Errorf("VerifySmartContractRawTransactionSignatures expected error with unknown account")
+	}
+}